@@ -16,7 +16,7 @@ func main() {
 	app := cmds.NewApp()
 	app.DisableSliceFlagSeparator = true
 	app.Commands = []*cli.Command{
-		cmds.NewAgentCommand(agent.Run),
+		cmds.NewAgentCommand(agent.Run, agent.LBStatus, agent.LBDrain, agent.LBUndrain, agent.LBFailover),
 	}
 
 	if err := app.Run(configfilearg.MustParse(os.Args)); err != nil && !errors.Is(err, context.Canceled) {
@@ -23,6 +23,7 @@ func main() {
 			secretsencrypt.Rotate,
 			secretsencrypt.Reencrypt,
 			secretsencrypt.RotateKeys,
+			secretsencrypt.Migrate,
 		),
 	}
 
@@ -20,6 +20,9 @@ func main() {
 			etcdsnapshot.List,
 			etcdsnapshot.Prune,
 			etcdsnapshot.Save,
+			etcdsnapshot.Verify,
+			etcdsnapshot.Extract,
+			etcdsnapshot.Diff,
 		),
 	}
 
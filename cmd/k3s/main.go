@@ -48,6 +48,8 @@ func main() {
 	etcdsnapshotCommand := internalCLIAction(version.Program+"-"+cmds.EtcdSnapshotCommand, dataDir, os.Args)
 	secretsencryptCommand := internalCLIAction(version.Program+"-"+cmds.SecretsEncryptCommand, dataDir, os.Args)
 	certCommand := internalCLIAction(version.Program+"-"+cmds.CertCommand, dataDir, os.Args)
+	configCommand := internalCLIAction(version.Program+"-"+cmds.ConfigCommand, dataDir, os.Args)
+	agentCommand := internalCLIAction(version.Program+"-agent"+programPostfix, dataDir, os.Args)
 
 	// Handle subcommand invocation (k3s server, k3s crictl, etc)
 	app := cmds.NewApp()
@@ -55,7 +57,13 @@ func main() {
 	app.DisableSliceFlagSeparator = true
 	app.Commands = []*cli.Command{
 		cmds.NewServerCommand(internalCLIAction(version.Program+"-server"+programPostfix, dataDir, os.Args)),
-		cmds.NewAgentCommand(internalCLIAction(version.Program+"-agent"+programPostfix, dataDir, os.Args)),
+		cmds.NewAgentCommand(
+			agentCommand,
+			agentCommand,
+			agentCommand,
+			agentCommand,
+			agentCommand,
+		),
 		cmds.NewKubectlCommand(externalCLIAction("kubectl", dataDir)),
 		cmds.NewCRICTL(externalCLIAction("crictl", dataDir)),
 		cmds.NewCtrCommand(externalCLIAction("ctr", dataDir)),
@@ -72,6 +80,9 @@ func main() {
 			etcdsnapshotCommand,
 			etcdsnapshotCommand,
 			etcdsnapshotCommand,
+			etcdsnapshotCommand,
+			etcdsnapshotCommand,
+			etcdsnapshotCommand,
 		),
 		cmds.NewSecretsEncryptCommands(
 			secretsencryptCommand,
@@ -81,11 +92,16 @@ func main() {
 			secretsencryptCommand,
 			secretsencryptCommand,
 			secretsencryptCommand,
+			secretsencryptCommand,
 		),
 		cmds.NewCertCommands(
 			certCommand,
 			certCommand,
 			certCommand,
+			certCommand,
+		),
+		cmds.NewConfigCommands(
+			configCommand,
 		),
 		cmds.NewCompletionCommand(
 			internalCLIAction(version.Program+"-completion", dataDir, os.Args),
@@ -11,6 +11,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/cli/cert"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/cli/completion"
+	"github.com/k3s-io/k3s/pkg/cli/config"
 	"github.com/k3s-io/k3s/pkg/cli/crictl"
 	"github.com/k3s-io/k3s/pkg/cli/ctr"
 	"github.com/k3s-io/k3s/pkg/cli/etcdsnapshot"
@@ -46,7 +47,7 @@ func main() {
 	app.DisableSliceFlagSeparator = true
 	app.Commands = []*cli.Command{
 		cmds.NewServerCommand(server.Run),
-		cmds.NewAgentCommand(agent.Run),
+		cmds.NewAgentCommand(agent.Run, agent.LBStatus, agent.LBDrain, agent.LBUndrain, agent.LBFailover),
 		cmds.NewKubectlCommand(kubectl.Run),
 		cmds.NewCRICTL(crictl.Run),
 		cmds.NewCtrCommand(ctr.Run),
@@ -62,6 +63,9 @@ func main() {
 			etcdsnapshot.List,
 			etcdsnapshot.Prune,
 			etcdsnapshot.Save,
+			etcdsnapshot.Verify,
+			etcdsnapshot.Extract,
+			etcdsnapshot.Diff,
 		),
 		cmds.NewSecretsEncryptCommands(
 			secretsencrypt.Status,
@@ -71,11 +75,16 @@ func main() {
 			secretsencrypt.Rotate,
 			secretsencrypt.Reencrypt,
 			secretsencrypt.RotateKeys,
+			secretsencrypt.Migrate,
 		),
 		cmds.NewCertCommands(
 			cert.Check,
 			cert.Rotate,
 			cert.RotateCA,
+			cert.GenerateCACSR,
+		),
+		cmds.NewConfigCommands(
+			config.Check,
 		),
 		cmds.NewCompletionCommand(
 			completion.Bash,
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
@@ -19,6 +20,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -29,13 +31,13 @@ import (
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
 	"github.com/k3s-io/k3s/pkg/spegel"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/k3s-io/k3s/pkg/vpn"
 	pkgerrors "github.com/pkg/errors"
 	certutil "github.com/rancher/dynamiclistener/cert"
-	"github.com/rancher/wharfie/pkg/registries"
 	"github.com/rancher/wrangler/v3/pkg/slice"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/json"
@@ -105,7 +107,7 @@ func WaitForAPIServers(ctx context.Context, node *config.Node, proxy proxy.Proxy
 	_ = wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
 		if info == nil {
 			withCert := clientaccess.WithClientCertificate(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey)
-			info, err = clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert)
+			info, err = clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert, clientaccess.WithNodeName(node.AgentConfig.NodeName))
 			if err != nil {
 				logrus.Warnf("Failed to validate server token: %v", err)
 				return false, nil
@@ -125,6 +127,69 @@ func WaitForAPIServers(ctx context.Context, node *config.Node, proxy proxy.Proxy
 	return addresses
 }
 
+// RenewCerts asks the server to sign new certificates for any agent certificates that are within
+// CertificateRenewDays of expiring, and returns the paths of the certificates that were renewed.
+// The existing private keys are reused. The kubelet, kube-proxy and agent controllers load their
+// certificates from disk as they are used, so the renewed certificates are picked up without a restart.
+func RenewCerts(node *config.Node, agent cmds.Agent, proxy proxy.Proxy) ([]string, error) {
+	withCert := clientaccess.WithClientCertificate(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey)
+	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert, clientaccess.WithNodeName(node.AgentConfig.NodeName))
+	if err != nil {
+		return nil, err
+	}
+
+	nodePasswordRoot := "/"
+	if agent.Rootless {
+		nodePasswordRoot = filepath.Join(agent.DataDir, "agent")
+	}
+	nodePasswordFile := filepath.Join(nodePasswordRoot, "etc", "rancher", "node", "password")
+	nodeName := node.AgentConfig.NodeName
+	nodeIPs := node.AgentConfig.NodeIPs
+	nodeExternalAndInternalIPs := append(slices.Clone(nodeIPs), node.AgentConfig.NodeExternalIPs...)
+
+	clientKubeProxyCert := filepath.Join(agent.DataDir, "agent", "client-kube-proxy.crt")
+	clientKubeProxyKey := filepath.Join(agent.DataDir, "agent", "client-kube-proxy.key")
+	clientK3sControllerCert := filepath.Join(agent.DataDir, "agent", "client-"+version.Program+"-controller.crt")
+	clientK3sControllerKey := filepath.Join(agent.DataDir, "agent", "client-"+version.Program+"-controller.key")
+
+	certRequests := map[string]func() error{
+		node.AgentConfig.ServingKubeletCert: func() error {
+			return getKubeletServingCert(nodeName, nodeExternalAndInternalIPs, node.AgentConfig.ServingKubeletCert, node.AgentConfig.ServingKubeletKey, nodePasswordFile, info)
+		},
+		node.AgentConfig.ClientKubeletCert: func() error {
+			return getKubeletClientCert(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey, nodeName, nodeIPs, nodePasswordFile, info)
+		},
+		clientKubeProxyCert: func() error {
+			return getClientCert(clientKubeProxyCert, clientKubeProxyKey, info)
+		},
+		clientK3sControllerCert: func() error {
+			return getClientCert(clientK3sControllerCert, clientK3sControllerKey, info)
+		},
+	}
+
+	renewed := []string{}
+	for _, certFile := range slices.Sorted(maps.Keys(certRequests)) {
+		if !renewalDue(certFile, time.Now()) {
+			continue
+		}
+		if err := certRequests[certFile](); err != nil {
+			return renewed, pkgerrors.WithMessage(err, certFile)
+		}
+		renewed = append(renewed, certFile)
+	}
+	return renewed, nil
+}
+
+// renewalDue returns true if the certificate file cannot be read, or if the certificate
+// expires within CertificateRenewDays of the given time.
+func renewalDue(certFile string, now time.Time) bool {
+	certs, err := certutil.CertsFromFile(certFile)
+	if err != nil || len(certs) == 0 {
+		return true
+	}
+	return now.Add(time.Hour * 24 * config.CertificateRenewDays).After(certs[0].NotAfter)
+}
+
 type HTTPRequester func(u string, client *http.Client, username, password, token string) ([]byte, error)
 
 func Request(path string, info *clientaccess.Info, requester HTTPRequester) ([]byte, error) {
@@ -430,8 +495,38 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	}
 	clientKubeletCert := filepath.Join(envInfo.DataDir, "agent", "client-kubelet.crt")
 	clientKubeletKey := filepath.Join(envInfo.DataDir, "agent", "client-kubelet.key")
+
+	nodePasswordRoot := "/"
+	if envInfo.Rootless {
+		nodePasswordRoot = filepath.Join(envInfo.DataDir, "agent")
+	}
+	nodeConfigPath := filepath.Join(nodePasswordRoot, "etc", "rancher", "node")
+	if err := os.MkdirAll(nodeConfigPath, 0755); err != nil {
+		return nil, err
+	}
+
+	oldNodePasswordFile := filepath.Join(envInfo.DataDir, "agent", "node-password.txt")
+	newNodePasswordFile := filepath.Join(nodeConfigPath, "password")
+	upgradeOldNodePasswordPath(oldNodePasswordFile, newNodePasswordFile)
+
+	nodeName, nodeIPs, err := util.GetHostnameAndIPs(envInfo.NodeName, envInfo.NodeIP.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	if envInfo.WithNodeID {
+		nodeID, err := ensureNodeID(filepath.Join(nodeConfigPath, "id"))
+		if err != nil {
+			return nil, err
+		}
+		nodeName += "-" + nodeID
+	}
+
+	os.Setenv("NODE_NAME", nodeName)
+
+	// The node name is sent with all requests, so that the server can enforce any join restrictions set on the token.
 	withCert := clientaccess.WithClientCertificate(clientKubeletCert, clientKubeletKey)
-	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), envInfo.Token, withCert)
+	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), envInfo.Token, withCert, clientaccess.WithNodeName(nodeName))
 	if err != nil {
 		return nil, err
 	}
@@ -470,24 +565,6 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	servingKubeletCert := filepath.Join(envInfo.DataDir, "agent", "serving-kubelet.crt")
 	servingKubeletKey := filepath.Join(envInfo.DataDir, "agent", "serving-kubelet.key")
 
-	nodePasswordRoot := "/"
-	if envInfo.Rootless {
-		nodePasswordRoot = filepath.Join(envInfo.DataDir, "agent")
-	}
-	nodeConfigPath := filepath.Join(nodePasswordRoot, "etc", "rancher", "node")
-	if err := os.MkdirAll(nodeConfigPath, 0755); err != nil {
-		return nil, err
-	}
-
-	oldNodePasswordFile := filepath.Join(envInfo.DataDir, "agent", "node-password.txt")
-	newNodePasswordFile := filepath.Join(nodeConfigPath, "password")
-	upgradeOldNodePasswordPath(oldNodePasswordFile, newNodePasswordFile)
-
-	nodeName, nodeIPs, err := util.GetHostnameAndIPs(envInfo.NodeName, envInfo.NodeIP.Value())
-	if err != nil {
-		return nil, err
-	}
-
 	// If there is a VPN, we must overwrite NodeIP and flannel interface
 	var vpnInfo vpn.VPNInfo
 	if envInfo.VPNAuth != "" {
@@ -542,16 +619,6 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 		return nil, fmt.Errorf("invalid node-external-ip: %w", err)
 	}
 
-	if envInfo.WithNodeID {
-		nodeID, err := ensureNodeID(filepath.Join(nodeConfigPath, "id"))
-		if err != nil {
-			return nil, err
-		}
-		nodeName += "-" + nodeID
-	}
-
-	os.Setenv("NODE_NAME", nodeName)
-
 	// Ensure that the kubelet's server certificate is valid for all configured node IPs.  Note
 	// that in the case of an external CCM, additional IPs may be added by the infra provider
 	// that the cert will not be valid for, as they are not present in the list collected here.
@@ -794,11 +861,25 @@ func get(ctx context.Context, envInfo *cmds.Agent, proxy proxy.Proxy) (*config.N
 	nodeConfig.AgentConfig.LogFile = cmds.LogConfig.LogFile
 	nodeConfig.AgentConfig.AlsoLogToStderr = cmds.LogConfig.AlsoLogToStderr
 
-	privRegistries, err := registries.GetPrivateRegistries(envInfo.PrivateRegistry)
+	privRegistry, err := registryconfig.ReadFile(envInfo.PrivateRegistry)
 	if err != nil {
 		return nil, err
 	}
-	nodeConfig.AgentConfig.Registry = privRegistries.Registry
+	if _, err := os.Stat(envInfo.PrivateRegistry); err == nil {
+		logrus.Infof("Using private registry config file at %s", envInfo.PrivateRegistry)
+	}
+	nodeConfig.AgentConfig.Registry.Store(privRegistry)
+	nodeConfig.AgentConfig.PrivateRegistry = envInfo.PrivateRegistry
+
+	// Merge the cluster registry configuration with the local registries.yaml. This is not fatal, as the server
+	// may not be able to provide the cluster registry configuration until the apiserver is up. The registry
+	// watcher will periodically retry once the agent is running, and will pick up any overrides for this node
+	// once the node has registered.
+	if clusterRegistry, err := getClusterRegistries(info); err != nil {
+		logrus.Warnf("Failed to retrieve cluster registry configuration from server: %v", err)
+	} else {
+		nodeConfig.AgentConfig.Registry.Store(registryconfig.Merge(clusterRegistry, privRegistry))
+	}
 
 	if nodeConfig.EmbeddedRegistry {
 		psk, err := hex.DecodeString(controlConfig.IPSECPSK)
@@ -845,7 +926,7 @@ func GetAPIServers(ctx context.Context, info *clientaccess.Info) ([]string, erro
 // It first checks the server readyz endpoint, to ensure that the configuration has stabilized before use.
 func getKubeProxyDisabled(ctx context.Context, node *config.Node, proxy proxy.Proxy) (bool, error) {
 	withCert := clientaccess.WithClientCertificate(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey)
-	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert)
+	info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert, clientaccess.WithNodeName(node.AgentConfig.NodeName))
 	if err != nil {
 		return false, err
 	}
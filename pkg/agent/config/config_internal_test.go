@@ -1,8 +1,19 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
 )
 
 func Test_isValidResolvConf(t *testing.T) {
@@ -38,3 +49,47 @@ func Test_isValidResolvConf(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitRenewalDue(t *testing.T) {
+	now := time.Now()
+	renewPeriod := time.Hour * 24 * config.CertificateRenewDays
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		missing  bool
+		want     bool
+	}{
+		{name: "Missing certificate", missing: true, want: true},
+		{name: "Valid certificate", notAfter: now.Add(renewPeriod + time.Hour), want: false},
+		{name: "Expiring certificate", notAfter: now.Add(renewPeriod - time.Hour), want: true},
+		{name: "Expired certificate", notAfter: now.Add(-time.Hour), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certFile := filepath.Join(t.TempDir(), "client.crt")
+			if !tt.missing {
+				key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				template := &x509.Certificate{
+					SerialNumber: big.NewInt(1),
+					Subject:      pkix.Name{CommonName: "system:kube-proxy"},
+					NotBefore:    now.Add(-time.Hour),
+					NotAfter:     tt.notAfter,
+				}
+				certBytes, err := x509.CreateCertificate(cryptorand.Reader, template, template, key.Public(), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := renewalDue(certFile, now); got != tt.want {
+				t.Errorf("renewalDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
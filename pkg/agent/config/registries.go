@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/k3s-io/k3s/pkg/agent/containerd"
+	"github.com/k3s-io/k3s/pkg/agent/proxy"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
+	"github.com/k3s-io/k3s/pkg/version"
+	pkgerrors "github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// LoadRegistries returns a function that loads the registry configuration for the node, by merging the
+// cluster registry configuration retrieved from the server with the local private registry config file.
+// Overrides in the cluster registry configuration are matched against the labels of the node object by
+// the server, so changes to node labels are picked up the next time the configuration is loaded.
+func LoadRegistries(node *config.Node, proxy proxy.Proxy) containerd.LoadRegistriesFunc {
+	return func(ctx context.Context) (*registryconfig.Registry, error) {
+		registry, err := registryconfig.ReadFile(node.AgentConfig.PrivateRegistry)
+		if err != nil {
+			return nil, err
+		}
+
+		withCert := clientaccess.WithClientCertificate(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey)
+		info, err := clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert, clientaccess.WithNodeName(node.AgentConfig.NodeName))
+		if err != nil {
+			return nil, err
+		}
+
+		clusterRegistry, err := getClusterRegistries(info)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to retrieve cluster registry configuration from server")
+		}
+		return registryconfig.Merge(clusterRegistry, registry), nil
+	}
+}
+
+// getClusterRegistries retrieves the cluster registry configuration for this node from the server. If the
+// server does not support cluster registry configuration, an empty configuration is returned.
+func getClusterRegistries(info *clientaccess.Info) (*registryconfig.Registry, error) {
+	data, err := info.Get("/v1-" + version.Program + "/registries")
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return &registryconfig.Registry{}, nil
+		}
+		return nil, err
+	}
+
+	registry := &registryconfig.Registry{}
+	return registry, json.Unmarshal(data, registry)
+}
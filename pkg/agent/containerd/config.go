@@ -2,6 +2,8 @@ package containerd
 
 import (
 	"bufio"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
@@ -13,6 +15,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/agent/templates"
 	util2 "github.com/k3s-io/k3s/pkg/agent/util"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
 	"github.com/k3s-io/k3s/pkg/spegel"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/rancher/wharfie/pkg/registries"
@@ -142,7 +145,7 @@ func cleanContainerdHosts(dir string, hosts HostConfigs) error {
 }
 
 // getHostConfigs merges the registry mirrors/configs into HostConfig template structs
-func getHostConfigs(registry *registries.Registry, noDefaultEndpoint bool, mirrorAddr string) HostConfigs {
+func getHostConfigs(registry *registryconfig.Registry, noDefaultEndpoint bool, mirrorAddr string) HostConfigs {
 	hosts := map[string]templates.HostConfig{}
 
 	// create config for default endpoints
@@ -178,29 +181,42 @@ func getHostConfigs(registry *registries.Registry, noDefaultEndpoint bool, mirro
 		// track which endpoints we've already seen to avoid creating duplicates
 		seenEndpoint := map[string]bool{}
 
-		// TODO: rewrites are currently copied from the mirror settings into each endpoint.
-		// In the future, we should allow for per-endpoint rewrites, instead of expecting
-		// all mirrors to have the same structure. This will require changes to the registries.yaml
-		// structure, which is defined in rancher/wharfie.
+		// Rewrites are copied from the mirror settings into each endpoint, unless the endpoint has
+		// its own rewrites. Endpoint TLS settings replace those from the config for the endpoint's
+		// host, and endpoint credentials are sent as a static Authorization header in place of the
+		// credentials from the config for the endpoint's host.
 		for i, endpoint := range mirror.Endpoints {
-			registryName, url, override, err := normalizeEndpointAddress(endpoint, mirrorAddr)
+			registryName, url, override, err := normalizeEndpointAddress(endpoint.URL, mirrorAddr)
 			if err != nil {
-				logrus.Warnf("Ignoring invalid endpoint URL %d=%s for %s: %v", i, endpoint, host, err)
+				logrus.Warnf("Ignoring invalid endpoint URL %d=%s for %s: %v", i, endpoint.URL, host, err)
 			} else if _, ok := seenEndpoint[url.String()]; ok {
-				logrus.Warnf("Skipping duplicate endpoint URL %d=%s for %s", i, endpoint, host)
+				logrus.Warnf("Skipping duplicate endpoint URL %d=%s for %s", i, endpoint.URL, host)
 			} else {
 				seenEndpoint[url.String()] = true
-				var rewrites map[string]string
-				// Do not apply rewrites to the embedded registry endpoint
-				if url.Host != mirrorAddr {
-					rewrites = mirror.Rewrites
-				}
 				ep := templates.RegistryEndpoint{
 					Config:       configForHost(registry.Configs, registryName),
-					Rewrites:     rewrites,
 					OverridePath: override,
 					URL:          url,
 				}
+				// Do not apply rewrites to the embedded registry endpoint
+				if url.Host != mirrorAddr {
+					ep.Rewrites = mirror.Rewrites
+					if endpoint.Rewrites != nil {
+						ep.Rewrites = endpoint.Rewrites
+					}
+				}
+				if endpoint.TLS != nil {
+					ep.Config.TLS = endpoint.TLS
+				}
+				if endpoint.Auth != nil {
+					if header, err := authorizationHeader(endpoint.Auth); err != nil {
+						logrus.Warnf("Ignoring credentials for endpoint URL %d=%s for %s: %v", i, endpoint.URL, host, err)
+					} else {
+						ep.Headers = map[string]string{"Authorization": header}
+					}
+				} else {
+					ep.Headers = configHeaders(registryName, ep.Config)
+				}
 				if i+1 == len(mirror.Endpoints) && endpointURLEqual(config.Default, &ep) {
 					// if the last endpoint is the default endpoint, move it there
 					config.Default = &ep
@@ -280,12 +296,29 @@ func defaultHostConfig(host, mirrorAddr string, config registries.RegistryConfig
 	return &templates.HostConfig{
 		Program: version.Program,
 		Default: &templates.RegistryEndpoint{
-			URL:    url,
-			Config: config,
+			URL:     url,
+			Config:  config,
+			Headers: configHeaders(host, config),
 		},
 	}, nil
 }
 
+// configHeaders returns a static Authorization header containing the credentials from the registry config.
+// Containerd only reads credentials from its config file at startup, but reads hosts.toml files when pulling
+// images, so sending the credentials as a header allows changes to take effect without a restart. Credentials
+// for the wildcard registry are not sent as a header, as they would be sent to every registry.
+func configHeaders(host string, config registries.RegistryConfig) map[string]string {
+	if host == "*" || config.Auth == nil {
+		return nil
+	}
+	header, err := authorizationHeader(config.Auth)
+	if err != nil {
+		logrus.Debugf("Not sending credentials for registry %s as a header: %v", host, err)
+		return nil
+	}
+	return map[string]string{"Authorization": header}
+}
+
 func configForHost(configs map[string]registries.RegistryConfig, host string) registries.RegistryConfig {
 	// check for config under modified hostname. If the hostname is unmodified, or there is no config for
 	// the modified hostname, return the config for the default hostname.
@@ -297,6 +330,19 @@ func configForHost(configs map[string]registries.RegistryConfig, host string) re
 	return configs[host]
 }
 
+// authorizationHeader returns the value of a static Authorization header containing the provided credentials.
+func authorizationHeader(auth *registries.AuthConfig) (string, error) {
+	switch {
+	case auth.Auth != "":
+		return "Basic " + auth.Auth, nil
+	case auth.Username != "" || auth.Password != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)), nil
+	case auth.IdentityToken != "":
+		return "", errors.New("identity tokens cannot be sent as a static header")
+	}
+	return "", errors.New("no credentials provided")
+}
+
 // endpointURLEqual compares endpoint URL strings
 func endpointURLEqual(a, b *templates.RegistryEndpoint) bool {
 	var au, bu string
@@ -311,7 +357,7 @@ func endpointURLEqual(a, b *templates.RegistryEndpoint) bool {
 
 func endpointHasConfig(ep *templates.RegistryEndpoint) bool {
 	if ep != nil {
-		return ep.OverridePath || ep.Config.Auth != nil || ep.Config.TLS != nil || len(ep.Rewrites) > 0
+		return ep.OverridePath || ep.Config.Auth != nil || ep.Config.TLS != nil || len(ep.Rewrites) > 0 || len(ep.Headers) > 0
 	}
 	return false
 }
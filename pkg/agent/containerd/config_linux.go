@@ -56,6 +56,32 @@ func getContainerdArgs(cfg *config.Node) []string {
 // SetupContainerdConfig generates the containerd.toml, using a template combined with various
 // runtime configurations and registry mirror settings provided by the administrator.
 func SetupContainerdConfig(cfg *config.Node) error {
+	containerdConfig, err := newContainerdConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	selEnabled, selConfigured, err := selinuxStatus()
+	if err != nil {
+		return pkgerrors.WithMessage(err, "failed to detect selinux")
+	}
+	switch {
+	case !cfg.SELinux && selEnabled:
+		logrus.Warn("SELinux is enabled on this host, but " + version.Program + " has not been started with --selinux - containerd SELinux support is disabled")
+	case cfg.SELinux && !selConfigured:
+		logrus.Warnf("SELinux is enabled for "+version.Program+" but process is not running in context '%s', "+version.Program+"-selinux policy may need to be applied", SELinuxContextType)
+	}
+
+	if err := writeContainerdConfig(cfg, *containerdConfig); err != nil {
+		return err
+	}
+
+	return writeContainerdHosts(cfg, *containerdConfig)
+}
+
+// newContainerdConfig returns the containerd config template data for the node, detecting
+// cgroup support and available runtimes.
+func newContainerdConfig(cfg *config.Node) (*templates.ContainerdConfig, error) {
 	isRunningInUserNS := userns.RunningInUserNS()
 	_, _, controllers := cgroups.CheckCgroups()
 	// "/sys/fs/cgroup" is namespaced
@@ -79,38 +105,21 @@ func SetupContainerdConfig(cfg *config.Node) error {
 
 	// Verifies if the DefaultRuntime can be found
 	if _, ok := extraRuntimes[cfg.DefaultRuntime]; !ok && cfg.DefaultRuntime != "" {
-		return fmt.Errorf("default runtime %s was not found", cfg.DefaultRuntime)
+		return nil, fmt.Errorf("default runtime %s was not found", cfg.DefaultRuntime)
 	}
 
-	containerdConfig := templates.ContainerdConfig{
+	return &templates.ContainerdConfig{
 		NodeConfig:            cfg,
 		DisableCgroup:         disableCgroup,
 		SystemdCgroup:         cfg.AgentConfig.Systemd,
 		IsRunningInUserNS:     isRunningInUserNS,
 		EnableUnprivileged:    kernel.CheckKernelVersion(4, 11, 0),
 		NonrootDevices:        cfg.Containerd.NonrootDevices,
-		PrivateRegistryConfig: cfg.AgentConfig.Registry,
+		PrivateRegistryConfig: cfg.AgentConfig.Registry.Load(),
 		ExtraRuntimes:         extraRuntimes,
 		Program:               version.Program,
 		NoDefaultEndpoint:     cfg.Containerd.NoDefault,
-	}
-
-	selEnabled, selConfigured, err := selinuxStatus()
-	if err != nil {
-		return pkgerrors.WithMessage(err, "failed to detect selinux")
-	}
-	switch {
-	case !cfg.SELinux && selEnabled:
-		logrus.Warn("SELinux is enabled on this host, but " + version.Program + " has not been started with --selinux - containerd SELinux support is disabled")
-	case cfg.SELinux && !selConfigured:
-		logrus.Warnf("SELinux is enabled for "+version.Program+" but process is not running in context '%s', "+version.Program+"-selinux policy may need to be applied", SELinuxContextType)
-	}
-
-	if err := writeContainerdConfig(cfg, containerdConfig); err != nil {
-		return err
-	}
-
-	return writeContainerdHosts(cfg, containerdConfig)
+	}, nil
 }
 
 func Client(address string) (*containerd.Client, error) {
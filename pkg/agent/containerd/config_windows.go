@@ -42,20 +42,28 @@ func SetupContainerdConfig(cfg *config.Node) error {
 		logrus.Warn("SELinux isn't supported on windows")
 	}
 
-	cfg.DefaultRuntime = "runhcs-wcow-process"
-	cfg.AgentConfig.Snapshotter = "windows"
-	containerdConfig := templates.ContainerdConfig{
-		NodeConfig:            cfg,
-		DisableCgroup:         true,
-		PrivateRegistryConfig: cfg.AgentConfig.Registry,
-		NoDefaultEndpoint:     cfg.Containerd.NoDefault,
+	containerdConfig, err := newContainerdConfig(cfg)
+	if err != nil {
+		return err
 	}
 
-	if err := writeContainerdConfig(cfg, containerdConfig); err != nil {
+	if err := writeContainerdConfig(cfg, *containerdConfig); err != nil {
 		return err
 	}
 
-	return writeContainerdHosts(cfg, containerdConfig)
+	return writeContainerdHosts(cfg, *containerdConfig)
+}
+
+// newContainerdConfig returns the containerd config template data for the node.
+func newContainerdConfig(cfg *config.Node) (*templates.ContainerdConfig, error) {
+	cfg.DefaultRuntime = "runhcs-wcow-process"
+	cfg.AgentConfig.Snapshotter = "windows"
+	return &templates.ContainerdConfig{
+		NodeConfig:            cfg,
+		DisableCgroup:         true,
+		PrivateRegistryConfig: cfg.AgentConfig.Registry.Load(),
+		NoDefaultEndpoint:     cfg.Containerd.NoDefault,
+	}, nil
 }
 
 func Client(address string) (*containerd.Client, error) {
@@ -0,0 +1,294 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/k3s-io/k3s/pkg/agent/templates"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
+	"github.com/k3s-io/k3s/pkg/spegel"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/version"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rancher/wharfie/pkg/registries"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var (
+	// registriesReloadDelay is the time to wait after the last change to the registries file
+	// before reloading it, so that multiple writes by an editor are handled as a single change.
+	registriesReloadDelay = time.Second * 2
+
+	// registriesRefreshInterval is the interval at which the registry configuration is reloaded,
+	// in order to pick up changes to the cluster registry configuration provided by the server.
+	registriesRefreshInterval = time.Minute * 5
+
+	registriesControllerName = version.Program + "-registries"
+)
+
+// LoadRegistriesFunc returns the current registry configuration for the node.
+type LoadRegistriesFunc func(ctx context.Context) (*registryconfig.Registry, error)
+
+// registryChanges summarizes the differences between two registry configurations.
+type registryChanges struct {
+	changes     []string
+	credentials bool
+	restart     bool
+}
+
+// WatchRegistries watches the private registry configuration file for changes, and periodically reloads
+// the registry configuration in order to pick up changes to the cluster registry configuration. When the
+// configuration changes, the containerd registry hosts directories are regenerated, and an event summarizing
+// the changes is recorded on the node. Changes to mirrors, rewrites, TLS settings, and credentials take effect
+// immediately, as containerd reads hosts.toml files when pulling images, and credentials are sent as a static
+// Authorization header. Identity tokens cannot be sent as a header, and are only written to the containerd
+// config, which containerd reads at startup; a warning event is recorded when a restart is required.
+func WatchRegistries(ctx context.Context, cfg *config.Node, load LoadRegistriesFunc) error {
+	client, err := util.GetClientSet(cfg.AgentConfig.KubeConfigKubelet)
+	if err != nil {
+		return err
+	}
+	recorder := util.BuildControllerEventRecorder(client, registriesControllerName, metav1.NamespaceDefault)
+	nodeRef := &corev1.ObjectReference{
+		Kind:      "Node",
+		Name:      cfg.AgentConfig.NodeName,
+		UID:       types.UID(cfg.AgentConfig.NodeName),
+		Namespace: "",
+	}
+
+	// watch the directory containing the registries file, as the file may not exist yet, and
+	// editors commonly replace the file instead of writing to it in place.
+	var watcher *fsnotify.Watcher
+	dir := filepath.Dir(cfg.AgentConfig.PrivateRegistry)
+	if cfg.AgentConfig.PrivateRegistry != "" {
+		if watcher, err = createWatcher(dir); err != nil {
+			if !os.IsNotExist(err) {
+				return pkgerrors.WithMessagef(err, "failed to create private registry config watcher for %s", dir)
+			}
+			logrus.Warnf("Not watching private registry config file %s for changes: directory %s does not exist", cfg.AgentConfig.PrivateRegistry, dir)
+		} else {
+			logrus.Infof("Watching private registry config file %s for changes", cfg.AgentConfig.PrivateRegistry)
+		}
+	}
+
+	go func() {
+		timer := time.NewTimer(registriesReloadDelay)
+		timer.Stop()
+		ticker := time.NewTicker(registriesRefreshInterval)
+		defer func() {
+			timer.Stop()
+			ticker.Stop()
+			if watcher != nil {
+				watcher.Close()
+			}
+		}()
+
+		for {
+			// receives from nil channels block, so the watcher cases are disabled if there is no watcher
+			var events <-chan fsnotify.Event
+			var watchErrors <-chan error
+			if watcher != nil {
+				events, watchErrors = watcher.Events, watcher.Errors
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					logrus.Info("Private registry config watcher event channel closed; retrying in 5 seconds")
+					watcher = recreateWatcher(ctx, dir)
+					continue
+				}
+				if event.Has(fsnotify.Chmod) {
+					continue
+				}
+				// Changes to any file in the directory are handled, in order to support symlinks
+				// that are replaced when the file is updated, as is done for ConfigMap volumes.
+				// Reloads are no-ops if the content of the registries file did not change.
+				timer.Reset(registriesReloadDelay)
+			case err, ok := <-watchErrors:
+				if !ok {
+					logrus.Info("Private registry config watcher error channel closed; retrying in 5 seconds")
+					watcher = recreateWatcher(ctx, dir)
+					continue
+				}
+				logrus.Errorf("Private registry config watcher received an error: %v", err)
+			case <-timer.C:
+				reloadRegistries(ctx, cfg, recorder, nodeRef, load)
+			case <-ticker.C:
+				reloadRegistries(ctx, cfg, recorder, nodeRef, load)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// recreateWatcher waits for 5 seconds and then recreates the watcher. If the context
+// is cancelled while waiting, or the watcher cannot be created, nil is returned.
+func recreateWatcher(ctx context.Context, dir string) *fsnotify.Watcher {
+	select {
+	case <-time.After(time.Second * 5):
+		watcher, err := createWatcher(dir)
+		if err != nil {
+			logrus.Errorf("Failed to recreate private registry config watcher: %v", err)
+			return nil
+		}
+		return watcher
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// reloadRegistries loads the registry configuration, and if it has changed, regenerates the containerd
+// registry hosts directories. The containerd config is also rewritten if registry credentials have changed.
+func reloadRegistries(ctx context.Context, cfg *config.Node, recorder record.EventRecorder, nodeRef *corev1.ObjectReference, load LoadRegistriesFunc) {
+	registry, err := load(ctx)
+	if err != nil {
+		message := fmt.Sprintf("Failed to reload registry configuration: %v", err)
+		logrus.Error(message)
+		recorder.Event(nodeRef, corev1.EventTypeWarning, "RegistriesReloadFailed", message)
+		return
+	}
+
+	// The embedded registry mirror is injected into the registry configuration at startup, and
+	// must also be injected into the reloaded configuration.
+	if cfg.EmbeddedRegistry {
+		mirrorConfig := &config.Node{}
+		mirrorConfig.AgentConfig.Registry.Store(registry)
+		if err := spegel.DefaultRegistry.InjectMirror(mirrorConfig); err != nil {
+			message := fmt.Sprintf("Failed to configure embedded registry mirror for reloaded registry configuration: %v", err)
+			logrus.Error(message)
+			recorder.Event(nodeRef, corev1.EventTypeWarning, "RegistriesReloadFailed", message)
+			return
+		}
+	}
+
+	rc := diffRegistries(cfg.AgentConfig.Registry.Load(), registry)
+	if len(rc.changes) == 0 {
+		logrus.Debugf("Registry configuration is unchanged")
+		return
+	}
+
+	if err := updateRegistries(cfg, registry, rc.credentials); err != nil {
+		message := fmt.Sprintf("Failed to apply registry configuration: %v", err)
+		logrus.Error(message)
+		recorder.Event(nodeRef, corev1.EventTypeWarning, "RegistriesReloadFailed", message)
+		return
+	}
+
+	message := "Reloaded registry configuration: " + strings.Join(rc.changes, "; ")
+	if rc.restart {
+		message += fmt.Sprintf(". Registry identity tokens are loaded by containerd at startup; restart %s to use the updated identity tokens", version.Program)
+		logrus.Warn(message)
+		recorder.Event(nodeRef, corev1.EventTypeWarning, "RegistriesReloadedRestartRequired", message)
+		return
+	}
+	logrus.Info(message)
+	recorder.Event(nodeRef, corev1.EventTypeNormal, "RegistriesReloaded", message)
+}
+
+// updateRegistries regenerates the containerd registry hosts directories using the provided registry
+// configuration, and if credentials have changed, the containerd config file. The containerd config is kept
+// in sync with the hosts files so that credentials are used on restart, and for registries that request a
+// token using the credentials. The node's registry configuration is restored to its previous value if the
+// files cannot be written.
+func updateRegistries(cfg *config.Node, registry *registryconfig.Registry, credentials bool) error {
+	previous := cfg.AgentConfig.Registry.Swap(registry)
+
+	if credentials {
+		containerdConfig, err := newContainerdConfig(cfg)
+		if err == nil {
+			err = writeContainerdConfig(cfg, *containerdConfig)
+		}
+		if err != nil {
+			cfg.AgentConfig.Registry.Store(previous)
+			return err
+		}
+	}
+
+	containerdConfig := templates.ContainerdConfig{
+		PrivateRegistryConfig: registry,
+		NoDefaultEndpoint:     cfg.Containerd.NoDefault,
+	}
+	if err := writeContainerdHosts(cfg, containerdConfig); err != nil {
+		cfg.AgentConfig.Registry.Store(previous)
+		return err
+	}
+	return nil
+}
+
+// diffRegistries compares two registry configurations, and returns a sorted list of the changed
+// mirrors and registry configs. Credentials is set to true if the credentials for any registry changed, and
+// restart is set to true if an identity token changed, as these can only be loaded by restarting containerd.
+func diffRegistries(current, updated *registryconfig.Registry) registryChanges {
+	if current == nil {
+		current = &registryconfig.Registry{}
+	}
+	if updated == nil {
+		updated = &registryconfig.Registry{}
+	}
+
+	rc := registryChanges{}
+	for host, mirror := range updated.Mirrors {
+		if currentMirror, ok := current.Mirrors[host]; !ok {
+			rc.changes = append(rc.changes, "added mirror for "+host)
+		} else if !reflect.DeepEqual(currentMirror, mirror) {
+			rc.changes = append(rc.changes, "updated mirror for "+host)
+		}
+	}
+	for host := range current.Mirrors {
+		if _, ok := updated.Mirrors[host]; !ok {
+			rc.changes = append(rc.changes, "removed mirror for "+host)
+		}
+	}
+
+	for host, config := range updated.Configs {
+		currentConfig, ok := current.Configs[host]
+		if !ok {
+			rc.changes = append(rc.changes, "added config for "+host)
+			rc.credentials = rc.credentials || config.Auth != nil
+			rc.restart = rc.restart || identityToken(config.Auth) != ""
+			continue
+		}
+		if !reflect.DeepEqual(currentConfig.TLS, config.TLS) {
+			rc.changes = append(rc.changes, "updated TLS config for "+host)
+		}
+		if !reflect.DeepEqual(currentConfig.Auth, config.Auth) {
+			rc.changes = append(rc.changes, "updated credentials for "+host)
+			rc.credentials = true
+			rc.restart = rc.restart || identityToken(currentConfig.Auth) != identityToken(config.Auth)
+		}
+	}
+	for host, config := range current.Configs {
+		if _, ok := updated.Configs[host]; !ok {
+			rc.changes = append(rc.changes, "removed config for "+host)
+			rc.credentials = rc.credentials || config.Auth != nil
+			rc.restart = rc.restart || identityToken(config.Auth) != ""
+		}
+	}
+
+	sort.Strings(rc.changes)
+	return rc
+}
+
+// identityToken returns the identity token from the provided credentials, if any.
+func identityToken(auth *registries.AuthConfig) string {
+	if auth == nil {
+		return ""
+	}
+	return auth.IdentityToken
+}
@@ -0,0 +1,275 @@
+package containerd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/agent/templates"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
+	"github.com/rancher/wharfie/pkg/registries"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_UnitDiffRegistries(t *testing.T) {
+	tests := []struct {
+		name            string
+		current         *registryconfig.Registry
+		updated         *registryconfig.Registry
+		wantChanges     []string
+		wantCredentials bool
+		wantRestart     bool
+	}{
+		{
+			name:    "No change",
+			current: &registryconfig.Registry{Mirrors: map[string]registryconfig.Mirror{"docker.io": {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com")}}},
+			updated: &registryconfig.Registry{Mirrors: map[string]registryconfig.Mirror{"docker.io": {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com")}}},
+		},
+		{
+			name:        "Added mirror to empty config",
+			updated:     &registryconfig.Registry{Mirrors: map[string]registryconfig.Mirror{"docker.io": {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com")}}},
+			wantChanges: []string{"added mirror for docker.io"},
+		},
+		{
+			name: "Updated and removed mirrors",
+			current: &registryconfig.Registry{Mirrors: map[string]registryconfig.Mirror{
+				"docker.io":       {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com")},
+				"registry.k8s.io": {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com")},
+			}},
+			updated: &registryconfig.Registry{Mirrors: map[string]registryconfig.Mirror{
+				"docker.io": {Endpoints: registryconfig.NewEndpoints("https://mirror.example.com"), Rewrites: map[string]string{"^(.*)$": "docker/$1"}},
+			}},
+			wantChanges: []string{"removed mirror for registry.k8s.io", "updated mirror for docker.io"},
+		},
+		{
+			name: "Updated TLS config",
+			current: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {TLS: &registries.TLSConfig{InsecureSkipVerify: true}},
+			}},
+			updated: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {TLS: &registries.TLSConfig{CAFile: "/etc/ssl/mirror.pem"}},
+			}},
+			wantChanges: []string{"updated TLS config for mirror.example.com"},
+		},
+		{
+			name: "Updated credentials",
+			current: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {Auth: &registries.AuthConfig{Username: "user", Password: "old"}},
+			}},
+			updated: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {Auth: &registries.AuthConfig{Username: "user", Password: "new"}},
+			}},
+			wantChanges:     []string{"updated credentials for mirror.example.com"},
+			wantCredentials: true,
+		},
+		{
+			name: "Added and removed configs",
+			current: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"old.example.com": {TLS: &registries.TLSConfig{InsecureSkipVerify: true}},
+			}},
+			updated: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"new.example.com": {Auth: &registries.AuthConfig{Username: "user", Password: "pass"}},
+			}},
+			wantChanges:     []string{"added config for new.example.com", "removed config for old.example.com"},
+			wantCredentials: true,
+		},
+		{
+			name: "Updated identity token",
+			current: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {Auth: &registries.AuthConfig{Username: "user", Password: "pass"}},
+			}},
+			updated: &registryconfig.Registry{Configs: map[string]registries.RegistryConfig{
+				"mirror.example.com": {Auth: &registries.AuthConfig{IdentityToken: "token"}},
+			}},
+			wantChanges:     []string{"updated credentials for mirror.example.com"},
+			wantCredentials: true,
+			wantRestart:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffRegistries(tt.current, tt.updated)
+			if !reflect.DeepEqual(got.changes, tt.wantChanges) {
+				t.Errorf("diffRegistries() changes = %v, want %v", got.changes, tt.wantChanges)
+			}
+			if got.credentials != tt.wantCredentials {
+				t.Errorf("diffRegistries() credentials = %v, want %v", got.credentials, tt.wantCredentials)
+			}
+			if got.restart != tt.wantRestart {
+				t.Errorf("diffRegistries() restart = %v, want %v", got.restart, tt.wantRestart)
+			}
+		})
+	}
+}
+
+func Test_UnitReloadRegistries(t *testing.T) {
+	tempDir := t.TempDir()
+	registriesFile := filepath.Join(tempDir, "registries.yaml")
+	registryDir := filepath.Join(tempDir, "certs.d")
+
+	cfg := &config.Node{
+		Containerd: config.Containerd{
+			Registry: registryDir,
+		},
+		AgentConfig: config.Agent{
+			PrivateRegistry: registriesFile,
+		},
+	}
+	cfg.AgentConfig.Registry.Store(&registryconfig.Registry{
+		Mirrors: map[string]registryconfig.Mirror{
+			"registry.k8s.io": {Endpoints: registryconfig.NewEndpoints("https://old.example.com")},
+		},
+	})
+	if err := writeContainerdHosts(cfg, templates.ContainerdConfig{PrivateRegistryConfig: cfg.AgentConfig.Registry.Load()}); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `
+mirrors:
+  docker.io:
+    endpoint:
+    - https://new.example.com
+`
+	if err := os.WriteFile(registriesFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(ctx context.Context) (*registryconfig.Registry, error) {
+		return registryconfig.ReadFile(registriesFile)
+	}
+
+	ctx := context.Background()
+	recorder := record.NewFakeRecorder(10)
+	nodeRef := &corev1.ObjectReference{Kind: "Node", Name: "node1"}
+	reloadRegistries(ctx, cfg, recorder, nodeRef, load)
+
+	if _, ok := cfg.AgentConfig.Registry.Load().Mirrors["docker.io"]; !ok {
+		t.Errorf("Expected node registry config to be updated, got %#v", cfg.AgentConfig.Registry.Load())
+	}
+	if _, err := os.Stat(filepath.Join(registryDir, "docker.io", "hosts.toml")); err != nil {
+		t.Errorf("Expected hosts.toml to be created for docker.io: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(registryDir, "registry.k8s.io")); !os.IsNotExist(err) {
+		t.Errorf("Expected hosts dir for registry.k8s.io to be removed, got error %v", err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "RegistriesReloaded") || !strings.Contains(event, "added mirror for docker.io") || !strings.Contains(event, "removed mirror for registry.k8s.io") {
+			t.Errorf("Unexpected event: %s", event)
+		}
+	default:
+		t.Errorf("Expected an event to be recorded")
+	}
+
+	// reloading an unchanged file should not record an event
+	reloadRegistries(ctx, cfg, recorder, nodeRef, load)
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("Unexpected event for unchanged config: %s", event)
+	default:
+	}
+}
+
+func Test_UnitReloadRegistriesCredentials(t *testing.T) {
+	tempDir := t.TempDir()
+	registriesFile := filepath.Join(tempDir, "registries.yaml")
+	registryDir := filepath.Join(tempDir, "certs.d")
+
+	cfg := &config.Node{
+		Containerd: config.Containerd{
+			Config:   filepath.Join(tempDir, "config.toml"),
+			Registry: registryDir,
+			Template: tempDir,
+		},
+		AgentConfig: config.Agent{
+			PrivateRegistry: registriesFile,
+		},
+	}
+	cfg.AgentConfig.Registry.Store(&registryconfig.Registry{
+		Configs: map[string]registries.RegistryConfig{
+			"registry.example.com": {Auth: &registries.AuthConfig{Username: "user", Password: "old"}},
+		},
+	})
+	if err := writeContainerdHosts(cfg, templates.ContainerdConfig{PrivateRegistryConfig: cfg.AgentConfig.Registry.Load()}); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(ctx context.Context) (*registryconfig.Registry, error) {
+		return registryconfig.ReadFile(registriesFile)
+	}
+	ctx := context.Background()
+	recorder := record.NewFakeRecorder(10)
+	nodeRef := &corev1.ObjectReference{Kind: "Node", Name: "node1"}
+	hostsFile := filepath.Join(registryDir, "registry.example.com", "hosts.toml")
+
+	tests := []struct {
+		name        string
+		content     string
+		wantHeader  string
+		wantReason  string
+		wantChanges string
+	}{
+		{
+			name: "Updated password",
+			content: `
+configs:
+  registry.example.com:
+    auth:
+      username: user
+      password: new
+`,
+			wantHeader:  `"Authorization" = "Basic dXNlcjpuZXc="`,
+			wantReason:  "RegistriesReloaded",
+			wantChanges: "updated credentials for registry.example.com",
+		},
+		{
+			name: "Updated identity token",
+			content: `
+configs:
+  registry.example.com:
+    auth:
+      identity_token: token
+`,
+			wantReason:  "RegistriesReloadedRestartRequired",
+			wantChanges: "updated credentials for registry.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(registriesFile, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			reloadRegistries(ctx, cfg, recorder, nodeRef, load)
+
+			// credentials are sent as a header from hosts.toml, so that they are used without restarting containerd
+			b, err := os.ReadFile(hostsFile)
+			if tt.wantHeader != "" {
+				if err != nil || !strings.Contains(string(b), tt.wantHeader) {
+					t.Errorf("Expected hosts.toml to contain %s, got %q, error %v", tt.wantHeader, b, err)
+				}
+			} else if strings.Contains(string(b), "Authorization") {
+				t.Errorf("Expected hosts.toml without Authorization header, got %q", b)
+			}
+
+			// credentials are also written to the containerd config, for use on restart
+			if b, err := os.ReadFile(cfg.Containerd.Config); err != nil || !strings.Contains(string(b), "registry.example.com") {
+				t.Errorf("Expected containerd config to contain credentials for registry.example.com, got %q, error %v", b, err)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, " "+tt.wantReason+" ") || !strings.Contains(event, tt.wantChanges) {
+					t.Errorf("Unexpected event: %s", event)
+				}
+			default:
+				t.Errorf("Expected an event to be recorded")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package flannel
 import (
 	"net"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -64,16 +65,15 @@ func Test_createFlannelConf(t *testing.T) {
 	}
 	var containerd = config.Containerd{}
 	for _, tt := range tests {
-		var agent = config.Agent{}
-		agent.ClusterCIDR = stringToCIDR(tt.args)[0]
-		agent.ClusterCIDRs = stringToCIDR(tt.args)
-		var nodeConfig = &config.Node{Docker: false, ContainerRuntimeEndpoint: "", SELinux: false, FlannelBackend: "vxlan", FlannelConfFile: "test_file", FlannelConfOverride: false, FlannelIface: nil, Containerd: containerd, Images: "", AgentConfig: agent, Token: "", ServerHTTPSPort: 0}
+		var nodeConfig = &config.Node{Docker: false, ContainerRuntimeEndpoint: "", SELinux: false, FlannelBackend: "vxlan", FlannelConfFile: filepath.Join(t.TempDir(), "test_file"), FlannelConfOverride: false, FlannelIface: nil, Containerd: containerd, Images: "", Token: "", ServerHTTPSPort: 0}
+		nodeConfig.AgentConfig.ClusterCIDR = stringToCIDR(tt.args)[0]
+		nodeConfig.AgentConfig.ClusterCIDRs = stringToCIDR(tt.args)
 
 		t.Run(tt.name, func(t *testing.T) {
 			if err := createFlannelConf(nodeConfig); (err != nil) != tt.wantErr {
 				t.Errorf("createFlannelConf() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			data, err := os.ReadFile("test_file")
+			data, err := os.ReadFile(nodeConfig.FlannelConfFile)
 			if err != nil {
 				t.Errorf("Something went wrong when reading the flannel config file")
 			}
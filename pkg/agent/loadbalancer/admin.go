@@ -0,0 +1,354 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminSocketName is the name of the unix socket, within the agent data directory,
+// that serves the status and admin endpoints for all load balancers in the process.
+const AdminSocketName = "lb.sock"
+
+// Status describes the current state of a load balancer.
+type Status struct {
+	Name          string         `json:"name"`
+	LocalAddress  string         `json:"localAddress"`
+	Strategy      Strategy       `json:"strategy"`
+	DefaultServer string         `json:"defaultServer"`
+	Servers       []ServerStatus `json:"servers"`
+}
+
+// ServerStatus describes the current state of a load balancer server.
+type ServerStatus struct {
+	Address        string    `json:"address"`
+	State          string    `json:"state"`
+	Reason         string    `json:"reason"`
+	LastTransition time.Time `json:"lastTransition"`
+	Connections    int       `json:"connections"`
+	Latency        string    `json:"latency,omitempty"`
+	Default        bool      `json:"default,omitempty"`
+	Drained        bool      `json:"drained,omitempty"`
+}
+
+// AdminRequest selects the load balancer servers to drain, undrain, or fail over.
+// If the load balancer name is empty, all load balancers are selected.
+// If the server address is empty, failover applies to all active servers.
+type AdminRequest struct {
+	LoadBalancer string `json:"loadBalancer,omitempty"`
+	Server       string `json:"server,omitempty"`
+}
+
+// registry tracks the running load balancers, so that they can be found by the admin endpoints.
+var registry = struct {
+	mutex         sync.Mutex
+	loadBalancers map[string]*LoadBalancer
+}{loadBalancers: map[string]*LoadBalancer{}}
+
+// register adds the load balancer to the registry until its context is cancelled.
+func (lb *LoadBalancer) register() {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.loadBalancers[lb.serviceName] = lb
+
+	go func() {
+		<-lb.ctx.Done()
+		registry.mutex.Lock()
+		defer registry.mutex.Unlock()
+		if registry.loadBalancers[lb.serviceName] == lb {
+			delete(registry.loadBalancers, lb.serviceName)
+		}
+	}()
+}
+
+// getLoadBalancers returns the registered load balancers with the given name, sorted by name.
+// If the name is empty, all registered load balancers are returned.
+func getLoadBalancers(name string) []*LoadBalancer {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	loadBalancers := []*LoadBalancer{}
+	for _, lb := range registry.loadBalancers {
+		if name == "" || lb.serviceName == name {
+			loadBalancers = append(loadBalancers, lb)
+		}
+	}
+	slices.SortFunc(loadBalancers, func(a, b *LoadBalancer) int { return strings.Compare(a.serviceName, b.serviceName) })
+	return loadBalancers
+}
+
+// Status returns the current state of the load balancer and its servers.
+func (lb *LoadBalancer) Status() Status {
+	status := Status{
+		Name:          lb.serviceName,
+		LocalAddress:  lb.localAddress,
+		Strategy:      lb.servers.getStrategy(),
+		DefaultServer: lb.servers.getDefaultAddress(),
+		Servers:       []ServerStatus{},
+	}
+	for _, s := range lb.servers.getServers() {
+		s.mutex.Lock()
+		ss := ServerStatus{
+			Address:        s.address,
+			State:          s.state.String(),
+			Reason:         s.lastReason.String(),
+			LastTransition: s.lastTransition,
+			Connections:    len(s.connections),
+			Default:        s.isDefault,
+			Drained:        s.drained,
+		}
+		if s.latency != 0 {
+			ss.Latency = s.latency.String()
+		}
+		s.mutex.Unlock()
+		status.Servers = append(status.Servers, ss)
+	}
+	return status
+}
+
+// Drain stops the load balancer from sending new connections to the server with the given address,
+// unless all other servers are unavailable. Existing connections are left open; Failover may be used
+// to close them. If drained is false, the server is returned to service.
+func (lb *LoadBalancer) Drain(address string, drained bool) error {
+	s := lb.servers.getServer(address)
+	if s == nil {
+		return fmt.Errorf("no server found for %s", address)
+	}
+	if s.setDrained(drained) {
+		if drained {
+			logrus.Infof("Drained server %s from load balancer %s", s, lb.serviceName)
+		} else {
+			logrus.Infof("Undrained server %s in load balancer %s", s, lb.serviceName)
+		}
+	}
+	return nil
+}
+
+// Failover marks the server with the given address as failed, closing all connections to it so that
+// clients reconnect to another server. If the address is empty, all active servers are failed. Failed
+// servers return to service once they pass health checks; servers should be drained to keep them out
+// of service.
+func (lb *LoadBalancer) Failover(address string) error {
+	var servers []*server
+	if address == "" {
+		servers = slices.DeleteFunc(lb.servers.getServers(), func(s *server) bool { return s.state != stateActive })
+		if len(servers) == 0 {
+			return errors.New("no active server found")
+		}
+	} else if s := lb.servers.getServer(address); s != nil {
+		servers = []*server{s}
+	} else {
+		return fmt.Errorf("no server found for %s", address)
+	}
+
+	for _, s := range servers {
+		logrus.Infof("Failing over from server %s in load balancer %s", s, lb.serviceName)
+		lb.servers.recordFailure(s, reasonAdmin)
+	}
+	return nil
+}
+
+// setDrained sets the drained flag on the server, and returns true if it was changed.
+func (s *server) setDrained(drained bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	changed := s.drained != drained
+	s.drained = drained
+	return changed
+}
+
+// isDrained returns true if the server has been drained.
+func (s *server) isDrained() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.drained
+}
+
+// ListenAndServeAdmin serves the status and admin endpoints for all load balancers in the process on
+// a unix socket at the given path, until the context is cancelled. The socket is only accessible by
+// the owner, as the admin endpoints allow connections to be redirected.
+func ListenAndServeAdmin(ctx context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return pkgerrors.WithMessage(err, "failed to remove stale load balancer admin socket")
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return pkgerrors.WithMessage(err, "failed to listen on load balancer admin socket")
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return pkgerrors.WithMessage(err, "failed to set load balancer admin socket permissions")
+	}
+
+	server := &http.Server{
+		Handler:     adminHandler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+		os.Remove(path)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Load balancer admin socket server exited: %v", err)
+		}
+	}()
+	logrus.Infof("Serving load balancer status on %s", path)
+	return nil
+}
+
+// adminHandler returns a handler for the load balancer status and admin endpoints.
+func adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", func(rw http.ResponseWriter, req *http.Request) {
+		statuses := []Status{}
+		for _, lb := range getLoadBalancers(req.URL.Query().Get("loadBalancer")) {
+			statuses = append(statuses, lb.Status())
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(statuses)
+	})
+	mux.HandleFunc("POST /v1/drain", adminRequestHandler(func(lb *LoadBalancer, address string) error {
+		return lb.Drain(address, true)
+	}))
+	mux.HandleFunc("POST /v1/undrain", adminRequestHandler(func(lb *LoadBalancer, address string) error {
+		return lb.Drain(address, false)
+	}))
+	mux.HandleFunc("POST /v1/failover", adminRequestHandler(func(lb *LoadBalancer, address string) error {
+		return lb.Failover(address)
+	}))
+	return mux
+}
+
+// adminRequestHandler returns a handler that applies an admin request to the selected load balancers.
+// The request fails if it could not be applied to any load balancer.
+func adminRequestHandler(apply func(lb *LoadBalancer, address string) error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		adminReq := &AdminRequest{}
+		if err := json.NewDecoder(req.Body).Decode(adminReq); err != nil {
+			http.Error(rw, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		loadBalancers := getLoadBalancers(adminReq.LoadBalancer)
+		if len(loadBalancers) == 0 {
+			http.Error(rw, "no load balancer found", http.StatusNotFound)
+			return
+		}
+
+		applied := 0
+		errs := []error{}
+		for _, lb := range loadBalancers {
+			if err := apply(lb, adminReq.Server); err != nil {
+				errs = append(errs, pkgerrors.WithMessage(err, lb.serviceName))
+			} else {
+				applied++
+			}
+		}
+		if applied == 0 {
+			http.Error(rw, errors.Join(errs...).Error(), http.StatusNotFound)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AdminClient is a client for the load balancer status and admin endpoints.
+type AdminClient struct {
+	client *http.Client
+}
+
+// NewAdminClient returns a client for the load balancer admin socket at the given path.
+func NewAdminClient(path string) *AdminClient {
+	dialer := &net.Dialer{}
+	return &AdminClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Status returns the status of the load balancers with the given name,
+// or all load balancers if the name is empty.
+func (c *AdminClient) Status(ctx context.Context, name string) ([]Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://lb/v1/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		req.URL.RawQuery = url.Values{"loadBalancer": []string{name}}.Encode()
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	return statuses, json.NewDecoder(resp.Body).Decode(&statuses)
+}
+
+// Drain drains the server from the selected load balancers.
+func (c *AdminClient) Drain(ctx context.Context, adminReq AdminRequest) error {
+	return c.post(ctx, "drain", adminReq)
+}
+
+// Undrain returns the server to service in the selected load balancers.
+func (c *AdminClient) Undrain(ctx context.Context, adminReq AdminRequest) error {
+	return c.post(ctx, "undrain", adminReq)
+}
+
+// Failover fails over from the server, or all active servers, in the selected load balancers.
+func (c *AdminClient) Failover(ctx context.Context, adminReq AdminRequest) error {
+	return c.post(ctx, "failover", adminReq)
+}
+
+func (c *AdminClient) post(ctx context.Context, operation string, adminReq AdminRequest) error {
+	b, err := json.Marshal(adminReq)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://lb/v1/"+operation, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// checkResponse returns an error containing the response body if the request was not successful.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,135 @@
+package loadbalancer
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_UnitAdmin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serviceName := "test-admin-load-balancer"
+	node1Server, err := createServer(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node2Server, err := createServer(ctx, "node2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lb, err := New(ctx, t.TempDir(), serviceName, "http://"+node1Server.address, RandomPort, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb.SetStrategy(StrategyRoundRobin)
+	lb.Update([]string{node1Server.address, node2Server.address})
+	lb.SetHealthCheck(node1Server.address, func() HealthCheckResult { return HealthCheckResultOK })
+	lb.SetHealthCheck(node2Server.address, func() HealthCheckResult { return HealthCheckResultOK })
+
+	socketPath := filepath.Join(t.TempDir(), AdminSocketName)
+	if err := ListenAndServeAdmin(ctx, socketPath); err != nil {
+		t.Fatal(err)
+	}
+	client := NewAdminClient(socketPath)
+
+	serverStates := func() map[string]ServerStatus {
+		statuses, err := client.Status(ctx, serviceName)
+		if err != nil {
+			t.Fatalf("AdminClient.Status() error = %v", err)
+		}
+		if len(statuses) != 1 || statuses[0].Name != serviceName {
+			t.Fatalf("AdminClient.Status() = %+v, want single status for %s", statuses, serviceName)
+		}
+		states := map[string]ServerStatus{}
+		for _, ss := range statuses[0].Servers {
+			states[ss.Address] = ss
+		}
+		return states
+	}
+	// wait for both servers to pass health checks
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		healthy := true
+		for _, s := range lb.servers.getServers() {
+			healthy = healthy && s.state.tier() == 2
+		}
+		if healthy {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Timed out waiting for servers to pass health checks: %v", lb.servers.getServers())
+		}
+	}
+	if !serverStates()[node1Server.address].Default {
+		t.Errorf("Server %s is not marked as default", node1Server.address)
+	}
+
+	dial := func() string {
+		conn, err := net.Dial("tcp", lb.localAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		result, err := ping(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	t.Run("Drain", func(t *testing.T) {
+		if err := client.Drain(ctx, AdminRequest{Server: node1Server.address}); err != nil {
+			t.Fatalf("AdminClient.Drain() error = %v", err)
+		}
+		if !serverStates()[node1Server.address].Drained {
+			t.Errorf("Server %s is not drained", node1Server.address)
+		}
+		for range 4 {
+			if result := dial(); result != "node2:ping" {
+				t.Errorf("ping() = %s, want node2:ping", result)
+			}
+		}
+	})
+
+	t.Run("Undrain", func(t *testing.T) {
+		if err := client.Undrain(ctx, AdminRequest{LoadBalancer: serviceName, Server: node1Server.address}); err != nil {
+			t.Fatalf("AdminClient.Undrain() error = %v", err)
+		}
+		if serverStates()[node1Server.address].Drained {
+			t.Errorf("Server %s is still drained", node1Server.address)
+		}
+		results := map[string]int{}
+		for range 4 {
+			results[dial()]++
+		}
+		if results["node1:ping"] == 0 {
+			t.Errorf("ping() results = %v, want connections to node1", results)
+		}
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		if err := client.Failover(ctx, AdminRequest{Server: node2Server.address}); err != nil {
+			t.Fatalf("AdminClient.Failover() error = %v", err)
+		}
+		ss := serverStates()[node2Server.address]
+		if ss.State != stateFailed.String() || ss.Reason != reasonAdmin.String() {
+			t.Errorf("Server %s state = %s from %s, want %s from %s", node2Server.address, ss.State, ss.Reason, stateFailed, reasonAdmin)
+		}
+		if result := dial(); result != "node1:ping" {
+			t.Errorf("ping() = %s, want node1:ping", result)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if err := client.Drain(ctx, AdminRequest{Server: "127.0.0.1:1"}); err == nil {
+			t.Errorf("AdminClient.Drain() of unknown server did not fail")
+		}
+		if err := client.Drain(ctx, AdminRequest{LoadBalancer: "missing", Server: node1Server.address}); err == nil {
+			t.Errorf("AdminClient.Drain() of unknown load balancer did not fail")
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package loadbalancer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/proxy"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ActiveHealthCheckConfig configures active health checks, which periodically request
+// an HTTP endpoint from each server, in addition to any health check functions set by
+// the caller. A server is considered failed if either check fails.
+type ActiveHealthCheckConfig struct {
+	// Path is the HTTP path requested from each server, such as /ping or /readyz.
+	Path string
+	// Interval is the time between checks. Active health checks are disabled if the interval is zero.
+	Interval time.Duration
+	// Timeout is the time allowed for each check to complete.
+	Timeout time.Duration
+	// HealthyThreshold is the number of consecutive successful checks required before a server is considered healthy.
+	HealthyThreshold int
+	// UnhealthyThreshold is the number of consecutive failed checks required before a server is considered failed.
+	UnhealthyThreshold int
+	// CAFile, CertFile, and KeyFile are used to verify servers and authenticate to them when using HTTPS.
+	// The client certificate is reloaded from disk for each check, so that it may be rotated.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Enabled returns true if active health checks are enabled.
+func (c ActiveHealthCheckConfig) Enabled() bool {
+	return c.Interval > 0
+}
+
+func (c ActiveHealthCheckConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Timeout <= 0 {
+		return errors.New("health check timeout must be greater than zero")
+	}
+	if c.HealthyThreshold < 1 || c.UnhealthyThreshold < 1 {
+		return errors.New("health check thresholds must be at least 1")
+	}
+	return nil
+}
+
+// probeStatus tracks the results of active health checks of a server. The result only changes once
+// the configured number of consecutive checks succeed or fail, to prevent a server from flapping
+// between healthy and failed when checks intermittently fail.
+type probeStatus struct {
+	result    HealthCheckResult
+	successes int
+	failures  int
+}
+
+// newHealthCheckClient returns a HTTP client for active health checks. Connections are dialed with the
+// same dialer as load balancer connections, and are not reused, so that each check confirms that the
+// server is still accepting new connections. Dialing is bounded by the check timeout.
+func newHealthCheckClient(config ActiveHealthCheckConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{}
+	if config.CAFile != "" {
+		caCerts, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to read health check CA certificates")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" && config.KeyFile != "" {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			return &cert, err
+		}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				if _, ok := defaultDialer.(*net.Dialer); ok {
+					return (&net.Dialer{Timeout: config.Timeout}).DialContext(ctx, network, address)
+				}
+				return dialContext(ctx, defaultDialer, network, address)
+			},
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// dialContext dials a connection using a dialer, returning early if the context is done. Proxy dialers that
+// do not support contexts are dialed in the background, and connections that complete after the context is done
+// are closed.
+func dialContext(ctx context.Context, dialer proxy.Dialer, network, address string) (net.Conn, error) {
+	if dialer, ok := dialer.(proxy.ContextDialer); ok {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := dialer.Dial(network, address)
+		done <- result{conn: conn, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// SetActiveHealthCheck configures active health checks of all servers, replacing any previous
+// configuration. Active health checks are stopped if the interval is zero.
+func (lb *LoadBalancer) SetActiveHealthCheck(config ActiveHealthCheckConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	var client *http.Client
+	if config.Enabled() {
+		var err error
+		if client, err = newHealthCheckClient(config); err != nil {
+			return err
+		}
+	}
+
+	lb.healthCheckMutex.Lock()
+	defer lb.healthCheckMutex.Unlock()
+
+	if lb.healthCheckCancel != nil {
+		lb.healthCheckCancel()
+		lb.healthCheckCancel = nil
+	}
+	for _, s := range lb.servers.getServers() {
+		s.resetProbe()
+	}
+
+	if !config.Enabled() {
+		logrus.Infof("Disabled active health checks for load balancer %s", lb.serviceName)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(lb.ctx)
+	lb.healthCheckCancel = cancel
+	go lb.servers.runActiveHealthChecks(ctx, lb.serviceName, lb.scheme, config, client)
+	logrus.Infof("Enabled active health checks for load balancer %s: path=%s interval=%s timeout=%s healthy-threshold=%d unhealthy-threshold=%d",
+		lb.serviceName, config.Path, config.Interval, config.Timeout, config.HealthyThreshold, config.UnhealthyThreshold)
+	return nil
+}
+
+// runActiveHealthChecks periodically requests the health check endpoint from all servers.
+func (sl *serverList) runActiveHealthChecks(ctx context.Context, serviceName, scheme string, config ActiveHealthCheckConfig, client *http.Client) {
+	wait.Until(func() {
+		wg := sync.WaitGroup{}
+		for _, s := range sl.getServers() {
+			if s.state == stateInvalid {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.probe(ctx, serviceName, scheme, config, client)
+			}()
+		}
+		wg.Wait()
+	}, config.Interval, ctx.Done())
+	logrus.Debugf("Stopped active health checks for load balancer %s", serviceName)
+}
+
+// probe requests the health check endpoint from the server, and records the result.
+// The time taken by successful checks is recorded as a latency sample for the server.
+func (s *server) probe(ctx context.Context, serviceName, scheme string, config ActiveHealthCheckConfig, client *http.Client) {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	url := scheme + "://" + s.address + config.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		s.recordProbe(serviceName, config, err)
+		return
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// health checks were stopped or reconfigured
+		return
+	}
+	if err == nil {
+		s.observeLatency(time.Now().Sub(start))
+	}
+	s.recordProbe(serviceName, config, err)
+}
+
+// recordProbe records the result of an active health check, and updates the probe result
+// once the configured number of consecutive checks have succeeded or failed.
+func (s *server) recordProbe(serviceName string, config ActiveHealthCheckConfig, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err == nil {
+		s.probeStatus.successes++
+		s.probeStatus.failures = 0
+		if s.probeStatus.result != HealthCheckResultOK && s.probeStatus.successes >= config.HealthyThreshold {
+			logrus.Infof("Active health check for load balancer %s server %s passed %d times", serviceName, s.address, s.probeStatus.successes)
+			s.probeStatus.result = HealthCheckResultOK
+		}
+	} else {
+		logrus.Debugf("Active health check for load balancer %s server %s failed: %v", serviceName, s.address, err)
+		s.probeStatus.failures++
+		s.probeStatus.successes = 0
+		if s.probeStatus.result != HealthCheckResultFailed && s.probeStatus.failures >= config.UnhealthyThreshold {
+			logrus.Warnf("Active health check for load balancer %s server %s failed %d times: %v", serviceName, s.address, s.probeStatus.failures, err)
+			s.probeStatus.result = HealthCheckResultFailed
+		}
+	}
+}
+
+// resetProbe discards the results of active health checks.
+func (s *server) resetProbe() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.probeStatus = probeStatus{}
+}
+
+// checkHealth combines the result of the server's health check function with the result of active
+// health checks. The server is considered failed if either check has failed; if the health check
+// function does not have a result, the result of active health checks is used.
+func (s *server) checkHealth() HealthCheckResult {
+	result := s.healthCheck()
+
+	s.mutex.Lock()
+	probeResult := s.probeStatus.result
+	s.mutex.Unlock()
+
+	if probeResult == HealthCheckResultFailed || result == HealthCheckResultUnknown {
+		return probeResult
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+package loadbalancer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_UnitRecordProbe(t *testing.T) {
+	config := ActiveHealthCheckConfig{Interval: time.Second, Timeout: time.Second, HealthyThreshold: 2, UnhealthyThreshold: 3}
+	failed := http.ErrHandlerTimeout
+	tests := []struct {
+		name    string
+		initial HealthCheckResult
+		results []error
+		want    HealthCheckResult
+	}{
+		{name: "Unknown after one success", results: []error{nil}, want: HealthCheckResultUnknown},
+		{name: "OK after healthy threshold", results: []error{nil, nil}, want: HealthCheckResultOK},
+		{name: "OK after intermittent failures", initial: HealthCheckResultOK, results: []error{failed, failed, nil, failed, failed}, want: HealthCheckResultOK},
+		{name: "Failed after unhealthy threshold", initial: HealthCheckResultOK, results: []error{failed, failed, failed}, want: HealthCheckResultFailed},
+		{name: "Failed after intermittent successes", initial: HealthCheckResultFailed, results: []error{nil, failed, nil}, want: HealthCheckResultFailed},
+		{name: "OK after recovery", initial: HealthCheckResultFailed, results: []error{failed, nil, nil}, want: HealthCheckResultOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer("127.0.0.1:6443", false)
+			s.probeStatus.result = tt.initial
+			for _, err := range tt.results {
+				s.recordProbe(SupervisorServiceName, config, err)
+			}
+			if got := s.probeStatus.result; got != tt.want {
+				t.Errorf("recordProbe() result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitCheckHealth(t *testing.T) {
+	tests := []struct {
+		name  string
+		check HealthCheckResult
+		probe HealthCheckResult
+		want  HealthCheckResult
+	}{
+		{name: "No results", check: HealthCheckResultUnknown, probe: HealthCheckResultUnknown, want: HealthCheckResultUnknown},
+		{name: "Health check only", check: HealthCheckResultOK, probe: HealthCheckResultUnknown, want: HealthCheckResultOK},
+		{name: "Probe only", check: HealthCheckResultUnknown, probe: HealthCheckResultOK, want: HealthCheckResultOK},
+		{name: "Probe failed", check: HealthCheckResultOK, probe: HealthCheckResultFailed, want: HealthCheckResultFailed},
+		{name: "Health check failed", check: HealthCheckResultFailed, probe: HealthCheckResultOK, want: HealthCheckResultFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer("127.0.0.1:6443", false)
+			s.healthCheck = func() HealthCheckResult { return tt.check }
+			s.probeStatus.result = tt.probe
+			if got := s.checkHealth(); got != tt.want {
+				t.Errorf("checkHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitProbe(t *testing.T) {
+	config := ActiveHealthCheckConfig{Path: "/readyz", Interval: time.Second, Timeout: 500 * time.Millisecond, HealthyThreshold: 1, UnhealthyThreshold: 1}
+	client, err := newHealthCheckClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    HealthCheckResult
+	}{
+		{
+			name:    "Ready",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			want:    HealthCheckResultOK,
+		},
+		{
+			name: "Not ready",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "not ready", http.StatusInternalServerError)
+			},
+			want: HealthCheckResultFailed,
+		},
+		{
+			name: "Redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/readyz" {
+					http.Redirect(w, r, "/ok", http.StatusFound)
+				}
+			},
+			want: HealthCheckResultFailed,
+		},
+		{
+			name: "Wedged",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			want: HealthCheckResultFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.handler)
+			defer ts.Close()
+
+			s := newServer(strings.TrimPrefix(ts.URL, "http://"), false)
+			s.probe(context.Background(), SupervisorServiceName, "http", config, client)
+			if got := s.probeStatus.result; got != tt.want {
+				t.Errorf("probe() result = %v, want %v", got, tt.want)
+			}
+			if got := s.getLatency() != 0; got != (tt.want == HealthCheckResultOK) {
+				t.Errorf("probe() recorded latency = %v, want %v", got, tt.want == HealthCheckResultOK)
+			}
+		})
+	}
+}
+
+// blockingDialer is a proxy dialer that does not support contexts, and never completes a connection.
+type blockingDialer struct{}
+
+func (blockingDialer) Dial(network, address string) (net.Conn, error) {
+	select {}
+}
+
+func Test_UnitDialContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialContext(ctx, blockingDialer{}, "tcp", "127.0.0.1:1")
+	if err == nil {
+		conn.Close()
+		t.Fatal("dialContext() succeeded, want error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dialContext() returned after %s, want context timeout", elapsed)
+	}
+}
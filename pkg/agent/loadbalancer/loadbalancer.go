@@ -8,25 +8,33 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/inetaf/tcpproxy"
 	"github.com/k3s-io/k3s/pkg/util/metrics"
 	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
 // LoadBalancer holds data for a local listener which forwards connections to a
-// pool of remote servers. It is not a proper load-balancer in that it does not
-// actually balance connections, but instead fails over to a new server only
-// when a connection attempt to the currently selected server fails.
+// pool of remote servers. By default it does not actually balance connections,
+// but instead fails over to a new server only when a connection attempt to the
+// currently selected server fails. Other strategies may be selected to spread
+// connections across all healthy servers.
 type LoadBalancer struct {
+	ctx          context.Context
 	serviceName  string
 	configFile   string
 	scheme       string
 	localAddress string
 	servers      serverList
 	proxy        *tcpproxy.Proxy
+
+	// This mutex protects access to the active health check cancel func.
+	healthCheckMutex  sync.Mutex
+	healthCheckCancel context.CancelFunc
 }
 
 const RandomPort = 0
@@ -76,6 +84,7 @@ func New(ctx context.Context, dataDir, serviceName, defaultServerURL string, lbS
 	}
 
 	lb := &LoadBalancer{
+		ctx:          ctx,
 		serviceName:  serviceName,
 		configFile:   filepath.Join(dataDir, "etc", serviceName+".json"),
 		scheme:       serverURL.Scheme,
@@ -106,6 +115,8 @@ func New(ctx context.Context, dataDir, serviceName, defaultServerURL string, lbS
 		},
 	})
 
+	lb.SetStrategy(StrategyFailover)
+
 	if err := lb.updateConfig(); err != nil {
 		return nil, err
 	}
@@ -115,6 +126,7 @@ func New(ctx context.Context, dataDir, serviceName, defaultServerURL string, lbS
 	logrus.Infof("Running load balancer %s %s -> %v [default: %s]", serviceName, lb.localAddress, lb.servers.getAddresses(), lb.servers.getDefaultAddress())
 
 	go lb.servers.runHealthChecks(ctx, lb.serviceName)
+	lb.register()
 
 	return lb, nil
 }
@@ -150,6 +162,26 @@ func (lb *LoadBalancer) SetHealthCheck(address string, healthCheck HealthCheckFu
 	}
 }
 
+// SetStrategy sets the strategy used to select servers for new connections.
+// Existing connections are not affected.
+func (lb *LoadBalancer) SetStrategy(strategy Strategy) {
+	if lb.servers.getStrategy() != strategy {
+		logrus.Infof("Updated load balancer %s strategy: %s", lb.serviceName, strategy)
+	}
+	lb.servers.setStrategy(strategy)
+	loadbalancerStrategy.DeletePartialMatch(prometheus.Labels{"name": lb.serviceName})
+	loadbalancerStrategy.WithLabelValues(lb.serviceName, strategy.String()).Set(1)
+}
+
+// ObserveLatency records a latency sample for the server with the given address, such as the
+// time taken to complete a health check. Samples are used by the EWMA strategy to prefer
+// servers with lower latency.
+func (lb *LoadBalancer) ObserveLatency(address string, latency time.Duration) {
+	if s := lb.servers.getServer(address); s != nil {
+		s.observeLatency(latency)
+	}
+}
+
 func (lb *LoadBalancer) LocalURL() string {
 	return lb.scheme + "://" + lb.localAddress
 }
@@ -316,6 +316,62 @@ var _ = Describe("LoadBalancer", func() {
 		})
 	})
 
+	// confirms that the round-robin strategy spreads connections across all healthy
+	// servers, instead of sending them all to a single active server.
+	When("loadbalancer uses the round-robin strategy", Ordered, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		var node1Server, node2Server *testServer
+		var lb *LoadBalancer
+		var err error
+
+		BeforeAll(func() {
+			tmpDir := GinkgoT().TempDir()
+
+			node1Server, err = createServer(ctx, "node1")
+			Expect(err).NotTo(HaveOccurred(), "createServer(node1) failed")
+
+			node2Server, err = createServer(ctx, "node2")
+			Expect(err).NotTo(HaveOccurred(), "createServer(node2) failed")
+
+			lb, err = New(ctx, tmpDir, SupervisorServiceName, "http://"+node1Server.address, RandomPort, false)
+			Expect(err).NotTo(HaveOccurred(), "New() failed")
+			lb.SetStrategy(StrategyRoundRobin)
+		})
+
+		AfterAll(func() {
+			cancel()
+		})
+
+		It("balances connections across servers", func() {
+			lb.Update([]string{node1Server.address, node2Server.address})
+			lb.SetHealthCheck(node1Server.address, func() HealthCheckResult { return HealthCheckResultOK })
+			lb.SetHealthCheck(node2Server.address, func() HealthCheckResult { return HealthCheckResultOK })
+
+			// wait for both servers to pass health checks
+			Eventually(func() bool {
+				for _, s := range lb.servers.getServers() {
+					if s.state.tier() != 2 {
+						return false
+					}
+				}
+				return true
+			}, 5, 1).Should(BeTrue())
+
+			By(fmt.Sprintf("All servers healthy: %v", lb.servers.getServers()))
+
+			results := map[string]int{}
+			for range 4 {
+				conn, err := net.Dial("tcp", lb.localAddress)
+				Expect(err).NotTo(HaveOccurred())
+				result, err := ping(conn)
+				Expect(err).NotTo(HaveOccurred())
+				results[result]++
+				defer conn.Close()
+			}
+			Expect(results).To(Equal(map[string]int{"node1:ping": 2, "node2:ping": 2}))
+		})
+	})
+
 	// confirms that the loadbalancer will not dial itself
 	When("the default server is the loadbalancer", Ordered, func() {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -18,6 +18,16 @@ var (
 			"State is enum of 0=INVALID, 1=FAILED, 2=STANDBY, 3=UNCHECKED, 4=RECOVERING, 5=HEALTHY, 6=PREFERRED, 7=ACTIVE.",
 	}, []string{"name", "server"})
 
+	loadbalancerLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: version.Program + "_loadbalancer_server_latency_seconds",
+		Help: "Exponentially weighted moving average of loadbalancer backend server latency in seconds, as measured by health checks and dials, labeled by loadbalancer name and server address.",
+	}, []string{"name", "server"})
+
+	loadbalancerStrategy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: version.Program + "_loadbalancer_strategy",
+		Help: "Server selection strategy in use by the loadbalancer, labeled by loadbalancer name and strategy. Value is always 1.",
+	}, []string{"name", "strategy"})
+
 	loadbalancerDials = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    version.Program + "_loadbalancer_dial_duration_seconds",
 		Help:    "Time in seconds taken to dial a connection to a backend server, labeled by loadbalancer name and success/failure status.",
@@ -27,5 +37,5 @@ var (
 
 // MustRegister registers loadbalancer metrics
 func MustRegister(registerer prometheus.Registerer) {
-	registerer.MustRegister(loadbalancerConnections, loadbalancerState, loadbalancerDials)
+	registerer.MustRegister(loadbalancerConnections, loadbalancerState, loadbalancerLatency, loadbalancerStrategy, loadbalancerDials)
 }
@@ -8,6 +8,7 @@ import (
 	"net"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -31,8 +32,30 @@ const (
 // serverList tracks potential backend servers for use by a loadbalancer.
 type serverList struct {
 	// This mutex protects access to the server list. All direct access to the list should be protected by it.
-	mutex   sync.Mutex
-	servers []*server
+	mutex    sync.Mutex
+	servers  []*server
+	strategy Strategy
+	// next is the round-robin counter, incremented each time a connection is dialed.
+	next atomic.Uint64
+}
+
+// setStrategy sets the strategy used to select servers when dialing new connections.
+func (sl *serverList) setStrategy(strategy Strategy) {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	sl.strategy = strategy
+}
+
+// getStrategy returns the strategy used to select servers when dialing new connections.
+func (sl *serverList) getStrategy() Strategy {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	if sl.strategy == "" {
+		return StrategyFailover
+	}
+	return sl.strategy
 }
 
 // setServers updates the server list to contain only the selected addresses.
@@ -58,6 +81,7 @@ func (sl *serverList) setAddresses(serviceName string, addresses []string) bool
 			// make default server go through the same health check promotions as a new server when added
 			logrus.Infof("Server %s->%s from add to load balancer %s", defaultServer, stateUnchecked, serviceName)
 			defaultServer.state = stateUnchecked
+			defaultServer.lastReason = reasonUpdate
 			defaultServer.lastTransition = time.Now()
 		} else {
 			s := newServer(addedAddress, false)
@@ -82,6 +106,7 @@ func (sl *serverList) setAddresses(serviceName string, addresses []string) bool
 					// remove metrics
 					loadbalancerState.DeleteLabelValues(serviceName, s.address)
 					loadbalancerConnections.DeleteLabelValues(serviceName, s.address)
+					loadbalancerLatency.DeleteLabelValues(serviceName, s.address)
 					return true
 				}
 				return false
@@ -240,8 +265,9 @@ func (sl *serverList) recordSuccess(srv *server, r reason) {
 	sl.mutex.Lock()
 	defer sl.mutex.Unlock()
 
-	// handle states of other servers when attempting to make this one active
-	if new_state == stateActive {
+	// handle states of other servers when attempting to make this one active. Balanced
+	// strategies allow multiple servers to be active at once, so other servers are left alone.
+	if new_state == stateActive && !sl.strategy.balanced() {
 		for _, s := range sl.servers {
 			if srv.address == s.address {
 				continue
@@ -272,6 +298,7 @@ func (sl *serverList) recordSuccess(srv *server, r reason) {
 
 	logrus.Infof("Server %s->%s from successful %s", srv, new_state, r)
 	srv.state = new_state
+	srv.lastReason = r
 	srv.lastTransition = time.Now()
 
 	slices.SortFunc(sl.servers, compareServers)
@@ -283,10 +310,10 @@ func (sl *serverList) recordFailure(srv *server, r reason) {
 	var new_state state
 	switch srv.state {
 	case stateUnchecked, stateRecovering:
-		if r == reasonDial {
-			// only demote from unchecked or recovering if a dial fails, health checks may
-			// continue to fail despite it being dialable. just leave it where it is
-			// and don't close any connections.
+		if r == reasonDial || r == reasonAdmin {
+			// only demote from unchecked or recovering if a dial fails or failover is requested,
+			// health checks may continue to fail despite it being dialable. just leave it where
+			// it is and don't close any connections.
 			new_state = stateFailed
 		}
 	case stateHealthy, statePreferred, stateActive:
@@ -312,6 +339,7 @@ func (sl *serverList) recordFailure(srv *server, r reason) {
 
 	logrus.Infof("Server %s->%s from failed %s", srv, new_state, r)
 	srv.state = new_state
+	srv.lastReason = r
 	srv.lastTransition = time.Now()
 
 	slices.SortFunc(sl.servers, compareServers)
@@ -361,6 +389,8 @@ type reason int
 const (
 	reasonDial reason = iota
 	reasonHealthCheck
+	reasonAdmin
+	reasonUpdate
 )
 
 func (r reason) String() string {
@@ -369,6 +399,10 @@ func (r reason) String() string {
 		return "dial"
 	case reasonHealthCheck:
 		return "health check"
+	case reasonAdmin:
+		return "admin request"
+	case reasonUpdate:
+		return "server list update"
 	default:
 		return "unknown reason"
 	}
@@ -381,9 +415,13 @@ type server struct {
 	address        string
 	isDefault      bool
 	state          state
+	lastReason     reason
 	lastTransition time.Time
 	healthCheck    HealthCheckFunc
 	connections    map[net.Conn]struct{}
+	latency        time.Duration
+	probeStatus    probeStatus
+	drained        bool
 }
 
 // newServer creates a new server, with a default health check
@@ -398,6 +436,7 @@ func newServer(address string, isDefault bool) *server {
 		address:        address,
 		isDefault:      isDefault,
 		state:          state,
+		lastReason:     reasonUpdate,
 		lastTransition: time.Now(),
 		healthCheck:    func() HealthCheckResult { return HealthCheckResultUnknown },
 		connections:    make(map[net.Conn]struct{}),
@@ -466,7 +505,7 @@ func (sc *serverConn) Close() error {
 func (sl *serverList) runHealthChecks(ctx context.Context, serviceName string) {
 	wait.Until(func() {
 		for _, s := range sl.getServers() {
-			switch s.healthCheck() {
+			switch s.checkHealth() {
 			case HealthCheckResultOK:
 				sl.recordSuccess(s, reasonHealthCheck)
 			case HealthCheckResultFailed:
@@ -475,19 +514,25 @@ func (sl *serverList) runHealthChecks(ctx context.Context, serviceName string) {
 			if s.state != stateInvalid {
 				loadbalancerState.WithLabelValues(serviceName, s.address).Set(float64(s.state))
 				loadbalancerConnections.WithLabelValues(serviceName, s.address).Set(float64(len(s.connections)))
+				loadbalancerLatency.WithLabelValues(serviceName, s.address).Set(s.getLatency().Seconds())
 			}
 		}
 	}, time.Second, ctx.Done())
 	logrus.Debugf("Stopped health checking for load balancer %s", serviceName)
 }
 
-// dialContext attemps to dial a connection to a server from the server list.
-// Success or failure is recorded to ensure that server state is updated appropriately.
+// dialContext attemps to dial a connection to a server from the server list, in the order selected
+// by the load balancer strategy. Drained servers are only dialed if all other servers fail. Success or
+// failure is recorded to ensure that server state is updated appropriately, and the time taken to connect
+// is recorded as a latency sample for the server.
 func (sl *serverList) dialContext(ctx context.Context, network, _ string) (net.Conn, error) {
-	for _, s := range sl.getServers() {
+	servers := sl.dialOrder(sl.getServers())
+	slices.SortStableFunc(servers, compareDrained)
+	for _, s := range servers {
 		dialTime := time.Now()
 		conn, err := s.dialContext(ctx, network)
 		if err == nil {
+			s.observeLatency(time.Now().Sub(dialTime))
 			sl.recordSuccess(s, reasonDial)
 			return conn, nil
 		}
@@ -506,3 +551,17 @@ func compareServers(a, b *server) int {
 	}
 	return c
 }
+
+// compareDrained is a comparison function that can be used to sort the server list
+// so that drained servers are ordered last.
+func compareDrained(a, b *server) int {
+	aDrained, bDrained := a.isDrained(), b.isDrained()
+	switch {
+	case aDrained == bDrained:
+		return 0
+	case aDrained:
+		return 1
+	default:
+		return -1
+	}
+}
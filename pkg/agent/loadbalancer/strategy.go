@@ -0,0 +1,129 @@
+package loadbalancer
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Strategy selects the order in which servers are dialed when making a new connection.
+type Strategy string
+
+const (
+	// StrategyFailover sends all connections to a single active server, and only fails
+	// over to another server when a connection attempt to the active server fails.
+	StrategyFailover Strategy = "failover"
+	// StrategyRoundRobin spreads connections across servers in turn.
+	StrategyRoundRobin Strategy = "round-robin"
+	// StrategyLeastConnections sends connections to the server with the fewest open connections.
+	StrategyLeastConnections Strategy = "least-connections"
+	// StrategyEWMA sends connections to the server with the lowest exponentially weighted moving
+	// average latency, as measured by health checks and connection attempts.
+	StrategyEWMA Strategy = "ewma"
+)
+
+// ewmaWeight is the weight given to each new latency sample when updating a server's moving average.
+const ewmaWeight = 0.3
+
+// Strategies lists all supported load balancer strategies.
+var Strategies = []Strategy{StrategyFailover, StrategyRoundRobin, StrategyLeastConnections, StrategyEWMA}
+
+// ParseStrategy returns the strategy with the given name. An empty
+// name selects the default failover strategy.
+func ParseStrategy(name string) (Strategy, error) {
+	if name == "" {
+		return StrategyFailover, nil
+	}
+	if s := Strategy(strings.ToLower(name)); slices.Contains(Strategies, s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid load balancer strategy %q: must be one of %v", name, Strategies)
+}
+
+func (s Strategy) String() string {
+	return string(s)
+}
+
+// balanced returns true if the strategy spreads connections across multiple servers,
+// instead of sending them all to a single active server.
+func (s Strategy) balanced() bool {
+	return s != "" && s != StrategyFailover
+}
+
+// tier groups server states into those that are known to be usable, those that have not
+// yet been confirmed usable, and those that should only be used as a last resort.
+func (s state) tier() int {
+	switch s {
+	case stateActive, statePreferred, stateHealthy:
+		return 2
+	case stateRecovering, stateUnchecked:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// dialOrder returns the servers in the order that they should be dialed. The provided list is
+// expected to be sorted by compareServers. For balanced strategies, the servers in the most
+// preferred tier are reordered according to the strategy; all other servers remain in their
+// existing order so that they are still tried if all of the preferred servers fail.
+func (sl *serverList) dialOrder(servers []*server) []*server {
+	strategy := sl.getStrategy()
+	if !strategy.balanced() || len(servers) < 2 {
+		return servers
+	}
+
+	n := slices.IndexFunc(servers, func(s *server) bool { return s.state.tier() != servers[0].state.tier() })
+	if n == -1 {
+		n = len(servers)
+	}
+	candidates := servers[:n]
+
+	switch strategy {
+	case StrategyRoundRobin:
+		i := int(sl.next.Add(1)-1) % len(candidates)
+		candidates = append(slices.Clone(candidates[i:]), candidates[:i]...)
+	case StrategyLeastConnections:
+		candidates = slices.Clone(candidates)
+		slices.SortStableFunc(candidates, func(a, b *server) int {
+			return cmp.Compare(a.connectionCount(), b.connectionCount())
+		})
+	case StrategyEWMA:
+		// servers without any latency samples are ordered first, so that they are measured
+		candidates = slices.Clone(candidates)
+		slices.SortStableFunc(candidates, func(a, b *server) int {
+			return cmp.Compare(a.getLatency(), b.getLatency())
+		})
+	}
+	return append(candidates, servers[n:]...)
+}
+
+// observeLatency updates the server's moving average latency with a new sample.
+func (s *server) observeLatency(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.latency == 0 {
+		s.latency = d
+	} else {
+		s.latency = time.Duration(ewmaWeight*float64(d) + (1-ewmaWeight)*float64(s.latency))
+	}
+}
+
+// getLatency returns the server's moving average latency, or zero if it has not been measured.
+func (s *server) getLatency() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.latency
+}
+
+// connectionCount returns the number of open connections to the server.
+func (s *server) connectionCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.connections)
+}
@@ -0,0 +1,96 @@
+package loadbalancer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_UnitParseStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Strategy
+		wantErr bool
+	}{
+		{name: "", want: StrategyFailover},
+		{name: "failover", want: StrategyFailover},
+		{name: "Round-Robin", want: StrategyRoundRobin},
+		{name: "least-connections", want: StrategyLeastConnections},
+		{name: "ewma", want: StrategyEWMA},
+		{name: "random", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStrategy(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseStrategy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitDialOrder(t *testing.T) {
+	newTestServer := func(address string, state state, connections int, latency time.Duration) *server {
+		s := newServer(address, false)
+		s.state = state
+		s.latency = latency
+		for range connections {
+			s.connections[&serverConn{server: s}] = struct{}{}
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		dials    int
+		want     []string
+	}{
+		{
+			name:     "Failover",
+			strategy: StrategyFailover,
+			want:     []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "Round-robin",
+			strategy: StrategyRoundRobin,
+			dials:    2,
+			want:     []string{"c", "a", "b", "d"},
+		},
+		{
+			name:     "Least connections",
+			strategy: StrategyLeastConnections,
+			want:     []string{"c", "b", "a", "d"},
+		},
+		{
+			name:     "EWMA",
+			strategy: StrategyEWMA,
+			want:     []string{"b", "c", "a", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sl := &serverList{strategy: tt.strategy}
+			// the failed server is always ordered last, regardless of strategy
+			sl.servers = []*server{
+				newTestServer("a", stateActive, 3, 30*time.Millisecond),
+				newTestServer("b", statePreferred, 1, 10*time.Millisecond),
+				newTestServer("c", stateHealthy, 0, 20*time.Millisecond),
+				newTestServer("d", stateFailed, 0, 0),
+			}
+			for range tt.dials {
+				sl.dialOrder(sl.getServers())
+			}
+			got := []string{}
+			for _, s := range sl.dialOrder(sl.getServers()) {
+				got = append(got, s.address)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serverList.dialOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -23,16 +23,17 @@ type Proxy interface {
 	APIServerURL() string
 	IsAPIServerLBEnabled() bool
 	SetHealthCheck(address string, healthCheck loadbalancer.HealthCheckFunc)
+	SetActiveHealthCheck(config loadbalancer.ActiveHealthCheckConfig) error
 }
 
 // NewSupervisorProxy sets up a new proxy for retrieving supervisor and apiserver addresses.  If
 // lbEnabled is true, a load-balancer is started on the requested port to connect to the supervisor
 // address, and the address of this local load-balancer is returned instead of the actual supervisor
-// and apiserver addresses.
+// and apiserver addresses. The load-balancers select servers using the provided strategy.
 // NOTE: This is a proxy in the API sense - it returns either actual server URLs, or the URL of the
 // local load-balancer. It is not actually responsible for proxying requests at the network level;
 // this is handled by the load-balancers that the proxy optionally steers connections towards.
-func NewSupervisorProxy(ctx context.Context, lbEnabled bool, dataDir, supervisorURL string, lbServerPort int, isIPv6 bool) (Proxy, error) {
+func NewSupervisorProxy(ctx context.Context, lbEnabled bool, dataDir, supervisorURL string, lbServerPort int, lbStrategy loadbalancer.Strategy, isIPv6 bool) (Proxy, error) {
 	p := proxy{
 		lbEnabled:            lbEnabled,
 		dataDir:              dataDir,
@@ -40,6 +41,7 @@ func NewSupervisorProxy(ctx context.Context, lbEnabled bool, dataDir, supervisor
 		supervisorURL:        supervisorURL,
 		apiServerURL:         supervisorURL,
 		lbServerPort:         lbServerPort,
+		lbStrategy:           lbStrategy,
 		context:              ctx,
 	}
 
@@ -51,6 +53,7 @@ func NewSupervisorProxy(ctx context.Context, lbEnabled bool, dataDir, supervisor
 		if err != nil {
 			return nil, err
 		}
+		lb.SetStrategy(lbStrategy)
 		p.supervisorLB = lb
 		p.supervisorURL = lb.LocalURL()
 		p.apiServerURL = p.supervisorURL
@@ -71,6 +74,8 @@ type proxy struct {
 	dataDir          string
 	lbEnabled        bool
 	lbServerPort     int
+	lbStrategy       loadbalancer.Strategy
+	lbHealthCheck    loadbalancer.ActiveHealthCheckConfig
 	apiServerEnabled bool
 
 	apiServerURL              string
@@ -114,6 +119,39 @@ func (p *proxy) SetHealthCheck(address string, healthCheck loadbalancer.HealthCh
 	}
 }
 
+// SetActiveHealthCheck configures active health checks for the load-balancers. If the apiserver
+// has a separate load-balancer, the apiserver's readiness endpoint is checked by the apiserver
+// load-balancer, and the supervisor's ping endpoint is checked by the supervisor load-balancer.
+// Otherwise, the apiserver's readiness endpoint is checked via the supervisor load-balancer.
+func (p *proxy) SetActiveHealthCheck(config loadbalancer.ActiveHealthCheckConfig) error {
+	p.lbHealthCheck = config
+	return p.setActiveHealthChecks()
+}
+
+func (p *proxy) setActiveHealthChecks() error {
+	if !p.lbHealthCheck.Enabled() {
+		return nil
+	}
+	if p.supervisorLB != nil {
+		config := p.lbHealthCheck
+		config.Path = "/readyz"
+		if p.apiServerLB != nil {
+			config.Path = "/ping"
+		}
+		if err := p.supervisorLB.SetActiveHealthCheck(config); err != nil {
+			return err
+		}
+	}
+	if p.apiServerLB != nil {
+		config := p.lbHealthCheck
+		config.Path = "/readyz"
+		if err := p.apiServerLB.SetActiveHealthCheck(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *proxy) setSupervisorPort(addresses []string) []string {
 	var newAddresses []string
 	for _, address := range addresses {
@@ -154,7 +192,11 @@ func (p *proxy) SetAPIServerPort(port int, isIPv6 bool) error {
 		if err != nil {
 			return err
 		}
+		lb.SetStrategy(p.lbStrategy)
 		p.apiServerLB = lb
+		if err := p.setActiveHealthChecks(); err != nil {
+			return err
+		}
 		p.apiServerURL = lb.LocalURL()
 	} else {
 		p.apiServerURL = u.String()
@@ -16,6 +16,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/agent/config"
 	"github.com/k3s-io/k3s/pkg/agent/containerd"
 	"github.com/k3s-io/k3s/pkg/agent/flannel"
+	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
 	"github.com/k3s-io/k3s/pkg/agent/netpol"
 	"github.com/k3s-io/k3s/pkg/agent/proxy"
 	"github.com/k3s-io/k3s/pkg/agent/syssetup"
@@ -61,6 +62,18 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		return pkgerrors.WithMessage(err, "failed to retrieve agent configuration")
 	}
 
+	if err := proxy.SetActiveHealthCheck(loadbalancer.ActiveHealthCheckConfig{
+		Interval:           cfg.LBHealthCheckInterval,
+		Timeout:            cfg.LBHealthCheckTimeout,
+		HealthyThreshold:   cfg.LBHealthyThreshold,
+		UnhealthyThreshold: cfg.LBUnhealthyThreshold,
+		CAFile:             filepath.Join(cfg.DataDir, "agent", "server-ca.crt"),
+		CertFile:           nodeConfig.AgentConfig.ClientKubeletCert,
+		KeyFile:            nodeConfig.AgentConfig.ClientKubeletKey,
+	}); err != nil {
+		return pkgerrors.WithMessage(err, "failed to configure load balancer health checks")
+	}
+
 	dualCluster, err := utilsnet.IsDualStackCIDRs(nodeConfig.AgentConfig.ClusterCIDRs)
 	if err != nil {
 		return pkgerrors.WithMessage(err, "failed to validate cluster-cidr")
@@ -158,6 +171,9 @@ func run(ctx context.Context, cfg cmds.Agent, proxy proxy.Proxy) error {
 		if err := executor.Containerd(ctx, nodeConfig); err != nil {
 			return err
 		}
+		if err := containerd.WatchRegistries(ctx, nodeConfig, config.LoadRegistries(nodeConfig, proxy)); err != nil {
+			return err
+		}
 	} else {
 		if err := executor.CRI(ctx, nodeConfig); err != nil {
 			return err
@@ -287,7 +303,7 @@ func RunStandalone(ctx context.Context, cfg cmds.Agent) error {
 	if err := tunnelSetup(ctx, nodeConfig, cfg, proxy); err != nil {
 		return err
 	}
-	if err := certMonitorSetup(ctx, nodeConfig, cfg); err != nil {
+	if err := certMonitorSetup(ctx, nodeConfig, cfg, proxy); err != nil {
 		return err
 	}
 
@@ -341,11 +357,20 @@ func createProxyAndValidateToken(ctx context.Context, cfg *cmds.Agent) (proxy.Pr
 	}
 	isIPv6 := utilsnet.IsIPv6(net.ParseIP(util.GetFirstValidIPString(cfg.NodeIP.Value())))
 
-	proxy, err := proxy.NewSupervisorProxy(ctx, !cfg.DisableLoadBalancer, agentDir, cfg.ServerURL, cfg.LBServerPort, isIPv6)
+	lbStrategy, err := loadbalancer.ParseStrategy(cfg.LBServerStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxy.NewSupervisorProxy(ctx, !cfg.DisableLoadBalancer, agentDir, cfg.ServerURL, cfg.LBServerPort, lbStrategy, isIPv6)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := loadbalancer.ListenAndServeAdmin(ctx, filepath.Join(agentDir, loadbalancer.AdminSocketName)); err != nil {
+		logrus.Warnf("Failed to serve load balancer status: %v", err)
+	}
+
 	options := []clientaccess.ValidationOption{
 		clientaccess.WithUser("node"),
 		clientaccess.WithClientCertificate(clientKubeletCert, clientKubeletKey),
@@ -526,7 +551,7 @@ func setupTunnelAndRunAgent(ctx context.Context, nodeConfig *daemonconfig.Node,
 		return err
 	}
 
-	if err := certMonitorSetup(ctx, nodeConfig, cfg); err != nil {
+	if err := certMonitorSetup(ctx, nodeConfig, cfg, proxy); err != nil {
 		return err
 	}
 
@@ -582,11 +607,13 @@ func tunnelSetup(ctx context.Context, nodeConfig *daemonconfig.Node, cfg cmds.Ag
 	return tunnel.Setup(ctx, nodeConfig, proxy)
 }
 
-func certMonitorSetup(ctx context.Context, nodeConfig *daemonconfig.Node, cfg cmds.Agent) error {
+func certMonitorSetup(ctx context.Context, nodeConfig *daemonconfig.Node, cfg cmds.Agent, proxy proxy.Proxy) error {
 	if cfg.ClusterReset {
 		return nil
 	}
-	return certmonitor.Setup(ctx, nodeConfig, cfg.DataDir)
+	return certmonitor.Setup(ctx, nodeConfig, cfg.DataDir, func() ([]string, error) {
+		return config.RenewCerts(nodeConfig, cfg, proxy)
+	})
 }
 
 // getHostname returns the actual system hostname.
@@ -11,6 +11,7 @@ import (
 	"github.com/rancher/wharfie/pkg/registries"
 
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
 	"github.com/k3s-io/k3s/pkg/version"
 )
 
@@ -27,7 +28,7 @@ type ContainerdConfig struct {
 	EnableUnprivileged    bool
 	NoDefaultEndpoint     bool
 	NonrootDevices        bool
-	PrivateRegistryConfig *registries.Registry
+	PrivateRegistryConfig *registryconfig.Registry
 	ExtraRuntimes         map[string]ContainerdRuntimeConfig
 	Program               string
 }
@@ -36,6 +37,7 @@ type RegistryEndpoint struct {
 	OverridePath bool
 	URL          *url.URL
 	Rewrites     map[string]string
+	Headers      map[string]string
 	Config       registries.RegistryConfig
 }
 
@@ -297,6 +299,12 @@ client = [[{{ printf "%q" $e.Config.TLS.CertFile }}, {{ printf "%q" $e.Config.TL
 skip_verify = true
 {{- end }}
 {{ end }}
+{{- if $e.Headers }}
+[header]
+{{- range $name, $value := $e.Headers }}
+  {{ printf "%q" $name }} = {{ printf "%q" $value }}
+{{- end }}
+{{ end }}
 {{ end }}
 [host]
 {{ range $e := .Endpoints -}}
@@ -316,6 +324,12 @@ skip_verify = true
   skip_verify = true
   {{- end }}
 {{ end }}
+{{- if $e.Headers }}
+  [host."{{ $e.URL }}".header]
+  {{- range $name, $value := $e.Headers }}
+    {{ printf "%q" $name }} = {{ printf "%q" $value }}
+  {{- end }}
+{{ end }}
 {{- if $e.Rewrites }}
   [host."{{ $e.URL }}".rewrite]
   {{- range $pattern, $replace := $e.Rewrites }}
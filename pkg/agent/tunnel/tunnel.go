@@ -552,7 +552,7 @@ func getAPIServersRequester(node *daemonconfig.Node, proxy proxy.Proxy, syncProx
 		if info == nil {
 			var err error
 			withCert := clientaccess.WithClientCertificate(node.AgentConfig.ClientKubeletCert, node.AgentConfig.ClientKubeletKey)
-			info, err = clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert)
+			info, err = clientaccess.ParseAndValidateToken(proxy.SupervisorURL(), node.Token, withCert, clientaccess.WithNodeName(node.AgentConfig.NodeName))
 			if err != nil {
 				logrus.Warnf("Failed to validate server token: %v", err)
 				return
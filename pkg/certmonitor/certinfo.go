@@ -0,0 +1,111 @@
+package certmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/k3s-io/k3s/pkg/agent/https"
+	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/services"
+	"github.com/k3s-io/k3s/pkg/version"
+	certutil "github.com/rancher/dynamiclistener/cert"
+	"github.com/sirupsen/logrus"
+)
+
+// CertInfoPath is the path at which each node serves information about its own certificates.
+var CertInfoPath = "/v1-" + version.Program + "/cert/info"
+
+// Router will be called to add the certificate info API handler to an existing router.
+// If it returns a nil router, the handler is not registered.
+var Router https.RouterFunc = func(context.Context, *daemonconfig.Node) (*mux.Router, error) {
+	return nil, errors.New("not implemented")
+}
+
+// Certificate defines a single certificate data structure
+type Certificate struct {
+	NodeName     string `json:",omitempty" yaml:",omitempty"`
+	Filename     string
+	Subject      string
+	Issuer       string
+	Usages       []string
+	ExpiryTime   time.Time
+	ResidualTime time.Duration
+	Status       string // "OK", "WARNING", "EXPIRED", "NOT YET VALID"
+}
+
+// CertificateInfo defines the structure for storing certificate information
+type CertificateInfo struct {
+	Certificates  []Certificate
+	Nodes         []NodeStatus `json:",omitempty" yaml:",omitempty"`
+	ReferenceTime time.Time    `json:"-" yaml:"-"`
+}
+
+// NodeStatus summarizes the status of the certificates on a single node, when certificate
+// information has been collected from multiple nodes.
+type NodeStatus struct {
+	NodeName string
+	Status   string // the least healthy certificate status, or "UNKNOWN" if the node could not be checked
+	Error    string `json:",omitempty" yaml:",omitempty"`
+}
+
+// CollectCertInfo collects information about certificates
+func CollectCertInfo(controlConfig daemonconfig.Control, servicesList []string) (*CertificateInfo, error) {
+	result := &CertificateInfo{}
+	now := time.Now()
+	warn := now.Add(time.Hour * 24 * daemonconfig.CertificateRenewDays)
+
+	fileMap, err := services.FilesForServices(controlConfig, servicesList)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, files := range fileMap {
+		for _, file := range files {
+			certs, err := certutil.CertsFromFile(file)
+			if err != nil {
+				logrus.Debugf("%v", err)
+				continue
+			}
+
+			for _, cert := range certs {
+
+				expiration := cert.NotAfter
+				status := util.GetCertStatus(cert, now, warn)
+				if status == util.CertStatusNotYetValid {
+					expiration = cert.NotBefore
+				}
+				usages := util.GetCertUsages(cert)
+				result.Certificates = append(result.Certificates, Certificate{
+					Filename:     filepath.Base(file),
+					Subject:      cert.Subject.CommonName,
+					Issuer:       cert.Issuer.CommonName,
+					Usages:       usages,
+					ExpiryTime:   expiration,
+					ResidualTime: cert.NotAfter.Sub(now),
+					Status:       status,
+				})
+			}
+		}
+	}
+	result.ReferenceTime = now
+	return result, nil
+}
+
+// certInfoHandler returns a handler that responds with information about the certificates on this node.
+func certInfoHandler(controlConfig daemonconfig.Control, servicesList []string) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		certInfo, err := CollectCertInfo(controlConfig, servicesList)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusInternalServerError)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(certInfo)
+	}
+}
@@ -3,6 +3,7 @@ package certmonitor
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,6 +24,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 )
 
 var (
@@ -39,8 +41,13 @@ var (
 	}, []string{"subject", "usages"})
 )
 
-// Setup starts the certificate expiration monitor
-func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string) error {
+// RenewFunc renews any certificates that are due for renewal, and returns the paths of the
+// certificates that were renewed.
+type RenewFunc func() ([]string, error)
+
+// Setup starts the certificate expiration monitor. If a renew function is provided, it is
+// called to renew node certificates before each expiration check.
+func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string, renew RenewFunc) error {
 	logrus.Debugf("Starting %s with monitoring period %s", controllerName, certCheckInterval)
 	metrics.DefaultRegisterer.MustRegister(certificateExpirationSeconds)
 
@@ -51,14 +58,7 @@ func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string) e
 
 	recorder := util.BuildControllerEventRecorder(client, controllerName, metav1.NamespaceDefault)
 
-	// This is consistent with events attached to the node generated by the kubelet
-	// https://github.com/kubernetes/kubernetes/blob/612130dd2f4188db839ea5c2dea07a96b0ad8d1c/pkg/kubelet/kubelet.go#L479-L485
-	nodeRef := &corev1.ObjectReference{
-		Kind:      "Node",
-		Name:      nodeConfig.AgentConfig.NodeName,
-		UID:       types.UID(nodeConfig.AgentConfig.NodeName),
-		Namespace: "",
-	}
+	nodeRef := getNodeRef(nodeConfig.AgentConfig.NodeName)
 
 	// Create a dummy controlConfig just to hold the paths for the server certs
 	controlConfig := daemonconfig.Control{
@@ -83,11 +83,22 @@ func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string) e
 		return err
 	}
 
+	// Serve information about the certificates on this node, so that certificates can be checked across the cluster.
+	if router, err := Router(ctx, nodeConfig); err != nil {
+		logrus.Warnf("Failed to register certificate info handler: %v", err)
+	} else if router != nil {
+		router.Handle(CertInfoPath, certInfoHandler(controlConfig, nodeList)).Methods(http.MethodGet)
+	}
+
 	go wait.Until(func() {
+		if renew != nil {
+			renewCerts(recorder, nodeRef, renew)
+		}
+
 		logrus.Debugf("Running %s certificate expiration check", controllerName)
 		var hasErr bool
 		if err := checkCerts(nodeMap, time.Hour*24*daemonconfig.CertificateRenewDays); err != nil {
-			message := fmt.Sprintf("Node certificates require attention - restart %s on this node to trigger automatic rotation: %v", version.Program, err)
+			message := fmt.Sprintf("Node certificates require attention - check %s logs for renewal errors, or restart %s on this node to trigger rotation: %v", version.Program, version.Program, err)
 			recorder.Event(nodeRef, corev1.EventTypeWarning, "CertificateExpirationWarning", message)
 			hasErr = true
 		}
@@ -109,6 +120,44 @@ func Setup(ctx context.Context, nodeConfig *daemonconfig.Node, dataDir string) e
 	return nil
 }
 
+// StartRenewer periodically calls the renew function to renew certificates before they expire,
+// recording events on the node when certificates are renewed or renewal fails. This is used by
+// servers to renew the control-plane certificates, which are not managed by the agent.
+func StartRenewer(ctx context.Context, recorder record.EventRecorder, nodeName string, renew RenewFunc) {
+	nodeRef := getNodeRef(nodeName)
+	go wait.Until(func() {
+		renewCerts(recorder, nodeRef, renew)
+	}, certCheckInterval, ctx.Done())
+}
+
+// getNodeRef returns a reference to the node, for use when recording events.
+// This is consistent with events attached to the node generated by the kubelet
+// https://github.com/kubernetes/kubernetes/blob/612130dd2f4188db839ea5c2dea07a96b0ad8d1c/pkg/kubelet/kubelet.go#L479-L485
+func getNodeRef(nodeName string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:      "Node",
+		Name:      nodeName,
+		UID:       types.UID(nodeName),
+		Namespace: "",
+	}
+}
+
+// renewCerts calls the renew function, and records an event if any certificates were renewed, or if renewal failed.
+func renewCerts(recorder record.EventRecorder, nodeRef *corev1.ObjectReference, renew RenewFunc) {
+	logrus.Debugf("Running %s certificate renewal", controllerName)
+	renewed, err := renew()
+	if len(renewed) > 0 {
+		message := fmt.Sprintf("Renewed certificates managed by %s: %s", version.Program, strings.Join(renewed, ", "))
+		logrus.Info(message)
+		recorder.Event(nodeRef, corev1.EventTypeNormal, "CertificateRenewed", message)
+	}
+	if err != nil {
+		message := fmt.Sprintf("Failed to renew certificates managed by %s: %v", version.Program, err)
+		logrus.Error(message)
+		recorder.Event(nodeRef, corev1.EventTypeWarning, "CertificateRenewalFailed", message)
+	}
+}
+
 func checkCerts(fileMap map[string][]string, warningPeriod time.Duration) error {
 	errs := merr.Errors{}
 	now := time.Now()
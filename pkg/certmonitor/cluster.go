@@ -0,0 +1,158 @@
+package certmonitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/clientaccess"
+	daemonconfig "github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NodeStatusUnknown is the status of a node whose certificates could not be checked.
+	NodeStatusUnknown = "UNKNOWN"
+	// clusterCheckConcurrency is the number of nodes that are checked in parallel.
+	clusterCheckConcurrency = 20
+)
+
+// statusSeverity orders certificate statuses from healthy to unhealthy, for sorting nodes in the cluster report.
+var statusSeverity = map[string]int{
+	util.CertStatusOK:          0,
+	util.CertStatusWarning:     1,
+	util.CertStatusNotYetValid: 2,
+	util.CertStatusExpired:     3,
+	NodeStatusUnknown:          4,
+}
+
+// CollectClusterCertInfo collects information about certificates from all nodes in the cluster.
+// Each node is asked for information about its own certificates, using the admin client certificate
+// to authenticate to the supervisor listener on the given port.
+func CollectClusterCertInfo(ctx context.Context, controlConfig *daemonconfig.Control, port int) (*CertificateInfo, error) {
+	runtime := controlConfig.Runtime
+	if _, err := os.Stat(runtime.KubeConfigAdmin); err != nil {
+		return nil, pkgerrors.WithMessage(err, "cluster certificate check must be run on a server")
+	}
+
+	caCerts, err := os.ReadFile(runtime.ServerCA)
+	if err != nil {
+		return nil, err
+	}
+
+	k8s, err := util.GetClientSet(runtime.KubeConfigAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeList, err := k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, pkgerrors.WithMessage(err, "failed to list nodes")
+	}
+
+	logrus.Infof("Checking certificates on %d nodes", len(nodeList.Items))
+
+	results := make([]*CertificateInfo, len(nodeList.Items))
+	errs := make([]error, len(nodeList.Items))
+	sem := make(chan struct{}, clusterCheckConcurrency)
+	wg := sync.WaitGroup{}
+	for i, node := range nodeList.Items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = getNodeCertInfo(&node, port, caCerts, runtime.ClientAdminCert, runtime.ClientAdminKey)
+		}()
+	}
+	wg.Wait()
+
+	result := &CertificateInfo{ReferenceTime: time.Now()}
+	for i, node := range nodeList.Items {
+		nodeStatus := NodeStatus{NodeName: node.Name}
+		if errs[i] != nil {
+			nodeStatus.Status = NodeStatusUnknown
+			nodeStatus.Error = errs[i].Error()
+		} else {
+			nodeStatus.Status = util.CertStatusOK
+			for _, cert := range results[i].Certificates {
+				cert.NodeName = node.Name
+				result.Certificates = append(result.Certificates, cert)
+				if statusSeverity[cert.Status] > statusSeverity[nodeStatus.Status] {
+					nodeStatus.Status = cert.Status
+				}
+			}
+		}
+		result.Nodes = append(result.Nodes, nodeStatus)
+	}
+	sortNodes(result)
+
+	return result, nil
+}
+
+// sortNodes sorts the nodes in the cluster report so that nodes with unhealthy certificates are listed first,
+// and sorts certificates to match.
+func sortNodes(certInfo *CertificateInfo) {
+	slices.SortStableFunc(certInfo.Nodes, func(a, b NodeStatus) int {
+		if severity := statusSeverity[b.Status] - statusSeverity[a.Status]; severity != 0 {
+			return severity
+		}
+		return strings.Compare(a.NodeName, b.NodeName)
+	})
+	nodeOrder := map[string]int{}
+	for i, node := range certInfo.Nodes {
+		nodeOrder[node.NodeName] = i
+	}
+	slices.SortStableFunc(certInfo.Certificates, func(a, b Certificate) int {
+		return nodeOrder[a.NodeName] - nodeOrder[b.NodeName]
+	})
+}
+
+// getNodeCertInfo retrieves information about the certificates on a node, from the supervisor listener on the node.
+func getNodeCertInfo(node *corev1.Node, port int, caCerts []byte, certFile, keyFile string) (*CertificateInfo, error) {
+	address := getNodeAddress(node)
+	if address == "" {
+		return nil, errors.New("node does not have an internal or external address")
+	}
+
+	info := &clientaccess.Info{
+		BaseURL:  "https://" + net.JoinHostPort(address, strconv.Itoa(port)),
+		CACerts:  caCerts,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+	body, err := info.Get(CertInfoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certInfo := &CertificateInfo{}
+	if err := json.Unmarshal(body, certInfo); err != nil {
+		return nil, err
+	}
+	return certInfo, nil
+}
+
+// getNodeAddress returns the first internal address of the node, or the first external address
+// if the node does not have an internal address.
+func getNodeAddress(node *corev1.Node) string {
+	for _, addressType := range []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP} {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType {
+				return address.Address
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package certmonitor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_UnitSortNodes(t *testing.T) {
+	certInfo := &CertificateInfo{
+		Certificates: []Certificate{
+			{NodeName: "agent-1", Filename: "client-kubelet.crt", Status: util.CertStatusOK},
+			{NodeName: "agent-2", Filename: "client-kubelet.crt", Status: util.CertStatusOK},
+			{NodeName: "agent-2", Filename: "serving-kubelet.crt", Status: util.CertStatusWarning},
+			{NodeName: "server-1", Filename: "client-admin.crt", Status: util.CertStatusExpired},
+			{NodeName: "agent-3", Filename: "client-kubelet.crt", Status: util.CertStatusOK},
+		},
+		Nodes: []NodeStatus{
+			{NodeName: "agent-1", Status: util.CertStatusOK},
+			{NodeName: "agent-2", Status: util.CertStatusWarning},
+			{NodeName: "agent-4", Status: NodeStatusUnknown, Error: "connection refused"},
+			{NodeName: "server-1", Status: util.CertStatusExpired},
+			{NodeName: "agent-3", Status: util.CertStatusOK},
+		},
+	}
+
+	sortNodes(certInfo)
+
+	nodes := []string{}
+	for _, node := range certInfo.Nodes {
+		nodes = append(nodes, node.NodeName)
+	}
+	if want := []string{"agent-4", "server-1", "agent-2", "agent-1", "agent-3"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("sortNodes() nodes = %v, want %v", nodes, want)
+	}
+
+	certs := []string{}
+	for _, cert := range certInfo.Certificates {
+		certs = append(certs, cert.NodeName+"/"+cert.Filename)
+	}
+	want := []string{"server-1/client-admin.crt", "agent-2/client-kubelet.crt", "agent-2/serving-kubelet.crt", "agent-1/client-kubelet.crt", "agent-3/client-kubelet.crt"}
+	if !reflect.DeepEqual(certs, want) {
+		t.Errorf("sortNodes() certificates = %v, want %v", certs, want)
+	}
+}
+
+func Test_UnitGetNodeAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []corev1.NodeAddress
+		want      string
+	}{
+		{
+			name: "Internal and external addresses",
+			addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "node-1"},
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.10"},
+			},
+			want: "10.0.0.10",
+		},
+		{
+			name: "External address only",
+			addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "node-1"},
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.10"},
+			},
+			want: "203.0.113.10",
+		},
+		{
+			name:      "No addresses",
+			addresses: []corev1.NodeAddress{{Type: corev1.NodeHostName, Address: "node-1"}},
+			want:      "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{Status: corev1.NodeStatus{Addresses: tt.addresses}}
+			if got := getNodeAddress(node); got != tt.want {
+				t.Errorf("getNodeAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/k3s-io/k3s/pkg/agent"
 	"github.com/k3s-io/k3s/pkg/agent/https"
+	"github.com/k3s-io/k3s/pkg/certmonitor"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/datadir"
@@ -109,31 +110,39 @@ func Run(ctx *cli.Context) error {
 		}
 	}
 
+	setupRouters(cfg)
+
+	if err := agent.Run(contextCtx, cfg); err != nil {
+		return err
+	}
+
+	<-contextCtx.Done()
+	return contextCtx.Err()
+}
+
+// startRouter returns the router for the agent's supervisor listener, starting the listener the first time it is called.
+var startRouter https.RouterFunc = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
+	return https.Start(ctx, nodeConfig, nil)
+}
+
+// setupRouters configures the components that serve handlers on the agent's supervisor listener.
+func setupRouters(cfg cmds.Agent) {
 	// Until the agent is run and retrieves config from the server, we won't know
 	// if the embedded registry is enabled. If it is not enabled, these are not
 	// used as the registry is never started.
 	registry := spegel.DefaultRegistry
 	registry.Bootstrapper = spegel.NewAgentBootstrapper(cfg.ServerURL, cfg.Token, cfg.DataDir)
-	registry.Router = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
-		return https.Start(ctx, nodeConfig, nil)
-	}
+	registry.Router = startRouter
 
 	// same deal for metrics - these are not used if the extra metrics listener is not enabled.
 	metrics := k3smetrics.DefaultMetrics
-	metrics.Router = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
-		return https.Start(ctx, nodeConfig, nil)
-	}
+	metrics.Router = startRouter
 
 	// and for pprof as well
 	pprof := profile.DefaultProfiler
-	pprof.Router = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
-		return https.Start(ctx, nodeConfig, nil)
-	}
-
-	if err := agent.Run(contextCtx, cfg); err != nil {
-		return err
-	}
+	pprof.Router = startRouter
 
-	<-contextCtx.Done()
-	return contextCtx.Err()
+	// certificate info is always served, starting the listener if none of the above are enabled,
+	// so that the certificates on every agent can be checked across the cluster.
+	certmonitor.Router = startRouter
 }
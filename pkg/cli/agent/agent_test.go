@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/k3s-io/k3s/pkg/certmonitor"
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+)
+
+func Test_UnitSetupRouters(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodeConfig *config.Node
+	}{
+		{
+			name:       "Default agent",
+			nodeConfig: &config.Node{},
+		},
+		{
+			name:       "Agent with embedded registry",
+			nodeConfig: &config.Node{EmbeddedRegistry: true},
+		},
+		{
+			name:       "Agent with supervisor metrics and pprof",
+			nodeConfig: &config.Node{SupervisorMetrics: true, EnablePProf: true},
+		},
+	}
+
+	certInfoRouter := certmonitor.Router
+	defaultStartRouter := startRouter
+	t.Cleanup(func() {
+		certmonitor.Router = certInfoRouter
+		startRouter = defaultStartRouter
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			startRouter = func(context.Context, *config.Node) (*mux.Router, error) {
+				return router, nil
+			}
+			setupRouters(cmds.Agent{})
+
+			// Certificate info must be served on every agent, so that the agent is not reported as UNKNOWN
+			// when certificates are checked across the cluster.
+			got, err := certmonitor.Router(context.Background(), tt.nodeConfig)
+			if err != nil {
+				t.Fatalf("certmonitor.Router() error = %v", err)
+			}
+			if got != router {
+				t.Errorf("certmonitor.Router() = %v, want agent supervisor router", got)
+			}
+		})
+	}
+}
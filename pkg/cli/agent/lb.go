@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/datadir"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var lbTimeout = 30 * time.Second
+
+// lbClient returns a client for the load balancer admin socket in the agent data directory.
+func lbClient() (*loadbalancer.AdminClient, error) {
+	dataDir, err := datadir.LocalHome(cmds.AgentConfig.DataDir, cmds.AgentConfig.Rootless)
+	if err != nil {
+		return nil, err
+	}
+	socketPath := filepath.Join(dataDir, "agent", loadbalancer.AdminSocketName)
+	if _, err := os.Stat(socketPath); err != nil {
+		return nil, pkgerrors.WithMessage(err, "load balancer admin socket not found; is the agent running?")
+	}
+	return loadbalancer.NewAdminClient(socketPath), nil
+}
+
+// LBStatus prints the state of each load balancer and its servers.
+func LBStatus(app *cli.Context) error {
+	client, err := lbClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(app.Context, lbTimeout)
+	defer cancel()
+
+	statuses, err := client.Status(ctx, cmds.AgentLBConfig.Name)
+	if err != nil {
+		return pkgerrors.WithMessage(err, "failed to get load balancer status")
+	}
+
+	switch cmds.AgentLBConfig.Output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	case "table", "":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprint(w, "Load Balancer\tServer\tState\tReason\tSince\tConnections\tLatency\tFlags\n")
+		for _, s := range statuses {
+			for _, ss := range s.Servers {
+				flags := []string{}
+				if ss.Default {
+					flags = append(flags, "default")
+				}
+				if ss.Drained {
+					flags = append(flags, "drained")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", s.Name, ss.Address, ss.State, ss.Reason,
+					ss.LastTransition.Format(time.RFC3339), ss.Connections, orDash(ss.Latency), orDash(strings.Join(flags, ",")))
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q: must be one of table, json", cmds.AgentLBConfig.Output)
+	}
+}
+
+// LBDrain stops the load balancers from sending new connections to a server.
+func LBDrain(app *cli.Context) error {
+	return lbRequest(app, "Drained", true, (*loadbalancer.AdminClient).Drain)
+}
+
+// LBUndrain returns a drained server to service.
+func LBUndrain(app *cli.Context) error {
+	return lbRequest(app, "Undrained", true, (*loadbalancer.AdminClient).Undrain)
+}
+
+// LBFailover fails over from a server, or from all active servers.
+func LBFailover(app *cli.Context) error {
+	return lbRequest(app, "Failed over from", false, (*loadbalancer.AdminClient).Failover)
+}
+
+// lbRequest sends an admin request for the server address given as the first argument.
+func lbRequest(app *cli.Context, action string, requireAddress bool, send func(*loadbalancer.AdminClient, context.Context, loadbalancer.AdminRequest) error) error {
+	if app.NArg() > 1 {
+		return errors.New("too many arguments: expected a single server address")
+	}
+	req := loadbalancer.AdminRequest{
+		LoadBalancer: cmds.AgentLBConfig.Name,
+		Server:       app.Args().First(),
+	}
+	if requireAddress && req.Server == "" {
+		return errors.New("server address is required")
+	}
+
+	client, err := lbClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(app.Context, lbTimeout)
+	defer cancel()
+
+	if err := send(client, ctx, req); err != nil {
+		return err
+	}
+
+	target := req.Server
+	if target == "" {
+		target = "active servers"
+	}
+	if req.LoadBalancer == "" {
+		logrus.Infof("%s %s", action, target)
+	} else {
+		logrus.Infof("%s %s in load balancer %s", action, target, req.LoadBalancer)
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/k3s-io/k3s/pkg/agent/util"
 	"github.com/k3s-io/k3s/pkg/bootstrap"
+	"github.com/k3s-io/k3s/pkg/certmonitor"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -27,75 +28,14 @@ import (
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/otiai10/copy"
 	pkgerrors "github.com/pkg/errors"
-	certutil "github.com/rancher/dynamiclistener/cert"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 )
 
-// Certificate defines a single certificate data structure
-type Certificate struct {
-	Filename     string
-	Subject      string
-	Issuer       string
-	Usages       []string
-	ExpiryTime   time.Time
-	ResidualTime time.Duration
-	Status       string // "OK", "WARNING", "EXPIRED", "NOT YET VALID"
-}
-
-// CertificateInfo defines the structure for storing certificate information
-type CertificateInfo struct {
-	Certificates  []Certificate
-	ReferenceTime time.Time `json:"-" yaml:"-"`
-}
-
-// collectCertInfo collects information about certificates
-func collectCertInfo(controlConfig config.Control, ServicesList []string) (*CertificateInfo, error) {
-	result := &CertificateInfo{}
-	now := time.Now()
-	warn := now.Add(time.Hour * 24 * config.CertificateRenewDays)
-
-	fileMap, err := services.FilesForServices(controlConfig, ServicesList)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, files := range fileMap {
-		for _, file := range files {
-			certs, err := certutil.CertsFromFile(file)
-			if err != nil {
-				logrus.Debugf("%v", err)
-				continue
-			}
-
-			for _, cert := range certs {
-
-				expiration := cert.NotAfter
-				status := k3sutil.GetCertStatus(cert, now, warn)
-				if status == k3sutil.CertStatusNotYetValid {
-					expiration = cert.NotBefore
-				}
-				usages := k3sutil.GetCertUsages(cert)
-				result.Certificates = append(result.Certificates, Certificate{
-					Filename:     filepath.Base(file),
-					Subject:      cert.Subject.CommonName,
-					Issuer:       cert.Issuer.CommonName,
-					Usages:       usages,
-					ExpiryTime:   expiration,
-					ResidualTime: cert.NotAfter.Sub(now),
-					Status:       status,
-				})
-			}
-		}
-	}
-	result.ReferenceTime = now
-	return result, nil
-}
-
 // CertFormatter defines the interface for formatting certificate information
 type CertFormatter interface {
-	Format(*CertificateInfo) error
+	Format(*certmonitor.CertificateInfo) error
 }
 
 // TextFormatter implements text format output
@@ -103,22 +43,36 @@ type TextFormatter struct {
 	Writer io.Writer
 }
 
-func (f *TextFormatter) Format(certInfo *CertificateInfo) error {
+func (f *TextFormatter) Format(certInfo *certmonitor.CertificateInfo) error {
 	for _, cert := range certInfo.Certificates {
 		usagesStr := strings.Join(cert.Usages, ",")
+		filename := cert.Filename
+		if cert.NodeName != "" {
+			filename = cert.NodeName + "/" + cert.Filename
+		}
 		switch cert.Status {
 		case k3sutil.CertStatusNotYetValid:
 			logrus.Errorf("%s: certificate %s (%s) is not valid before %s",
-				cert.Filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
+				filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
 		case k3sutil.CertStatusExpired:
 			logrus.Errorf("%s: certificate %s (%s) expired at %s",
-				cert.Filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
+				filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
 		case k3sutil.CertStatusWarning:
 			logrus.Warnf("%s: certificate %s (%s) will expire within %d days at %s",
-				cert.Filename, cert.Subject, usagesStr, config.CertificateRenewDays, cert.ExpiryTime.Format(time.RFC3339))
+				filename, cert.Subject, usagesStr, config.CertificateRenewDays, cert.ExpiryTime.Format(time.RFC3339))
 		default:
 			logrus.Infof("%s: certificate %s (%s) is ok, expires at %s",
-				cert.Filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
+				filename, cert.Subject, usagesStr, cert.ExpiryTime.Format(time.RFC3339))
+		}
+	}
+	for _, node := range certInfo.Nodes {
+		switch node.Status {
+		case certmonitor.NodeStatusUnknown:
+			logrus.Errorf("%s: failed to check certificates: %s", node.NodeName, node.Error)
+		case k3sutil.CertStatusOK:
+			logrus.Infof("%s: certificates are ok", node.NodeName)
+		default:
+			logrus.Warnf("%s: certificates require attention, status %s", node.NodeName, node.Status)
 		}
 	}
 	return nil
@@ -129,16 +83,33 @@ type TableFormatter struct {
 	Writer io.Writer
 }
 
-func (f *TableFormatter) Format(certInfo *CertificateInfo) error {
+func (f *TableFormatter) Format(certInfo *certmonitor.CertificateInfo) error {
 	w := tabwriter.NewWriter(f.Writer, 0, 0, 3, ' ', 0)
 	now := certInfo.ReferenceTime
 	defer w.Flush()
 
-	fmt.Fprintf(w, "\nFILENAME\tSUBJECT\tUSAGES\tEXPIRES\tRESIDUAL TIME\tSTATUS\n")
-	fmt.Fprintf(w, "--------\t-------\t------\t-------\t-------------\t------\n")
+	// When checking the whole cluster, list the status of each node first, and prefix each
+	// certificate with the name of the node it was collected from.
+	var nodePrefix, nodeHeader, nodeUnderline string
+	if len(certInfo.Nodes) > 0 {
+		fmt.Fprintf(w, "\nNODE\tSTATUS\tERROR\n")
+		fmt.Fprintf(w, "----\t------\t-----\n")
+		for _, node := range certInfo.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", node.NodeName, node.Status, node.Error)
+		}
+		nodeHeader = "NODE\t"
+		nodeUnderline = "----\t"
+	}
+
+	fmt.Fprintf(w, "\n%sFILENAME\tSUBJECT\tUSAGES\tEXPIRES\tRESIDUAL TIME\tSTATUS\n", nodeHeader)
+	fmt.Fprintf(w, "%s--------\t-------\t------\t-------\t-------------\t------\n", nodeUnderline)
 
 	for _, cert := range certInfo.Certificates {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		if nodeHeader != "" {
+			nodePrefix = cert.NodeName + "\t"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\n",
+			nodePrefix,
 			cert.Filename,
 			cert.Subject,
 			strings.Join(cert.Usages, ","),
@@ -154,7 +125,7 @@ type JSONFormatter struct {
 	Writer io.Writer
 }
 
-func (f *JSONFormatter) Format(certInfo *CertificateInfo) error {
+func (f *JSONFormatter) Format(certInfo *certmonitor.CertificateInfo) error {
 	return json.NewEncoder(f.Writer).Encode(certInfo)
 }
 
@@ -163,7 +134,7 @@ type YAMLFormatter struct {
 	Writer io.Writer
 }
 
-func (f *YAMLFormatter) Format(certInfo *CertificateInfo) error {
+func (f *YAMLFormatter) Format(certInfo *certmonitor.CertificateInfo) error {
 	return yaml.NewEncoder(f.Writer).Encode(certInfo)
 }
 
@@ -212,22 +183,33 @@ func check(app *cli.Context, cfg *cmds.Server) error {
 		return err
 	}
 
-	if len(cmds.ServicesList.Value()) == 0 {
-		// detecting if the command is being run on an agent or server based on presence of the server data-dir
-		_, err := os.Stat(serverConfig.ControlConfig.DataDir)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				return err
+	var certInfo *certmonitor.CertificateInfo
+	if app.Bool("cluster") {
+		if len(cmds.ServicesList.Value()) != 0 {
+			return errors.New("--service cannot be used with --cluster")
+		}
+		port := cfg.SupervisorPort
+		if port == 0 {
+			port = cfg.HTTPSPort
+		}
+		certInfo, err = certmonitor.CollectClusterCertInfo(app.Context, &serverConfig.ControlConfig, port)
+	} else {
+		if len(cmds.ServicesList.Value()) == 0 {
+			// detecting if the command is being run on an agent or server based on presence of the server data-dir
+			_, err := os.Stat(serverConfig.ControlConfig.DataDir)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return err
+				}
+				logrus.Infof("Agent detected, checking agent certificates")
+				cmds.ServicesList = *cli.NewStringSlice(services.Agent...)
+			} else {
+				logrus.Infof("Server detected, checking agent and server certificates")
+				cmds.ServicesList = *cli.NewStringSlice(services.All...)
 			}
-			logrus.Infof("Agent detected, checking agent certificates")
-			cmds.ServicesList = *cli.NewStringSlice(services.Agent...)
-		} else {
-			logrus.Infof("Server detected, checking agent and server certificates")
-			cmds.ServicesList = *cli.NewStringSlice(services.All...)
 		}
+		certInfo, err = certmonitor.CollectCertInfo(serverConfig.ControlConfig, cmds.ServicesList.Value())
 	}
-
-	certInfo, err := collectCertInfo(serverConfig.ControlConfig, cmds.ServicesList.Value())
 	if err != nil {
 		return err
 	}
@@ -408,3 +390,31 @@ func rotateCA(app *cli.Context, cfg *cmds.Server, sync *cmds.CertRotateCA) error
 	fmt.Println("certificates saved to datastore")
 	return nil
 }
+
+func GenerateCACSR(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return generateCACSR(app, &cmds.CertGenerateCACSRConfig)
+}
+
+// generateCACSR generates keys and certificate requests for the cluster CAs. Once signed by an external CA,
+// the certificate chains can be used to start a new cluster, or imported into an existing cluster with rotate-ca.
+func generateCACSR(app *cli.Context, gen *cmds.CertGenerateCACSR) error {
+	tmpServer := &config.Control{
+		Runtime: config.NewRuntime(),
+		DataDir: gen.Path,
+	}
+	deps.CreateRuntimeCertFiles(tmpServer)
+
+	csrFiles, err := deps.GenCACertificateRequests(tmpServer)
+	if err != nil {
+		return err
+	}
+
+	for _, csrFile := range csrFiles {
+		fmt.Printf("%s\n", csrFile)
+	}
+	fmt.Printf("certificate requests saved; sign each request with your CA, and save the certificate chain alongside the request with a .crt extension\n")
+	return nil
+}
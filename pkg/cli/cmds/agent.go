@@ -3,6 +3,7 @@ package cmds
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/urfave/cli/v2"
@@ -18,6 +19,11 @@ type Agent struct {
 	DisableServiceLB         bool
 	ETCDAgent                bool
 	LBServerPort             int
+	LBServerStrategy         string
+	LBHealthCheckInterval    time.Duration
+	LBHealthCheckTimeout     time.Duration
+	LBHealthyThreshold       int
+	LBUnhealthyThreshold     int
 	ResolvConf               string
 	DataDir                  string
 	BindAddress              string
@@ -63,9 +69,16 @@ type AgentShared struct {
 	NodeIP string
 }
 
+// AgentLB holds CLI values for the agent lb subcommands
+type AgentLB struct {
+	Name   string
+	Output string
+}
+
 var (
 	appName        = filepath.Base(os.Args[0])
 	AgentConfig    Agent
+	AgentLBConfig  AgentLB
 	AgentTokenFlag = &cli.StringFlag{
 		Name:        "token",
 		Aliases:     []string{"t"},
@@ -116,6 +129,43 @@ var (
 		Destination: &AgentConfig.EnableSELinux,
 		EnvVars:     []string{version.ProgramUpper + "_SELINUX"},
 	}
+	// Note that this is different from DataDirFlag used elswhere in the CLI,
+	// as this is bound to AgentConfig instead of ServerConfig.
+	AgentDataDirFlag = &cli.StringFlag{
+		Name:        "data-dir",
+		Aliases:     []string{"d"},
+		Usage:       "(agent/data) Folder to hold state",
+		Destination: &AgentConfig.DataDir,
+		Value:       "/var/lib/rancher/" + version.Program + "",
+		EnvVars:     []string{version.ProgramUpper + "_DATA_DIR"},
+	}
+	AgentRootlessFlag = &cli.BoolFlag{
+		Name:        "rootless",
+		Usage:       "(experimental) Run rootless",
+		Destination: &AgentConfig.Rootless,
+	}
+	// AgentLBConfigFlags are the agent flags used by the lb subcommands. Other
+	// agent or server flags set in the config file are ignored by the lb subcommands.
+	AgentLBConfigFlags = []cli.Flag{
+		DebugFlag,
+		AgentDataDirFlag,
+		AgentRootlessFlag,
+	}
+	AgentLBFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:        "load-balancer",
+			Aliases:     []string{"l"},
+			Usage:       "Name of the load-balancer to manage. If not set, all load-balancers are managed",
+			Destination: &AgentLBConfig.Name,
+		},
+	}
+	AgentLBOutputFlag = &cli.StringFlag{
+		Name:        "output",
+		Aliases:     []string{"o"},
+		Usage:       "Output format. One of: table, json",
+		Destination: &AgentLBConfig.Output,
+		Value:       "table",
+	}
 	LBServerPortFlag = &cli.IntFlag{
 		Name:        "lb-server-port",
 		Usage:       "(agent/node) Local port for supervisor client load-balancer. If the supervisor and apiserver are not colocated an additional port 1 less than this port will also be used for the apiserver client load-balancer.",
@@ -123,6 +173,37 @@ var (
 		EnvVars:     []string{version.ProgramUpper + "_LB_SERVER_PORT"},
 		Value:       6444,
 	}
+	LBServerStrategyFlag = &cli.StringFlag{
+		Name:        "lb-server-strategy",
+		Usage:       "(agent/node) Server selection strategy for client load-balancers. One of: failover, round-robin, least-connections, ewma",
+		Destination: &AgentConfig.LBServerStrategy,
+		EnvVars:     []string{version.ProgramUpper + "_LB_SERVER_STRATEGY"},
+		Value:       "failover",
+	}
+	LBHealthCheckIntervalFlag = &cli.DurationFlag{
+		Name:        "lb-health-check-interval",
+		Usage:       "(agent/node) Interval between active health checks of servers by client load-balancers, which request the supervisor /ping or apiserver /readyz endpoint using the node's client certificate. Active health checks are disabled if set to 0",
+		Destination: &AgentConfig.LBHealthCheckInterval,
+		EnvVars:     []string{version.ProgramUpper + "_LB_HEALTH_CHECK_INTERVAL"},
+	}
+	LBHealthCheckTimeoutFlag = &cli.DurationFlag{
+		Name:        "lb-health-check-timeout",
+		Usage:       "(agent/node) Timeout for active health checks of servers by client load-balancers",
+		Destination: &AgentConfig.LBHealthCheckTimeout,
+		Value:       2 * time.Second,
+	}
+	LBHealthyThresholdFlag = &cli.IntFlag{
+		Name:        "lb-health-check-healthy-threshold",
+		Usage:       "(agent/node) Number of consecutive successful active health checks before a client load-balancer server is considered healthy",
+		Destination: &AgentConfig.LBHealthyThreshold,
+		Value:       2,
+	}
+	LBUnhealthyThresholdFlag = &cli.IntFlag{
+		Name:        "lb-health-check-unhealthy-threshold",
+		Usage:       "(agent/node) Number of consecutive failed active health checks before a client load-balancer server is considered failed",
+		Destination: &AgentConfig.LBUnhealthyThreshold,
+		Value:       3,
+	}
 	DockerFlag = &cli.BoolFlag{
 		Name:        "docker",
 		Usage:       "(agent/runtime) (experimental) Use cri-dockerd instead of containerd",
@@ -145,7 +226,7 @@ var (
 	}
 	PrivateRegistryFlag = &cli.StringFlag{
 		Name:        "private-registry",
-		Usage:       "(agent/runtime) Private registry configuration file",
+		Usage:       "(agent/runtime) Private registry configuration file. Changes are applied while running, except for registry credentials, which require a restart",
 		Destination: &AgentConfig.PrivateRegistry,
 		Value:       "/etc/rancher/" + version.Program + "/registries.yaml",
 	}
@@ -259,7 +340,7 @@ var (
 	}
 )
 
-func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
+func NewAgentCommand(action, lbStatus, lbDrain, lbUndrain, lbFailover func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:      "agent",
 		Usage:     "Run node agent",
@@ -286,16 +367,7 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 				EnvVars:     []string{version.ProgramUpper + "_URL"},
 				Destination: &AgentConfig.ServerURL,
 			},
-			// Note that this is different from DataDirFlag used elswhere in the CLI,
-			// as this is bound to AgentConfig instead of ServerConfig.
-			&cli.StringFlag{
-				Name:        "data-dir",
-				Aliases:     []string{"d"},
-				Usage:       "(agent/data) Folder to hold state",
-				Destination: &AgentConfig.DataDir,
-				Value:       "/var/lib/rancher/" + version.Program + "",
-				EnvVars:     []string{version.ProgramUpper + "_DATA_DIR"},
-			},
+			AgentDataDirFlag,
 			NodeNameFlag,
 			WithNodeIDFlag,
 			NodeLabels,
@@ -304,6 +376,11 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			ImageCredProvConfigFlag,
 			SELinuxFlag,
 			LBServerPortFlag,
+			LBServerStrategyFlag,
+			LBHealthCheckIntervalFlag,
+			LBHealthCheckTimeoutFlag,
+			LBHealthyThresholdFlag,
+			LBUnhealthyThresholdFlag,
 			ProtectKernelDefaultsFlag,
 			CRIEndpointFlag,
 			DefaultRuntimeFlag,
@@ -327,11 +404,7 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			ExtraKubeProxyArgs,
 			// Experimental flags
 			EnablePProfFlag,
-			&cli.BoolFlag{
-				Name:        "rootless",
-				Usage:       "(experimental) Run rootless",
-				Destination: &AgentConfig.Rootless,
-			},
+			AgentRootlessFlag,
 			PreferBundledBin,
 			// Deprecated/hidden below
 			DockerFlag,
@@ -339,5 +412,41 @@ func NewAgentCommand(action func(ctx *cli.Context) error) *cli.Command {
 			VPNAuthFile,
 			DisableAgentLBFlag,
 		},
+		Subcommands: []*cli.Command{
+			{
+				Name:      "lb",
+				Usage:     "Manage the agent's client load-balancers",
+				UsageText: appName + " agent [OPTIONS] lb COMMAND",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "status",
+						Usage:  "Print the state of each load-balancer and its servers",
+						Flags:  append(AgentLBFlags, AgentLBOutputFlag),
+						Action: lbStatus,
+					},
+					{
+						Name:      "drain",
+						Usage:     "Stop sending new connections to a server, unless all other servers are unavailable. Existing connections are not closed",
+						UsageText: appName + " agent lb drain [OPTIONS] ADDRESS",
+						Flags:     AgentLBFlags,
+						Action:    lbDrain,
+					},
+					{
+						Name:      "undrain",
+						Usage:     "Return a drained server to service",
+						UsageText: appName + " agent lb undrain [OPTIONS] ADDRESS",
+						Flags:     AgentLBFlags,
+						Action:    lbUndrain,
+					},
+					{
+						Name:      "failover",
+						Usage:     "Mark a server as failed and close its connections, so that clients reconnect to another server. If no address is given, all active servers are failed over",
+						UsageText: appName + " agent lb failover [OPTIONS] [ADDRESS]",
+						Flags:     AgentLBFlags,
+						Action:    lbFailover,
+					},
+				},
+			},
+		},
 	}
 }
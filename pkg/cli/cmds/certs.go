@@ -12,6 +12,10 @@ type CertRotateCA struct {
 	Force      bool
 }
 
+type CertGenerateCACSR struct {
+	Path string
+}
+
 var (
 	ServicesList           cli.StringSlice
 	CertRotateCAConfig     CertRotateCA
@@ -50,9 +54,22 @@ var (
 			Destination: &CertRotateCAConfig.Force,
 		},
 	}
+
+	CertGenerateCACSRConfig       CertGenerateCACSR
+	CertGenerateCACSRCommandFlags = []cli.Flag{
+		DebugFlag,
+		LogFile,
+		AlsoLogToStderr,
+		&cli.StringFlag{
+			Name:        "path",
+			Usage:       "Path to directory in which to generate CA keys and certificate requests. Use the server directory within the data-dir to prepare a new cluster, or another directory to prepare for rotate-ca",
+			Destination: &CertGenerateCACSRConfig.Path,
+			Required:    true,
+		},
+	}
 )
 
-func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.Command {
+func NewCertCommands(check, rotate, rotateCA, generateCACSR func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:            CertCommand,
 		Usage:           "Manage K3s certificates",
@@ -68,6 +85,20 @@ func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.
 					Aliases: []string{"o"},
 					Usage:   "Format output. Options: text, table, json, yaml",
 					Value:   "text",
+				}, &cli.BoolFlag{
+					Name:  "cluster",
+					Usage: "Check certificates on all nodes in the cluster. Must be run on a server. Agents can only be checked if the embedded registry or supervisor metrics are enabled",
+				}, &cli.IntFlag{
+					Name:        "https-listen-port",
+					Usage:       "(cluster) HTTPS listen port, used to connect to the supervisor on each node",
+					Value:       6443,
+					Destination: &ServerConfig.HTTPSPort,
+				}, &cli.IntFlag{
+					Name:        "supervisor-port",
+					EnvVars:     []string{version.ProgramUpper + "_SUPERVISOR_PORT"},
+					Usage:       "(experimental) Supervisor listen port override",
+					Hidden:      true,
+					Destination: &ServerConfig.SupervisorPort,
 				}),
 			},
 			{
@@ -84,6 +115,13 @@ func NewCertCommands(check, rotate, rotateCA func(ctx *cli.Context) error) *cli.
 				Action:          rotateCA,
 				Flags:           CertRotateCACommandFlags,
 			},
+			{
+				Name:            "generate-ca-csr",
+				Usage:           "Generate " + version.Program + " CA keys and certificate requests, for signing by an external CA",
+				SkipFlagParsing: false,
+				Action:          generateCACSR,
+				Flags:           CertGenerateCACSRCommandFlags,
+			},
 		},
 	}
 }
@@ -5,14 +5,72 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+const ConfigCommand = "config"
+
+type ConfigCheck struct {
+	ConfigFile string
+	Role       string
+	Output     string
+}
+
 var (
 	// ConfigFlag is here to show to the user, but the actually processing is done by configfileargs before
 	// call urfave
 	ConfigFlag = &cli.StringFlag{
 		Name:    "config",
 		Aliases: []string{"c"},
-		Usage:   "(config) Load configuration from `FILE`",
+		Usage: "(config) Load configuration from `FILE`. If " + version.ProgramUpper + "_CONFIG_INTERPOLATION=true is set, ${VAR}, file:PATH, and secret-file:PATH " +
+			"references in config values are expanded; use $${VAR}, file::, and secret-file:: for literal values",
 		EnvVars: []string{version.ProgramUpper + "_CONFIG_FILE"},
 		Value:   "/etc/rancher/" + version.Program + "/config.yaml",
 	}
+
+	// DeprecatedFlags maps the names of deprecated flags to the names of the flags that replace them, if any.
+	DeprecatedFlags = map[string]string{
+		"kube-controller-arg":       "kube-controller-manager-arg",
+		"kube-cloud-controller-arg": "kube-cloud-controller-manager-arg",
+	}
+
+	ConfigCheckConfig       ConfigCheck
+	ConfigCheckCommandFlags = []cli.Flag{
+		DebugFlag,
+		&cli.StringFlag{
+			Name:        "config",
+			Aliases:     []string{"c"},
+			Usage:       "(config) Check configuration loaded from `FILE` and its dropin directory",
+			EnvVars:     []string{version.ProgramUpper + "_CONFIG_FILE"},
+			Value:       "/etc/rancher/" + version.Program + "/config.yaml",
+			Destination: &ConfigCheckConfig.ConfigFile,
+		},
+		&cli.StringFlag{
+			Name:        "role",
+			Usage:       "Check configuration for use by this command (one of: 'server', 'agent')",
+			Value:       "server",
+			Destination: &ConfigCheckConfig.Role,
+		},
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "Output format (one of: 'text', 'json', 'yaml')",
+			Value:       "text",
+			Destination: &ConfigCheckConfig.Output,
+		},
+	}
 )
+
+func NewConfigCommands(check func(ctx *cli.Context) error) *cli.Command {
+	return &cli.Command{
+		Name:            ConfigCommand,
+		Usage:           "Manage " + version.Program + " configuration files",
+		SkipFlagParsing: false,
+		Subcommands: []*cli.Command{
+			{
+				Name:            "check",
+				Usage:           "Validate the configuration file and dropins, and print the effective configuration",
+				SkipFlagParsing: false,
+				Action:          check,
+				Flags:           ConfigCheckCommandFlags,
+			},
+		},
+	}
+}
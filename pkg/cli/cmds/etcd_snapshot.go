@@ -52,6 +52,18 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Usage:       "(db) Compress etcd snapshot",
 		Destination: &ServerConfig.EtcdSnapshotCompress,
 	},
+	&cli.BoolFlag{
+		Name:        "snapshot-encrypt",
+		Aliases:     []string{"etcd-snapshot-encrypt"},
+		Usage:       "(db) Encrypt etcd snapshot with a key derived from the server token, or from snapshot-encryption-key-file if set",
+		Destination: &ServerConfig.EtcdSnapshotEncrypt,
+	},
+	&cli.StringFlag{
+		Name:        "snapshot-encryption-key-file",
+		Aliases:     []string{"etcd-snapshot-encryption-key-file"},
+		Usage:       "(db) Path to a file on the server containing the secret used to derive the etcd snapshot encryption key",
+		Destination: &ServerConfig.EtcdSnapshotKeyFile,
+	},
 	&cli.IntFlag{
 		Name:        "snapshot-retention,",
 		Aliases:     []string{"etcd-snapshot-retention"},
@@ -59,6 +71,18 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdSnapshotRetention,
 		Value:       defaultSnapshotRentention,
 	},
+	&cli.StringFlag{
+		Name:        "snapshot-retention-policy",
+		Aliases:     []string{"etcd-snapshot-retention-policy"},
+		Usage:       "(db) Tiered retention for local snapshots, as a comma-separated list of hourly=N,daily=N,weekly=N,monthly=N",
+		Destination: &ServerConfig.EtcdSnapshotTiers,
+	},
+	&cli.StringFlag{
+		Name:        "snapshot-remote-retention-policy",
+		Aliases:     []string{"etcd-snapshot-remote-retention-policy"},
+		Usage:       "(db) Tiered retention for snapshots in remote storage, as a comma-separated list of hourly=N,daily=N,weekly=N,monthly=N (default: snapshot-retention-policy)",
+		Destination: &ServerConfig.EtcdRemoteSnapshotTiers,
+	},
 	&cli.BoolFlag{
 		Name:        "s3",
 		Aliases:     []string{"etcd-s3"},
@@ -148,6 +172,12 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Usage:       "(db) Disables S3 over HTTPS",
 		Destination: &ServerConfig.EtcdS3Insecure,
 	},
+	&cli.BoolFlag{
+		Name:        "s3-stream",
+		Aliases:     []string{"etcd-s3-stream"},
+		Usage:       "(db) Stream snapshots directly to S3 without saving a local copy. Cannot be used with other remote storage backends",
+		Destination: &ServerConfig.EtcdS3Stream,
+	},
 	&cli.DurationFlag{
 		Name:        "s3-timeout",
 		Aliases:     []string{"etcd-s3-timeout"},
@@ -155,9 +185,88 @@ var EtcdSnapshotFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdS3Timeout,
 		Value:       5 * time.Minute,
 	},
+	&cli.IntFlag{
+		Name:        "s3-retention",
+		Aliases:     []string{"etcd-s3-retention"},
+		Usage:       "(db) Number of snapshots to retain in S3 (default: snapshot-retention)",
+		Destination: &ServerConfig.EtcdS3Retention,
+	},
+	&cli.StringFlag{
+		Name:        "remote-dir",
+		Aliases:     []string{"etcd-remote-dir"},
+		Usage:       "(db) Enable backup to a directory on a mounted file system, such as an NFS share",
+		Destination: &ServerConfig.EtcdRemoteDir,
+	},
+	&cli.IntFlag{
+		Name:        "remote-dir-retention",
+		Aliases:     []string{"etcd-remote-dir-retention"},
+		Usage:       "(db) Number of snapshots to retain in remote-dir (default: snapshot-retention)",
+		Destination: &ServerConfig.EtcdRemoteDirRetention,
+	},
+	&cli.BoolFlag{
+		Name:        "sftp",
+		Aliases:     []string{"etcd-sftp"},
+		Usage:       "(db) Enable backup to SFTP",
+		Destination: &ServerConfig.EtcdSFTP,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-endpoint",
+		Aliases:     []string{"etcd-sftp-endpoint"},
+		Usage:       "(db) SFTP server address, as host or host:port",
+		Destination: &ServerConfig.EtcdSFTPEndpoint,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-username",
+		Aliases:     []string{"etcd-sftp-username"},
+		Usage:       "(db) SFTP username",
+		Destination: &ServerConfig.EtcdSFTPUsername,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-password",
+		Aliases:     []string{"etcd-sftp-password"},
+		Usage:       "(db) SFTP password",
+		Destination: &ServerConfig.EtcdSFTPPassword,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-private-key",
+		Aliases:     []string{"etcd-sftp-private-key"},
+		Usage:       "(db) Path to SSH private key on the server used to authenticate to the SFTP server",
+		Destination: &ServerConfig.EtcdSFTPPrivateKey,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-known-hosts",
+		Aliases:     []string{"etcd-sftp-known-hosts"},
+		Usage:       "(db) Path to SSH known hosts file on the server used to verify the SFTP server host key",
+		Destination: &ServerConfig.EtcdSFTPKnownHosts,
+	},
+	&cli.StringFlag{
+		Name:        "sftp-folder",
+		Aliases:     []string{"etcd-sftp-folder"},
+		Usage:       "(db) SFTP folder",
+		Destination: &ServerConfig.EtcdSFTPFolder,
+	},
+	&cli.BoolFlag{
+		Name:        "sftp-skip-host-key-check",
+		Aliases:     []string{"etcd-sftp-skip-host-key-check"},
+		Usage:       "(db) Disables SFTP server host key verification",
+		Destination: &ServerConfig.EtcdSFTPSkipHostKeyCheck,
+	},
+	&cli.DurationFlag{
+		Name:        "sftp-timeout",
+		Aliases:     []string{"etcd-sftp-timeout"},
+		Usage:       "(db) SFTP connection timeout",
+		Destination: &ServerConfig.EtcdSFTPTimeout,
+		Value:       30 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:        "sftp-retention",
+		Aliases:     []string{"etcd-sftp-retention"},
+		Usage:       "(db) Number of snapshots to retain on the SFTP server (default: snapshot-retention)",
+		Destination: &ServerConfig.EtcdSFTPRetention,
+	},
 }
 
-func NewEtcdSnapshotCommands(delete, list, prune, save func(ctx *cli.Context) error) *cli.Command {
+func NewEtcdSnapshotCommands(delete, list, prune, save, verify, extract, diff func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:            EtcdSnapshotCommand,
 		Usage:           "Manage etcd snapshots",
@@ -192,10 +301,84 @@ func NewEtcdSnapshotCommands(delete, list, prune, save func(ctx *cli.Context) er
 			},
 			{
 				Name:            "prune",
-				Usage:           "Remove snapshots that match the name prefix that exceed the configured retention count",
+				Usage:           "Remove snapshots that match the name prefix that are not retained by the configured retention policy",
 				SkipFlagParsing: false,
 				Action:          prune,
-				Flags:           EtcdSnapshotFlags,
+				Flags: append(EtcdSnapshotFlags, &cli.BoolFlag{
+					Name:        "dry-run",
+					Usage:       "(db) List the snapshots that would be removed, and the reasons for retaining the others, without removing any snapshots",
+					Destination: &ServerConfig.EtcdPruneDryRun,
+				}),
+			},
+			{
+				Name:            "verify",
+				Usage:           "Verify that given snapshot(s) can be restored, and record the result on the ETCDSnapshotFile",
+				SkipFlagParsing: false,
+				Action:          verify,
+				Flags: append(EtcdSnapshotFlags, &cli.StringFlag{
+					Name:        "output",
+					Aliases:     []string{"o"},
+					Usage:       "(db) Output format. Default: standard. Optional: json",
+					Destination: &ServerConfig.EtcdListFormat,
+				}),
+			},
+			{
+				Name:            "extract",
+				Usage:           "Extract resources from the given snapshot as YAML, without restoring it. The output may contain decrypted secrets",
+				SkipFlagParsing: false,
+				Action:          extract,
+				Flags: append(EtcdSnapshotFlags,
+					&cli.StringSliceFlag{
+						Name:        "resource",
+						Usage:       "(db) Extract only resources of this type, by plural name, group-qualified name, or kind (for example, configmaps, deployments.apps, or Secret)",
+						Destination: &ServerConfig.EtcdExtractResources,
+					},
+					&cli.StringSliceFlag{
+						Name:        "namespace",
+						Usage:       "(db) Extract only resources in this namespace",
+						Destination: &ServerConfig.EtcdExtractNamespaces,
+					},
+					&cli.StringSliceFlag{
+						Name:        "resource-name",
+						Usage:       "(db) Extract only resources with this name",
+						Destination: &ServerConfig.EtcdExtractNames,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Aliases:     []string{"o"},
+						Usage:       "(db) File to write extracted resources to. Default: stdout",
+						Destination: &ServerConfig.EtcdExtractOutput,
+					},
+				),
+			},
+			{
+				Name:            "diff",
+				Usage:           "Report the resources added, removed, or modified between two given snapshots",
+				SkipFlagParsing: false,
+				Action:          diff,
+				Flags: append(EtcdSnapshotFlags,
+					&cli.StringSliceFlag{
+						Name:        "resource",
+						Usage:       "(db) Compare only resources of this type, by plural name or group-qualified name (for example, configmaps or deployments.apps)",
+						Destination: &ServerConfig.EtcdExtractResources,
+					},
+					&cli.StringSliceFlag{
+						Name:        "namespace",
+						Usage:       "(db) Compare only resources in this namespace",
+						Destination: &ServerConfig.EtcdExtractNamespaces,
+					},
+					&cli.StringSliceFlag{
+						Name:        "resource-name",
+						Usage:       "(db) Compare only resources with this name",
+						Destination: &ServerConfig.EtcdExtractNames,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Aliases:     []string{"o"},
+						Usage:       "(db) Output format. Default: standard. Optional: json",
+						Destination: &ServerConfig.EtcdListFormat,
+					},
+				),
 			},
 		},
 		Flags: EtcdSnapshotFlags,
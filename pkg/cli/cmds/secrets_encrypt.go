@@ -28,7 +28,7 @@ var (
 	}
 )
 
-func NewSecretsEncryptCommands(status, enable, disable, prepare, rotate, reencrypt, rotateKeys func(ctx *cli.Context) error) *cli.Command {
+func NewSecretsEncryptCommands(status, enable, disable, prepare, rotate, reencrypt, rotateKeys, migrate func(ctx *cli.Context) error) *cli.Command {
 	return &cli.Command{
 		Name:  SecretsEncryptCommand,
 		Usage: "Control secrets encryption and keys rotation",
@@ -87,6 +87,17 @@ func NewSecretsEncryptCommands(status, enable, disable, prepare, rotate, reencry
 				Action: rotateKeys,
 				Flags:  EncryptFlags,
 			},
+			{
+				Name:   "migrate",
+				Usage:  "Migrate secrets encryption to a different provider, and re-encrypt secrets",
+				Action: migrate,
+				Flags: append(EncryptFlags, &cli.StringFlag{
+					Name:        "to",
+					Usage:       "Provider to migrate to (valid values: 'aescbc', 'aesgcm', 'secretbox', 'kms')",
+					Destination: &ServerConfig.EncryptMigrateTo,
+					Required:    true,
+				}),
+			},
 		},
 	}
 }
@@ -85,6 +85,10 @@ type Server struct {
 	EncryptOutput            string
 	EncryptSkip              bool
 	EncryptProvider          string
+	EncryptKMSEndpoint       string
+	EncryptKMSName           string
+	EncryptMigrateTo         string
+	EncryptResources         cli.StringSlice
 	SystemDefaultRegistry    string
 	StartupHooks             []StartupHook
 	SupervisorMetrics        bool
@@ -95,8 +99,18 @@ type Server struct {
 	EtcdSnapshotCron         string
 	EtcdSnapshotReconcile    time.Duration
 	EtcdSnapshotRetention    int
+	EtcdSnapshotTiers        string
+	EtcdRemoteSnapshotTiers  string
+	EtcdPruneDryRun          bool
 	EtcdSnapshotCompress     bool
+	EtcdSnapshotEncrypt      bool
+	EtcdSnapshotKeyFile      string
+	EtcdSnapshotVerify       bool
 	EtcdListFormat           string
+	EtcdExtractResources     cli.StringSlice
+	EtcdExtractNamespaces    cli.StringSlice
+	EtcdExtractNames         cli.StringSlice
+	EtcdExtractOutput        string
 	EtcdS3                   bool
 	EtcdS3Endpoint           string
 	EtcdS3EndpointCA         string
@@ -112,7 +126,26 @@ type Server struct {
 	EtcdS3ConfigSecret       string
 	EtcdS3Timeout            time.Duration
 	EtcdS3Insecure           bool
+	EtcdS3Stream             bool
+	EtcdS3Retention          int
+	EtcdRemoteDir            string
+	EtcdRemoteDirRetention   int
+	EtcdSFTP                 bool
+	EtcdSFTPEndpoint         string
+	EtcdSFTPUsername         string
+	EtcdSFTPPassword         string
+	EtcdSFTPPrivateKey       string
+	EtcdSFTPKnownHosts       string
+	EtcdSFTPFolder           string
+	EtcdSFTPSkipHostKeyCheck bool
+	EtcdSFTPTimeout          time.Duration
+	EtcdSFTPRetention        int
 	ServiceLBNamespace       string
+
+	SupervisorAuditLogPath      string
+	SupervisorAuditLogMaxAge    int
+	SupervisorAuditLogMaxBackup int
+	SupervisorAuditLogMaxSize   int
 }
 
 var (
@@ -410,6 +443,16 @@ var ServerFlags = []cli.Flag{
 		Destination: &ServerConfig.EtcdSnapshotRetention,
 		Value:       defaultSnapshotRentention,
 	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-retention-policy",
+		Usage:       "(db) Tiered retention for local snapshots, as a comma-separated list of hourly=N,daily=N,weekly=N,monthly=N. The newest snapshot in each period is retained, in addition to the newest etcd-snapshot-retention snapshots",
+		Destination: &ServerConfig.EtcdSnapshotTiers,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-remote-retention-policy",
+		Usage:       "(db) Tiered retention for snapshots in remote storage, as a comma-separated list of hourly=N,daily=N,weekly=N,monthly=N (default: etcd-snapshot-retention-policy)",
+		Destination: &ServerConfig.EtcdRemoteSnapshotTiers,
+	},
 	&cli.StringFlag{
 		Name:        "etcd-snapshot-dir",
 		Usage:       "(db) Directory to save db snapshots. (default: ${data-dir}/server/db/snapshots)",
@@ -420,6 +463,21 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(db) Compress etcd snapshot",
 		Destination: &ServerConfig.EtcdSnapshotCompress,
 	},
+	&cli.BoolFlag{
+		Name:        "etcd-snapshot-encrypt",
+		Usage:       "(db) Encrypt etcd snapshots with a key derived from the server token, or from etcd-snapshot-encryption-key-file if set",
+		Destination: &ServerConfig.EtcdSnapshotEncrypt,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-snapshot-encryption-key-file",
+		Usage:       "(db) Path to a file containing the secret used to derive the etcd snapshot encryption key",
+		Destination: &ServerConfig.EtcdSnapshotKeyFile,
+	},
+	&cli.BoolFlag{
+		Name:        "etcd-snapshot-verify",
+		Usage:       "(db) Verify each scheduled snapshot after it is saved, and record the result on the ETCDSnapshotFile",
+		Destination: &ServerConfig.EtcdSnapshotVerify,
+	},
 	&cli.BoolFlag{
 		Name:        "etcd-s3",
 		Usage:       "(db) Enable backup to S3",
@@ -495,12 +553,83 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(db) Disables S3 over HTTPS",
 		Destination: &ServerConfig.EtcdS3Insecure,
 	},
+	&cli.BoolFlag{
+		Name:        "etcd-s3-stream",
+		Usage:       "(db) Stream snapshots directly to S3 without saving a local copy. Cannot be used with other remote storage backends",
+		Destination: &ServerConfig.EtcdS3Stream,
+	},
 	&cli.DurationFlag{
 		Name:        "etcd-s3-timeout",
 		Usage:       "(db) S3 timeout",
 		Destination: &ServerConfig.EtcdS3Timeout,
 		Value:       5 * time.Minute,
 	},
+	&cli.IntFlag{
+		Name:        "etcd-s3-retention",
+		Usage:       "(db) Number of snapshots to retain in S3 (default: etcd-snapshot-retention)",
+		Destination: &ServerConfig.EtcdS3Retention,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-remote-dir",
+		Usage:       "(db) Enable backup to a directory on a mounted file system, such as an NFS share",
+		Destination: &ServerConfig.EtcdRemoteDir,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-remote-dir-retention",
+		Usage:       "(db) Number of snapshots to retain in etcd-remote-dir (default: etcd-snapshot-retention)",
+		Destination: &ServerConfig.EtcdRemoteDirRetention,
+	},
+	&cli.BoolFlag{
+		Name:        "etcd-sftp",
+		Usage:       "(db) Enable backup to SFTP",
+		Destination: &ServerConfig.EtcdSFTP,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-endpoint",
+		Usage:       "(db) SFTP server address, as host or host:port",
+		Destination: &ServerConfig.EtcdSFTPEndpoint,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-username",
+		Usage:       "(db) SFTP username",
+		Destination: &ServerConfig.EtcdSFTPUsername,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-password",
+		Usage:       "(db) SFTP password",
+		Destination: &ServerConfig.EtcdSFTPPassword,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-private-key",
+		Usage:       "(db) Path to SSH private key used to authenticate to the SFTP server",
+		Destination: &ServerConfig.EtcdSFTPPrivateKey,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-known-hosts",
+		Usage:       "(db) Path to SSH known hosts file used to verify the SFTP server host key",
+		Destination: &ServerConfig.EtcdSFTPKnownHosts,
+	},
+	&cli.StringFlag{
+		Name:        "etcd-sftp-folder",
+		Usage:       "(db) SFTP folder",
+		Destination: &ServerConfig.EtcdSFTPFolder,
+	},
+	&cli.BoolFlag{
+		Name:        "etcd-sftp-skip-host-key-check",
+		Usage:       "(db) Disables SFTP server host key verification",
+		Destination: &ServerConfig.EtcdSFTPSkipHostKeyCheck,
+	},
+	&cli.DurationFlag{
+		Name:        "etcd-sftp-timeout",
+		Usage:       "(db) SFTP connection timeout",
+		Destination: &ServerConfig.EtcdSFTPTimeout,
+		Value:       30 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:        "etcd-sftp-retention",
+		Usage:       "(db) Number of snapshots to retain on the SFTP server (default: etcd-snapshot-retention)",
+		Destination: &ServerConfig.EtcdSFTPRetention,
+	},
 	&cli.StringFlag{
 		Name:        "default-local-storage-path",
 		Usage:       "(storage) Default local storage path for local provisioner storage class",
@@ -563,6 +692,29 @@ var ServerFlags = []cli.Flag{
 		Usage:       "(experimental/components) Enable serving " + version.Program + " internal metrics on the supervisor port; when enabled agents will also listen on the supervisor port",
 		Destination: &ServerConfig.SupervisorMetrics,
 	},
+	&cli.StringFlag{
+		Name:        "supervisor-audit-log-path",
+		Usage:       "(logging) Write an audit log of supervisor API requests to this file, or to syslog if set to 'syslog' or 'syslog:<socket path>'",
+		Destination: &ServerConfig.SupervisorAuditLogPath,
+	},
+	&cli.IntFlag{
+		Name:        "supervisor-audit-log-maxage",
+		Usage:       "(logging) Maximum number of days to retain old supervisor audit log files",
+		Destination: &ServerConfig.SupervisorAuditLogMaxAge,
+		Value:       30,
+	},
+	&cli.IntFlag{
+		Name:        "supervisor-audit-log-maxbackup",
+		Usage:       "(logging) Maximum number of old supervisor audit log files to retain",
+		Destination: &ServerConfig.SupervisorAuditLogMaxBackup,
+		Value:       10,
+	},
+	&cli.IntFlag{
+		Name:        "supervisor-audit-log-maxsize",
+		Usage:       "(logging) Maximum size in megabytes of the supervisor audit log file before it gets rotated",
+		Destination: &ServerConfig.SupervisorAuditLogMaxSize,
+		Value:       100,
+	},
 	NodeNameFlag,
 	WithNodeIDFlag,
 	NodeLabels,
@@ -612,13 +764,34 @@ var ServerFlags = []cli.Flag{
 	},
 	&cli.StringFlag{
 		Name:        "secrets-encryption-provider",
-		Usage:       "(experimental) Secret encryption provider (valid values: 'aescbc', 'secretbox')",
+		Usage:       "(experimental) Secret encryption provider used when secrets encryption is first configured; use 'secrets-encrypt migrate' to change it (valid values: 'aescbc', 'aesgcm', 'secretbox', 'kms')",
 		Destination: &ServerConfig.EncryptProvider,
 		Value:       "aescbc",
 	},
+	&cli.StringFlag{
+		Name:        "secrets-encryption-kms-endpoint",
+		Usage:       "(experimental) Endpoint of the KMS v2 plugin used by the 'kms' secret encryption provider (example: unix:///var/run/kms-plugin.sock)",
+		Destination: &ServerConfig.EncryptKMSEndpoint,
+	},
+	&cli.StringFlag{
+		Name:        "secrets-encryption-kms-name",
+		Usage:       "(experimental) Name of the KMS v2 provider in the secret encryption configuration",
+		Destination: &ServerConfig.EncryptKMSName,
+		Value:       version.Program + "-kms",
+	},
+	&cli.StringSliceFlag{
+		Name:        "secrets-encryption-resources",
+		Usage:       "(experimental) Resources to encrypt at rest in addition to secrets, including custom resources. Resources cannot be removed once added (example: configmaps, widgets.example.com, *.example.com)",
+		Destination: &ServerConfig.EncryptResources,
+	},
 	PreferBundledBin,
 	SELinuxFlag,
 	LBServerPortFlag,
+	LBServerStrategyFlag,
+	LBHealthCheckIntervalFlag,
+	LBHealthCheckTimeoutFlag,
+	LBHealthyThresholdFlag,
+	LBUnhealthyThresholdFlag,
 
 	// Hidden/Deprecated flags below
 
@@ -20,6 +20,11 @@ type Token struct {
 	Groups      cli.StringSlice
 	Usages      cli.StringSlice
 	TTL         time.Duration
+
+	MaxJoins        int
+	NodeNamePattern string
+	AllowedCIDRs    cli.StringSlice
+	Roles           cli.StringSlice
 }
 
 var (
@@ -61,6 +66,22 @@ func NewTokenCommands(create, delete, generate, list, rotate func(ctx *cli.Conte
 					Name:        "usages",
 					Usage:       "Describes the ways in which this token can be used.",
 					Destination: &TokenConfig.Usages,
+				}, &cli.IntFlag{
+					Name:        "max-joins",
+					Usage:       "The maximum number of nodes that may join the cluster using this token. If set to '0', any number of nodes may join",
+					Destination: &TokenConfig.MaxJoins,
+				}, &cli.StringFlag{
+					Name:        "node-name-pattern",
+					Usage:       "A glob pattern that the names of nodes joining with this token must match (e.g. 'worker-*')",
+					Destination: &TokenConfig.NodeNamePattern,
+				}, &cli.StringSliceFlag{
+					Name:        "allowed-cidrs",
+					Usage:       "Address ranges from which nodes may join using this token",
+					Destination: &TokenConfig.AllowedCIDRs,
+				}, &cli.StringSliceFlag{
+					Name:        "roles",
+					Usage:       "Node roles that may join using this token. Bootstrap tokens can only be used to join agents; servers must join using the server token (one of: 'agent')",
+					Destination: &TokenConfig.Roles,
 				}),
 				SkipFlagParsing: false,
 				Action:          create,
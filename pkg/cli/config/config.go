@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/configfilearg"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const redacted = "<redacted>"
+
+// sensitiveKey matches config keys whose values should not be printed.
+var sensitiveKey = regexp.MustCompile(`(token|password|secret|secret-key|vpn-auth)$`)
+
+func Check(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return check(app, &cmds.ConfigCheckConfig)
+}
+
+func check(app *cli.Context, cfg *cmds.ConfigCheck) error {
+	var flags []cli.Flag
+	switch cfg.Role {
+	case "server":
+		flags = cmds.ServerFlags
+	case "agent":
+		flags = cmds.NewAgentCommand(nil, nil, nil, nil, nil).Flags
+	default:
+		return errors.New("invalid role: " + cfg.Role)
+	}
+
+	result, err := configfilearg.Check(cfg.ConfigFile, flags, cmds.DeprecatedFlags)
+	if err != nil {
+		return pkgerrors.WithMessage(err, "failed to load configuration")
+	}
+
+	for i, value := range result.Values {
+		if value.Secret {
+			result.Values[i].Value = redacted
+		} else {
+			result.Values[i].Value = redact(value.Key, value.Value)
+		}
+	}
+
+	switch cfg.Output {
+	case "json":
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			return err
+		}
+	case "yaml":
+		if err := yaml.NewEncoder(os.Stdout).Encode(result); err != nil {
+			return err
+		}
+	case "text":
+		printText(result)
+	default:
+		return errors.New("invalid output format: " + cfg.Output)
+	}
+
+	if count := result.Errors(); count > 0 {
+		return fmt.Errorf("found %d errors in configuration", count)
+	}
+	return nil
+}
+
+// printText prints the files that were checked, any problems found, and the effective configuration with the origin of each value.
+func printText(result *configfilearg.CheckResult) {
+	fmt.Println("Configuration files:")
+	for _, file := range result.Files {
+		fmt.Println("  " + file)
+	}
+
+	if len(result.Problems) > 0 {
+		fmt.Println("\nProblems:")
+		for _, problem := range result.Problems {
+			fmt.Println("  " + problem.String())
+		}
+	}
+
+	fmt.Println("\nEffective configuration:")
+	format := "%s\t%s\t%s\n"
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, format, "KEY", "VALUE", "ORIGIN")
+	for _, value := range result.Values {
+		str := fmt.Sprint(value.Value)
+		if slice, ok := value.Value.([]string); ok {
+			str = strings.Join(slice, ",")
+		}
+		origins := make([]string, len(value.Origins))
+		for i, origin := range value.Origins {
+			origins[i] = origin.String()
+		}
+		fmt.Fprintf(w, format, value.Key, str, strings.Join(origins, ","))
+	}
+}
+
+// redact hides the values of keys that may contain secrets, and passwords embedded in URLs.
+func redact(key string, value interface{}) interface{} {
+	if sensitiveKey.MatchString(key) {
+		return redacted
+	}
+	if str, ok := value.(string); ok {
+		if u, err := url.Parse(str); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				return u.Redacted()
+			}
+		}
+	}
+	return value
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -19,6 +20,8 @@ import (
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/etcd/extract"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/server"
 	util2 "github.com/k3s-io/k3s/pkg/util"
@@ -40,7 +43,7 @@ func commandSetup(app *cli.Context, cfg *cmds.Server) (*etcd.SnapshotRequest, *c
 
 	sr := &etcd.SnapshotRequest{}
 	// Operation and name are set by the command handler.
-	// Compression, dir, and retention take the server defaults if not overridden on the CLI.
+	// Compression, encryption, dir, and retention take the server defaults if not overridden on the CLI.
 	if app.IsSet("etcd-snapshot-compress") {
 		sr.Compress = &cfg.EtcdSnapshotCompress
 	}
@@ -50,6 +53,18 @@ func commandSetup(app *cli.Context, cfg *cmds.Server) (*etcd.SnapshotRequest, *c
 	if app.IsSet("etcd-snapshot-retention") {
 		sr.Retention = &cfg.EtcdSnapshotRetention
 	}
+	if app.IsSet("etcd-snapshot-retention-policy") {
+		sr.RetentionPolicy = &cfg.EtcdSnapshotTiers
+	}
+	if app.IsSet("etcd-snapshot-remote-retention-policy") {
+		sr.RemoteRetentionPolicy = &cfg.EtcdRemoteSnapshotTiers
+	}
+	if app.IsSet("etcd-snapshot-encrypt") {
+		sr.Encrypt = &cfg.EtcdSnapshotEncrypt
+	}
+	if app.IsSet("etcd-snapshot-encryption-key-file") {
+		sr.KeyFile = &cfg.EtcdSnapshotKeyFile
+	}
 
 	if cfg.EtcdS3 {
 		sr.S3 = &config.EtcdS3{
@@ -66,11 +81,42 @@ func commandSetup(app *cli.Context, cfg *cmds.Server) (*etcd.SnapshotRequest, *c
 			SecretKey:     cfg.EtcdS3SecretKey,
 			SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
 			Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
+			Retention:     cfg.EtcdS3Retention,
+		}
+		if app.IsSet("etcd-s3-stream") {
+			sr.S3Stream = &cfg.EtcdS3Stream
 		}
 		// extend request timeout to allow the S3 operation to complete
 		timeout += cfg.EtcdS3Timeout
 	}
 
+	if cfg.EtcdRemoteDir != "" {
+		sr.RemoteDir = &config.EtcdRemoteDir{
+			Path:      cfg.EtcdRemoteDir,
+			Retention: cfg.EtcdRemoteDirRetention,
+		}
+	}
+
+	if cfg.EtcdSFTP {
+		sr.SFTP = &config.EtcdSFTP{
+			Endpoint:         cfg.EtcdSFTPEndpoint,
+			Username:         cfg.EtcdSFTPUsername,
+			Password:         cfg.EtcdSFTPPassword,
+			PrivateKey:       cfg.EtcdSFTPPrivateKey,
+			KnownHosts:       cfg.EtcdSFTPKnownHosts,
+			Folder:           cfg.EtcdSFTPFolder,
+			SkipHostKeyCheck: cfg.EtcdSFTPSkipHostKeyCheck,
+			Timeout:          metav1.Duration{Duration: cfg.EtcdSFTPTimeout},
+			Retention:        cfg.EtcdSFTPRetention,
+		}
+		// extend request timeout to allow the SFTP operation to complete
+		timeout += cfg.EtcdSFTPTimeout
+	}
+
+	if sr.S3Stream != nil && *sr.S3Stream && (sr.RemoteDir != nil || sr.SFTP != nil) {
+		return nil, nil, errors.New("invalid flag use; --etcd-s3-stream cannot be used with --etcd-remote-dir or --etcd-sftp")
+	}
+
 	dataDir, err := server.ResolveDataDir(cfg.DataDir)
 	if err != nil {
 		return nil, nil, err
@@ -110,9 +156,14 @@ func save(app *cli.Context, cfg *cmds.Server) error {
 		return util2.ErrCommandNoArgs
 	}
 
-	// Save always sets retention to 0 to disable automatic pruning.
+	// Save always sets retention to 0 and clears the retention policy to disable automatic pruning.
 	// Prune can be run manually after save, if desired.
 	app.Set("etcd-snapshot-retention", "0")
+	app.Set("etcd-snapshot-retention-policy", "")
+	app.Set("etcd-snapshot-remote-retention-policy", "")
+	app.Set("etcd-s3-retention", "0")
+	app.Set("etcd-remote-dir-retention", "0")
+	app.Set("etcd-sftp-retention", "0")
 
 	sr, info, err := commandSetup(app, cfg)
 	if err != nil {
@@ -280,6 +331,9 @@ func prune(app *cli.Context, cfg *cmds.Server) error {
 
 	sr.Operation = etcd.SnapshotOperationPrune
 	sr.Name = []string{cfg.EtcdSnapshotName}
+	if cfg.EtcdPruneDryRun {
+		sr.DryRun = &cfg.EtcdPruneDryRun
+	}
 
 	b, err := json.Marshal(sr)
 	if err != nil {
@@ -289,14 +343,229 @@ func prune(app *cli.Context, cfg *cmds.Server) error {
 	if err != nil {
 		return wrapServerError(err)
 	}
-	resp := &managed.SnapshotResult{}
+	resp := &etcd.PruneResult{}
 	if err := json.Unmarshal(r, resp); err != nil {
 		return err
 	}
 
+	if cfg.EtcdPruneDryRun {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprint(w, "Storage\tName\tCreated\tAction\tReason\n")
+		for _, d := range resp.Retention {
+			action, reason := "delete", "not retained by policy"
+			if d.Keep {
+				action, reason = "keep", strings.Join(d.Reasons, ", ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.Storage, d.Name, d.CreatedAt.Format(time.RFC3339), action, reason)
+		}
+		return nil
+	}
+
 	for _, name := range resp.Deleted {
 		logrus.Infof("Snapshot %s deleted.", name)
 	}
+	for _, d := range resp.Retention {
+		if d.Error != "" {
+			logrus.Errorf("Snapshot %s could not be deleted from %s storage: %s", d.Name, d.Storage, d.Error)
+		}
+	}
+
+	return nil
+}
+
+func Verify(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return verify(app, &cmds.ServerConfig)
+}
+
+func verify(app *cli.Context, cfg *cmds.Server) error {
+	if cfg.EtcdListFormat != "" && cfg.EtcdListFormat != "json" {
+		return errors.New("invalid output format: " + cfg.EtcdListFormat)
+	}
+
+	snapshots := app.Args()
+	if snapshots.Len() == 0 {
+		return errors.New("no snapshots given for verification")
+	}
+
+	sr, info, err := commandSetup(app, cfg)
+	if err != nil {
+		return err
+	}
+
+	sr.Operation = etcd.SnapshotOperationVerify
+	sr.Name = snapshots.Slice()
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	r, err := info.Post("/db/snapshot", b, clientaccess.WithTimeout(timeout))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	resp := &etcd.VerifyResult{}
+	if err := json.Unmarshal(r, resp); err != nil {
+		return err
+	}
+
+	if cfg.EtcdListFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprint(w, "Name\tStorage\tResult\tRevision\tKeys\tMessage\n")
+		for _, v := range resp.Snapshots {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", v.Name, v.Storage, v.Reason, v.Revision, v.TotalKey, v.Message)
+		}
+		w.Flush()
+	}
+
+	failed, withoutDigest := 0, 0
+	for _, v := range resp.Snapshots {
+		if !v.Verified {
+			failed++
+		} else if v.Reason == snapshot.ReasonVerifiedWithoutDigest {
+			withoutDigest++
+		}
+	}
+	if withoutDigest > 0 {
+		logrus.Warnf("%d of %d snapshots have no recorded digest; only the etcd checksum was verified for these snapshots", withoutDigest, len(resp.Snapshots))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d snapshots failed verification", failed, len(resp.Snapshots))
+	}
+	return nil
+}
+
+func Extract(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return extractSnapshot(app, &cmds.ServerConfig)
+}
+
+func extractSnapshot(app *cli.Context, cfg *cmds.Server) error {
+	// hide process arguments from ps output, since they may contain
+	// database credentials or other secrets.
+	proctitle.SetProcTitle(os.Args[0] + " etcd-snapshot")
+
+	if app.Args().Len() != 1 {
+		return errors.New("exactly one snapshot name or path must be given for extraction")
+	}
+
+	dataDir, err := server.ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return err
+	}
 
+	// The snapshot is read locally, so only the configuration needed to locate and decrypt it is required.
+	control := &config.Control{
+		DataDir:             dataDir,
+		Token:               cfg.Token,
+		EtcdSnapshotDir:     cfg.EtcdSnapshotDir,
+		EtcdSnapshotKeyFile: cfg.EtcdSnapshotKeyFile,
+	}
+	if control.Token == "" {
+		if tokenByte, err := os.ReadFile(filepath.Join(dataDir, "token")); err != nil {
+			logrus.Warnf("Unable to read server token; encrypted snapshots can only be extracted with the snapshot encryption key file: %v", err)
+		} else {
+			control.Token = string(bytes.TrimRight(tokenByte, "\n"))
+		}
+	}
+
+	out := os.Stdout
+	if cfg.EtcdExtractOutput != "" && cfg.EtcdExtractOutput != "-" {
+		f, err := os.OpenFile(cfg.EtcdExtractOutput, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	filter := extract.Filter{
+		Resources:  cfg.EtcdExtractResources.Value(),
+		Namespaces: cfg.EtcdExtractNamespaces.Value(),
+		Names:      cfg.EtcdExtractNames.Value(),
+	}
+	count, err := etcd.ExtractSnapshot(app.Context, control, app.Args().First(), filter, out)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Extracted %d resources from snapshot %s", count, app.Args().First())
+	return nil
+}
+
+func Diff(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	return diff(app, &cmds.ServerConfig)
+}
+
+func diff(app *cli.Context, cfg *cmds.Server) error {
+	if cfg.EtcdListFormat != "" && cfg.EtcdListFormat != "json" {
+		return errors.New("invalid output format: " + cfg.EtcdListFormat)
+	}
+
+	snapshots := app.Args()
+	if snapshots.Len() != 2 {
+		return errors.New("exactly two snapshots must be given for comparison")
+	}
+
+	sr, info, err := commandSetup(app, cfg)
+	if err != nil {
+		return err
+	}
+
+	sr.Operation = etcd.SnapshotOperationDiff
+	sr.Name = snapshots.Slice()
+	sr.Filter = &extract.Filter{
+		Resources:  cfg.EtcdExtractResources.Value(),
+		Namespaces: cfg.EtcdExtractNamespaces.Value(),
+		Names:      cfg.EtcdExtractNames.Value(),
+	}
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	r, err := info.Post("/db/snapshot", b, clientaccess.WithTimeout(timeout))
+	if err != nil {
+		return wrapServerError(err)
+	}
+	resp := &extract.DiffResult{}
+	if err := json.Unmarshal(r, resp); err != nil {
+		return err
+	}
+
+	if cfg.EtcdListFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(resp)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer w.Flush()
+	fmt.Fprint(w, "Change\tResource\tNamespace\tName\n")
+	for _, rd := range resp.Resources {
+		for _, changes := range []struct {
+			change string
+			names  []string
+		}{{"Added", rd.Added}, {"Removed", rd.Removed}, {"Modified", rd.Modified}} {
+			for _, name := range changes.names {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", changes.change, rd.Resource, rd.Namespace, name)
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%d added, %d removed, %d modified\n", resp.Added, resp.Removed, resp.Modified)
 	return nil
 }
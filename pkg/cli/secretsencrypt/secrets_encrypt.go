@@ -144,6 +144,21 @@ func Status(app *cli.Context) error {
 		fmt.Fprintf(w, "\t%s\t%s\n", ik[0], ik[1])
 	}
 	w.Flush()
+
+	if len(status.Resources) > 0 {
+		fmt.Fprintf(&tabBuffer, "\n")
+		w = tabwriter.NewWriter(&tabBuffer, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Resource\tEncrypted\tActive Key\n")
+		fmt.Fprintf(w, "--------\t---------\t----------\n")
+		for _, r := range status.Resources {
+			activeKey := r.ActiveKey
+			if activeKey == "" {
+				activeKey = "-"
+			}
+			fmt.Fprintf(w, "%s\t%t\t%s\n", r.Resource, r.Encrypted, activeKey)
+		}
+		w.Flush()
+	}
 	fmt.Println(statusOutput + tabBuffer.String())
 	return nil
 }
@@ -229,10 +244,34 @@ func RotateKeys(app *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	timeout := 90 * time.Second
+	// Allow extra time for the apiserver to pick up a new key ID from the KMS plugin, if in use
+	timeout := 90*time.Second + secretsencrypt.KMSKeyIDWaitTimeout
 	if err = info.Put("/v1-"+version.Program+"/encrypt/config", b, clientaccess.WithTimeout(timeout)); err != nil {
 		return wrapServerError(err)
 	}
 	fmt.Println("keys rotated, reencryption finished")
 	return nil
 }
+
+func Migrate(app *cli.Context) error {
+	if err := cmds.InitLogging(); err != nil {
+		return err
+	}
+	info, err := commandPrep(&cmds.ServerConfig)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(handlers.EncryptionRequest{
+		Stage:    ptr.To(secretsencrypt.EncryptionMigrate),
+		Provider: ptr.To(cmds.ServerConfig.EncryptMigrateTo),
+	})
+	if err != nil {
+		return err
+	}
+	timeout := 90 * time.Second
+	if err = info.Put("/v1-"+version.Program+"/encrypt/config", b, clientaccess.WithTimeout(timeout)); err != nil {
+		return wrapServerError(err)
+	}
+	fmt.Printf("secrets migrated to %s provider, reencryption finished\n", cmds.ServerConfig.EncryptMigrateTo)
+	return nil
+}
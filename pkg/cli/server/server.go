@@ -16,16 +16,19 @@ import (
 	"github.com/k3s-io/k3s/pkg/agent"
 	"github.com/k3s-io/k3s/pkg/agent/https"
 	"github.com/k3s-io/k3s/pkg/agent/loadbalancer"
+	"github.com/k3s-io/k3s/pkg/certmonitor"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/datadir"
 	"github.com/k3s-io/k3s/pkg/etcd"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
 	k3smetrics "github.com/k3s-io/k3s/pkg/metrics"
 	"github.com/k3s-io/k3s/pkg/proctitle"
 	"github.com/k3s-io/k3s/pkg/profile"
 	"github.com/k3s-io/k3s/pkg/rootless"
+	"github.com/k3s-io/k3s/pkg/secretsencrypt"
 	"github.com/k3s-io/k3s/pkg/server"
 	"github.com/k3s-io/k3s/pkg/spegel"
 	"github.com/k3s-io/k3s/pkg/util"
@@ -176,9 +179,16 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 	serverConfig.ControlConfig.ClusterInit = cfg.ClusterInit
 	serverConfig.ControlConfig.EncryptSecrets = cfg.EncryptSecrets
 	serverConfig.ControlConfig.EncryptProvider = cfg.EncryptProvider
+	serverConfig.ControlConfig.EncryptKMSEndpoint = cfg.EncryptKMSEndpoint
+	serverConfig.ControlConfig.EncryptKMSName = cfg.EncryptKMSName
+	serverConfig.ControlConfig.EncryptResources = secretsencrypt.NormalizeResources(util.SplitStringSlice(cfg.EncryptResources.Value()))
 	serverConfig.ControlConfig.EtcdExposeMetrics = cfg.EtcdExposeMetrics
 	serverConfig.ControlConfig.EtcdDisableSnapshots = cfg.EtcdDisableSnapshots
 	serverConfig.ControlConfig.SupervisorMetrics = cfg.SupervisorMetrics
+	serverConfig.ControlConfig.SupervisorAuditLogPath = cfg.SupervisorAuditLogPath
+	serverConfig.ControlConfig.SupervisorAuditLogMaxAge = cfg.SupervisorAuditLogMaxAge
+	serverConfig.ControlConfig.SupervisorAuditLogMaxBackup = cfg.SupervisorAuditLogMaxBackup
+	serverConfig.ControlConfig.SupervisorAuditLogMaxSize = cfg.SupervisorAuditLogMaxSize
 	serverConfig.ControlConfig.VLevel = cmds.LogConfig.VLevel
 	serverConfig.ControlConfig.VModule = cmds.LogConfig.VModule
 
@@ -187,11 +197,22 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 			return errors.New("etcd-snapshot-reconcile-interval must be greater than 0s")
 		}
 		serverConfig.ControlConfig.EtcdSnapshotCompress = cfg.EtcdSnapshotCompress
+		serverConfig.ControlConfig.EtcdSnapshotEncrypt = cfg.EtcdSnapshotEncrypt
+		serverConfig.ControlConfig.EtcdSnapshotKeyFile = cfg.EtcdSnapshotKeyFile
+		serverConfig.ControlConfig.EtcdSnapshotVerify = cfg.EtcdSnapshotVerify
 		serverConfig.ControlConfig.EtcdSnapshotName = cfg.EtcdSnapshotName
 		serverConfig.ControlConfig.EtcdSnapshotCron = cfg.EtcdSnapshotCron
 		serverConfig.ControlConfig.EtcdSnapshotDir = cfg.EtcdSnapshotDir
 		serverConfig.ControlConfig.EtcdSnapshotReconcile = metav1.Duration{Duration: cfg.EtcdSnapshotReconcile}
 		serverConfig.ControlConfig.EtcdSnapshotRetention = cfg.EtcdSnapshotRetention
+		if _, err := snapshot.ParseRetentionPolicy(cfg.EtcdSnapshotRetention, cfg.EtcdSnapshotTiers); err != nil {
+			return pkgerrors.WithMessage(err, "invalid etcd-snapshot-retention-policy")
+		}
+		if _, err := snapshot.ParseRetentionPolicy(cfg.EtcdSnapshotRetention, cfg.EtcdRemoteSnapshotTiers); err != nil {
+			return pkgerrors.WithMessage(err, "invalid etcd-snapshot-remote-retention-policy")
+		}
+		serverConfig.ControlConfig.EtcdSnapshotTiers = cfg.EtcdSnapshotTiers
+		serverConfig.ControlConfig.EtcdRemoteSnapshotTiers = cfg.EtcdRemoteSnapshotTiers
 		if cfg.EtcdS3 {
 			if cfg.EtcdS3Timeout <= 0 {
 				return errors.New("etcd-s3-timeout must be greater than 0s")
@@ -211,7 +232,36 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 				SessionToken:  cfg.EtcdS3SessionToken,
 				SkipSSLVerify: cfg.EtcdS3SkipSSLVerify,
 				Timeout:       metav1.Duration{Duration: cfg.EtcdS3Timeout},
+				Retention:     cfg.EtcdS3Retention,
+			}
+			serverConfig.ControlConfig.EtcdS3Stream = cfg.EtcdS3Stream
+		} else if cfg.EtcdS3Stream {
+			return errors.New("invalid flag use; --etcd-s3 required with --etcd-s3-stream")
+		}
+		if cfg.EtcdRemoteDir != "" {
+			serverConfig.ControlConfig.EtcdRemoteDir = &config.EtcdRemoteDir{
+				Path:      cfg.EtcdRemoteDir,
+				Retention: cfg.EtcdRemoteDirRetention,
+			}
+		}
+		if cfg.EtcdSFTP {
+			if cfg.EtcdSFTPTimeout <= 0 {
+				return errors.New("etcd-sftp-timeout must be greater than 0s")
 			}
+			serverConfig.ControlConfig.EtcdSFTP = &config.EtcdSFTP{
+				Endpoint:         cfg.EtcdSFTPEndpoint,
+				Username:         cfg.EtcdSFTPUsername,
+				Password:         cfg.EtcdSFTPPassword,
+				PrivateKey:       cfg.EtcdSFTPPrivateKey,
+				KnownHosts:       cfg.EtcdSFTPKnownHosts,
+				Folder:           cfg.EtcdSFTPFolder,
+				SkipHostKeyCheck: cfg.EtcdSFTPSkipHostKeyCheck,
+				Timeout:          metav1.Duration{Duration: cfg.EtcdSFTPTimeout},
+				Retention:        cfg.EtcdSFTPRetention,
+			}
+		}
+		if cfg.EtcdS3Stream && (cfg.EtcdRemoteDir != "" || cfg.EtcdSFTP) {
+			return errors.New("invalid flag use; --etcd-s3-stream cannot be used with --etcd-remote-dir or --etcd-sftp")
 		}
 	} else {
 		logrus.Info("ETCD snapshots are disabled")
@@ -583,6 +633,11 @@ func run(app *cli.Context, cfg *cmds.Server, leaderControllers server.CustomCont
 		return https.Start(ctx, nodeConfig, serverConfig.ControlConfig.Runtime)
 	}
 
+	// and for certificate info, which is always served so that certificates can be checked across the cluster.
+	certmonitor.Router = func(ctx context.Context, nodeConfig *config.Node) (*mux.Router, error) {
+		return https.Start(ctx, nodeConfig, serverConfig.ControlConfig.Runtime)
+	}
+
 	if cfg.DisableAgent {
 		agentConfig.ContainerRuntimeEndpoint = "/dev/null"
 		if err := agent.RunStandalone(ctx, agentConfig); err != nil {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -67,11 +68,15 @@ func create(app *cli.Context, cfg *cmds.Token) error {
 	}
 
 	bt := kubeadm.BootstrapToken{
-		Token:       bts,
-		Description: cfg.Description,
-		TTL:         &metav1.Duration{Duration: cfg.TTL},
-		Usages:      cfg.Usages.Value(),
-		Groups:      cfg.Groups.Value(),
+		Token:           bts,
+		Description:     cfg.Description,
+		TTL:             &metav1.Duration{Duration: cfg.TTL},
+		Usages:          cfg.Usages.Value(),
+		Groups:          cfg.Groups.Value(),
+		MaxJoins:        cfg.MaxJoins,
+		NodeNamePattern: cfg.NodeNamePattern,
+		AllowedCIDRs:    cfg.AllowedCIDRs.Value(),
+		Roles:           cfg.Roles.Value(),
 	}
 
 	secretName := bootstraputil.BootstrapTokenSecretName(bt.Token.ID)
@@ -244,11 +249,11 @@ func list(app *cli.Context, cfg *cmds.Token) error {
 		}
 		return nil
 	default:
-		format := "%s\t%s\t%s\t%s\t%s\t%s\n"
+		format := "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n"
 		w := tabwriter.NewWriter(os.Stdout, 10, 4, 3, ' ', 0)
 		defer w.Flush()
 
-		fmt.Fprintf(w, format, "TOKEN", "TTL", "EXPIRES", "USAGES", "DESCRIPTION", "EXTRA GROUPS")
+		fmt.Fprintf(w, format, "TOKEN", "TTL", "EXPIRES", "USAGES", "DESCRIPTION", "EXTRA GROUPS", "JOINS", "RESTRICTIONS")
 		for _, token := range tokens {
 			ttl := "<forever>"
 			expires := "<never>"
@@ -257,13 +262,33 @@ func list(app *cli.Context, cfg *cmds.Token) error {
 				expires = token.Expires.Format(time.RFC3339)
 			}
 
-			fmt.Fprintf(w, format, token.Token.ID, ttl, expires, joinOrNone(token.Usages...), joinOrNone(token.Description), joinOrNone(token.Groups...))
+			joins := strconv.Itoa(len(token.JoinedNodes))
+			if token.MaxJoins > 0 {
+				joins += "/" + strconv.Itoa(token.MaxJoins)
+			}
+
+			fmt.Fprintf(w, format, token.Token.ID, ttl, expires, joinOrNone(token.Usages...), joinOrNone(token.Description), joinOrNone(token.Groups...), joins, joinOrNone(restrictions(token)...))
 		}
 	}
 
 	return nil
 }
 
+// restrictions returns a list of the join restrictions set on a token, for display
+func restrictions(token *kubeadm.BootstrapToken) []string {
+	r := []string{}
+	if len(token.Roles) > 0 {
+		r = append(r, "roles="+strings.Join(token.Roles, "+"))
+	}
+	if token.NodeNamePattern != "" {
+		r = append(r, "node-name="+token.NodeNamePattern)
+	}
+	if len(token.AllowedCIDRs) > 0 {
+		r = append(r, "cidrs="+strings.Join(token.AllowedCIDRs, "+"))
+	}
+	return r
+}
+
 // joinOrNone joins strings with a comma. If the resulting output is an empty string,
 // it instead returns the replacement string "<none>"
 func joinOrNone(s ...string) string {
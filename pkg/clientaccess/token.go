@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/k3s-io/k3s/pkg/kubeadm"
+	"github.com/k3s-io/k3s/pkg/version"
 	pkgerrors "github.com/pkg/errors"
 	certutil "github.com/rancher/dynamiclistener/cert"
 	"github.com/sirupsen/logrus"
@@ -62,6 +63,7 @@ type Info struct {
 	Password string
 	CertFile string
 	KeyFile  string
+	NodeName string
 	caHash   string
 }
 
@@ -98,6 +100,14 @@ func WithClientCertificate(certFile, keyFile string) ValidationOption {
 	}
 }
 
+// WithNodeName configures the node name to be sent with requests, so that
+// the server can enforce any join restrictions set on the token.
+func WithNodeName(nodeName string) ValidationOption {
+	return func(i *Info) {
+		i.NodeName = nodeName
+	}
+}
+
 // WithUser overrides the username from the token with the provided value.
 func WithUser(username string) ValidationOption {
 	return func(i *Info) {
@@ -324,7 +334,7 @@ func (i *Info) Get(path string, options ...any) ([]byte, error) {
 	p.Scheme = u.Scheme
 	p.Host = u.Host
 	client := GetHTTPClient(i.CACerts, i.CertFile, i.KeyFile, options...)
-	return get(p.String(), client, i.Username, i.Password, i.Token(), options...)
+	return get(p.String(), client, i.Username, i.Password, i.Token(), i.requestOptions(options)...)
 }
 
 // Put makes a request to a subpath of info's BaseURL
@@ -340,7 +350,7 @@ func (i *Info) Put(path string, body []byte, options ...any) error {
 	p.Scheme = u.Scheme
 	p.Host = u.Host
 	client := GetHTTPClient(i.CACerts, i.CertFile, i.KeyFile, options...)
-	return put(p.String(), body, client, i.Username, i.Password, i.Token(), options...)
+	return put(p.String(), body, client, i.Username, i.Password, i.Token(), i.requestOptions(options)...)
 }
 
 // Post makes a request to a subpath of info's BaseURL
@@ -356,7 +366,15 @@ func (i *Info) Post(path string, body []byte, options ...any) ([]byte, error) {
 	p.Scheme = u.Scheme
 	p.Host = u.Host
 	client := GetHTTPClient(i.CACerts, i.CertFile, i.KeyFile, options...)
-	return post(p.String(), body, client, i.Username, i.Password, i.Token(), options...)
+	return post(p.String(), body, client, i.Username, i.Password, i.Token(), i.requestOptions(options)...)
+}
+
+// requestOptions returns the provided options, with the node name header added if a node name is set.
+func (i *Info) requestOptions(options []any) []any {
+	if i.NodeName != "" {
+		return append(options, WithHeader(version.Program+"-Node-Name", i.NodeName))
+	}
+	return options
 }
 
 // setServer sets the BaseURL and CACerts fields of the Info by connecting to the server
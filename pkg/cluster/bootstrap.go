@@ -511,6 +511,10 @@ func (c *Cluster) compareConfig() error {
 	if clusterControl.CriticalControlArgs.EncryptProvider == "" {
 		clusterControl.CriticalControlArgs.EncryptProvider = c.config.CriticalControlArgs.EncryptProvider
 	}
+	// If the remote server is down-level, for secrets-encryption-resources
+	if clusterControl.CriticalControlArgs.EncryptResources == nil {
+		clusterControl.CriticalControlArgs.EncryptResources = c.config.CriticalControlArgs.EncryptResources
+	}
 
 	if diff := deep.Equal(c.config.CriticalControlArgs, clusterControl.CriticalControlArgs); diff != nil {
 		rc := reflect.ValueOf(clusterControl.CriticalControlArgs).Type()
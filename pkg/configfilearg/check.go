@@ -0,0 +1,240 @@
+package configfilearg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "ERROR"
+	SeverityWarning = "WARNING"
+)
+
+// Problem describes an issue with a single key in a config file.
+type Problem struct {
+	File     string
+	Line     int
+	Key      string
+	Severity string
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s: %s", p.File, p.Line, p.Severity, p.Key, p.Message)
+}
+
+// Origin identifies the file and line that a config value was read from.
+type Origin struct {
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	return o.File + ":" + strconv.Itoa(o.Line)
+}
+
+// Value is the effective value of a config key, after merging the config file and all dropins
+// and interpolating environment variable and file references. Value is a string for single-value keys,
+// or a slice of strings for keys that are set multiple times. Secret is true if any part of the value was
+// read from a secret file.
+type Value struct {
+	Key     string
+	Value   interface{}
+	Origins []Origin
+	Secret  bool `json:"-" yaml:"-"`
+}
+
+// CheckResult contains the files that make up the configuration, any problems found
+// with the keys and values in those files, and the effective merged configuration.
+type CheckResult struct {
+	Files    []string
+	Problems []Problem
+	Values   []Value
+}
+
+// Errors returns the number of problems with error severity.
+func (r *CheckResult) Errors() int {
+	count := 0
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+// Check loads the config file and its dropins in the same order and with the same merge rules as
+// are used when running a command, and validates each key against the provided flags. Keys that
+// are listed in the deprecated map are reported as warnings, with the replacement key if one is set.
+func Check(file string, flags []cli.Flag, deprecated map[string]string) (*CheckResult, error) {
+	files, err := configFiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	flagsByName := map[string]cli.Flag{}
+	for _, f := range flags {
+		for _, name := range f.Names() {
+			flagsByName[name] = f
+		}
+	}
+
+	result := &CheckResult{Files: files}
+	values := map[string]*Value{}
+	for _, file := range files {
+		bytes, err := readConfigFileData(file)
+		if err != nil {
+			return nil, err
+		}
+
+		data := yaml.MapSlice{}
+		if err := yaml.Unmarshal(bytes, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		lines := keyLines(bytes)
+
+		for i, item := range data {
+			k, v := convert.ToString(item.Key), item.Value
+			isAppend := strings.HasSuffix(k, "+")
+			k = strings.TrimSuffix(k, "+")
+			secret := false
+			origin := Origin{File: file}
+			if i < len(lines) {
+				origin.Line = lines[i]
+			}
+
+			// Values are checked after interpolation, as they will be when the command is run.
+			var message, severity string
+			if interpolated, isSecret, err := interpolate(v); err != nil {
+				message, severity = err.Error(), SeverityError
+			} else {
+				v, secret = interpolated, isSecret
+				message, severity = checkValue(flagsByName[k], k, v, isAppend, deprecated)
+			}
+			if message == "" && !interpolationEnabled() && hasReference(v) {
+				message, severity = "value contains an environment variable or file reference, which is used literally unless "+InterpolationEnvName+"=true is set", SeverityWarning
+			}
+			if message != "" {
+				result.Problems = append(result.Problems, Problem{File: origin.File, Line: origin.Line, Key: k, Severity: severity, Message: message})
+			}
+
+			if value, ok := values[k]; ok && isAppend {
+				value.Value = append(toSlice(value.Value), toSlice(v)...)
+				value.Origins = append(value.Origins, origin)
+				value.Secret = value.Secret || secret
+			} else if ok {
+				value.Value = v
+				value.Origins = []Origin{origin}
+				value.Secret = secret
+			} else {
+				values[k] = &Value{Key: k, Value: v, Origins: []Origin{origin}, Secret: secret}
+				result.Values = append(result.Values, Value{Key: k})
+			}
+		}
+	}
+
+	// Convert values to the strings that will be passed as flags, preserving the order in which keys were first seen.
+	for i := range result.Values {
+		value := values[result.Values[i].Key]
+		if slice, ok := value.Value.([]interface{}); ok {
+			strs := make([]string, len(slice))
+			for j, v := range slice {
+				strs[j] = convert.ToString(v)
+			}
+			value.Value = strs
+		} else {
+			value.Value = convert.ToString(value.Value)
+		}
+		result.Values[i] = *value
+	}
+
+	return result, nil
+}
+
+// checkValue validates a single config key and value against the flag with that name, returning
+// a message and severity if there is a problem.
+func checkValue(f cli.Flag, key string, value interface{}, isAppend bool, deprecated map[string]string) (string, string) {
+	if f == nil {
+		return "unknown key", SeverityError
+	}
+
+	if replacement, ok := deprecated[key]; ok {
+		if replacement != "" {
+			return "deprecated key; use " + replacement + " instead", SeverityWarning
+		}
+		return "deprecated key", SeverityWarning
+	}
+
+	var values []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case yaml.MapSlice, map[interface{}]interface{}:
+		return "value must be a string, number, boolean, or list", SeverityError
+	default:
+		values = []interface{}{v}
+	}
+
+	isSlice := false
+	switch f.(type) {
+	case *cli.StringSliceFlag, *cli.IntSliceFlag, *cli.Int64SliceFlag, *cli.Float64SliceFlag, *cli.UintSliceFlag, *cli.Uint64SliceFlag:
+		isSlice = true
+	}
+	if !isSlice {
+		if len(values) > 1 {
+			return "key accepts a single value, but a list was provided; only the last value will be used", SeverityError
+		}
+		if isAppend {
+			return "key accepts a single value; appending with '+' will replace the previous value", SeverityWarning
+		}
+	}
+
+	for _, v := range values {
+		switch v.(type) {
+		case yaml.MapSlice, map[interface{}]interface{}, []interface{}:
+			return "list items must be strings, numbers, or booleans", SeverityError
+		}
+
+		str := convert.ToString(v)
+		var err error
+		switch f.(type) {
+		case *cli.BoolFlag:
+			_, err = strconv.ParseBool(str)
+		case *cli.IntFlag, *cli.Int64Flag, *cli.IntSliceFlag, *cli.Int64SliceFlag:
+			_, err = strconv.ParseInt(str, 0, 64)
+		case *cli.UintFlag, *cli.Uint64Flag, *cli.UintSliceFlag, *cli.Uint64SliceFlag:
+			_, err = strconv.ParseUint(str, 0, 64)
+		case *cli.Float64Flag, *cli.Float64SliceFlag:
+			_, err = strconv.ParseFloat(str, 64)
+		case *cli.DurationFlag:
+			_, err = time.ParseDuration(str)
+		}
+		if err != nil {
+			return fmt.Sprintf("invalid value %q: %v", str, err), SeverityError
+		}
+	}
+
+	return "", ""
+}
+
+// keyLines returns the line numbers of the top-level keys in a YAML document, in order.
+func keyLines(bytes []byte) []int {
+	doc := yamlv3.Node{}
+	if err := yamlv3.Unmarshal(bytes, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	var lines []int
+	if mapping := doc.Content[0]; mapping.Kind == yamlv3.MappingNode {
+		for i := 0; i < len(mapping.Content); i += 2 {
+			lines = append(lines, mapping.Content[i].Line)
+		}
+	}
+	return lines
+}
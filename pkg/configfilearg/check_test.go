@@ -0,0 +1,76 @@
+package configfilearg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func Test_UnitCheck(t *testing.T) {
+	flags := []cli.Flag{
+		&cli.StringFlag{Name: "write-kubeconfig-mode"},
+		&cli.BoolFlag{Name: "debug"},
+		&cli.StringSliceFlag{Name: "node-label"},
+		&cli.StringSliceFlag{Name: "kube-controller-arg"},
+		&cli.StringSliceFlag{Name: "tls-san"},
+		&cli.IntFlag{Name: "etcd-snapshot-retention"},
+		&cli.StringSliceFlag{Name: "kube-apiserver-arg"},
+		&cli.StringFlag{Name: "etcd-snapshot-schedule-cron"},
+	}
+	deprecated := map[string]string{"kube-controller-arg": "kube-controller-manager-arg"}
+
+	result, err := Check("./testdata/check.yaml", flags, deprecated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []string{"./testdata/check.yaml", "testdata/check.yaml.d/01-dropin.yaml"}
+	if !reflect.DeepEqual(result.Files, wantFiles) {
+		t.Errorf("Check() files = %v, want %v", result.Files, wantFiles)
+	}
+
+	wantProblems := []Problem{
+		{File: "./testdata/check.yaml", Line: 5, Key: "kube-controller-arg", Severity: SeverityWarning, Message: "deprecated key; use kube-controller-manager-arg instead"},
+		{File: "./testdata/check.yaml", Line: 7, Key: "unknown-key", Severity: SeverityError, Message: "unknown key"},
+		{File: "testdata/check.yaml.d/01-dropin.yaml", Line: 3, Key: "debug", Severity: SeverityError, Message: `invalid value "maybe": strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{File: "testdata/check.yaml.d/01-dropin.yaml", Line: 4, Key: "etcd-snapshot-retention", Severity: SeverityError, Message: "key accepts a single value, but a list was provided; only the last value will be used"},
+		{File: "testdata/check.yaml.d/01-dropin.yaml", Line: 6, Key: "kube-apiserver-arg", Severity: SeverityError, Message: "value must be a string, number, boolean, or list"},
+		{File: "testdata/check.yaml.d/01-dropin.yaml", Line: 8, Key: "etcd-snapshot-schedule-cron", Severity: SeverityWarning, Message: "key accepts a single value; appending with '+' will replace the previous value"},
+	}
+	if !reflect.DeepEqual(result.Problems, wantProblems) {
+		t.Errorf("Check() problems = %v, want %v", result.Problems, wantProblems)
+	}
+	if errors := result.Errors(); errors != 4 {
+		t.Errorf("Errors() = %d, want 4", errors)
+	}
+
+	wantValues := map[string]Value{
+		"node-label": {
+			Key:     "node-label",
+			Value:   []string{"foo=bar", "baz=qux"},
+			Origins: []Origin{{File: "./testdata/check.yaml", Line: 3}, {File: "testdata/check.yaml.d/01-dropin.yaml", Line: 1}},
+		},
+		"tls-san": {
+			Key:     "tls-san",
+			Value:   "two",
+			Origins: []Origin{{File: "testdata/check.yaml.d/01-dropin.yaml", Line: 5}},
+		},
+		"write-kubeconfig-mode": {
+			Key:     "write-kubeconfig-mode",
+			Value:   "0644",
+			Origins: []Origin{{File: "./testdata/check.yaml", Line: 1}},
+		},
+	}
+	keys := []string{}
+	for _, value := range result.Values {
+		keys = append(keys, value.Key)
+		if want, ok := wantValues[value.Key]; ok && !reflect.DeepEqual(value, want) {
+			t.Errorf("Check() value = %+v, want %+v", value, want)
+		}
+	}
+	wantKeys := []string{"write-kubeconfig-mode", "debug", "node-label", "kube-controller-arg", "tls-san", "unknown-key", "etcd-snapshot-retention", "kube-apiserver-arg", "etcd-snapshot-schedule-cron"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("Check() keys = %v, want %v", keys, wantKeys)
+	}
+}
@@ -63,6 +63,18 @@ func Test_UnitMustParse(t *testing.T) {
 			want: []string{"k3s", "agent", "--token=12345", "--node-label=DEAFBEEF",
 				"--etcd-s3=true", "--etcd-s3-bucket=my-backup", "--notaflag=true", "--kubelet-arg=max-pods=999"},
 		},
+		{
+			name:   "Agent lb with config with known and unknown flags",
+			args:   []string{"k3s", "agent", "lb", "status"},
+			config: "./testdata/defaultdata.yaml",
+			want:   []string{"k3s", "agent", "lb", "status"},
+		},
+		{
+			name:   "Agent lb with config with data-dir",
+			args:   []string{"k3s", "agent", "lb", "drain", "10.0.0.1:6443"},
+			config: "./testdata/datadir.yaml",
+			want:   []string{"k3s", "agent", "--data-dir=/opt/k3s", "lb", "drain", "10.0.0.1:6443"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
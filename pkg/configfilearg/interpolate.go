@@ -0,0 +1,152 @@
+package configfilearg
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// FilePrefix marks a config value that should be read from a file.
+	FilePrefix = "file:"
+	// SecretFilePrefix marks a config value that should be read from a file, and that contains a secret.
+	// Secret files should not be readable by other users, and their values are not displayed by config check.
+	SecretFilePrefix = "secret-file:"
+)
+
+// InterpolationEnvName is the environment variable that enables interpolation of environment variable and file
+// references in config files. Interpolation is disabled by default, so that existing config values that contain
+// ${...} or start with a file prefix continue to be used literally.
+var InterpolationEnvName = version.ProgramUpper + "_CONFIG_INTERPOLATION"
+
+// filePrefixes lists the prefixes that mark a config value as a file reference. A prefix followed by an
+// additional colon, as in file::value, is an escaped literal.
+var filePrefixes = []string{SecretFilePrefix, FilePrefix}
+
+// envVarRegexp matches ${NAME} environment variable references. A reference
+// preceded by an additional $, as in $${NAME}, is an escaped literal.
+var envVarRegexp = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolationEnabled returns true if interpolation of config values has been enabled.
+func interpolationEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(InterpolationEnvName))
+	return enabled
+}
+
+// interpolate expands environment variable and file references in a config value, if interpolation is enabled.
+// Strings, and strings within lists, are interpolated; other values are returned unmodified.
+// The returned bool is true if any part of the value was read from a secret file.
+func interpolate(value interface{}) (interface{}, bool, error) {
+	if !interpolationEnabled() {
+		return value, false, nil
+	}
+	return interpolateValue(value)
+}
+
+// interpolateValue expands environment variable and file references in strings, and strings within lists.
+func interpolateValue(value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		return interpolateString(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		isSecret := false
+		for i, item := range v {
+			var err error
+			var secret bool
+			if result[i], secret, err = interpolateValue(item); err != nil {
+				return nil, false, err
+			}
+			isSecret = isSecret || secret
+		}
+		return result, isSecret, nil
+	default:
+		return value, false, nil
+	}
+}
+
+// interpolateString expands environment variable references in the string, and then
+// replaces the string with the contents of the referenced file if the result has a file prefix.
+// Escaped file prefixes are unescaped, and the value is otherwise returned unmodified.
+func interpolateString(s string) (string, bool, error) {
+	var err error
+	s = envVarRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		groups := envVarRegexp.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return match[1:]
+		}
+		value, ok := os.LookupEnv(groups[2])
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", groups[2])
+		}
+		return value
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, prefix := range filePrefixes {
+		if value, ok := strings.CutPrefix(s, prefix+":"); ok {
+			return prefix + value, false, nil
+		}
+	}
+
+	if path, ok := strings.CutPrefix(s, SecretFilePrefix); ok {
+		value, err := readValueFile(path)
+		if err != nil {
+			return "", false, err
+		}
+		if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+			logrus.Warnf("Secret file %s is accessible by other users; permissions should be 0600 or more restrictive", path)
+		}
+		return value, true, nil
+	}
+
+	if path, ok := strings.CutPrefix(s, FilePrefix); ok {
+		value, err := readValueFile(path)
+		return value, false, err
+	}
+
+	return s, false, nil
+}
+
+// hasReference returns true if a config value, or any string within it, contains an environment variable
+// or file reference that would be expanded if interpolation were enabled.
+func hasReference(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		if envVarRegexp.MatchString(v) {
+			return true
+		}
+		for _, prefix := range filePrefixes {
+			if strings.HasPrefix(v, prefix) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasReference(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readValueFile returns the contents of a file referenced by a config value, with leading and trailing whitespace removed.
+func readValueFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("file reference is missing a path")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read referenced file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
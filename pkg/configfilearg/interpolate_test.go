@@ -0,0 +1,156 @@
+package configfilearg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_UnitInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(InterpolationEnvName, "true")
+	t.Setenv("NODE_ZONE", "zone-a")
+	t.Setenv("SECRETS_DIR", dir)
+
+	tests := []struct {
+		name       string
+		value      interface{}
+		want       interface{}
+		wantSecret bool
+		wantErr    bool
+	}{
+		{
+			name:  "Literal string",
+			value: "plain value",
+			want:  "plain value",
+		},
+		{
+			name:  "Non-string value",
+			value: true,
+			want:  true,
+		},
+		{
+			name:  "Environment variable",
+			value: "topology.kubernetes.io/zone=${NODE_ZONE}",
+			want:  "topology.kubernetes.io/zone=zone-a",
+		},
+		{
+			name:  "Escaped environment variable",
+			value: "$${NODE_ZONE}",
+			want:  "${NODE_ZONE}",
+		},
+		{
+			name:  "Unbraced environment variable",
+			value: "pa$$word$NODE_ZONE",
+			want:  "pa$$word$NODE_ZONE",
+		},
+		{
+			name:    "Missing environment variable",
+			value:   "${MISSING_VARIABLE_FOR_TEST}",
+			wantErr: true,
+		},
+		{
+			name:  "File reference",
+			value: "file:" + tokenFile,
+			want:  "secret-token",
+		},
+		{
+			name:       "Secret file reference with environment variable",
+			value:      "secret-file:${SECRETS_DIR}/token",
+			want:       "secret-token",
+			wantSecret: true,
+		},
+		{
+			name:  "Escaped file reference",
+			value: "file::" + tokenFile,
+			want:  "file:" + tokenFile,
+		},
+		{
+			name:  "Escaped secret file reference",
+			value: "secret-file::${NODE_ZONE}",
+			want:  "secret-file:zone-a",
+		},
+		{
+			name:    "Missing file",
+			value:   "file:" + filepath.Join(dir, "missing"),
+			wantErr: true,
+		},
+		{
+			name:       "List",
+			value:      []interface{}{"zone=${NODE_ZONE}", 1, "secret-file:" + tokenFile},
+			want:       []interface{}{"zone=zone-a", 1, "secret-token"},
+			wantSecret: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, secret, err := interpolate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("interpolate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("interpolate() = %#v, want %#v", got, tt.want)
+			}
+			if secret != tt.wantSecret {
+				t.Errorf("interpolate() secret = %v, want %v", secret, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func Test_UnitInterpolateDisabled(t *testing.T) {
+	t.Setenv("NODE_ZONE", "zone-a")
+
+	for _, value := range []interface{}{"${NODE_ZONE}", "${MISSING_VARIABLE_FOR_TEST}", "file:/missing", []interface{}{"secret-file:/missing"}} {
+		got, secret, err := interpolate(value)
+		if err != nil || secret || !reflect.DeepEqual(got, value) {
+			t.Errorf("interpolate(%#v) = %#v, %v, %v, want value unmodified", value, got, secret, err)
+		}
+		if !hasReference(value) {
+			t.Errorf("hasReference(%#v) = false, want true", value)
+		}
+	}
+	for _, value := range []interface{}{"plain value", "pa$$word$NODE_ZONE", true, []interface{}{"zone=a", 1}} {
+		if hasReference(value) {
+			t.Errorf("hasReference(%#v) = true, want false", value)
+		}
+	}
+}
+
+func Test_UnitReadConfigFileInterpolation(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(InterpolationEnvName, "true")
+	t.Setenv("NODE_ZONE", "zone-a")
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.yaml")
+	config := "token: secret-file:" + filepath.Join(dir, "token") + "\nnode-label:\n- zone=${NODE_ZONE}\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readConfigFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--token=secret-token", "--node-label=zone=zone-a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readConfigFile() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(configFile, []byte("node-name: ${MISSING_VARIABLE_FOR_TEST}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfigFile(configFile); err == nil {
+		t.Errorf("readConfigFile() did not return an error for a missing environment variable")
+	}
+}
@@ -51,7 +51,14 @@ func (p *Parser) Parse(args []string) ([]string, error) {
 			return nil, err
 		}
 		if len(args) > 1 {
-			values, err = p.stripInvalidFlags(args[1], values)
+			// Subcommands may have their own set of valid flags, keyed by the command and subcommand names.
+			command := args[1]
+			if len(suffix) > 0 {
+				if _, ok := p.ValidFlags[command+" "+suffix[0]]; ok {
+					command += " " + suffix[0]
+				}
+			}
+			values, err = p.stripInvalidFlags(command, values)
 			if err != nil {
 				return nil, err
 			}
@@ -135,10 +142,15 @@ func (p *Parser) FindString(args []string, target string) (string, error) {
 			return "", err
 		}
 		for _, i := range data {
-			k, v := convert.ToString(i.Key), convert.ToString(i.Value)
+			k := convert.ToString(i.Key)
 			isAppend := strings.HasSuffix(k, "+")
 			k = strings.TrimSuffix(k, "+")
 			if k == target {
+				value, _, err := interpolate(i.Value)
+				if err != nil {
+					return "", fmt.Errorf("failed to interpolate value of %s in %s: %w", k, file, err)
+				}
+				v := convert.ToString(value)
 				if isAppend {
 					lastVal = lastVal + "," + v
 				} else {
@@ -244,22 +256,11 @@ func dotDFiles(basefile string) (result []string, _ error) {
 // file, and any config file dropins in the dropin directory that corresponds to that
 // config file.  The config file or at least one dropin must exist.
 func readConfigFile(file string) (result []string, _ error) {
-	files, err := dotDFiles(file)
+	files, err := configFiles(file)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err = os.Stat(file); err != nil {
-		// If the config file doesn't exist and we have dropins that's fine.
-		// Other errors are bubbled up regardless of how many dropins we have.
-		if !(os.IsNotExist(err) && len(files) > 0) {
-			return nil, err
-		}
-	} else {
-		// The config file exists, load it first.
-		files = append([]string{file}, files...)
-	}
-
 	var (
 		keySeen  = map[string]bool{}
 		keyOrder []string
@@ -277,10 +278,15 @@ func readConfigFile(file string) (result []string, _ error) {
 		}
 
 		for _, i := range data {
-			k, v := convert.ToString(i.Key), i.Value
+			k := convert.ToString(i.Key)
 			isAppend := strings.HasSuffix(k, "+")
 			k = strings.TrimSuffix(k, "+")
 
+			v, _, err := interpolate(i.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to interpolate value of %s in %s: %w", k, file, err)
+			}
+
 			if !keySeen[k] {
 				keySeen[k] = true
 				keyOrder = append(keyOrder, k)
@@ -315,6 +321,27 @@ func readConfigFile(file string) (result []string, _ error) {
 	return
 }
 
+// configFiles returns the list of files that make up the configuration: the config file
+// itself if it exists, followed by any dropins. The config file or at least one dropin must exist.
+func configFiles(file string) ([]string, error) {
+	files, err := dotDFiles(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = os.Stat(file); err != nil {
+		// If the config file doesn't exist and we have dropins that's fine.
+		// Other errors are bubbled up regardless of how many dropins we have.
+		if !(os.IsNotExist(err) && len(files) > 0) {
+			return nil, err
+		}
+	} else {
+		// The config file exists, load it first.
+		files = append([]string{file}, files...)
+	}
+	return files, nil
+}
+
 func toSlice(v interface{}) []interface{} {
 	switch k := v.(type) {
 	case string:
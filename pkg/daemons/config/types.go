@@ -7,10 +7,11 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/k3s-io/api/pkg/generated/controllers/k3s.cattle.io"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
 	"github.com/k3s-io/kine/pkg/endpoint"
-	"github.com/rancher/wharfie/pkg/registries"
 	"github.com/rancher/wrangler/v3/pkg/generated/controllers/core"
 	"github.com/rancher/wrangler/v3/pkg/generated/controllers/discovery"
 	"github.com/rancher/wrangler/v3/pkg/leader"
@@ -77,6 +78,24 @@ type EtcdS3 struct {
 	Insecure      bool            `json:"insecure,omitempty"`
 	SkipSSLVerify bool            `json:"skipSSLVerify,omitempty"`
 	Timeout       metav1.Duration `json:"timeout,omitempty"`
+	Retention     int             `json:"retention,omitempty"`
+}
+
+type EtcdRemoteDir struct {
+	Path      string `json:"path,omitempty"`
+	Retention int    `json:"retention,omitempty"`
+}
+
+type EtcdSFTP struct {
+	Endpoint         string          `json:"endpoint,omitempty"`
+	Username         string          `json:"username,omitempty"`
+	Password         string          `json:"password,omitempty"`
+	PrivateKey       string          `json:"privateKey,omitempty"`
+	KnownHosts       string          `json:"knownHosts,omitempty"`
+	Folder           string          `json:"folder,omitempty"`
+	SkipHostKeyCheck bool            `json:"skipHostKeyCheck,omitempty"`
+	Timeout          metav1.Duration `json:"timeout,omitempty"`
+	Retention        int             `json:"retention,omitempty"`
 }
 
 type Containerd struct {
@@ -148,7 +167,8 @@ type Agent struct {
 	ImageCredProvConfig     string
 	IPSECPSK                string
 	FlannelCniConfFile      string
-	Registry                *registries.Registry
+	Registry                atomic.Pointer[registryconfig.Registry] // replaced when the registry configuration is reloaded
+	PrivateRegistry         string
 	SystemDefaultRegistry   string
 	AirgapExtraRegistry     []string
 	DisableCCM              bool
@@ -180,6 +200,7 @@ type CriticalControlArgs struct {
 	DisableServiceLB      bool         `cli:"disable-service-lb"`
 	EncryptSecrets        bool         `cli:"secrets-encryption"`
 	EncryptProvider       string       `cli:"secrets-encryption-provider"`
+	EncryptResources      []string     `cli:"secrets-encryption-resources"`
 	EmbeddedRegistry      bool         `cli:"embedded-registry"`
 	FlannelBackend        string       `cli:"flannel-backend"`
 	FlannelIPv6Masq       bool         `cli:"flannel-ipv6-masq"`
@@ -231,6 +252,8 @@ type Control struct {
 	IPSECPSK                 string
 	DefaultLocalStoragePath  string
 	Skips                    map[string]bool
+	EncryptKMSEndpoint       string
+	EncryptKMSName           string
 	SystemDefaultRegistry    string
 	ClusterInit              bool
 	ClusterReset             bool
@@ -246,13 +269,26 @@ type Control struct {
 	EtcdSnapshotCron         string          `json:"-"`
 	EtcdSnapshotReconcile    metav1.Duration `json:"-"`
 	EtcdSnapshotRetention    int             `json:"-"`
+	EtcdSnapshotTiers        string          `json:"-"`
+	EtcdRemoteSnapshotTiers  string          `json:"-"`
 	EtcdSnapshotCompress     bool            `json:"-"`
+	EtcdSnapshotEncrypt      bool            `json:"-"`
+	EtcdSnapshotKeyFile      string          `json:"-"`
+	EtcdSnapshotVerify       bool            `json:"-"`
 	EtcdListFormat           string          `json:"-"`
 	EtcdS3                   *EtcdS3         `json:"-"`
+	EtcdS3Stream             bool            `json:"-"`
+	EtcdRemoteDir            *EtcdRemoteDir  `json:"-"`
+	EtcdSFTP                 *EtcdSFTP       `json:"-"`
 	ServerNodeName           string
 	VLevel                   int
 	VModule                  string
 
+	SupervisorAuditLogPath      string
+	SupervisorAuditLogMaxAge    int
+	SupervisorAuditLogMaxBackup int
+	SupervisorAuditLogMaxSize   int
+
 	BindAddress string
 	SANs        []string
 	SANSecurity bool
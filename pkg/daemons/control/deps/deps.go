@@ -2,19 +2,24 @@ package deps
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/template"
 	"time"
@@ -321,6 +326,72 @@ func genCerts(config *config.Control) error {
 	return genETCDCerts(config)
 }
 
+// RenewCerts regenerates any leaf certificates that are within CertificateRenewDays of expiring,
+// and returns the paths of the certificates that were renewed. CA certificates are not modified.
+// Kubeconfigs and component flags reference certificates by path, and the apiserver, kubelet, etcd
+// and supervisor all load certificates from disk as they are used, so the renewed certificates are
+// picked up without restarting any components.
+func RenewCerts(config *config.Control) ([]string, error) {
+	certDir := filepath.Join(config.DataDir, "tls")
+	before, err := readCertFiles(certDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// genCerts only regenerates certificates that are expiring, so it does not
+	// need to be run at all unless at least one leaf certificate is expiring.
+	renewed := []string{}
+	expiring := false
+	for file := range before {
+		if expiredLeaf(file) {
+			expiring = true
+			break
+		}
+	}
+	if !expiring {
+		return renewed, nil
+	}
+
+	if err := genCerts(config); err != nil {
+		return nil, err
+	}
+
+	after, err := readCertFiles(certDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for file, certBytes := range after {
+		if !bytes.Equal(before[file], certBytes) {
+			renewed = append(renewed, file)
+		}
+	}
+	slices.Sort(renewed)
+	return renewed, nil
+}
+
+// readCertFiles returns the contents of all certificate files in the given directory tree.
+func readCertFiles(dir string) (map[string][]byte, error) {
+	certFiles := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".crt" {
+			return nil
+		}
+		certBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		certFiles[path] = certBytes
+		return nil
+	})
+	return certFiles, err
+}
+
 func getSigningCertFactory(regen bool, altNames *certutil.AltNames, extKeyUsage []x509.ExtKeyUsage, caCertFile, caKeyFile string) signedCertFactory {
 	return func(commonName string, organization []string, certFile, keyFile string) (bool, error) {
 		return createClientCertKey(regen, commonName, organization, altNames, extKeyUsage, caCertFile, caKeyFile, certFile, keyFile)
@@ -726,9 +797,19 @@ func genServiceAccount(runtime *config.ControlRuntime) error {
 
 func createSigningCertKey(prefix, certFile, keyFile string) (bool, error) {
 	if exists(certFile, keyFile) {
+		if certs, err := certutil.CertsFromFile(certFile); err == nil {
+			if err := util.VerifyCAChain(certs); err != nil {
+				logrus.Warnf("CA certificate %s is not valid: %v", certFile, err)
+			}
+		}
 		return false, nil
 	}
 
+	// Do not self-sign the CA if a certificate request has been generated for signing by an external CA.
+	if csrFile := caCSRFile(certFile); exists(keyFile, csrFile) {
+		return false, fmt.Errorf("certificate request %s must be signed by an external CA, and the certificate chain saved to %s", csrFile, certFile)
+	}
+
 	caKeyBytes, _, err := certutil.LoadOrGenerateKeyFile(keyFile, false)
 	if err != nil {
 		return false, err
@@ -754,6 +835,71 @@ func createSigningCertKey(prefix, certFile, keyFile string) (bool, error) {
 	return true, nil
 }
 
+// GenCACertificateRequests generates a private key and certificate request for each cluster CA, so that the
+// CAs can be signed by an external root or intermediate CA. Existing CA keys are reused. The paths of the
+// certificate requests are returned; the signed certificate chain for each CA should be saved alongside the
+// request with a .crt extension, ordered from the CA certificate up to the root CA.
+func GenCACertificateRequests(config *config.Control) ([]string, error) {
+	runtime := config.Runtime
+	signingCerts := []struct {
+		prefix   string
+		certFile string
+		keyFile  string
+	}{
+		{version.Program + "-client", runtime.ClientCA, runtime.ClientCAKey},
+		{version.Program + "-server", runtime.ServerCA, runtime.ServerCAKey},
+		{version.Program + "-request-header", runtime.RequestHeaderCA, runtime.RequestHeaderCAKey},
+		{"etcd-server", runtime.ETCDServerCA, runtime.ETCDServerCAKey},
+		{"etcd-peer", runtime.ETCDPeerCA, runtime.ETCDPeerCAKey},
+	}
+
+	// Request a CA certificate that can sign certificates and CRLs
+	basicConstraints, err := asn1.Marshal(struct{ IsCA bool }{IsCA: true})
+	if err != nil {
+		return nil, err
+	}
+	keyUsage, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x86}, BitLength: 7})
+	if err != nil {
+		return nil, err
+	}
+
+	csrFiles := []string{}
+	for _, sc := range signingCerts {
+		if err := os.MkdirAll(filepath.Dir(sc.keyFile), 0700); err != nil {
+			return nil, err
+		}
+		keyBytes, _, err := certutil.LoadOrGenerateKeyFile(sc.keyFile, false)
+		if err != nil {
+			return nil, err
+		}
+		key, err := certutil.ParsePrivateKeyPEM(keyBytes)
+		if err != nil {
+			return nil, err
+		}
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: fmt.Sprintf("%s-ca@%d", sc.prefix, time.Now().Unix())},
+			ExtraExtensions: []pkix.Extension{
+				{Id: asn1.ObjectIdentifier{2, 5, 29, 19}, Critical: true, Value: basicConstraints},
+				{Id: asn1.ObjectIdentifier{2, 5, 29, 15}, Critical: true, Value: keyUsage},
+			},
+		}, key)
+		if err != nil {
+			return nil, err
+		}
+		csrFile := caCSRFile(sc.certFile)
+		if err := os.WriteFile(csrFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), 0600); err != nil {
+			return nil, err
+		}
+		csrFiles = append(csrFiles, csrFile)
+	}
+	return csrFiles, nil
+}
+
+// caCSRFile returns the path of the certificate request for a CA certificate.
+func caCSRFile(certFile string) string {
+	return strings.TrimSuffix(certFile, filepath.Ext(certFile)) + ".csr"
+}
+
 func expired(certFile string) bool {
 	certificates, err := certutil.CertsFromFile(certFile)
 	if err != nil {
@@ -762,6 +908,16 @@ func expired(certFile string) bool {
 	return certutil.IsCertExpired(certificates[0], config.CertificateRenewDays)
 }
 
+// expiredLeaf returns true if a certificate file contains a leaf certificate that is within
+// CertificateRenewDays of expiring. CA certificates are not renewed, and are ignored.
+func expiredLeaf(certFile string) bool {
+	certificates, err := certutil.CertsFromFile(certFile)
+	if err != nil {
+		return false
+	}
+	return !certificates[0].IsCA && certutil.IsCertExpired(certificates[0], config.CertificateRenewDays)
+}
+
 func genEncryptionConfigAndState(controlConfig *config.Control) error {
 	runtime := controlConfig.Runtime
 	if !controlConfig.EncryptSecrets {
@@ -771,25 +927,65 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 	switch controlConfig.EncryptProvider {
 	case secretsencrypt.AESCBCProvider:
 		keyName = "aescbckey"
+	case secretsencrypt.AESGCMProvider:
+		keyName = "aesgcmkey"
 	case secretsencrypt.SecretBoxProvider:
 		keyName = "secretboxkey"
+	case secretsencrypt.KMSProvider:
+		if controlConfig.EncryptKMSEndpoint == "" {
+			return fmt.Errorf("secrets-encryption-kms-endpoint is required when using the %s secrets-encryption provider", secretsencrypt.KMSProvider)
+		}
 	default:
 		return fmt.Errorf("unsupported secrets-encryption-key-type %s", controlConfig.EncryptProvider)
 	}
 	if s, err := os.Stat(runtime.EncryptionConfig); err == nil && s.Size() > 0 {
+		resourcesChanged, err := updateEncryptionResources(controlConfig)
+		if err != nil {
+			return err
+		}
 		// On upgrade from older versions, the encryption hash may not exist, create it
 		if _, err := os.Stat(runtime.EncryptionHash); errors.Is(err, os.ErrNotExist) {
-			curEncryptionByte, err := os.ReadFile(runtime.EncryptionConfig)
+			encryptionConfigHash, err := secretsencrypt.GenEncryptionConfigHash(context.Background(), runtime)
+			if err != nil {
+				return err
+			}
+			ann := "start-" + encryptionConfigHash
+			return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
+		} else if resourcesChanged {
+			// Keep the current stage, but update the hash to match the new configuration
+			curAnn, err := os.ReadFile(runtime.EncryptionHash)
+			if err != nil {
+				return err
+			}
+			encryptionConfigHash, err := secretsencrypt.GenEncryptionConfigHash(context.Background(), runtime)
 			if err != nil {
 				return err
 			}
-			encryptionConfigHash := sha256.Sum256(curEncryptionByte)
-			ann := "start-" + hex.EncodeToString(encryptionConfigHash[:])
+			stage, _, _ := strings.Cut(string(curAnn), "-")
+			ann := stage + "-" + encryptionConfigHash
 			return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
 		}
 		return nil
 	}
 
+	if controlConfig.EncryptProvider == secretsencrypt.KMSProvider {
+		keys := &secretsencrypt.EncryptionKeys{
+			KMS: []apiserverconfigv1.KMSConfiguration{
+				secretsencrypt.NewKMSConfiguration(controlConfig.EncryptKMSName, controlConfig.EncryptKMSEndpoint),
+			},
+			Resources: controlConfig.EncryptResources,
+		}
+		if err := secretsencrypt.WriteEncryptionConfig(runtime, keys, secretsencrypt.KMSProvider, true); err != nil {
+			return err
+		}
+		encryptionConfigHash, err := secretsencrypt.GenEncryptionConfigHash(context.Background(), runtime)
+		if err != nil {
+			return err
+		}
+		ann := "start-" + encryptionConfigHash
+		return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
+	}
+
 	keyByte := make([]byte, secretsencrypt.KeySize)
 	if _, err := rand.Read(keyByte); err != nil {
 		return err
@@ -812,6 +1008,17 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 				Identity: &apiserverconfigv1.IdentityConfiguration{},
 			},
 		}
+	} else if controlConfig.EncryptProvider == secretsencrypt.AESGCMProvider {
+		provider = []apiserverconfigv1.ProviderConfiguration{
+			{
+				AESGCM: &apiserverconfigv1.AESConfiguration{
+					Keys: newKey,
+				},
+			},
+			{
+				Identity: &apiserverconfigv1.IdentityConfiguration{},
+			},
+		}
 	} else if controlConfig.EncryptProvider == secretsencrypt.SecretBoxProvider {
 		provider = []apiserverconfigv1.ProviderConfiguration{
 			{
@@ -832,7 +1039,7 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 		},
 		Resources: []apiserverconfigv1.ResourceConfiguration{
 			{
-				Resources: []string{"secrets"},
+				Resources: secretsencrypt.NormalizeResources(controlConfig.EncryptResources),
 				Providers: provider,
 			},
 		},
@@ -849,6 +1056,44 @@ func genEncryptionConfigAndState(controlConfig *config.Control) error {
 	return os.WriteFile(controlConfig.Runtime.EncryptionHash, []byte(ann), 0600)
 }
 
+// updateEncryptionResources updates the list of resources in an existing encryption configuration to match the
+// configured resources, keeping the current keys. Existing objects of any added resources are not encrypted
+// until they are next written, so the user is prompted to reencrypt.
+func updateEncryptionResources(controlConfig *config.Control) (bool, error) {
+	runtime := controlConfig.Runtime
+	curKeys, err := secretsencrypt.GetEncryptionKeys(runtime)
+	if err != nil {
+		return false, err
+	}
+	resources := secretsencrypt.NormalizeResources(controlConfig.EncryptResources)
+	if slices.Equal(curKeys.Resources, resources) {
+		return false, nil
+	}
+	// Existing objects of a resource may already be encrypted, and the apiserver would be unable to read
+	// them if the resource were removed from the encryption configuration, so resources cannot be removed.
+	var removed []string
+	for _, r := range curKeys.Resources {
+		if !slices.Contains(resources, r) {
+			removed = append(removed, r)
+		}
+	}
+	if len(removed) > 0 {
+		return false, fmt.Errorf("cannot remove %s from secrets-encryption-resources; existing objects may be encrypted and would become unreadable", strings.Join(removed, ", "))
+	}
+	providers, err := secretsencrypt.GetEncryptionProviders(runtime)
+	if err != nil {
+		return false, err
+	}
+	enabled := providers[0].Identity == nil
+	logrus.Infof("Updating secrets-encryption resources from %v to %v", curKeys.Resources, resources)
+	curKeys.Resources = resources
+	if err := secretsencrypt.WriteEncryptionConfig(runtime, curKeys, secretsencrypt.GetActiveProvider(providers), enabled); err != nil {
+		return false, err
+	}
+	logrus.Warnf("Secrets-encryption resources changed; run '%s secrets-encrypt rotate-keys' once all servers have been restarted with the same resources to encrypt existing objects", version.Program)
+	return true, nil
+}
+
 func genEgressSelectorConfig(controlConfig *config.Control) error {
 	var clusterConn apiserverv1beta1.Connection
 
@@ -1,11 +1,24 @@
 package deps
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/secretsencrypt"
+	"github.com/k3s-io/k3s/pkg/util"
 	certutil "github.com/rancher/dynamiclistener/cert"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func Test_UnitAddSANs(t *testing.T) {
@@ -49,3 +62,217 @@ func Test_UnitAddSANs(t *testing.T) {
 		})
 	}
 }
+
+func Test_UnitRenewCerts(t *testing.T) {
+	controlConfig := &config.Control{
+		DataDir: t.TempDir(),
+		Runtime: &config.ControlRuntime{},
+	}
+	CreateRuntimeCertFiles(controlConfig)
+	runtime := controlConfig.Runtime
+	if err := os.MkdirAll(filepath.Join(controlConfig.DataDir, "cred"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := genCerts(controlConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := RenewCerts(controlConfig)
+	if err != nil {
+		t.Fatalf("RenewCerts() error = %v", err)
+	}
+	if len(renewed) != 0 {
+		t.Errorf("RenewCerts() = %v, want no certificates renewed", renewed)
+	}
+
+	expireCert(t, runtime.ClientAdminCert, runtime.ClientAdminKey, runtime.ClientCA, runtime.ClientCAKey)
+	expireCert(t, runtime.ClientETCDCert, runtime.ClientETCDKey, runtime.ETCDServerCA, runtime.ETCDServerCAKey)
+	renewed, err = RenewCerts(controlConfig)
+	if err != nil {
+		t.Fatalf("RenewCerts() error = %v", err)
+	}
+	if want := []string{runtime.ClientAdminCert, runtime.ClientETCDCert}; !reflect.DeepEqual(renewed, want) {
+		t.Errorf("RenewCerts() = %v, want %v", renewed, want)
+	}
+
+	// The admin kubeconfig, and the apiserver's etcd client, reference the certificate files by path,
+	// so the renewed certificates are used the next time that the files are loaded.
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", runtime.KubeConfigAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, files := range [][2]string{
+		{kubeConfig.TLSClientConfig.CertFile, kubeConfig.TLSClientConfig.KeyFile},
+		{runtime.ClientETCDCert, runtime.ClientETCDKey},
+	} {
+		cert, err := tls.LoadX509KeyPair(files[0], files[1])
+		if err != nil {
+			t.Fatalf("failed to load renewed certificate %s: %v", files[0], err)
+		}
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			t.Fatal(err)
+		} else if certutil.IsCertExpired(leaf, config.CertificateRenewDays) {
+			t.Errorf("certificate %s expires at %s, want renewed certificate", files[0], leaf.NotAfter)
+		}
+	}
+}
+
+// expireCert replaces a certificate with one that has the same subject and key, but that expires within a day.
+func expireCert(t *testing.T, certFile, keyFile, caCertFile, caKeyFile string) {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := tls.LoadX509KeyPair(caCertFile, caKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf.SerialNumber = big.NewInt(time.Now().UnixNano())
+	leaf.NotAfter = time.Now().Add(time.Hour * 24)
+	certBytes, err := x509.CreateCertificate(rand.Reader, leaf, caCert, leaf.PublicKey, ca.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_UnitGenCACertificateRequests(t *testing.T) {
+	controlConfig := &config.Control{
+		DataDir: t.TempDir(),
+		Runtime: &config.ControlRuntime{},
+	}
+	CreateRuntimeCertFiles(controlConfig)
+	runtime := controlConfig.Runtime
+
+	csrFiles, err := GenCACertificateRequests(controlConfig)
+	if err != nil {
+		t.Fatalf("GenCACertificateRequests() error = %v", err)
+	}
+	if len(csrFiles) != 5 {
+		t.Fatalf("GenCACertificateRequests() = %v, want 5 certificate requests", csrFiles)
+	}
+
+	csrBytes, err := os.ReadFile(caCSRFile(runtime.ClientCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(csrBytes)
+	if block == nil {
+		t.Fatalf("failed to decode certificate request %s", caCSRFile(runtime.ClientCA))
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CA must not be self-signed while the certificate request is waiting to be signed
+	if _, err := createSigningCertKey("k3s-client", runtime.ClientCA, runtime.ClientCAKey); err == nil {
+		t.Errorf("createSigningCertKey() did not fail for unsigned certificate request")
+	}
+
+	// Sign the request with an external root CA, and save the chain
+	rootKey, err := certutil.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: "root-ca"}, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               csr.Subject,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour * 24 * 365),
+		ExtraExtensions:       csr.Extensions,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, root, csr.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := certutil.WriteCert(runtime.ClientCA, util.EncodeCertsPEM(cert, []*x509.Certificate{root})); err != nil {
+		t.Fatal(err)
+	}
+
+	if regen, err := createSigningCertKey("k3s-client", runtime.ClientCA, runtime.ClientCAKey); err != nil || regen {
+		t.Errorf("createSigningCertKey() = %v, %v; want signed CA to be used", regen, err)
+	}
+	if _, err := tls.LoadX509KeyPair(runtime.ClientCA, runtime.ClientCAKey); err != nil {
+		t.Errorf("signed CA certificate does not match generated key: %v", err)
+	}
+}
+
+func Test_UnitUpdateEncryptionResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		resources   []string
+		want        []string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{
+			name:      "Unchanged",
+			resources: []string{"configmaps"},
+			want:      []string{"secrets", "configmaps"},
+		},
+		{
+			name:        "Resource added",
+			resources:   []string{"configmaps", "widgets.example.com"},
+			want:        []string{"secrets", "configmaps", "widgets.example.com"},
+			wantChanged: true,
+		},
+		{
+			name:      "Resource removed",
+			resources: []string{"widgets.example.com"},
+			want:      []string{"secrets", "configmaps"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlConfig := &config.Control{Runtime: &config.ControlRuntime{}}
+			controlConfig.Runtime.EncryptionConfig = filepath.Join(t.TempDir(), "encryption-config.json")
+			controlConfig.EncryptResources = tt.resources
+			keys := &secretsencrypt.EncryptionKeys{
+				AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey", Secret: "c2VjcmV0"}},
+				Resources:  []string{"configmaps"},
+			}
+			if err := secretsencrypt.WriteEncryptionConfig(controlConfig.Runtime, keys, secretsencrypt.AESCBCProvider, true); err != nil {
+				t.Fatal(err)
+			}
+
+			changed, err := updateEncryptionResources(controlConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("updateEncryptionResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("updateEncryptionResources() = %v, want %v", changed, tt.wantChanged)
+			}
+			got, err := secretsencrypt.GetEncryptionResources(controlConfig.Runtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetEncryptionResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
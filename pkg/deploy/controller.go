@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -21,6 +22,7 @@ import (
 	pkgutil "github.com/k3s-io/k3s/pkg/util"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/rancher/wrangler/v3/pkg/apply"
+	coreclient "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/v3/pkg/kv"
 	"github.com/rancher/wrangler/v3/pkg/merr"
 	"github.com/rancher/wrangler/v3/pkg/objectset"
@@ -45,16 +47,22 @@ const (
 )
 
 // WatchFiles sets up an OnChange callback to start a periodic goroutine to watch files for changes once the controller has started up.
-func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Apply, addons controllersv1.AddonController, disables map[string]bool, bases ...string) error {
+// Manifests with a .tmpl suffix are rendered as Go templates using the provided template data before they are applied.
+func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Apply, addons controllersv1.AddonController, nodes coreclient.NodeController, templateVars TemplateData, disables map[string]bool, bases ...string) error {
 	w := &watcher{
-		apply:      apply,
-		addonCache: addons.Cache(),
-		addons:     addons,
-		bases:      bases,
-		disables:   disables,
-		modTime:    map[string]time.Time{},
-		gvkCache:   map[schema.GroupVersionKind]bool{},
-		discovery:  client.Discovery(),
+		apply:           apply,
+		addonCache:      addons.Cache(),
+		addons:          addons,
+		nodeCache:       nodes.Cache(),
+		bases:           bases,
+		disables:        disables,
+		templateVars:    templateVars,
+		modTime:         map[string]time.Time{},
+		checksums:       map[string]string{},
+		applied:         map[string]*objectset.ObjectSet{},
+		kustomizeInputs: map[string][]string{},
+		gvkCache:        map[schema.GroupVersionKind]bool{},
+		discovery:       client.Discovery(),
 	}
 
 	addons.Enqueue(metav1.NamespaceNone, startKey)
@@ -71,15 +79,20 @@ func WatchFiles(ctx context.Context, client kubernetes.Interface, apply apply.Ap
 type watcher struct {
 	sync.Mutex
 
-	apply      apply.Apply
-	addonCache controllersv1.AddonCache
-	addons     controllersv1.AddonClient
-	bases      []string
-	disables   map[string]bool
-	modTime    map[string]time.Time
-	gvkCache   map[schema.GroupVersionKind]bool
-	recorder   record.EventRecorder
-	discovery  discovery.DiscoveryInterface
+	apply           apply.Apply
+	addonCache      controllersv1.AddonCache
+	addons          controllersv1.AddonClient
+	nodeCache       coreclient.NodeCache
+	bases           []string
+	disables        map[string]bool
+	templateVars    TemplateData
+	modTime         map[string]time.Time
+	checksums       map[string]string
+	kustomizeInputs map[string][]string
+	applied         map[string]*objectset.ObjectSet
+	gvkCache        map[schema.GroupVersionKind]bool
+	recorder        record.EventRecorder
+	discovery       discovery.DiscoveryInterface
 }
 
 // start calls listFiles at regular intervals to trigger application of manifests that have changed on disk.
@@ -112,7 +125,8 @@ func (w *watcher) listFiles(force bool) error {
 }
 
 // listFilesIn recursively processes all files within a path, and checks them against the disable and skip lists. Files found that
-// are not on either list are loaded as Addons and applied to the cluster.
+// are not on either list are loaded as Addons and applied to the cluster, after any Addons that they depend on. Directories
+// containing a kustomization file are built with kustomize, and applied as a single Addon.
 func (w *watcher) listFilesIn(base string, force bool) error {
 	files := map[string]os.FileInfo{}
 	if err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
@@ -160,40 +174,107 @@ func (w *watcher) listFilesIn(base string, force bool) error {
 	}
 	sort.Strings(keys)
 
+	// Files within a kustomization directory are not processed individually; the directory is handled as a whole.
+	kustomizations := findKustomizations(base, files)
+
 	var errs []error
+	var manifests []*manifest
+	modTimes := map[string]time.Time{}
+	names := map[string]string{}
 	for _, path := range keys {
-		// Disabled files are not just skipped, but actively deleted from the filesystem
-		if shouldDisableFile(base, path, w.disables) {
+		if inKustomization(path, kustomizations) {
+			continue
+		}
+		kustomization, isKustomization := kustomizations[path]
+
+		// Disabled files are not just skipped, but actively deleted from the filesystem.
+		// Kustomizations are disabled if their kustomization file would be.
+		if shouldDisableFile(base, path, w.disables) || (isKustomization && shouldDisableFile(base, kustomization, w.disables)) {
 			if err := w.delete(path); err != nil {
 				errs = append(errs, pkgerrors.WithMessagef(err, "failed to delete %s", path))
 			}
 			continue
 		}
-		// Skipped files are just ignored
-		if shouldSkipFile(files[path].Name(), skips) {
+
+		// Skipped files and kustomizations are just ignored
+		if (isKustomization && shouldSkipDir(files[path].Name(), skips)) || (!isKustomization && shouldSkipFile(files[path].Name(), skips)) {
 			continue
 		}
-		modTime := files[path].ModTime()
-		if !force && modTime.Equal(w.modTime[path]) {
+
+		// Addons are named for the basename of their file or kustomization directory, so a file and a directory
+		// such as foo.yaml and foo/ would be applied as the same Addon. Only the first path with a given name is used.
+		name := basename(path)
+		if other, ok := names[name]; ok {
+			w.nameConflict(name, path, other)
 			continue
 		}
-		if err := w.deploy(path, !force); err != nil {
+		names[name] = path
+
+		if isKustomization {
+			// Kustomizations are checked for changes to the content of any of their inputs
+			sum, err := w.kustomizationChecksum(path)
+			if err != nil {
+				errs = append(errs, pkgerrors.WithMessagef(err, "failed to process %s", path))
+				continue
+			}
+			if !force && sum == w.checksums[path] {
+				continue
+			}
+		} else {
+			// Templates are rendered on every pass, as the cluster facts used to render them may have changed.
+			modTime := files[path].ModTime()
+			if !force && !isTemplate(files[path].Name()) && modTime.Equal(w.modTime[path]) {
+				continue
+			}
+			modTimes[path] = modTime
+		}
+
+		m, err := w.load(path)
+		if err != nil {
 			errs = append(errs, pkgerrors.WithMessagef(err, "failed to process %s", path))
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	sorted, cyclic := sortManifests(manifests)
+	for _, m := range cyclic {
+		w.recorder.Eventf(&m.addon, corev1.EventTypeWarning, "DependencyCycle", "Manifest at %q is part of or depends on a dependency cycle among addons %s", m.path, strings.Join(manifestNames(cyclic), ", "))
+		logrus.Warnf("Not applying manifest at %s: dependency cycle among addons %s", m.path, strings.Join(manifestNames(cyclic), ", "))
+	}
+	for _, m := range sorted {
+		if err := w.deploy(m, !force); errors.Is(err, errDependenciesNotReady) {
+			logrus.Debugf("Not applying manifest at %s until its dependencies are ready", m.path)
+		} else if err != nil {
+			errs = append(errs, pkgerrors.WithMessagef(err, "failed to process %s", m.path))
+		} else if _, ok := kustomizations[m.path]; ok {
+			w.checksums[m.path] = m.checksum
 		} else {
-			w.modTime[path] = modTime
+			w.modTime[m.path] = modTimes[m.path]
 		}
 	}
 
 	return merr.NewErrors(errs...)
 }
 
-// deploy loads yaml from a manifest on disk, creates an AddOn resource to track its application, and then applies
-// all resources contained within to the cluster.
-func (w *watcher) deploy(path string, compareChecksum bool) error {
+// nameConflict reports that a manifest is not applied because its Addon name is already used by another manifest.
+func (w *watcher) nameConflict(name, path, other string) {
+	logrus.Warnf("Not applying manifest at %s: Addon %s is already used by manifest at %s", path, name, other)
+	addon, err := w.getOrCreateAddon(name)
+	if err != nil {
+		logrus.Errorf("Failed to get Addon %s: %v", name, err)
+		return
+	}
+	w.recorder.Eventf(&addon, corev1.EventTypeWarning, "NameConflict", "Manifest at %q is not applied because Addon %s is already used by manifest at %q", path, name, other)
+}
+
+// load loads yaml from a manifest on disk, rendering it first if it is a template, and creates an AddOn resource to
+// track its application.
+func (w *watcher) load(path string) (*manifest, error) {
 	name := basename(path)
 	addon, err := w.getOrCreateAddon(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	addon.Spec.Source = path
@@ -203,21 +284,14 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 	if addon.UID == "" {
 		newAddon, err := w.addons.Create(&addon)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		addon = *newAddon
 	}
 
-	content, err := os.ReadFile(path)
+	content, err := w.readManifest(&addon, path)
 	if err != nil {
-		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ReadManifestFailed", "Read manifest at %q failed: %v", path, err)
-		return err
-	}
-
-	checksum := checksum(content)
-	if compareChecksum && checksum == addon.Spec.Checksum {
-		logrus.Debugf("Skipping existing deployment of %s, check=%v, checksum %s=%s", path, compareChecksum, checksum, addon.Spec.Checksum)
-		return nil
+		return nil, err
 	}
 
 	// Attempt to parse the YAML/JSON into objects. Failure at this point would be due to bad file content - not YAML/JSON,
@@ -225,7 +299,41 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 	objects, err := objectSet(content)
 	if err != nil {
 		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ParseManifestFailed", "Parse manifest at %q failed: %v", path, err)
-		return err
+		return nil, err
+	}
+
+	// The checksum for a kustomization covers all of its inputs, not the built output, so that the checksum can be
+	// compared without building the kustomization.
+	sum := checksum(content)
+	if isKustomization(path) {
+		if sum, err = w.kustomizationChecksum(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &manifest{
+		path:         path,
+		addon:        addon,
+		checksum:     sum,
+		objects:      objects,
+		dependencies: getDependencies(name, objects),
+	}, nil
+}
+
+// deploy applies all resources contained within a loaded manifest to the cluster. If the manifest's dependencies are
+// not ready, the manifest is not applied, and errDependenciesNotReady is returned so that it is retried on the next pass.
+func (w *watcher) deploy(m *manifest, compareChecksum bool) error {
+	addon, path, objects := m.addon, m.path, m.objects
+	if compareChecksum && m.checksum == addon.Spec.Checksum {
+		logrus.Debugf("Skipping existing deployment of %s, check=%v, checksum %s=%s", path, compareChecksum, m.checksum, addon.Spec.Checksum)
+		w.applied[addon.Name] = objects
+		return nil
+	}
+
+	// Do not apply the manifest until the Addons it depends on have been applied.
+	if err := w.checkDependencies(m.dependencies); err != nil {
+		w.recorder.Eventf(&addon, corev1.EventTypeNormal, "WaitingForDependencies", "Waiting for dependencies of manifest at %q: %v", path, err)
+		return errDependenciesNotReady
 	}
 
 	// Merge GVK list early for validation
@@ -238,7 +346,7 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 
 	// Ensure that we don't try to prune using GVKs that the server doesn't have.
 	// This can happen when CRDs are removed or when core types are removed - PodSecurityPolicy, for example.
-	addonGVKs, err = w.validateGVKs(addonGVKs)
+	addonGVKs, err := w.validateGVKs(addonGVKs)
 	if err != nil {
 		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ValidateManifestFailed", "Validate GVKs for manifest at %q failed: %v", path, err)
 		return err
@@ -253,16 +361,23 @@ func (w *watcher) deploy(path string, compareChecksum bool) error {
 
 	if err := w.apply.WithOwner(&addon).WithGVK(addonGVKs...).Apply(objects); err != nil {
 		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ApplyManifestFailed", "Applying manifest at %q failed: %v", path, err)
+		delete(w.applied, addon.Name)
 		return err
 	}
 
-	// Emit event, Update Addon checksum and GVKs only if apply was successful
+	// Emit event, Update Addon checksum, GVKs, and dependencies only if apply was successful
 	w.recorder.Eventf(&addon, corev1.EventTypeNormal, "AppliedManifest", "Applied manifest at %q", path)
+	w.applied[addon.Name] = objects
 	if addon.Annotations == nil {
 		addon.Annotations = map[string]string{}
 	}
-	addon.Spec.Checksum = checksum
+	addon.Spec.Checksum = m.checksum
 	addon.Annotations[GVKAnnotation] = getGVKString(objects.GVKs())
+	if len(m.dependencies) > 0 {
+		addon.Annotations[DependsOnAnnotation] = strings.Join(m.dependencies, ",")
+	} else {
+		delete(addon.Annotations, DependsOnAnnotation)
+	}
 	_, err = w.addons.Update(&addon)
 	return err
 }
@@ -283,10 +398,8 @@ func (w *watcher) delete(path string) error {
 		}
 	}
 
-	content, err := os.ReadFile(path)
-	if err != nil {
-		w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ReadManifestFailed", "Read manifest at %q failed: %v", path, err)
-	} else {
+	content, err := w.readManifest(&addon, path)
+	if err == nil {
 		if o, err := objectSet(content); err != nil {
 			w.recorder.Eventf(&addon, corev1.EventTypeWarning, "ParseManifestFailed", "Parse manifest at %q failed: %v", path, err)
 		} else {
@@ -308,12 +421,19 @@ func (w *watcher) delete(path string) error {
 		return err
 	}
 
-	// Remove the addon file
-	if err := os.Remove(path); err != nil {
+	// Remove the addon file, or kustomization directory
+	remove := os.Remove
+	if isKustomization(path) {
+		remove = os.RemoveAll
+	}
+	if err := remove(path); err != nil {
 		return err
 	}
 
 	// Delete the addon
+	delete(w.applied, addon.Name)
+	delete(w.checksums, path)
+	delete(w.kustomizeInputs, path)
 	w.recorder.Eventf(&addon, corev1.EventTypeNormal, "DeletingManifest", "Deleting manifest at %q", path)
 	if err := w.addons.Delete(addon.Namespace, addon.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
 		return err
@@ -322,6 +442,38 @@ func (w *watcher) delete(path string) error {
 	return nil
 }
 
+// readManifest reads a manifest from disk, and renders it if it is a template.
+// If the path is a kustomization directory, the kustomization is built instead.
+func (w *watcher) readManifest(addon *apisv1.Addon, path string) ([]byte, error) {
+	if isKustomization(path) {
+		content, err := w.buildKustomization(path)
+		if err != nil {
+			w.recorder.Eventf(addon, corev1.EventTypeWarning, "BuildKustomizationFailed", "Build kustomization at %q failed: %v", path, err)
+			return nil, err
+		}
+		return content, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		w.recorder.Eventf(addon, corev1.EventTypeWarning, "ReadManifestFailed", "Read manifest at %q failed: %v", path, err)
+		return nil, err
+	}
+	if !isTemplate(path) {
+		return content, nil
+	}
+
+	data, err := w.templateData()
+	if err == nil {
+		content, err = render(filepath.Base(path), content, data)
+	}
+	if err != nil {
+		w.recorder.Eventf(addon, corev1.EventTypeWarning, "RenderManifestFailed", "Render manifest at %q failed: %v", path, err)
+		return nil, err
+	}
+	return content, nil
+}
+
 // getOrCreateAddon attempts to get an Addon by name from the addon namespace, and creates a new one
 // if it cannot be found.
 func (w *watcher) getOrCreateAddon(name string) (apisv1.Addon, error) {
@@ -467,20 +619,26 @@ func toObjects(bytes []byte) ([]runtime.Object, error) {
 }
 
 // Returns true if a file should be skipped. Skips anything from the provided skip map,
-// anything that is a dotfile, and anything that does not have a json/yaml/yml extension.
+// anything that is a dotfile, and anything that does not have a json/yaml/yml extension, optionally followed by .tmpl.
 func shouldSkipFile(fileName string, skips map[string]bool) bool {
 	switch {
 	case strings.HasPrefix(fileName, "."):
 		return true
 	case skips[fileName]:
 		return true
-	case util.HasSuffixI(fileName, ".yaml", ".yml", ".json"):
+	case util.HasSuffixI(fileName, ".yaml", ".yml", ".json") || isTemplate(fileName):
 		return false
 	default:
 		return true
 	}
 }
 
+// Returns true if a kustomization directory should be skipped. Skips anything from the provided skip map,
+// and anything that is a dotfile.
+func shouldSkipDir(dirName string, skips map[string]bool) bool {
+	return strings.HasPrefix(dirName, ".") || skips[dirName]
+}
+
 // Returns true if a file should be disabled, by checking the file basename against a disables map.
 // only json/yaml files are checked.
 func shouldDisableFile(base, fileName string, disables map[string]bool) bool {
@@ -494,11 +652,11 @@ func shouldDisableFile(base, fileName string, disables map[string]bool) bool {
 			return true
 		}
 	}
-	if !util.HasSuffixI(fileName, ".yaml", ".yml", ".json") {
+	if !util.HasSuffixI(fileName, ".yaml", ".yml", ".json") && !isTemplate(fileName) {
 		return false
 	}
 	// Check the basename against the disables map
-	baseFile := filepath.Base(fileName)
+	baseFile := strings.TrimSuffix(filepath.Base(fileName), templateSuffix)
 	suffix := filepath.Ext(baseFile)
 	baseName := strings.TrimSuffix(baseFile, suffix)
 	return disables[baseName]
@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	apisv1 "github.com/k3s-io/api/k3s.cattle.io/v1"
+	"github.com/rancher/wrangler/v3/pkg/merr"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DependsOnAnnotation may be set on any object in a manifest to a comma-separated list of addon names
+// that must be applied before the manifest is applied. Addon names are the manifest file basenames.
+const DependsOnAnnotation = "addon.k3s.cattle.io/depends-on"
+
+// errDependenciesNotReady is returned when a manifest is not applied because its dependencies are not ready.
+var errDependenciesNotReady = errors.New("dependencies not ready")
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// manifest is a manifest that has been read from disk and parsed, but not yet applied.
+type manifest struct {
+	path         string
+	addon        apisv1.Addon
+	checksum     string
+	objects      *objectset.ObjectSet
+	dependencies []string
+}
+
+// getDependencies returns the sorted list of addons that the objects in a manifest depend on.
+// Dependencies of an addon on itself are ignored.
+func getDependencies(name string, objects *objectset.ObjectSet) []string {
+	dependencies := sets.New[string]()
+	for _, obj := range objects.All() {
+		metadata, err := meta.Accessor(obj)
+		if err != nil {
+			continue
+		}
+		for _, dependency := range strings.Split(metadata.GetAnnotations()[DependsOnAnnotation], ",") {
+			if dependency = strings.TrimSpace(dependency); dependency != "" && dependency != name {
+				dependencies.Insert(dependency)
+			}
+		}
+	}
+	return sets.List(dependencies)
+}
+
+// sortManifests orders manifests so that each manifest comes after any other manifests that it depends on.
+// Manifests are otherwise kept in their original order. Manifests that are part of a dependency cycle, or that
+// depend on a manifest in a cycle, cannot be ordered and are returned separately.
+func sortManifests(manifests []*manifest) ([]*manifest, []*manifest) {
+	pending := map[string]bool{}
+	for _, m := range manifests {
+		pending[m.addon.Name] = true
+	}
+
+	sorted := make([]*manifest, 0, len(manifests))
+	remaining := manifests
+	for len(remaining) > 0 {
+		next := remaining[:0:0]
+		for _, m := range remaining {
+			ready := true
+			for _, dependency := range m.dependencies {
+				if pending[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, m)
+				delete(pending, m.addon.Name)
+			} else {
+				next = append(next, m)
+			}
+		}
+		if len(next) == len(remaining) {
+			return sorted, next
+		}
+		remaining = next
+	}
+	return sorted, nil
+}
+
+// checkDependencies returns an error describing any dependencies that are not ready. A dependency is ready once
+// its manifest has been successfully applied, and the types defined by any CustomResourceDefinitions it contains
+// are served by the apiserver. CRD types are not served until the CRD has been Established.
+func (w *watcher) checkDependencies(dependencies []string) error {
+	var errs []error
+	for _, dependency := range dependencies {
+		objects, ok := w.applied[dependency]
+		if !ok {
+			errs = append(errs, fmt.Errorf("addon %q has not been applied", dependency))
+			continue
+		}
+		for _, obj := range objects.All() {
+			if obj.GetObjectKind().GroupVersionKind().GroupKind() != crdGroupKind {
+				continue
+			}
+			crd, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			for _, gvk := range getCRDGVKs(crd) {
+				found, err := w.serverHasGVK(gvk)
+				if err != nil {
+					errs = append(errs, err)
+				} else if !found {
+					errs = append(errs, fmt.Errorf("CustomResourceDefinition %s from addon %q is not established", crd.GetName(), dependency))
+					break
+				}
+			}
+		}
+	}
+	return merr.NewErrors(errs...)
+}
+
+// manifestNames returns the names of the Addons for a list of manifests.
+func manifestNames(manifests []*manifest) []string {
+	names := make([]string, len(manifests))
+	for i, m := range manifests {
+		names[i] = m.addon.Name
+	}
+	return names
+}
+
+// getCRDGVKs returns the GroupVersionKinds for all served versions of a CustomResourceDefinition.
+func getCRDGVKs(crd *unstructured.Unstructured) []schema.GroupVersionKind {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	var gvks []schema.GroupVersionKind
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		served, _, _ := unstructured.NestedBool(version, "served")
+		if name != "" && served {
+			gvks = append(gvks, schema.GroupVersionKind{Group: group, Version: name, Kind: kind})
+		}
+	}
+	return gvks
+}
@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	apisv1 "github.com/k3s-io/api/k3s.cattle.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newManifest(name string, dependencies ...string) *manifest {
+	return &manifest{
+		path:         "/manifests/" + name + ".yaml",
+		addon:        *apisv1.NewAddon(metav1.NamespaceSystem, name, apisv1.Addon{}),
+		dependencies: dependencies,
+	}
+}
+
+func Test_UnitSortManifests(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests []*manifest
+		want      []string
+		wantCycle []string
+	}{
+		{
+			name:      "No dependencies",
+			manifests: []*manifest{newManifest("a"), newManifest("b"), newManifest("c")},
+			want:      []string{"a", "b", "c"},
+		},
+		{
+			name:      "Dependency sorted after dependent",
+			manifests: []*manifest{newManifest("a", "c"), newManifest("b"), newManifest("c")},
+			want:      []string{"b", "c", "a"},
+		},
+		{
+			name:      "Chained dependencies",
+			manifests: []*manifest{newManifest("a", "b"), newManifest("b", "c"), newManifest("c")},
+			want:      []string{"c", "b", "a"},
+		},
+		{
+			name:      "Dependency not in batch",
+			manifests: []*manifest{newManifest("a", "traefik"), newManifest("b")},
+			want:      []string{"a", "b"},
+		},
+		{
+			name:      "Dependency cycle",
+			manifests: []*manifest{newManifest("a", "b"), newManifest("b", "a"), newManifest("c")},
+			want:      []string{"c"},
+			wantCycle: []string{"a", "b"},
+		},
+		{
+			name:      "Dependent of cycle",
+			manifests: []*manifest{newManifest("a", "b"), newManifest("b", "a"), newManifest("c", "a"), newManifest("d")},
+			want:      []string{"d"},
+			wantCycle: []string{"a", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, cyclic := sortManifests(tt.manifests)
+			if got := manifestNames(sorted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortManifests() sorted = %v, want %v", got, tt.want)
+			}
+			if got := manifestNames(cyclic); len(got) != len(tt.wantCycle) || (len(got) > 0 && !reflect.DeepEqual(got, tt.wantCycle)) {
+				t.Errorf("sortManifests() cyclic = %v, want %v", got, tt.wantCycle)
+			}
+		})
+	}
+}
+
+func Test_UnitGetDependencies(t *testing.T) {
+	content := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+  annotations:
+    addon.k3s.cattle.io/depends-on: traefik, example-crds
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: two
+  annotations:
+    addon.k3s.cattle.io/depends-on: example,traefik
+`
+	objects, err := objectSet([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example-crds", "traefik"}
+	if got := getDependencies("example", objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("getDependencies() = %v, want %v", got, want)
+	}
+}
+
+func Test_UnitGetCRDGVKs(t *testing.T) {
+	content := `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+  - name: v1
+    served: true
+  - name: v1beta1
+    served: false
+  - name: v2
+    served: true
+`
+	objects, err := objectSet([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crd := objects.All()[0]
+	if gk := crd.GetObjectKind().GroupVersionKind().GroupKind(); gk != crdGroupKind {
+		t.Fatalf("unexpected GroupKind %v", gk)
+	}
+	want := []schema.GroupVersionKind{
+		{Group: "example.com", Version: "v1", Kind: "Widget"},
+		{Group: "example.com", Version: "v2", Kind: "Widget"},
+	}
+	if got := getCRDGVKs(crd.(*unstructured.Unstructured)); !reflect.DeepEqual(got, want) {
+		t.Errorf("getCRDGVKs() = %v, want %v", got, want)
+	}
+}
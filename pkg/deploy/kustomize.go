@@ -0,0 +1,208 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// recordingFS wraps a filesystem to record the path of every file that is read by kustomize, so that
+// changes to files outside the kustomization directory - such as a base in a parent directory - are detected.
+// Kustomization files are checked for references to remote resources as they are read, so that the build
+// fails before kustomize attempts to fetch them. Kustomize does not return all read errors as-is, so the
+// error is also stored for the caller.
+type recordingFS struct {
+	filesys.FileSystem
+	files sets.Set[string]
+	err   error
+}
+
+func (r *recordingFS) ReadFile(path string) ([]byte, error) {
+	b, err := r.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		r.files.Insert(abs)
+	}
+	if isKustomizationFile(filepath.Base(path)) {
+		k := &types.Kustomization{}
+		if err := k.Unmarshal(b); err == nil {
+			if refs := remoteReferences(k); len(refs) > 0 {
+				r.err = fmt.Errorf("kustomization %s references remote resources, which are not supported: %s", path, strings.Join(refs, ", "))
+				return nil, r.err
+			}
+		}
+	}
+	return b, nil
+}
+
+// isRemote returns true if a path in a kustomization refers to a remote file or git repository,
+// instead of a file or directory on the local filesystem.
+func isRemote(path string) bool {
+	if strings.HasPrefix(path, "github.com/") {
+		return true
+	}
+	// scp-style git URLs, such as git@github.com:org/repo
+	if host, _, _ := strings.Cut(path, "/"); strings.Contains(host, "@") && strings.Contains(host, ":") {
+		return true
+	}
+	// Single-letter schemes are Windows drive letters
+	u, err := url.Parse(path)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// remoteReferences returns a list of all paths in a kustomization that refer to remote files or git repositories.
+func remoteReferences(k *types.Kustomization) []string {
+	paths := []string{}
+	paths = append(paths, k.Resources...)
+	paths = append(paths, k.Components...)
+	paths = append(paths, k.Bases...)
+	paths = append(paths, k.Crds...)
+	paths = append(paths, k.Configurations...)
+	paths = append(paths, k.Generators...)
+	paths = append(paths, k.Transformers...)
+	paths = append(paths, k.Validators...)
+	paths = append(paths, k.OpenAPI["path"])
+	for _, patch := range k.Patches {
+		paths = append(paths, patch.Path)
+	}
+	for _, patch := range k.PatchesStrategicMerge {
+		paths = append(paths, string(patch))
+	}
+	for _, replacement := range k.Replacements {
+		paths = append(paths, replacement.Path)
+	}
+	sources := []types.KvPairSources{}
+	for _, generator := range k.ConfigMapGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, generator := range k.SecretGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, source := range sources {
+		for _, file := range source.FileSources {
+			paths = append(paths, file)
+			// File sources may be prefixed with a key, as key=path
+			if _, path, ok := strings.Cut(file, "="); ok {
+				paths = append(paths, path)
+			}
+		}
+		paths = append(paths, source.EnvSources...)
+		paths = append(paths, source.EnvSource)
+	}
+
+	refs := []string{}
+	for _, path := range paths {
+		if isRemote(path) {
+			refs = append(refs, path)
+		}
+	}
+	return refs
+}
+
+// isKustomizationFile returns true if a file name is one of the file names recognized by kustomize.
+func isKustomizationFile(fileName string) bool {
+	return slices.Contains(konfig.RecognizedKustomizationFileNames(), fileName)
+}
+
+// isKustomization returns true if a path is a directory containing a kustomization file.
+func isKustomization(path string) bool {
+	for _, fileName := range konfig.RecognizedKustomizationFileNames() {
+		if _, err := os.Stat(filepath.Join(path, fileName)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// findKustomizations returns a map of directories containing a kustomization file to the path of the kustomization
+// file. Only the topmost kustomization in each subdirectory of the base path is returned; kustomizations nested within
+// it are used only as part of building it. A kustomization file in the base path itself is ignored.
+func findKustomizations(base string, files map[string]os.FileInfo) map[string]string {
+	kustomizations := map[string]string{}
+	for path, info := range files {
+		if dir := filepath.Dir(path); isKustomizationFile(info.Name()) && dir != filepath.Clean(base) {
+			kustomizations[dir] = path
+		}
+	}
+	for dir := range kustomizations {
+		for parent := filepath.Dir(dir); parent != dir && parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+			if _, ok := kustomizations[parent]; ok {
+				delete(kustomizations, dir)
+				break
+			}
+		}
+	}
+	return kustomizations
+}
+
+// inKustomization returns true if a path is within, but is not itself, a kustomization directory.
+func inKustomization(path string, kustomizations map[string]string) bool {
+	for dir := range kustomizations {
+		if strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildKustomization builds a kustomization directory, and returns the resulting yaml. The paths of all files read
+// while building the kustomization are recorded for use when calculating the kustomization's checksum.
+// Only local files may be used; kustomizations that reference remote files or git repositories fail to build.
+func (w *watcher) buildKustomization(dir string) ([]byte, error) {
+	fSys := &recordingFS{FileSystem: filesys.MakeFsOnDisk(), files: sets.New[string]()}
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, dir)
+	if fSys.err != nil {
+		return nil, fSys.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.kustomizeInputs[dir] = sets.List(fSys.files)
+	return resources.AsYaml()
+}
+
+// kustomizationChecksum returns the hex-encoded SHA256 sum of the paths and contents of all files within a
+// kustomization directory, and any other files that were read when the kustomization was last built.
+// Files that no longer exist contribute only their path, so that deleting an input changes the checksum.
+func (w *watcher) kustomizationChecksum(dir string) (string, error) {
+	inputs := sets.New(w.kustomizeInputs[dir]...)
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			if abs, err := filepath.Abs(path); err == nil {
+				inputs.Insert(abs)
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, path := range sets.List(inputs) {
+		b, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		h.Write([]byte(path + "\x00"))
+		h.Write(b)
+		h.Write([]byte("\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,231 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walkFiles(t *testing.T, base string) map[string]os.FileInfo {
+	t.Helper()
+	files := map[string]os.FileInfo{}
+	if err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		files[path] = info
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func Test_UnitFindKustomizations(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "manifests")
+	writeFiles(t, base, map[string]string{
+		"kustomization.yaml":                      "resources: []\n",
+		"coredns.yaml":                            "",
+		"app/kustomization.yaml":                  "resources: [base]\n",
+		"app/base/kustomization.yml":              "resources: []\n",
+		"app/base/deployment.yaml":                "",
+		"other/Kustomization":                     "resources: []\n",
+		"plain/service.yaml":                      "",
+		"plain/nested/kustomization.yaml":         "resources: []\n",
+		"plain/nested/overlays/kustomization.yml": "resources: []\n",
+	})
+
+	kustomizations := findKustomizations(base, walkFiles(t, base))
+	want := map[string]string{
+		filepath.Join(base, "app"):          filepath.Join(base, "app/kustomization.yaml"),
+		filepath.Join(base, "other"):        filepath.Join(base, "other/Kustomization"),
+		filepath.Join(base, "plain/nested"): filepath.Join(base, "plain/nested/kustomization.yaml"),
+	}
+	if !reflect.DeepEqual(kustomizations, want) {
+		t.Errorf("findKustomizations() = %v, want %v", kustomizations, want)
+	}
+
+	for path, want := range map[string]bool{
+		filepath.Join(base, "app"):                        false,
+		filepath.Join(base, "app/base/deployment.yaml"):   true,
+		filepath.Join(base, "app/kustomization.yaml"):     true,
+		filepath.Join(base, "application.yaml"):           false,
+		filepath.Join(base, "plain/service.yaml"):         false,
+		filepath.Join(base, "plain/nested/overlays"):      true,
+		filepath.Join(base, "coredns.yaml"):               false,
+		filepath.Join(base, "other/Kustomization"):        true,
+		filepath.Join(base, "plain/nested/kustomization"): true,
+	} {
+		if got := inKustomization(path, kustomizations); got != want {
+			t.Errorf("inKustomization(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func Test_UnitBuildKustomization(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "manifests")
+	app := filepath.Join(base, "app")
+	writeFiles(t, dir, map[string]string{
+		"shared/kustomization.yaml": "resources:\n- configmap.yaml\n",
+		"shared/configmap.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: shared\ndata:\n  key: one\n",
+		"shared/unused.yaml":        "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: unused\n",
+		"manifests/app/kustomization.yaml": "namespace: example\nresources:\n- ../../shared\n- service.yaml\n" +
+			"patches:\n- path: patch.yaml\n",
+		"manifests/app/service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: example\nspec:\n  ports:\n  - port: 80\n",
+		"manifests/app/patch.yaml":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: shared\ndata:\n  patched: \"true\"\n",
+	})
+
+	w := &watcher{kustomizeInputs: map[string][]string{}}
+	content, err := w.buildKustomization(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := objectSet(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects.All()) != 2 {
+		t.Fatalf("buildKustomization() returned %d objects, want 2:\n%s", len(objects.All()), content)
+	}
+	for _, s := range []string{"namespace: example", "patched: \"true\"", "key: one"} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("buildKustomization() output does not contain %q:\n%s", s, content)
+		}
+	}
+
+	checksum, err := w.kustomizationChecksum(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		remove  string
+		changed bool
+	}{
+		{
+			name:    "Unreferenced file outside kustomization",
+			files:   map[string]string{"shared/unused.yaml": "changed"},
+			changed: false,
+		},
+		{
+			name:    "Referenced file outside kustomization",
+			files:   map[string]string{"shared/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: shared\ndata:\n  key: two\n"},
+			changed: true,
+		},
+		{
+			name:    "Patch within kustomization",
+			files:   map[string]string{"manifests/app/patch.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: shared\n"},
+			changed: true,
+		},
+		{
+			name:    "New file within kustomization",
+			files:   map[string]string{"manifests/app/README.md": "example"},
+			changed: true,
+		},
+		{
+			name:    "Deleted file within kustomization",
+			remove:  "manifests/app/README.md",
+			changed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, dir, tt.files)
+			if tt.remove != "" {
+				if err := os.Remove(filepath.Join(dir, tt.remove)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := w.kustomizationChecksum(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := got != checksum; changed != tt.changed {
+				t.Errorf("kustomizationChecksum() changed = %v, want %v", changed, tt.changed)
+			}
+			checksum = got
+		})
+	}
+}
+
+func Test_UnitBuildKustomizationRemote(t *testing.T) {
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: example\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Local resources",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- configmap.yaml\nconfigMapGenerator:\n- name: generated\n  files:\n  - key=configmap.yaml\n",
+				"app/configmap.yaml":     configMap,
+			},
+		},
+		{
+			name: "Remote file resource",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- https://example.com/configmap.yaml\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Remote git resource",
+			files: map[string]string{
+				"app/kustomization.yaml": "resources:\n- github.com/example/repo//app?ref=v1.0.0\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Remote scp-style git component",
+			files: map[string]string{
+				"app/kustomization.yaml": "components:\n- git@example.com:example/repo.git\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Remote generator file",
+			files: map[string]string{
+				"app/kustomization.yaml": "configMapGenerator:\n- name: generated\n  files:\n  - key=https://example.com/config\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Remote resource in local base",
+			files: map[string]string{
+				"app/kustomization.yaml":  "resources:\n- ../base\n",
+				"base/kustomization.yaml": "resources:\n- https://example.com/configmap.yaml\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			w := &watcher{kustomizeInputs: map[string][]string{}}
+			_, err := w.buildKustomization(filepath.Join(dir, "app"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildKustomization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && err != nil && !strings.Contains(err.Error(), "remote resources") {
+				t.Errorf("buildKustomization() error = %v, want remote resources error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"text/template"
+
+	"github.com/k3s-io/k3s/pkg/agent/util"
+	pkgutil "github.com/k3s-io/k3s/pkg/util"
+	pkgerrors "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// templateSuffix is the suffix used to indicate that a manifest should be rendered as a Go template
+// before it is parsed. For example, 'addon.yaml.tmpl' is rendered and then applied as 'addon.yaml'.
+const templateSuffix = ".tmpl"
+
+// TemplateData contains the cluster facts that are available to templated manifests.
+// Comma-separated values use the same format as the corresponding CLI flags.
+type TemplateData struct {
+	ClusterCIDR           string
+	ClusterCIDRs          []string
+	ServiceCIDR           string
+	ServiceCIDRs          []string
+	ClusterDNS            string
+	ClusterDNSs           []string
+	ClusterDomain         string
+	SystemDefaultRegistry string
+	NodeCount             int
+	ServerCount           int
+	AgentCount            int
+}
+
+// isTemplate returns true if a manifest should be rendered as a template.
+func isTemplate(fileName string) bool {
+	return util.HasSuffixI(fileName, ".yaml"+templateSuffix, ".yml"+templateSuffix, ".json"+templateSuffix)
+}
+
+// templateData returns a copy of the static template data, with the node counts populated from the node cache.
+// Control-plane and etcd-only nodes are both counted as servers.
+func (w *watcher) templateData() (TemplateData, error) {
+	data := w.templateVars
+	if w.nodeCache == nil {
+		return data, nil
+	}
+	nodes, err := w.nodeCache.List(labels.Everything())
+	if err != nil {
+		return data, pkgerrors.WithMessage(err, "failed to list nodes")
+	}
+	for _, node := range nodes {
+		data.NodeCount++
+		if node.Labels[pkgutil.ControlPlaneRoleLabelKey] == "true" || node.Labels[pkgutil.ETCDRoleLabelKey] == "true" {
+			data.ServerCount++
+		} else {
+			data.AgentCount++
+		}
+	}
+	return data, nil
+}
+
+// render executes a templated manifest with the provided data. Referencing a field that
+// does not exist is an error, so that typos do not result in silently empty values.
+func render(name string, content []byte, data TemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// NewTemplateData returns template data populated with the cluster and service CIDRs, cluster DNS addresses,
+// cluster domain, and system default registry. Node counts are populated when each manifest is rendered.
+func NewTemplateData(clusterCIDRs, serviceCIDRs []*net.IPNet, clusterDNSs []net.IP, clusterDomain, systemDefaultRegistry string) TemplateData {
+	data := TemplateData{
+		ClusterCIDR:           pkgutil.JoinIPNets(clusterCIDRs),
+		ServiceCIDR:           pkgutil.JoinIPNets(serviceCIDRs),
+		ClusterDomain:         clusterDomain,
+		SystemDefaultRegistry: systemDefaultRegistry,
+	}
+	for _, cidr := range clusterCIDRs {
+		data.ClusterCIDRs = append(data.ClusterCIDRs, cidr.String())
+	}
+	for _, cidr := range serviceCIDRs {
+		data.ServiceCIDRs = append(data.ServiceCIDRs, cidr.String())
+	}
+	for _, ip := range clusterDNSs {
+		data.ClusterDNSs = append(data.ClusterDNSs, ip.String())
+	}
+	if len(data.ClusterDNSs) > 0 {
+		data.ClusterDNS = data.ClusterDNSs[0]
+	}
+	return data
+}
@@ -0,0 +1,140 @@
+package deploy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/tests/mock"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func Test_UnitRender(t *testing.T) {
+	_, clusterCIDR, _ := net.ParseCIDR("10.42.0.0/16")
+	_, clusterCIDR6, _ := net.ParseCIDR("2001:cafe:42::/56")
+	_, serviceCIDR, _ := net.ParseCIDR("10.43.0.0/16")
+	data := NewTemplateData([]*net.IPNet{clusterCIDR, clusterCIDR6}, []*net.IPNet{serviceCIDR}, []net.IP{net.ParseIP("10.43.0.10")}, "cluster.local", "")
+	data.NodeCount, data.ServerCount, data.AgentCount = 5, 3, 2
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Plain content",
+			content: "kind: ConfigMap\n",
+			want:    "kind: ConfigMap\n",
+		},
+		{
+			name:    "Cluster facts",
+			content: "cidr: {{ .ClusterCIDR }}\nservice: {{ .ServiceCIDR }}\ndns: {{ .ClusterDNS }}.{{ .ClusterDomain }}\n",
+			want:    "cidr: 10.42.0.0/16,2001:cafe:42::/56\nservice: 10.43.0.0/16\ndns: 10.43.0.10.cluster.local\n",
+		},
+		{
+			name:    "Node counts",
+			content: "replicas: {{ if gt .ServerCount 1 }}{{ .ServerCount }}{{ else }}1{{ end }}\nagents: {{ .AgentCount }}",
+			want:    "replicas: 3\nagents: 2",
+		},
+		{
+			name:    "Join list",
+			content: `cidrs: [{{ join .ClusterCIDRs ", " }}]`,
+			want:    "cidrs: [10.42.0.0/16, 2001:cafe:42::/56]",
+		},
+		{
+			name:    "Unknown field",
+			content: "cidr: {{ .PodCIDR }}",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid template",
+			content: "cidr: {{ .ClusterCIDR",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(tt.name, []byte(tt.content), data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitShouldSkipFile(t *testing.T) {
+	tests := []struct {
+		fileName string
+		skips    map[string]bool
+		want     bool
+	}{
+		{fileName: "addon.yaml", want: false},
+		{fileName: "addon.yaml.tmpl", want: false},
+		{fileName: "addon.JSON.tmpl", want: false},
+		{fileName: "addon.tmpl", want: true},
+		{fileName: "addon.txt", want: true},
+		{fileName: ".addon.yaml.tmpl", want: true},
+		{fileName: "addon.yaml.tmpl", skips: map[string]bool{"addon.yaml.tmpl": true}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			if got := shouldSkipFile(tt.fileName, tt.skips); got != tt.want {
+				t.Errorf("shouldSkipFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitShouldDisableFile(t *testing.T) {
+	disables := map[string]bool{"traefik": true, "metrics-server": true}
+	tests := []struct {
+		fileName string
+		want     bool
+	}{
+		{fileName: "/manifests/traefik.yaml", want: true},
+		{fileName: "/manifests/traefik.yaml.tmpl", want: true},
+		{fileName: "/manifests/metrics-server/service.yaml.tmpl", want: true},
+		{fileName: "/manifests/coredns.yaml.tmpl", want: false},
+		{fileName: "/manifests/traefik.tmpl", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			if got := shouldDisableFile("/manifests/", tt.fileName, disables); got != tt.want {
+				t.Errorf("shouldDisableFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitTemplateData(t *testing.T) {
+	nodeStore := &mock.NodeStore{}
+	nodeStore.Create(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "server-1", Labels: map[string]string{util.ControlPlaneRoleLabelKey: "true", util.ETCDRoleLabelKey: "true"}}})
+	nodeStore.Create(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "etcd-1", Labels: map[string]string{util.ETCDRoleLabelKey: "true"}}})
+	nodeStore.Create(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "agent-1"}})
+
+	v1Mock := mock.NewV1(gomock.NewController(t))
+	v1Mock.NodeCache.EXPECT().List(gomock.Any()).AnyTimes().DoAndReturn(func(selector labels.Selector) ([]*v1.Node, error) {
+		nodes, err := nodeStore.List(selector)
+		result := make([]*v1.Node, len(nodes))
+		for i := range nodes {
+			result[i] = &nodes[i]
+		}
+		return result, err
+	})
+
+	w := &watcher{nodeCache: v1Mock.NodeCache}
+	data, err := w.templateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.NodeCount != 3 || data.ServerCount != 2 || data.AgentCount != 1 {
+		t.Errorf("templateData() counts = %d/%d/%d, want 3/2/1", data.NodeCount, data.ServerCount, data.AgentCount)
+	}
+}
@@ -426,6 +426,13 @@ func (e *ETCD) Reset(ctx context.Context, rebootstrap func() error) error {
 			}
 			e.config.ClusterResetRestorePath = path
 			logrus.Infof("S3 download complete for %s", e.config.ClusterResetRestorePath)
+		} else if _, err := os.Stat(e.config.ClusterResetRestorePath); os.IsNotExist(err) && (e.config.EtcdRemoteDir != nil || e.config.EtcdSFTP != nil) {
+			// The snapshot does not exist locally; try to retrieve it from remote storage.
+			path, err := e.downloadSnapshot(ctx, filepath.Base(e.config.ClusterResetRestorePath))
+			if err != nil {
+				return pkgerrors.WithMessage(err, "failed to download snapshot from remote storage")
+			}
+			e.config.ClusterResetRestorePath = path
 		}
 
 		info, err := os.Stat(e.config.ClusterResetRestorePath)
@@ -1574,6 +1581,21 @@ func (e *ETCD) Restore(ctx context.Context) error {
 		}
 
 		restorePath = decompressSnapshot
+	} else if keyID, err := snapshot.ReadFileEncryptionKeyID(e.config.ClusterResetRestorePath); err != nil {
+		return err
+	} else if keyID != "" {
+		dir, err := snapshotDir(e.config, true)
+		if err != nil {
+			return pkgerrors.WithMessage(err, "failed to get the snapshot dir")
+		}
+
+		decryptedSnapshot, err := e.decryptSnapshot(dir, e.config.ClusterResetRestorePath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(decryptedSnapshot)
+
+		restorePath = decryptedSnapshot
 	} else {
 		restorePath = e.config.ClusterResetRestorePath
 	}
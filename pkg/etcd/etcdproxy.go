@@ -73,7 +73,8 @@ func (e *etcdproxy) Update(addresses []string) {
 }
 
 // start a polling routine that makes periodic requests to the etcd node's supervisor port.
-// If the request fails, the node is marked unhealthy.
+// If the request fails, the node is marked unhealthy. The time taken by successful requests
+// is recorded as a latency sample for the node.
 func (e etcdproxy) createHealthCheck(ctx context.Context, address string) loadbalancer.HealthCheckFunc {
 	var status loadbalancer.HealthCheckResult
 
@@ -84,6 +85,7 @@ func (e etcdproxy) createHealthCheck(ctx context.Context, address string) loadba
 		ctx, cancel := context.WithTimeout(ctx, time.Second)
 		defer cancel()
 		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		start := time.Now()
 		resp, err := httpClient.Do(req)
 		var statusCode int
 		if resp != nil {
@@ -93,6 +95,7 @@ func (e etcdproxy) createHealthCheck(ctx context.Context, address string) loadba
 			logrus.Debugf("Health check %s failed: %v (StatusCode: %d)", address, err, statusCode)
 			status = loadbalancer.HealthCheckResultFailed
 		} else {
+			e.etcdLB.ObserveLatency(address, time.Since(start))
 			status = loadbalancer.HealthCheckResultOK
 		}
 	}, 5*time.Second, 1.0, true)
@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"bytes"
+	"sort"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// DiffResult describes the changes to resources between two snapshots.
+type DiffResult struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Added     int            `json:"added"`
+	Removed   int            `json:"removed"`
+	Modified  int            `json:"modified"`
+	Resources []ResourceDiff `json:"resources,omitempty"`
+}
+
+// ResourceDiff lists the names of resources of a single type within a single
+// namespace that were added, removed, or modified between two snapshots.
+type ResourceDiff struct {
+	Resource  string   `json:"resource"`
+	Namespace string   `json:"namespace,omitempty"`
+	Added     []string `json:"added,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+	Modified  []string `json:"modified,omitempty"`
+}
+
+// Diff compares the key-values read from two snapshots. A key is considered modified if it was
+// modified at a different revision, or if its value differs. Changes are grouped by resource type
+// and namespace, sorted by resource type, namespace, and name.
+func Diff(from, to map[string]*mvccpb.KeyValue) *DiffResult {
+	res := &DiffResult{}
+	groups := map[Key]*ResourceDiff{}
+	group := func(key string) (*ResourceDiff, string) {
+		k, _ := ParseKey(key)
+		name := k.Name
+		k.Name = ""
+		if _, ok := groups[k]; !ok {
+			groups[k] = &ResourceDiff{Resource: k.Resource, Namespace: k.Namespace}
+		}
+		return groups[k], name
+	}
+
+	for key, kv := range to {
+		prev, ok := from[key]
+		switch {
+		case !ok:
+			rd, name := group(key)
+			rd.Added = append(rd.Added, name)
+			res.Added++
+		case prev.ModRevision != kv.ModRevision || !bytes.Equal(prev.Value, kv.Value):
+			rd, name := group(key)
+			rd.Modified = append(rd.Modified, name)
+			res.Modified++
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			rd, name := group(key)
+			rd.Removed = append(rd.Removed, name)
+			res.Removed++
+		}
+	}
+
+	for _, rd := range groups {
+		sort.Strings(rd.Added)
+		sort.Strings(rd.Removed)
+		sort.Strings(rd.Modified)
+		res.Resources = append(res.Resources, *rd)
+	}
+	sort.Slice(res.Resources, func(i, j int) bool {
+		if res.Resources[i].Resource != res.Resources[j].Resource {
+			return res.Resources[i].Resource < res.Resources[j].Resource
+		}
+		return res.Resources[i].Namespace < res.Resources[j].Namespace
+	})
+	return res
+}
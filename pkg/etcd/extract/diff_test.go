@@ -0,0 +1,84 @@
+package extract
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func keyValues(ctx context.Context, t *testing.T, path string, filter Filter) map[string]*mvccpb.KeyValue {
+	r, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	kvs, err := r.KeyValues(ctx, filter)
+	if err != nil {
+		t.Fatalf("Reader.KeyValues() error = %v", err)
+	}
+	return kvs
+}
+
+func Test_UnitDiff(t *testing.T) {
+	ctx := context.Background()
+	from := writeSnapshotDB(t, []change{
+		{key: "/registry/configmaps/default/unchanged", value: []byte("a")},
+		{key: "/registry/configmaps/default/modified", value: []byte("a")},
+		{key: "/registry/configmaps/default/removed", value: []byte("a")},
+		{key: "/registry/leases/kube-system/lease", value: []byte("a")},
+	})
+	to := writeSnapshotDB(t, []change{
+		{key: "/registry/configmaps/default/unchanged", value: []byte("a")},
+		{key: "/registry/configmaps/default/modified", value: []byte("b")},
+		{key: "/registry/configmaps/default/removed", value: []byte("a")},
+		{key: "/registry/leases/kube-system/lease", value: []byte("a")},
+		{key: "/registry/configmaps/default/removed"},
+		{key: "/registry/namespaces/added", value: []byte("a")},
+		{key: "/registry/configmaps/added/added", value: []byte("a")},
+	})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   *DiffResult
+	}{
+		{
+			name: "All resources",
+			want: &DiffResult{
+				Added:    2,
+				Removed:  1,
+				Modified: 1,
+				Resources: []ResourceDiff{
+					{Resource: "configmaps", Namespace: "added", Added: []string{"added"}},
+					{Resource: "configmaps", Namespace: "default", Removed: []string{"removed"}, Modified: []string{"modified"}},
+					{Resource: "namespaces", Added: []string{"added"}},
+				},
+			},
+		},
+		{
+			name:   "Namespace",
+			filter: Filter{Namespaces: []string{"default"}},
+			want: &DiffResult{
+				Removed:  1,
+				Modified: 1,
+				Resources: []ResourceDiff{
+					{Resource: "configmaps", Namespace: "default", Removed: []string{"removed"}, Modified: []string{"modified"}},
+				},
+			},
+		},
+		{
+			name:   "Resource",
+			filter: Filter{Resources: []string{"leases.coordination.k8s.io"}},
+			want:   &DiffResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(keyValues(ctx, t, from, tt.filter), keyValues(ctx, t, to, tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+// Package extract reads Kubernetes resources directly from the bbolt database of an
+// etcd snapshot, without starting etcd or restoring the snapshot to a cluster.
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/storage/value"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// registryPrefix is the prefix of all keys written by the Kubernetes apiserver.
+	registryPrefix = "/registry/"
+	// encryptedPrefix is the prefix of values encrypted by the apiserver's encryption providers.
+	encryptedPrefix = "k8s:enc:"
+)
+
+var (
+	// keyResources maps the key prefixes that the apiserver uses for some built-in resources
+	// to the resource names.
+	keyResources = map[string]string{
+		"controllers": "replicationcontrollers",
+		"ingress":     "ingresses",
+		"minions":     "nodes",
+	}
+
+	// keyBucket is the bbolt bucket that holds the etcd key-value store, indexed by revision.
+	keyBucket = []byte("key")
+
+	scheme       = runtime.NewScheme()
+	deserializer runtime.Decoder
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+	deserializer = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+}
+
+// Filter selects resources to extract. Resources are matched by plural resource name, optionally
+// qualified by group (for example, "deployments" or "deployments.apps"), or by kind. Empty fields
+// match all resources.
+type Filter struct {
+	Resources  []string `json:"resources,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Names      []string `json:"names,omitempty"`
+}
+
+// Key identifies the resource stored at an apiserver key.
+type Key struct {
+	// Resource is the plural resource name, qualified by group for custom resources.
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// Object is a single resource read from a snapshot.
+type Object struct {
+	// Key is the etcd key that the resource was stored at.
+	Key string
+	// Resource is the plural resource name from the key, qualified by group for custom resources.
+	Resource string
+	// ModRevision is the etcd revision at which the resource was last modified.
+	ModRevision int64
+	Object      runtime.Object
+}
+
+// Reader reads resources from a snapshot database.
+type Reader struct {
+	db           *bolt.DB
+	transformers map[schema.GroupResource]value.Transformer
+}
+
+// Open opens the snapshot database at the given path for reading. The transformers, if
+// provided, are used to decrypt resources encrypted at rest by the apiserver.
+func Open(path string, transformers map[schema.GroupResource]value.Transformer) (*Reader, error) {
+	db, err := bolt.Open(path, 0400, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{db: db, transformers: transformers}, nil
+}
+
+// Close closes the snapshot database.
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
+
+// Objects returns the current revision of all resources in the snapshot that match the filter,
+// sorted so that namespaces and custom resource definitions come before the resources that depend
+// on them. Resources that cannot be decrypted or decoded are skipped with a warning.
+func (r *Reader) Objects(ctx context.Context, filter Filter) ([]Object, error) {
+	kvs, err := r.scan(ctx, func(Key) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []Object{}
+	for key, kv := range kvs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		obj, err := r.decode(ctx, key, kv.Value)
+		if err != nil {
+			logrus.Warnf("Skipping %s: %v", key, err)
+			continue
+		}
+		k, _ := ParseKey(key)
+		o := Object{Key: key, Resource: k.Resource, ModRevision: kv.ModRevision, Object: obj}
+		if filter.Matches(o) {
+			objects = append(objects, o)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if pi, pj := priority(objects[i].Resource), priority(objects[j].Resource); pi != pj {
+			return pi < pj
+		}
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// KeyValues returns the current revision of all apiserver keys in the snapshot that match the filter,
+// without decrypting or decoding the values. As the values are not decoded, resources are only matched
+// by resource name, and not by kind.
+func (r *Reader) KeyValues(ctx context.Context, filter Filter) (map[string]*mvccpb.KeyValue, error) {
+	return r.scan(ctx, filter.MatchesKey)
+}
+
+// scan returns the current revision of all apiserver keys in the snapshot that are matched by the
+// provided function.
+func (r *Reader) scan(ctx context.Context, match func(Key) bool) (map[string]*mvccpb.KeyValue, error) {
+	kvs := map[string]*mvccpb.KeyValue{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keyBucket)
+		if bucket == nil {
+			return errors.New("key bucket not found in snapshot database")
+		}
+
+		// Keys in the bucket are revisions, so iterating over the bucket visits each change in order.
+		// Track the latest revision of each key, and forget keys once they are deleted.
+		latest := map[string][]byte{}
+		if err := bucket.ForEach(func(rev, v []byte) error {
+			kv := &mvccpb.KeyValue{}
+			if err := kv.Unmarshal(v); err != nil {
+				return fmt.Errorf("failed to unmarshal key-value at revision %x: %w", rev, err)
+			}
+			key := string(kv.Key)
+			if !strings.HasPrefix(key, registryPrefix) {
+				return nil
+			}
+			if isTombstone(rev) {
+				delete(latest, key)
+			} else {
+				latest[key] = bytes.Clone(rev)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for key, rev := range latest {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if k, ok := ParseKey(key); !ok || !match(k) {
+				continue
+			}
+			kv := &mvccpb.KeyValue{}
+			if err := kv.Unmarshal(bucket.Get(rev)); err != nil {
+				return fmt.Errorf("failed to unmarshal key-value for %s: %w", key, err)
+			}
+			kvs[key] = kv
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+// decode decrypts the value if necessary, and decodes it into a typed object if the type is known,
+// or an unstructured object if not. The object's apiVersion and kind are always set.
+func (r *Reader) decode(ctx context.Context, key string, data []byte) (runtime.Object, error) {
+	if bytes.HasPrefix(data, []byte(encryptedPrefix)) {
+		decrypted, err := r.decrypt(ctx, key, data)
+		if err != nil {
+			return nil, err
+		}
+		data = decrypted
+	}
+
+	obj, gvk, err := deserializer.Decode(data, nil, nil)
+	if err != nil {
+		// custom resources and other types not known to the scheme are stored as JSON
+		if !runtime.IsNotRegisteredError(err) || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+		return u, nil
+	}
+	obj.GetObjectKind().SetGroupVersionKind(*gvk)
+	return obj, nil
+}
+
+// decrypt decrypts the value using the first transformer that is able to do so. The key is
+// used as authenticated data, as the apiserver does when encrypting the value.
+func (r *Reader) decrypt(ctx context.Context, key string, data []byte) ([]byte, error) {
+	if len(r.transformers) == 0 {
+		return nil, errors.New("value is encrypted, and no encryption configuration is available")
+	}
+	errs := []error{}
+	for _, transformer := range r.transformers {
+		out, _, err := transformer.TransformFromStorage(ctx, data, value.DefaultContext(key))
+		if err == nil {
+			return out, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("failed to decrypt value: %w", errors.Join(errs...))
+}
+
+// ParseKey returns the resource identified by an apiserver key. Resources in the core and built-in
+// groups are stored under /registry/<resource>/; custom resources are stored under /registry/<group>/<resource>/
+// and are qualified by group, for example "widgets.example.com". Namespaced resources are stored under
+// <namespace>/<name>, and cluster-scoped resources under <name>. Some built-in resources are stored under a
+// prefix other than their resource name, such as nodes under /registry/minions/.
+func ParseKey(key string) (Key, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, registryPrefix), "/")
+	if len(parts) < 2 || parts[0] == "" {
+		return Key{}, false
+	}
+	k := Key{Resource: parts[0]}
+	if resource, ok := keyResources[k.Resource]; ok {
+		k.Resource = resource
+	}
+	parts = parts[1:]
+	if strings.Contains(k.Resource, ".") {
+		if len(parts) < 2 || parts[0] == "" {
+			return Key{}, false
+		}
+		k.Resource = parts[0] + "." + k.Resource
+		parts = parts[1:]
+	}
+	// services and endpoints are stored under /registry/services/specs/ and /registry/services/endpoints/
+	if k.Resource == "services" && len(parts) > 2 {
+		if parts[0] == "endpoints" {
+			k.Resource = "endpoints"
+		}
+		parts = parts[1:]
+	}
+	k.Name = parts[len(parts)-1]
+	if k.Name == "" {
+		return Key{}, false
+	}
+	if len(parts) > 1 {
+		k.Namespace = parts[len(parts)-2]
+	}
+	return k, true
+}
+
+// MatchesKey returns true if the resource identified by the key is selected by the filter.
+// Kinds cannot be determined from the key, so resources are only matched by resource name.
+func (f Filter) MatchesKey(k Key) bool {
+	if len(f.Namespaces) > 0 && !slices.Contains(f.Namespaces, k.Namespace) {
+		return false
+	}
+	if len(f.Names) > 0 && !slices.Contains(f.Names, k.Name) {
+		return false
+	}
+	if len(f.Resources) == 0 {
+		return true
+	}
+	for _, resource := range f.Resources {
+		resource = strings.ToLower(resource)
+		// keys for built-in resources are not qualified by group
+		if resource == k.Resource || (!strings.Contains(k.Resource, ".") && strings.HasPrefix(resource, k.Resource+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if the object is selected by the filter.
+func (f Filter) Matches(o Object) bool {
+	accessor, err := meta.Accessor(o.Object)
+	if err != nil {
+		return false
+	}
+	if len(f.Namespaces) > 0 && !slices.Contains(f.Namespaces, accessor.GetNamespace()) {
+		return false
+	}
+	if len(f.Names) > 0 && !slices.Contains(f.Names, accessor.GetName()) {
+		return false
+	}
+	if len(f.Resources) == 0 {
+		return true
+	}
+
+	gvk := o.Object.GetObjectKind().GroupVersionKind()
+	names := []string{o.Resource, strings.ToLower(gvk.Kind)}
+	if gvk.Group != "" && !strings.Contains(o.Resource, ".") {
+		names = append(names, o.Resource+"."+gvk.Group)
+	}
+	for _, resource := range f.Resources {
+		if slices.Contains(names, strings.ToLower(resource)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteYAML writes the objects to the writer as a stream of YAML documents. Fields set by the
+// apiserver that would prevent the objects from being re-applied to a cluster are removed.
+func WriteYAML(w io.Writer, objects []Object) error {
+	for i, o := range objects {
+		obj := o.Object.DeepCopyObject()
+		if err := Sanitize(obj); err != nil {
+			return fmt.Errorf("failed to sanitize %s: %w", o.Key, err)
+		}
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", o.Key, err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sanitize removes server-populated metadata from the object, so that it can be created in a
+// cluster other than the one it was read from, or re-created after it has been deleted.
+func Sanitize(obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	accessor.SetUID("")
+	accessor.SetResourceVersion("")
+	accessor.SetSelfLink("")
+	accessor.SetGeneration(0)
+	accessor.SetCreationTimestamp(metav1.Time{})
+	accessor.SetDeletionTimestamp(nil)
+	accessor.SetDeletionGracePeriodSeconds(nil)
+	accessor.SetManagedFields(nil)
+	// owners are identified by UID, which will not match if the owner is re-created
+	accessor.SetOwnerReferences(nil)
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	}
+	return nil
+}
+
+// isTombstone returns true if the revision marks the deletion of a key.
+func isTombstone(rev []byte) bool {
+	return len(rev) == 18 && rev[17] == 't'
+}
+
+// priority returns the order in which resources should be created when re-applied.
+func priority(resource string) int {
+	switch resource {
+	case "namespaces":
+		return 0
+	case "customresourcedefinitions.apiextensions.k8s.io":
+		return 1
+	default:
+		return 2
+	}
+}
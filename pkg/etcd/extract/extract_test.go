@@ -0,0 +1,264 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/storage/value"
+	aestransformer "k8s.io/apiserver/pkg/storage/value/encrypt/aes"
+)
+
+// writeSnapshotDB writes a bbolt database containing the given changes to the key bucket,
+// in the same format as the etcd mvcc store. A nil value records the deletion of the key.
+func writeSnapshotDB(t *testing.T, changes []change) string {
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(keyBucket)
+		if err != nil {
+			return err
+		}
+		for i, c := range changes {
+			rev := make([]byte, 17, 18)
+			binary.BigEndian.PutUint64(rev, uint64(i+2))
+			rev[8] = '_'
+			kv := &mvccpb.KeyValue{Key: []byte(c.key), Value: c.value, ModRevision: int64(i + 2)}
+			if c.value == nil {
+				rev = append(rev, 't')
+			}
+			b, err := kv.Marshal()
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(rev, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type change struct {
+	key   string
+	value []byte
+}
+
+func encodeProtobuf(t *testing.T, obj runtime.Object) []byte {
+	buf := &bytes.Buffer{}
+	if err := protobuf.NewSerializer(scheme, scheme).Encode(obj, buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func configMap(namespace, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			UID:             types.UID("6d0d8d3c-0b1a-4d43-9a6f-4f8b3a2c1d00"),
+			ResourceVersion: "1234",
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Data: map[string]string{"key": "value"},
+	}
+}
+
+func Test_UnitParseKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   Key
+		wantOK bool
+	}{
+		{key: "/registry/configmaps/default/cm1", want: Key{Resource: "configmaps", Namespace: "default", Name: "cm1"}, wantOK: true},
+		{key: "/registry/namespaces/default", want: Key{Resource: "namespaces", Name: "default"}, wantOK: true},
+		{key: "/registry/services/specs/default/kubernetes", want: Key{Resource: "services", Namespace: "default", Name: "kubernetes"}, wantOK: true},
+		{key: "/registry/services/endpoints/default/kubernetes", want: Key{Resource: "endpoints", Namespace: "default", Name: "kubernetes"}, wantOK: true},
+		{key: "/registry/minions/node1", want: Key{Resource: "nodes", Name: "node1"}, wantOK: true},
+		{key: "/registry/controllers/default/rc1", want: Key{Resource: "replicationcontrollers", Namespace: "default", Name: "rc1"}, wantOK: true},
+		{key: "/registry/ingress/default/ing1", want: Key{Resource: "ingresses", Namespace: "default", Name: "ing1"}, wantOK: true},
+		{key: "/registry/example.com/widgets/default/w1", want: Key{Resource: "widgets.example.com", Namespace: "default", Name: "w1"}, wantOK: true},
+		{key: "/registry/apiextensions.k8s.io/customresourcedefinitions/widgets.example.com", want: Key{Resource: "customresourcedefinitions.apiextensions.k8s.io", Name: "widgets.example.com"}, wantOK: true},
+		{key: "/registry/example.com/widgets"},
+		{key: "/registry/configmaps/"},
+		{key: "/registry/health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := ParseKey(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseKey() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func Test_UnitReaderObjects(t *testing.T) {
+	ctx := context.Background()
+
+	block, err := aes.NewCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformers := map[schema.GroupResource]value.Transformer{
+		{Resource: "secrets"}: value.NewPrefixTransformers(errors.New("no matching prefix"), value.PrefixTransformer{
+			Prefix:      []byte("k8s:enc:aescbc:v1:key1:"),
+			Transformer: aestransformer.NewCBCTransformer(block),
+		}),
+	}
+	secretKey := "/registry/secrets/default/s1"
+	secret, _ := transformers[schema.GroupResource{Resource: "secrets"}].TransformToStorage(ctx, encodeProtobuf(t, &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "s1"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}), value.DefaultContext(secretKey))
+
+	path := writeSnapshotDB(t, []change{
+		{key: "/registry/namespaces/default", value: encodeProtobuf(t, &corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		})},
+		{key: "/registry/configmaps/default/cm1", value: encodeProtobuf(t, configMap("default", "cm1"))},
+		{key: "/registry/configmaps/default/cm2", value: encodeProtobuf(t, configMap("default", "cm2"))},
+		{key: "/registry/configmaps/default/cm2"},
+		{key: "/registry/configmaps/kube-system/cm3", value: encodeProtobuf(t, configMap("kube-system", "cm3"))},
+		{key: secretKey, value: secret},
+		{key: "/registry/minions/node1", value: encodeProtobuf(t, &corev1.Node{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+			ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		})},
+		{key: "/registry/example.com/widgets/default/w1", value: []byte(`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"namespace":"default","name":"w1","uid":"1234"},"spec":{"size":3}}`)},
+		{key: "/bootstrap/0123456789ab", value: []byte("bootstrap data")},
+	})
+
+	tests := []struct {
+		name         string
+		filter       Filter
+		transformers map[schema.GroupResource]value.Transformer
+		want         []string
+	}{
+		{
+			name:         "All resources",
+			transformers: transformers,
+			want: []string{
+				"/registry/namespaces/default",
+				"/registry/configmaps/default/cm1",
+				"/registry/configmaps/kube-system/cm3",
+				"/registry/example.com/widgets/default/w1",
+				"/registry/minions/node1",
+				secretKey,
+			},
+		},
+		{
+			name: "Encrypted resources without transformers",
+			want: []string{
+				"/registry/namespaces/default",
+				"/registry/configmaps/default/cm1",
+				"/registry/configmaps/kube-system/cm3",
+				"/registry/example.com/widgets/default/w1",
+				"/registry/minions/node1",
+			},
+		},
+		{
+			name:         "Namespace",
+			filter:       Filter{Namespaces: []string{"default"}},
+			transformers: transformers,
+			want: []string{
+				"/registry/configmaps/default/cm1",
+				"/registry/example.com/widgets/default/w1",
+				secretKey,
+			},
+		},
+		{
+			name:         "Resource by kind and name",
+			filter:       Filter{Resources: []string{"ConfigMap"}, Names: []string{"cm3"}},
+			transformers: transformers,
+			want:         []string{"/registry/configmaps/kube-system/cm3"},
+		},
+		{
+			name:         "Resources by plural and group",
+			filter:       Filter{Resources: []string{"secrets", "widgets.example.com"}},
+			transformers: transformers,
+			want:         []string{"/registry/example.com/widgets/default/w1", secretKey},
+		},
+		{
+			name:   "Nodes",
+			filter: Filter{Resources: []string{"nodes"}},
+			want:   []string{"/registry/minions/node1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Open(path, tt.transformers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			objects, err := r.Objects(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Reader.Objects() error = %v", err)
+			}
+			got := []string{}
+			for _, o := range objects {
+				got = append(got, o.Key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.Objects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitWriteYAML(t *testing.T) {
+	objects := []Object{
+		{Key: "/registry/configmaps/default/cm1", Resource: "configmaps", Object: configMap("default", "cm1")},
+		{Key: "/registry/configmaps/default/cm2", Resource: "configmaps", Object: configMap("default", "cm2")},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteYAML(buf, objects); err != nil {
+		t.Fatalf("WriteYAML() error = %v", err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "\n---\n"); n != 1 {
+		t.Errorf("WriteYAML() wrote %d document separators, want 1", n)
+	}
+	for _, field := range []string{"uid:", "resourceVersion:", "managedFields:"} {
+		if strings.Contains(out, field) {
+			t.Errorf("WriteYAML() output contains %s\n%s", field, out)
+		}
+	}
+	for _, field := range []string{"apiVersion: v1", "kind: ConfigMap", "name: cm1", "key: value"} {
+		if !strings.Contains(out, field) {
+			t.Errorf("WriteYAML() output does not contain %s\n%s", field, out)
+		}
+	}
+	if objects[0].Object.(*corev1.ConfigMap).UID == "" {
+		t.Errorf("WriteYAML() modified the original object")
+	}
+}
@@ -0,0 +1,81 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+)
+
+// NewRemoteDir returns a Store that keeps snapshots in a directory on a mounted file system,
+// such as an NFS share. The directory must already exist, to avoid writing snapshots to the
+// local disk if the file system is not mounted.
+func NewRemoteDir(etcdRemoteDir *config.EtcdRemoteDir, nodeName, tokenHash string) (*Store, error) {
+	if etcdRemoteDir == nil || etcdRemoteDir.Path == "" {
+		return nil, errors.New("remote snapshot directory was not set")
+	}
+	dir, err := filepath.Abs(etcdRemoteDir.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	dial := func(ctx context.Context) (FS, error) {
+		if info, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, errors.New("remote snapshot directory " + dir + " is not a directory")
+		}
+		return osFS{}, nil
+	}
+	return New(snapshot.StorageRemoteDir, filepath.ToSlash(dir), "file://"+filepath.ToSlash(dir), nodeName, tokenHash, dial), nil
+}
+
+// osFS implements FS using the local file system.
+type osFS struct{}
+
+func (osFS) Create(name string) (io.WriteCloser, error) {
+	return os.OpenFile(filepath.FromSlash(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+func (osFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (osFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.FromSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (osFS) MkdirAll(name string) error {
+	return os.MkdirAll(filepath.FromSlash(name), 0700)
+}
+
+func (osFS) Rename(oldname, newname string) error {
+	return os.Rename(filepath.FromSlash(oldname), filepath.FromSlash(newname))
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(filepath.FromSlash(name))
+}
+
+func (osFS) Close() error {
+	return nil
+}
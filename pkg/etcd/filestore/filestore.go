@@ -0,0 +1,410 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// partialExtension is appended to the name of snapshot files while they are
+// being written, so that incomplete snapshots are never listed or restored.
+const partialExtension = ".part"
+
+// infoDir holds a file for each snapshot recording the node that took it and the hash of
+// the server token, as a plain file system has nowhere else to keep them with the snapshot.
+const infoDir = ".info"
+
+// snapshotInfo is the content of the files in the info directory.
+type snapshotInfo struct {
+	NodeName  string `json:"nodeName,omitempty"`
+	TokenHash string `json:"tokenHash,omitempty"`
+}
+
+// FS provides access to a file system that snapshots are stored on.
+// Paths are slash-separated, regardless of the local operating system.
+type FS interface {
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	MkdirAll(name string) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	Close() error
+}
+
+// DialFunc returns a connection to the file system that snapshots are stored on.
+type DialFunc func(ctx context.Context) (FS, error)
+
+var _ snapshot.Storage = &Store{}
+
+// Store stores snapshots in a directory on a file system, such as a
+// mounted network share or a remote SFTP server. A new connection to the
+// file system is dialed for each operation, and closed once it completes.
+type Store struct {
+	storage   string
+	dir       string
+	location  string
+	nodeName  string
+	tokenHash string
+	dial      DialFunc
+}
+
+// New returns a Store that keeps snapshots in the given directory on the file system
+// returned by dial. The storage name is used as the node name of snapshots held by the
+// store, and the location is used as a prefix when generating snapshot locations.
+func New(storage, dir, location, nodeName, tokenHash string, dial DialFunc) *Store {
+	return &Store{
+		storage:   storage,
+		dir:       dir,
+		location:  strings.TrimSuffix(location, "/"),
+		nodeName:  nodeName,
+		tokenHash: tokenHash,
+		dial:      dial,
+	}
+}
+
+// Upload copies the given snapshot, and any metadata saved alongside it, to the store.
+func (s *Store) Upload(ctx context.Context, snapshotPath string, extraMetadata *v1.ConfigMap, now time.Time) (*snapshot.File, error) {
+	basename := filepath.Base(snapshotPath)
+	metadata := filepath.Join(filepath.Dir(snapshotPath), "..", snapshot.MetadataDir, basename)
+
+	sf := &snapshot.File{
+		Name:     basename,
+		Location: s.location + "/" + basename,
+		NodeName: s.storage,
+		CreatedAt: &metav1.Time{
+			Time: now,
+		},
+		Compressed:     strings.HasSuffix(snapshotPath, snapshot.CompressedExtension),
+		MetadataSource: extraMetadata,
+		NodeSource:     s.nodeName,
+	}
+
+	keyID, err := snapshot.ReadFileEncryptionKeyID(snapshotPath)
+	if err != nil {
+		logrus.Warnf("Failed to read snapshot encryption header: %v", err)
+	}
+	sf.EncryptionKeyID = keyID
+
+	logrus.Infof("Uploading snapshot to %s", sf.Location)
+	size, err := s.upload(ctx, snapshotPath, metadata)
+	if err != nil {
+		sf.Status = snapshot.FailedStatus
+		sf.Message = base64.StdEncoding.EncodeToString([]byte(err.Error()))
+	} else {
+		sf.Status = snapshot.SuccessfulStatus
+		sf.Size = size
+		sf.TokenHash = s.tokenHash
+	}
+	return sf, err
+}
+
+// upload copies the snapshot file and metadata to the store, returning the size of the snapshot.
+// Failing to copy the metadata is not fatal, the snapshot can still be used without it.
+func (s *Store) upload(ctx context.Context, snapshotPath, metadataPath string) (int64, error) {
+	fs, err := s.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer fs.Close()
+
+	basename := filepath.Base(snapshotPath)
+	if err := fs.MkdirAll(s.dir); err != nil {
+		return 0, err
+	}
+	size, err := copyTo(fs, snapshotPath, path.Join(s.dir, basename))
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := os.Stat(metadataPath); err == nil {
+		metadataDir := path.Join(s.dir, snapshot.MetadataDir)
+		if err := fs.MkdirAll(metadataDir); err != nil {
+			logrus.Warnf("Failed to create snapshot metadata directory %s: %v", metadataDir, err)
+		} else if _, err := copyTo(fs, metadataPath, path.Join(metadataDir, basename)); err != nil {
+			logrus.Warnf("Failed to upload snapshot metadata: %v", err)
+		} else {
+			logrus.Infof("Uploaded snapshot metadata %s/%s/%s", s.location, snapshot.MetadataDir, basename)
+		}
+	}
+
+	info, err := json.Marshal(snapshotInfo{NodeName: s.nodeName, TokenHash: s.tokenHash})
+	if err != nil {
+		return 0, err
+	}
+	infoPath := path.Join(s.dir, infoDir)
+	if err := fs.MkdirAll(infoPath); err != nil {
+		logrus.Warnf("Failed to create snapshot info directory %s: %v", infoPath, err)
+	} else if _, err := writeTo(fs, bytes.NewReader(info), path.Join(infoPath, basename)); err != nil {
+		logrus.Warnf("Failed to upload snapshot info: %v", err)
+	}
+
+	return size, nil
+}
+
+// Download copies the given snapshot, and its metadata if it exists, from the store
+// into the snapshot directory. Returns the path the snapshot was downloaded to.
+func (s *Store) Download(ctx context.Context, snapshotName, snapshotDir string) (string, error) {
+	fs, err := s.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer fs.Close()
+
+	snapshotFile := filepath.Join(snapshotDir, snapshotName)
+	metadataFile := filepath.Join(snapshotDir, "..", snapshot.MetadataDir, snapshotName)
+
+	logrus.Debugf("Downloading snapshot from %s/%s", s.location, snapshotName)
+	if err := copyFrom(fs, path.Join(s.dir, snapshotName), snapshotFile); err != nil {
+		return "", err
+	}
+
+	logrus.Debugf("Downloading snapshot metadata from %s/%s/%s", s.location, snapshot.MetadataDir, snapshotName)
+	if err := os.MkdirAll(filepath.Dir(metadataFile), 0700); err != nil {
+		return "", err
+	}
+	if err := copyFrom(fs, path.Join(s.dir, snapshot.MetadataDir, snapshotName), metadataFile); err != nil && !snapshot.IsNotExist(err) {
+		return "", err
+	}
+
+	return snapshotFile, nil
+}
+
+// ListSnapshots provides a list of the snapshots held by the
+// store, along with their relevant metadata.
+func (s *Store) ListSnapshots(ctx context.Context) (map[string]snapshot.File, error) {
+	fs, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	files, err := listSnapshotFiles(fs, s.dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := map[string]snapshot.File{}
+	for _, file := range files {
+		sf := snapshot.File{
+			Name:     file.Name(),
+			Location: s.location + "/" + file.Name(),
+			NodeName: s.storage,
+			CreatedAt: &metav1.Time{
+				Time: snapshotTime(file),
+			},
+			Size:       file.Size(),
+			Status:     snapshot.SuccessfulStatus,
+			Compressed: strings.HasSuffix(file.Name(), snapshot.CompressedExtension),
+		}
+
+		// try to read metadata and the encryption header; don't warn if metadata is missing
+		// as it will not exist if there was no metadata provided.
+		if m, err := readFile(fs, path.Join(s.dir, snapshot.MetadataDir, file.Name())); err == nil {
+			logrus.Debugf("Loading snapshot metadata from %s/%s/%s", s.location, snapshot.MetadataDir, file.Name())
+			sf.Metadata = base64.StdEncoding.EncodeToString(m)
+		}
+		// snapshots uploaded by older releases have no info file; leave the node and token hash unset.
+		if b, err := readFile(fs, path.Join(s.dir, infoDir, file.Name())); err == nil {
+			info := snapshotInfo{}
+			if err := json.Unmarshal(b, &info); err != nil {
+				logrus.Warnf("Failed to decode info for snapshot %s: %v", sf.Location, err)
+			} else {
+				sf.NodeSource = info.NodeName
+				sf.TokenHash = info.TokenHash
+			}
+		}
+		if keyID, err := readEncryptionKeyID(fs, path.Join(s.dir, file.Name())); err != nil {
+			logrus.Warnf("Failed to read encryption header from snapshot %s: %v", sf.Location, err)
+		} else {
+			sf.EncryptionKeyID = keyID
+		}
+
+		sfKey := sf.GenerateConfigMapKey()
+		snapshots[sfKey] = sf
+	}
+
+	return snapshots, nil
+}
+
+// SnapshotRetention prunes snapshots with the given prefix that are not retained by the retention policy.
+// Returns the retention decision for each snapshot.
+func (s *Store) SnapshotRetention(ctx context.Context, policy snapshot.RetentionPolicy, prefix string, dryRun bool) ([]snapshot.RetentionDecision, error) {
+	if !policy.Enabled() {
+		return nil, nil
+	}
+
+	fs, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	logrus.Infof("Applying snapshot %s to snapshots with prefix %s stored in %s", policy, prefix, s.location)
+
+	files, err := listSnapshotFiles(fs, s.dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotFiles := make([]snapshot.File, 0, len(files))
+	for _, file := range files {
+		snapshotFiles = append(snapshotFiles, snapshot.File{Name: file.Name(), CreatedAt: &metav1.Time{Time: snapshotTime(file)}})
+	}
+
+	return snapshot.ApplyRetention(snapshotFiles, policy, dryRun, func(name string) error {
+		logrus.Infof("Removing %s snapshot: %s/%s", s.storage, s.location, name)
+		if err := deleteSnapshot(fs, s.dir, name); err != nil && !snapshot.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// DeleteSnapshot deletes the given snapshot and its metadata from the store.
+func (s *Store) DeleteSnapshot(ctx context.Context, snapshotName string) error {
+	fs, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	return deleteSnapshot(fs, s.dir, snapshotName)
+}
+
+// deleteSnapshot deletes a snapshot, its metadata and info. These are removed regardless
+// of whether or not the snapshot existed, to ensure that things are cleaned up in the case of
+// ephemeral errors. The error from removing the snapshot is returned, so that callers can
+// determine if the snapshot was actually deleted or not.
+func deleteSnapshot(fs FS, dir, snapshotName string) error {
+	err := fs.Remove(path.Join(dir, snapshotName))
+	for _, subdir := range []string{snapshot.MetadataDir, infoDir} {
+		if merr := fs.Remove(path.Join(dir, subdir, snapshotName)); merr != nil && !snapshot.IsNotExist(merr) && err == nil {
+			err = merr
+		}
+	}
+	return err
+}
+
+// listSnapshotFiles lists the snapshot files with the given prefix in a directory,
+// skipping subdirectories and partially written files. A missing directory is not
+// an error, as it will not exist until the first snapshot is uploaded.
+func listSnapshotFiles(fs FS, dir, prefix string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		if snapshot.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := []os.FileInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Size() == 0 || strings.HasSuffix(entry.Name(), partialExtension) || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		files = append(files, entry)
+	}
+	return files, nil
+}
+
+// snapshotTime returns the creation time of a snapshot, from the timestamp
+// in the snapshot name if possible, or the file modification time if not.
+func snapshotTime(file os.FileInfo) time.Time {
+	basename, _ := strings.CutSuffix(file.Name(), snapshot.CompressedExtension)
+	ts, err := strconv.ParseInt(basename[strings.LastIndexByte(basename, '-')+1:], 10, 64)
+	if err != nil {
+		return file.ModTime()
+	}
+	return time.Unix(ts, 0)
+}
+
+// copyTo copies a local file to the file system. The content is written to a temporary
+// file that is renamed into place once complete. Returns the number of bytes copied.
+func copyTo(fs FS, src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	return writeTo(fs, in, dst)
+}
+
+// writeTo writes the content of a reader to a file on the file system. The content is written
+// to a temporary file that is renamed into place once complete. Returns the number of bytes written.
+func writeTo(fs FS, in io.Reader, dst string) (int64, error) {
+	partPath := dst + partialExtension
+	out, err := fs.Create(partPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fs.Rename(partPath, dst)
+	}
+	if err != nil {
+		fs.Remove(partPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+// copyFrom copies a file from the file system to a local file.
+func copyFrom(fs FS, src, dst string) error {
+	in, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// readFile reads the content of a file on the file system.
+func readFile(fs FS, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// readEncryptionKeyID returns the ID of the key used to encrypt a file on the
+// file system. An empty string is returned if the file is not encrypted.
+func readEncryptionKeyID(fs FS, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return snapshot.PeekEncryptionKeyID(bufio.NewReader(f))
+}
@@ -0,0 +1,238 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	v1 "k8s.io/api/core/v1"
+)
+
+// writeSnapshots creates snapshot files for the given timestamps in a new local
+// snapshot directory, returning the path of the snapshot directory.
+func writeSnapshots(t *testing.T, prefix string, timestamps ...int64) string {
+	dataDir := t.TempDir()
+	snapshotDir := filepath.Join(dataDir, "snapshots")
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range timestamps {
+		name := fmt.Sprintf("%s-node1-%d", prefix, ts)
+		if err := os.WriteFile(filepath.Join(snapshotDir, name), []byte("snapshot "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return snapshotDir
+}
+
+func Test_UnitNewRemoteDir(t *testing.T) {
+	tests := []struct {
+		name          string
+		etcdRemoteDir *config.EtcdRemoteDir
+		wantErr       bool
+	}{
+		{
+			name:    "Nil config",
+			wantErr: true,
+		},
+		{
+			name:          "Empty path",
+			etcdRemoteDir: &config.EtcdRemoteDir{},
+			wantErr:       true,
+		},
+		{
+			name:          "Valid path",
+			etcdRemoteDir: &config.EtcdRemoteDir{Path: t.TempDir()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRemoteDir(tt.etcdRemoteDir, "node1", "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRemoteDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitStoreUpload(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Round(time.Second)
+
+	tests := []struct {
+		name     string
+		remote   func(t *testing.T) string
+		metadata *v1.ConfigMap
+		wantErr  bool
+	}{
+		{
+			name:   "Successful upload",
+			remote: func(t *testing.T) string { return t.TempDir() },
+		},
+		{
+			name:     "Successful upload with metadata",
+			remote:   func(t *testing.T) string { return t.TempDir() },
+			metadata: &v1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+		},
+		{
+			name:   "Missing remote directory",
+			remote: func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+			// the remote directory must exist, as it may be an unmounted network file system
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotDir := writeSnapshots(t, "etcd-snapshot", now.Unix())
+			snapshotPath := filepath.Join(snapshotDir, fmt.Sprintf("etcd-snapshot-node1-%d", now.Unix()))
+			if tt.metadata != nil {
+				metadataDir := filepath.Join(snapshotDir, "..", snapshot.MetadataDir)
+				if err := os.MkdirAll(metadataDir, 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(metadataDir, filepath.Base(snapshotPath)), []byte(`{"foo":"bar"}`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			remote := tt.remote(t)
+			s, err := NewRemoteDir(&config.EtcdRemoteDir{Path: remote}, "node1", "hash")
+			if err != nil {
+				t.Fatalf("NewRemoteDir() error = %v", err)
+			}
+
+			sf, err := s.Upload(ctx, snapshotPath, tt.metadata, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Store.Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if sf == nil {
+				t.Fatal("Store.Upload() returned nil snapshot file")
+			}
+			if sf.NodeName != snapshot.StorageRemoteDir {
+				t.Errorf("Store.Upload() NodeName = %s, want %s", sf.NodeName, snapshot.StorageRemoteDir)
+			}
+			if tt.wantErr {
+				if sf.Status != snapshot.FailedStatus {
+					t.Errorf("Store.Upload() Status = %s, want %s", sf.Status, snapshot.FailedStatus)
+				}
+				return
+			}
+
+			if sf.Status != snapshot.SuccessfulStatus || sf.TokenHash != "hash" || sf.NodeSource != "node1" {
+				t.Errorf("Store.Upload() unexpected snapshot file = %+v", sf)
+			}
+			if _, err := os.Stat(filepath.Join(remote, sf.Name)); err != nil {
+				t.Errorf("Uploaded snapshot not found: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(remote, sf.Name+partialExtension)); !os.IsNotExist(err) {
+				t.Errorf("Partial snapshot file was not removed: %v", err)
+			}
+			_, err = os.Stat(filepath.Join(remote, snapshot.MetadataDir, sf.Name))
+			if tt.metadata != nil && err != nil {
+				t.Errorf("Uploaded snapshot metadata not found: %v", err)
+			} else if tt.metadata == nil && !os.IsNotExist(err) {
+				t.Errorf("Unexpected snapshot metadata: %v", err)
+			}
+		})
+	}
+}
+
+func Test_UnitStoreListRetentionDelete(t *testing.T) {
+	ctx := context.Background()
+	timestamps := []int64{1700000000, 1700000100, 1700000200, 1700000300, 1700000400}
+	snapshotDir := writeSnapshots(t, "etcd-snapshot", timestamps...)
+	remote := t.TempDir()
+
+	s, err := NewRemoteDir(&config.EtcdRemoteDir{Path: remote}, "node1", "token-hash")
+	if err != nil {
+		t.Fatalf("NewRemoteDir() error = %v", err)
+	}
+	for _, ts := range timestamps {
+		if _, err := s.Upload(ctx, filepath.Join(snapshotDir, fmt.Sprintf("etcd-snapshot-node1-%d", ts)), nil, time.Unix(ts, 0)); err != nil {
+			t.Fatalf("Store.Upload() error = %v", err)
+		}
+	}
+	// Snapshots with other prefixes and partially written files should not be pruned or listed
+	if err := os.WriteFile(filepath.Join(remote, "other-node1-1600000000"), []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "etcd-snapshot-node1-1600000000"+partialExtension), []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sfs, err := s.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("Store.ListSnapshots() error = %v", err)
+	}
+	if len(sfs) != len(timestamps)+1 {
+		t.Errorf("Store.ListSnapshots() returned %d snapshots, want %d", len(sfs), len(timestamps)+1)
+	}
+	for key, sf := range sfs {
+		if sf.NodeName != snapshot.StorageRemoteDir || key != snapshot.StorageRemoteDir+"-"+sf.Name {
+			t.Errorf("Store.ListSnapshots() unexpected snapshot %s = %+v", key, sf)
+		}
+		// Only uploaded snapshots have an info file recording the node and token hash
+		wantNode, wantHash := "node1", "token-hash"
+		if !strings.HasPrefix(sf.Name, "etcd-snapshot") {
+			wantNode, wantHash = "", ""
+		}
+		if sf.NodeSource != wantNode || sf.TokenHash != wantHash {
+			t.Errorf("Store.ListSnapshots() snapshot %s NodeSource = %q, TokenHash = %q, want %q, %q", key, sf.NodeSource, sf.TokenHash, wantNode, wantHash)
+		}
+	}
+
+	policy := snapshot.RetentionPolicy{Count: 2}
+	decisions, err := s.SnapshotRetention(ctx, policy, "etcd-snapshot", true)
+	if err != nil {
+		t.Fatalf("Store.SnapshotRetention() dry run error = %v", err)
+	}
+	want := []string{"etcd-snapshot-node1-1700000000", "etcd-snapshot-node1-1700000100", "etcd-snapshot-node1-1700000200"}
+	if pruned := snapshot.Pruned(decisions); len(pruned) != len(want) {
+		t.Errorf("Store.SnapshotRetention() dry run pruned = %v, want %v", pruned, want)
+	}
+	if sfs, err := s.ListSnapshots(ctx); err != nil || len(sfs) != len(timestamps)+1 {
+		t.Errorf("Store.SnapshotRetention() dry run removed snapshots, got %d snapshots, error = %v", len(sfs), err)
+	}
+
+	decisions, err = s.SnapshotRetention(ctx, policy, "etcd-snapshot", false)
+	if err != nil {
+		t.Fatalf("Store.SnapshotRetention() error = %v", err)
+	}
+	deleted := snapshot.Pruned(decisions)
+	slices.Sort(deleted)
+	if !slices.Equal(deleted, want) {
+		t.Errorf("Store.SnapshotRetention() deleted = %v, want %v", deleted, want)
+	}
+
+	if err := s.DeleteSnapshot(ctx, "etcd-snapshot-node1-1700000400"); err != nil {
+		t.Errorf("Store.DeleteSnapshot() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(remote, infoDir, "etcd-snapshot-node1-1700000400")); !os.IsNotExist(err) {
+		t.Errorf("Store.DeleteSnapshot() did not remove snapshot info, error = %v", err)
+	}
+	if err := s.DeleteSnapshot(ctx, "etcd-snapshot-node1-1700000400"); !snapshot.IsNotExist(err) {
+		t.Errorf("Store.DeleteSnapshot() of missing snapshot error = %v, want not exist", err)
+	}
+
+	downloadDir := filepath.Join(t.TempDir(), "snapshots")
+	if err := os.MkdirAll(downloadDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path, err := s.Download(ctx, "etcd-snapshot-node1-1700000300", downloadDir)
+	if err != nil {
+		t.Fatalf("Store.Download() error = %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "snapshot etcd-snapshot-node1-1700000300" {
+		t.Errorf("Store.Download() unexpected content = %q, error = %v", b, err)
+	}
+	if _, err := s.Download(ctx, "etcd-snapshot-node1-1700000400", downloadDir); !snapshot.IsNotExist(err) {
+		t.Errorf("Store.Download() of missing snapshot error = %v, want not exist", err)
+	}
+}
@@ -1,12 +1,18 @@
 package s3
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/textproto"
@@ -15,7 +21,6 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,8 +45,15 @@ var (
 	clusterIDKey = textproto.CanonicalMIMEHeaderKey(version.Program + "-cluster-id")
 	tokenHashKey = textproto.CanonicalMIMEHeaderKey(version.Program + "-token-hash")
 	nodeNameKey  = textproto.CanonicalMIMEHeaderKey(version.Program + "-node-name")
+	keyIDKey     = textproto.CanonicalMIMEHeaderKey(version.Program + "-encryption-key-id")
 )
 
+// streamPartSize is the size of each part uploaded when streaming snapshots to S3.
+// The maximum size of a streamed snapshot is 10000 times this value.
+const streamPartSize = 16 * 1024 * 1024
+
+var _ snapshot.Storage = &Client{}
+
 var defaultEtcdS3 = &config.EtcdS3{
 	Endpoint: "s3.amazonaws.com",
 	Region:   "us-east-1",
@@ -127,9 +139,10 @@ func (c *Controller) GetClient(ctx context.Context, etcdS3 *config.EtcdS3) (*Cli
 		return nil, errors.New("nil s3 configuration")
 	}
 
-	// update ConfigSecret in defaults so that comparisons between current and default config
-	// ignore ConfigSecret when deciding if CLI configuration is present.
+	// update ConfigSecret and Retention in defaults so that comparisons between current and default
+	// config ignore these fields when deciding if CLI configuration is present.
 	defaultEtcdS3.ConfigSecret = etcdS3.ConfigSecret
+	defaultEtcdS3.Retention = etcdS3.Retention
 
 	// If config is default, try to load config from secret, and fail if it cannot be retrieved or if the secret name is not set.
 	// If config is not default, and secret name is set, warn that the secret is being ignored
@@ -270,7 +283,7 @@ func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata
 	sf := &snapshot.File{
 		Name:     basename,
 		Location: fmt.Sprintf("s3://%s/%s", c.etcdS3.Bucket, snapshotKey),
-		NodeName: "s3",
+		NodeName: snapshot.StorageS3,
 		CreatedAt: &metav1.Time{
 			Time: now,
 		},
@@ -280,8 +293,14 @@ func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata
 		NodeSource:     c.controller.nodeName,
 	}
 
+	keyID, err := snapshot.ReadFileEncryptionKeyID(snapshotPath)
+	if err != nil {
+		logrus.Warnf("Failed to read snapshot encryption header: %v", err)
+	}
+	sf.EncryptionKeyID = keyID
+
 	logrus.Infof("Uploading snapshot to s3://%s/%s", c.etcdS3.Bucket, snapshotKey)
-	uploadInfo, err := c.uploadSnapshot(ctx, snapshotKey, snapshotPath)
+	uploadInfo, err := c.uploadSnapshot(ctx, snapshotKey, snapshotPath, keyID)
 	if err != nil {
 		sf.Status = snapshot.FailedStatus
 		sf.Message = base64.StdEncoding.EncodeToString([]byte(err.Error()))
@@ -299,7 +318,7 @@ func (c *Client) Upload(ctx context.Context, snapshotPath string, extraMetadata
 }
 
 // uploadSnapshot uploads the snapshot file to S3 using the minio API.
-func (c *Client) uploadSnapshot(ctx context.Context, key, path string) (info minio.UploadInfo, err error) {
+func (c *Client) uploadSnapshot(ctx context.Context, key, path, keyID string) (info minio.UploadInfo, err error) {
 	opts := minio.PutObjectOptions{
 		NumThreads: 2,
 		UserMetadata: map[string]string{
@@ -308,6 +327,9 @@ func (c *Client) uploadSnapshot(ctx context.Context, key, path string) (info min
 			tokenHashKey: c.controller.tokenHash,
 		},
 	}
+	if keyID != "" {
+		opts.UserMetadata[keyIDKey] = keyID
+	}
 	if strings.HasSuffix(key, snapshot.CompressedExtension) {
 		opts.ContentType = "application/zip"
 	} else {
@@ -342,6 +364,138 @@ func (c *Client) uploadSnapshotMetadata(ctx context.Context, key, path string) (
 	return c.mc.FPutObject(ctx, c.etcdS3.Bucket, key, path, opts)
 }
 
+// UploadStream uploads a snapshot to the configured S3 compatible backend, reading the
+// snapshot content from the provided reader instead of a file on disk. The content is sent
+// as a multipart upload, and is verified after upload by comparing the sha256 digest of the
+// uploaded object against that of the content read from the reader. Objects that fail
+// verification are removed.
+func (c *Client) UploadStream(ctx context.Context, snapshotName string, r io.Reader, extraMetadata *v1.ConfigMap, now time.Time) (*snapshot.File, error) {
+	snapshotKey := path.Join(c.etcdS3.Folder, snapshotName)
+	metadataKey := path.Join(c.etcdS3.Folder, snapshot.MetadataDir, snapshotName)
+
+	sf := &snapshot.File{
+		Name:     snapshotName,
+		Location: fmt.Sprintf("s3://%s/%s", c.etcdS3.Bucket, snapshotKey),
+		NodeName: snapshot.StorageS3,
+		CreatedAt: &metav1.Time{
+			Time: now,
+		},
+		S3:             &snapshot.S3Config{EtcdS3: *c.etcdS3},
+		Compressed:     strings.HasSuffix(snapshotName, snapshot.CompressedExtension),
+		MetadataSource: extraMetadata,
+		NodeSource:     c.controller.nodeName,
+	}
+
+	br := bufio.NewReader(r)
+	keyID, err := snapshot.PeekEncryptionKeyID(br)
+	if err != nil {
+		logrus.Warnf("Failed to read snapshot encryption header: %v", err)
+	}
+	sf.EncryptionKeyID = keyID
+
+	logrus.Infof("Streaming snapshot to s3://%s/%s", c.etcdS3.Bucket, snapshotKey)
+	hash := sha256.New()
+	uploadInfo, err := c.uploadSnapshotStream(ctx, snapshotKey, io.TeeReader(br, hash), keyID)
+	if err == nil {
+		err = c.verifySnapshot(ctx, snapshotKey, hex.EncodeToString(hash.Sum(nil)))
+		if err != nil {
+			if derr := c.DeleteSnapshot(ctx, snapshotName); derr != nil {
+				logrus.Warnf("Failed to remove unverified snapshot s3://%s/%s: %v", c.etcdS3.Bucket, snapshotKey, derr)
+			}
+		}
+	}
+	if err != nil {
+		sf.Status = snapshot.FailedStatus
+		sf.Message = base64.StdEncoding.EncodeToString([]byte(err.Error()))
+		return sf, err
+	}
+
+	sf.Status = snapshot.SuccessfulStatus
+	sf.Size = uploadInfo.Size
+	sf.TokenHash = c.controller.tokenHash
+
+	if uploadInfo, err := c.uploadSnapshotMetadataData(ctx, metadataKey, extraMetadata); err != nil {
+		logrus.Warnf("Failed to upload snapshot metadata to S3: %v", err)
+	} else if uploadInfo.Size != 0 {
+		logrus.Infof("Uploaded snapshot metadata s3://%s/%s", c.etcdS3.Bucket, metadataKey)
+	}
+	return sf, nil
+}
+
+// uploadSnapshotStream uploads the snapshot content to S3 using the minio API.
+// As the length of the content is not known in advance, the minio client will
+// always use a multipart upload, buffering at most one part in memory at a time.
+func (c *Client) uploadSnapshotStream(ctx context.Context, key string, r io.Reader, keyID string) (info minio.UploadInfo, err error) {
+	opts := minio.PutObjectOptions{
+		PartSize: streamPartSize,
+		UserMetadata: map[string]string{
+			clusterIDKey: c.controller.clusterID,
+			nodeNameKey:  c.controller.nodeName,
+			tokenHashKey: c.controller.tokenHash,
+		},
+	}
+	if keyID != "" {
+		opts.UserMetadata[keyIDKey] = keyID
+	}
+	if strings.HasSuffix(key, snapshot.CompressedExtension) {
+		opts.ContentType = "application/zip"
+	} else {
+		opts.ContentType = "application/octet-stream"
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+	return c.mc.PutObject(ctx, c.etcdS3.Bucket, key, r, -1, opts)
+}
+
+// uploadSnapshotMetadataData marshals and uploads the snapshot metadata to S3 using the minio API.
+// The upload is silently skipped if no extra metadata is provided.
+func (c *Client) uploadSnapshotMetadataData(ctx context.Context, key string, extraMetadata *v1.ConfigMap) (info minio.UploadInfo, err error) {
+	if extraMetadata == nil || len(extraMetadata.Data) == 0 {
+		return minio.UploadInfo{}, nil
+	}
+
+	m, err := json.Marshal(extraMetadata.Data)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	opts := minio.PutObjectOptions{
+		NumThreads:  2,
+		ContentType: "application/json",
+		UserMetadata: map[string]string{
+			clusterIDKey: c.controller.clusterID,
+			nodeNameKey:  c.controller.nodeName,
+			tokenHashKey: c.controller.tokenHash,
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+	return c.mc.PutObject(ctx, c.etcdS3.Bucket, key, bytes.NewReader(m), int64(len(m)), opts)
+}
+
+// verifySnapshot reads back the snapshot object from S3, and compares the
+// sha256 digest of its content against the provided hex-encoded digest.
+func (c *Client) verifySnapshot(ctx context.Context, key, digest string) error {
+	logrus.Debugf("Verifying snapshot digest for s3://%s/%s", c.etcdS3.Bucket, key)
+	ctx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
+	defer cancel()
+
+	obj, err := c.mc.GetObject(ctx, c.etcdS3.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, obj); err != nil {
+		return pkgerrors.WithMessage(err, "failed to read uploaded snapshot")
+	}
+	if uploaded := hex.EncodeToString(hash.Sum(nil)); uploaded != digest {
+		return fmt.Errorf("digest mismatch for uploaded snapshot: expected sha256:%s, got sha256:%s", digest, uploaded)
+	}
+	return nil
+}
+
 // Download downloads the given snapshot from the configured S3
 // compatible backend. If the file is successfully downloaded, it returns
 // the path the file was downloaded to.
@@ -385,16 +539,16 @@ func (c *Client) downloadSnapshotMetadata(ctx context.Context, key, file string)
 }
 
 // SnapshotRetention prunes snapshots in the configured S3 compatible backend for this specific node.
-// Returns a list of pruned snapshot names.
-func (c *Client) SnapshotRetention(ctx context.Context, retention int, prefix string) ([]string, error) {
-	if retention < 1 {
+// Returns the retention decision for each snapshot.
+func (c *Client) SnapshotRetention(ctx context.Context, policy snapshot.RetentionPolicy, prefix string, dryRun bool) ([]snapshot.RetentionDecision, error) {
+	if !policy.Enabled() {
 		return nil, nil
 	}
 
 	prefix = path.Join(c.etcdS3.Folder, prefix)
-	logrus.Infof("Applying snapshot retention=%d to snapshots stored in s3://%s/%s", retention, c.etcdS3.Bucket, prefix)
+	logrus.Infof("Applying snapshot %s to snapshots stored in s3://%s/%s", policy, c.etcdS3.Bucket, prefix)
 
-	var snapshotFiles []minio.ObjectInfo
+	var snapshotFiles []snapshot.File
 
 	toCtx, cancel := context.WithTimeout(ctx, c.etcdS3.Timeout.Duration)
 	defer cancel()
@@ -413,30 +567,16 @@ func (c *Client) SnapshotRetention(ctx context.Context, retention int, prefix st
 			continue
 		}
 
-		snapshotFiles = append(snapshotFiles, info)
-	}
-
-	if len(snapshotFiles) <= retention {
-		return nil, nil
+		snapshotFiles = append(snapshotFiles, snapshot.File{Name: path.Base(info.Key), CreatedAt: &metav1.Time{Time: info.LastModified}})
 	}
 
-	// sort newest-first so we can prune entries past the retention count
-	sort.Slice(snapshotFiles, func(i, j int) bool {
-		return snapshotFiles[j].LastModified.Before(snapshotFiles[i].LastModified)
-	})
-
-	deleted := []string{}
-	for _, df := range snapshotFiles[retention:] {
-		logrus.Infof("Removing S3 snapshot: s3://%s/%s", c.etcdS3.Bucket, df.Key)
-
-		key := path.Base(df.Key)
+	return snapshot.ApplyRetention(snapshotFiles, policy, dryRun, func(key string) error {
+		logrus.Infof("Removing S3 snapshot: s3://%s/%s", c.etcdS3.Bucket, path.Join(c.etcdS3.Folder, key))
 		if err := c.DeleteSnapshot(ctx, key); err != nil && !snapshot.IsNotExist(err) {
-			return deleted, err
+			return err
 		}
-		deleted = append(deleted, key)
-	}
-
-	return deleted, nil
+		return nil
+	})
 }
 
 // DeleteSnapshot deletes the selected snapshot (and its metadata) from S3
@@ -514,16 +654,17 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 		sf := snapshot.File{
 			Name:     filename,
 			Location: fmt.Sprintf("s3://%s/%s", c.etcdS3.Bucket, obj.Key),
-			NodeName: "s3",
+			NodeName: snapshot.StorageS3,
 			CreatedAt: &metav1.Time{
 				Time: time.Unix(ts, 0),
 			},
-			Size:       obj.Size,
-			S3:         &snapshot.S3Config{EtcdS3: *c.etcdS3},
-			Status:     snapshot.SuccessfulStatus,
-			Compressed: compressed,
-			NodeSource: obj.UserMetadata[nodeNameKey],
-			TokenHash:  obj.UserMetadata[tokenHashKey],
+			Size:            obj.Size,
+			S3:              &snapshot.S3Config{EtcdS3: *c.etcdS3},
+			Status:          snapshot.SuccessfulStatus,
+			Compressed:      compressed,
+			NodeSource:      obj.UserMetadata[nodeNameKey],
+			TokenHash:       obj.UserMetadata[tokenHashKey],
+			EncryptionKeyID: obj.UserMetadata[keyIDKey],
 		}
 		sfKey := sf.GenerateConfigMapKey()
 		snapshots[sfKey] = sf
@@ -531,7 +672,7 @@ func (c *Client) ListSnapshots(ctx context.Context) (map[string]snapshot.File, e
 
 	for _, metadataKey := range metadatas {
 		filename := path.Base(metadataKey)
-		dsf := &snapshot.File{Name: filename, NodeName: "s3"}
+		dsf := &snapshot.File{Name: filename, NodeName: snapshot.StorageS3}
 		sfKey := dsf.GenerateConfigMapKey()
 		if sf, ok := snapshots[sfKey]; ok {
 			logrus.Debugf("Loading snapshot metadata from s3://%s/%s", c.etcdS3.Bucket, metadataKey)
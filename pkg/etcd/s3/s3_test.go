@@ -793,6 +793,164 @@ func Test_UnitClientUpload(t *testing.T) {
 	}
 }
 
+func Test_UnitClientUploadStream(t *testing.T) {
+	logrus.SetLevel(logrus.DebugLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Dummy server with http listener as a simple S3 mock
+	server := &http.Server{Handler: s3Router(t)}
+
+	listener, _ := net.Listen("tcp", ":0")
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listenerAddr := net.JoinHostPort("localhost", port)
+
+	go server.Serve(listener)
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	controller, err := Start(ctx, &config.Control{ClusterReset: true})
+	if err != nil {
+		t.Errorf("Start() for Client.UploadStream() failed = %v", err)
+		return
+	}
+
+	type fields struct {
+		controller *Controller
+		etcdS3     *config.EtcdS3
+	}
+	type args struct {
+		ctx           context.Context
+		snapshotName  string
+		content       string
+		extraMetadata *v1.ConfigMap
+		now           time.Time
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Successful Upload",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "testbucket",
+					Endpoint:  listenerAddr,
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:           ctx,
+				snapshotName:  "snapshot-01",
+				content:       "test snapshot file\n",
+				extraMetadata: &v1.ConfigMap{Data: map[string]string{"foo": "bar"}},
+				now:           time.Now(),
+			},
+		},
+		{
+			name: "Successful Upload with Prefix",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "testbucket",
+					Endpoint:  listenerAddr,
+					Folder:    "testfolder",
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:          ctx,
+				snapshotName: "snapshot-01",
+				content:      "test snapshot file\n",
+				now:          time.Now(),
+			},
+		},
+		{
+			name: "Fails Upload with Digest Mismatch",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "testbucket",
+					Endpoint:  listenerAddr,
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:          ctx,
+				snapshotName: "snapshot-01",
+				content:      "corrupted snapshot file\n",
+				now:          time.Now(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fails Upload to Unauthorized Bucket",
+			fields: fields{
+				controller: controller,
+				etcdS3: &config.EtcdS3{
+					AccessKey: "test",
+					Bucket:    "authbucket",
+					Endpoint:  listenerAddr,
+					Insecure:  true,
+					Region:    defaultEtcdS3.Region,
+					Timeout:   *defaultEtcdS3.Timeout.DeepCopy(),
+				},
+			},
+			args: args{
+				ctx:          ctx,
+				snapshotName: "snapshot-01",
+				content:      "test snapshot file\n",
+				now:          time.Now(),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.fields.controller.GetClient(tt.args.ctx, tt.fields.etcdS3)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("GetClient for Client.UploadStream() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			got, err := c.UploadStream(tt.args.ctx, tt.args.snapshotName, strings.NewReader(tt.args.content), tt.args.extraMetadata, tt.args.now)
+			t.Logf("Got File=%#v err=%v", got, err)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.UploadStream() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				t.Errorf("Client.UploadStream() returned nil File")
+				return
+			}
+			if wantStatus := snapshot.SuccessfulStatus; !tt.wantErr && got.Status != wantStatus {
+				t.Errorf("Client.UploadStream() Status = %v, want %v", got.Status, wantStatus)
+			}
+			if wantStatus := snapshot.FailedStatus; tt.wantErr && got.Status != wantStatus {
+				t.Errorf("Client.UploadStream() Status = %v, want %v", got.Status, wantStatus)
+			}
+		})
+	}
+}
+
 func Test_UnitClientDownload(t *testing.T) {
 	logrus.SetLevel(logrus.DebugLevel)
 
@@ -1431,7 +1589,8 @@ func Test_UnitClientSnapshotRetention(t *testing.T) {
 				}
 				return
 			}
-			got, err := c.SnapshotRetention(tt.args.ctx, tt.args.retention, tt.args.prefix)
+			decisions, err := c.SnapshotRetention(tt.args.ctx, snapshot.RetentionPolicy{Count: tt.args.retention}, tt.args.prefix, false)
+			got := snapshot.Pruned(decisions)
 			t.Logf("Got snapshots=%#v err=%v", got, err)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.SnapshotRetention() error = %v, wantErr %v", err, tt.wantErr)
@@ -1574,6 +1733,23 @@ func s3Router(t *testing.T) http.Handler {
 			rw.Write([]byte("test snapshot file\n"))
 		}
 	})
+	// CreateMultipartUpload/CompleteMultipartUpload - snapshot
+	router.Path("/{bucket}/{prefix:.*}snapshot-{snapshot}").Methods(http.MethodPost).HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		switch vars["bucket"] {
+		case "badbucket":
+			rw.WriteHeader(http.StatusNotFound)
+		case "authbucket":
+			rw.WriteHeader(http.StatusForbidden)
+		default:
+			key := strings.TrimPrefix(r.URL.Path, "/"+vars["bucket"]+"/")
+			if r.URL.Query().Has("uploads") {
+				fmt.Fprintf(rw, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>0000</UploadId></InitiateMultipartUploadResult>", vars["bucket"], key)
+			} else {
+				fmt.Fprintf(rw, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>\"0000\"</ETag></CompleteMultipartUploadResult>", vars["bucket"], key)
+			}
+		}
+	})
 	// PutObject/DeleteObject - snapshot
 	router.Path("/{bucket}/{prefix:.*}snapshot-{snapshot}").Methods(http.MethodPut, http.MethodDelete).HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -0,0 +1,173 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/filestore"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultPort = "22"
+
+// New returns a Store that keeps snapshots in a folder on an SFTP server.
+// A new SSH connection is made to the server for each operation.
+func New(etcdSFTP *config.EtcdSFTP, nodeName, tokenHash string) (*filestore.Store, error) {
+	if etcdSFTP == nil || etcdSFTP.Endpoint == "" {
+		return nil, errors.New("sftp endpoint was not set")
+	}
+	if etcdSFTP.Username == "" {
+		return nil, errors.New("sftp username was not set")
+	}
+
+	address := etcdSFTP.Endpoint
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	clientConfig, err := clientConfig(etcdSFTP)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := etcdSFTP.Folder
+	if dir == "" {
+		dir = "."
+	}
+	location := "sftp://" + address + "/" + path.Clean(etcdSFTP.Folder)
+	if etcdSFTP.Folder == "" {
+		location = "sftp://" + address
+	}
+
+	dial := func(ctx context.Context) (filestore.FS, error) {
+		return dial(ctx, address, clientConfig)
+	}
+
+	logrus.Infof("Using SFTP snapshot storage at %s", location)
+	return filestore.New(snapshot.StorageSFTP, dir, location, nodeName, tokenHash, dial), nil
+}
+
+// clientConfig returns the SSH client configuration for the given SFTP configuration.
+// Password and public key authentication are both offered if configured. The server
+// host key is checked against the known hosts file, unless host key checking is disabled.
+func clientConfig(etcdSFTP *config.EtcdSFTP) (*ssh.ClientConfig, error) {
+	clientConfig := &ssh.ClientConfig{
+		User:    etcdSFTP.Username,
+		Timeout: etcdSFTP.Timeout.Duration,
+	}
+
+	if etcdSFTP.PrivateKey != "" {
+		keyBytes, err := os.ReadFile(etcdSFTP.PrivateKey)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to read sftp private key")
+		}
+		signer, err := ssh.ParsePrivateKey(keyBytes)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to parse sftp private key")
+		}
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeys(signer))
+	}
+	if etcdSFTP.Password != "" {
+		clientConfig.Auth = append(clientConfig.Auth, ssh.Password(etcdSFTP.Password))
+	}
+	if len(clientConfig.Auth) == 0 {
+		return nil, errors.New("sftp password or private key must be set")
+	}
+
+	switch {
+	case etcdSFTP.SkipHostKeyCheck:
+		logrus.Warn("SFTP snapshot storage host key checking is disabled")
+		clientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	case etcdSFTP.KnownHosts != "":
+		callback, err := knownhosts.New(etcdSFTP.KnownHosts)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to load sftp known hosts")
+		}
+		clientConfig.HostKeyCallback = callback
+	default:
+		return nil, errors.New("sftp known hosts file must be set unless host key checking is disabled")
+	}
+
+	return clientConfig, nil
+}
+
+// dial connects to the SFTP server, returning a file system that
+// closes the underlying SSH connection when closed.
+func dial(ctx context.Context, address string, clientConfig *ssh.ClientConfig) (filestore.FS, error) {
+	dialer := &net.Dialer{Timeout: clientConfig.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, pkgerrors.WithMessagef(err, "failed to connect to sftp server %s", address)
+	}
+
+	// Close the connection if the context is cancelled during the SSH handshake.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, clientConfig)
+	if !stop() {
+		err = errors.Join(err, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, pkgerrors.WithMessagef(err, "failed to establish ssh connection to %s", address)
+	}
+	sshClient := ssh.NewClient(c, chans, reqs)
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, pkgerrors.WithMessage(err, "failed to start sftp session")
+	}
+	return &sftpFS{ssh: sshClient, sftp: sftpClient}, nil
+}
+
+// sftpFS implements filestore.FS using an SFTP client.
+type sftpFS struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (f *sftpFS) Create(name string) (io.WriteCloser, error) {
+	return f.sftp.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+
+func (f *sftpFS) Open(name string) (io.ReadCloser, error) {
+	return f.sftp.Open(name)
+}
+
+func (f *sftpFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return f.sftp.ReadDir(name)
+}
+
+func (f *sftpFS) MkdirAll(name string) error {
+	return f.sftp.MkdirAll(name)
+}
+
+// Rename uses the posix-rename extension if supported by the server, as the
+// standard SFTP rename operation fails if the target file already exists.
+func (f *sftpFS) Rename(oldname, newname string) error {
+	if _, ok := f.sftp.HasExtension("posix-rename@openssh.com"); ok {
+		return f.sftp.PosixRename(oldname, newname)
+	}
+	if err := f.sftp.Remove(newname); err != nil && !snapshot.IsNotExist(err) {
+		return err
+	}
+	return f.sftp.Rename(oldname, newname)
+}
+
+func (f *sftpFS) Remove(name string) error {
+	return f.sftp.Remove(name)
+}
+
+func (f *sftpFS) Close() error {
+	return errors.Join(f.sftp.Close(), f.ssh.Close())
+}
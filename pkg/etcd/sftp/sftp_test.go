@@ -0,0 +1,300 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testUsername = "k3s"
+	testPassword = "password"
+)
+
+// startServer starts an in-process SFTP server serving files from a new temporary directory, accepting
+// password authentication with the test credentials. Returns the server address, the root directory
+// of the server, and the path of a known hosts file containing the server host key.
+func startServer(t *testing.T) (string, string, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testUsername && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	root := t.TempDir()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, serverConfig, root)
+		}
+	}()
+
+	address := listener.Addr().String()
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, signer.PublicKey())
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return address, root, knownHostsFile
+}
+
+// serveConn handles a single SSH connection, serving the sftp subsystem on session channels.
+func serveConn(conn net.Conn, serverConfig *ssh.ServerConfig, root string) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					channel.Close()
+					return
+				}
+				server.Serve()
+				server.Close()
+			}
+		}()
+	}
+}
+
+func Test_UnitNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		etcdSFTP *config.EtcdSFTP
+		wantErr  bool
+	}{
+		{
+			name:    "Nil config",
+			wantErr: true,
+		},
+		{
+			name:     "No username",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: "127.0.0.1", Password: testPassword, SkipHostKeyCheck: true},
+			wantErr:  true,
+		},
+		{
+			name:     "No credentials",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: "127.0.0.1", Username: testUsername, SkipHostKeyCheck: true},
+			wantErr:  true,
+		},
+		{
+			name:     "No known hosts",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: "127.0.0.1", Username: testUsername, Password: testPassword},
+			wantErr:  true,
+		},
+		{
+			name:     "Missing private key",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: "127.0.0.1", Username: testUsername, PrivateKey: filepath.Join(t.TempDir(), "id_ed25519"), SkipHostKeyCheck: true},
+			wantErr:  true,
+		},
+		{
+			name:     "Password with host key check disabled",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: "127.0.0.1", Username: testUsername, Password: testPassword, SkipHostKeyCheck: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.etcdSFTP, "node1", "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitStoreConnect(t *testing.T) {
+	ctx := context.Background()
+	address, _, knownHostsFile := startServer(t)
+
+	tests := []struct {
+		name     string
+		etcdSFTP *config.EtcdSFTP
+		wantErr  bool
+	}{
+		{
+			name:     "Known host",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: address, Username: testUsername, Password: testPassword, KnownHosts: knownHostsFile},
+		},
+		{
+			name:     "Unknown host",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: address, Username: testUsername, Password: testPassword, KnownHosts: filepath.Join(filepath.Dir(knownHostsFile), "empty")},
+			wantErr:  true,
+		},
+		{
+			name:     "Wrong password",
+			etcdSFTP: &config.EtcdSFTP{Endpoint: address, Username: testUsername, Password: "wrong", SkipHostKeyCheck: true},
+			wantErr:  true,
+		},
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(knownHostsFile), "empty"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.etcdSFTP.Timeout.Duration = 10 * time.Second
+			s, err := New(tt.etcdSFTP, "node1", "")
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if _, err := s.ListSnapshots(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Store.ListSnapshots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitStoreUploadListRetentionDelete(t *testing.T) {
+	ctx := context.Background()
+	address, root, _ := startServer(t)
+
+	s, err := New(&config.EtcdSFTP{
+		Endpoint:         address,
+		Username:         testUsername,
+		Password:         testPassword,
+		Folder:           "snapshots",
+		SkipHostKeyCheck: true,
+		Timeout:          metav1.Duration{Duration: 10 * time.Second},
+	}, "node1", "token-hash")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The snapshot metadata directory is a sibling of the local snapshot directory
+	snapshotDir := filepath.Join(t.TempDir(), "snapshots")
+	metadataDir := filepath.Join(filepath.Dir(snapshotDir), snapshot.MetadataDir)
+	for _, dir := range []string{snapshotDir, metadataDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timestamps := []int64{1700000000, 1700000100, 1700000200}
+	for _, ts := range timestamps {
+		name := fmt.Sprintf("etcd-snapshot-node1-%d", ts)
+		if err := os.WriteFile(filepath.Join(snapshotDir, name), []byte("snapshot "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(metadataDir, name), []byte("metadata "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		sf, err := s.Upload(ctx, filepath.Join(snapshotDir, name), nil, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatalf("Store.Upload() error = %v", err)
+		}
+		if sf.Status != snapshot.SuccessfulStatus || sf.Location != "sftp://"+address+"/snapshots/"+name {
+			t.Errorf("Store.Upload() unexpected snapshot = %+v", sf)
+		}
+		if b, err := os.ReadFile(filepath.Join(root, "snapshots", name)); err != nil || string(b) != "snapshot "+name {
+			t.Errorf("Store.Upload() unexpected content on server = %q, error = %v", b, err)
+		}
+	}
+
+	sfs, err := s.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("Store.ListSnapshots() error = %v", err)
+	}
+	if len(sfs) != len(timestamps) {
+		t.Errorf("Store.ListSnapshots() returned %d snapshots, want %d", len(sfs), len(timestamps))
+	}
+	for key, sf := range sfs {
+		if sf.NodeName != snapshot.StorageSFTP || key != snapshot.StorageSFTP+"-"+sf.Name {
+			t.Errorf("Store.ListSnapshots() unexpected snapshot %s = %+v", key, sf)
+		}
+		if sf.NodeSource != "node1" || sf.TokenHash != "token-hash" {
+			t.Errorf("Store.ListSnapshots() snapshot %s NodeSource = %q, TokenHash = %q", key, sf.NodeSource, sf.TokenHash)
+		}
+		if sf.Metadata == "" {
+			t.Errorf("Store.ListSnapshots() snapshot %s has no metadata", key)
+		}
+	}
+
+	decisions, err := s.SnapshotRetention(ctx, snapshot.RetentionPolicy{Count: 1}, "etcd-snapshot", false)
+	if err != nil {
+		t.Fatalf("Store.SnapshotRetention() error = %v", err)
+	}
+	deleted := snapshot.Pruned(decisions)
+	slices.Sort(deleted)
+	if want := []string{"etcd-snapshot-node1-1700000000", "etcd-snapshot-node1-1700000100"}; !slices.Equal(deleted, want) {
+		t.Errorf("Store.SnapshotRetention() deleted = %v, want %v", deleted, want)
+	}
+	for _, name := range deleted {
+		if _, err := os.Stat(filepath.Join(root, "snapshots", snapshot.MetadataDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Store.SnapshotRetention() did not remove metadata for %s, error = %v", name, err)
+		}
+	}
+
+	downloadDir := filepath.Join(t.TempDir(), "snapshots")
+	if err := os.MkdirAll(downloadDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path, err := s.Download(ctx, "etcd-snapshot-node1-1700000200", downloadDir)
+	if err != nil {
+		t.Fatalf("Store.Download() error = %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "snapshot etcd-snapshot-node1-1700000200" {
+		t.Errorf("Store.Download() unexpected content = %q, error = %v", b, err)
+	}
+
+	if err := s.DeleteSnapshot(ctx, "etcd-snapshot-node1-1700000200"); err != nil {
+		t.Errorf("Store.DeleteSnapshot() error = %v", err)
+	}
+	if err := s.DeleteSnapshot(ctx, "etcd-snapshot-node1-1700000200"); !snapshot.IsNotExist(err) {
+		t.Errorf("Store.DeleteSnapshot() of missing snapshot error = %v, want not exist", err)
+	}
+	if sfs, err := s.ListSnapshots(ctx); err != nil || len(sfs) != 0 {
+		t.Errorf("Store.ListSnapshots() after delete returned %d snapshots, error = %v", len(sfs), err)
+	}
+}
@@ -3,6 +3,7 @@ package etcd
 import (
 	"archive/zip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -13,7 +14,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -50,7 +50,6 @@ const (
 
 var (
 	annotationLocalReconciled = "etcd." + version.Program + ".cattle.io/local-snapshots-timestamp"
-	annotationS3Reconciled    = "etcd." + version.Program + ".cattle.io/s3-snapshots-timestamp"
 
 	// snapshotDataBackoff will retry at increasing steps for up to ~30 seconds.
 	// If the ConfigMap update fails, the list won't be reconciled again until next time
@@ -152,11 +151,127 @@ func (e *ETCD) compressSnapshot(snapshotDir, snapshotName, snapshotPath string,
 	return zipPath, err
 }
 
+// encryptSnapshot encrypts the given snapshot file in place. The encrypted
+// content is written to a temporary file that replaces the original.
+func encryptSnapshot(snapshotPath string, key *snapshot.EncryptionKey) error {
+	logrus.Info("Encrypting etcd snapshot file: " + filepath.Base(snapshotPath))
+
+	in, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	partPath := snapshotPath + ".part"
+	out, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partPath)
+	defer out.Close()
+
+	ew, err := snapshot.NewEncryptWriter(out, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, in); err != nil {
+		return err
+	}
+	if err := ew.Close(); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partPath, snapshotPath)
+}
+
+// decryptSnapshot decrypts the given snapshot and provides the caller with the
+// full path to the decrypted snapshot, which is written to the snapshot directory.
+// The caller is responsible for removing the decrypted file when it is no longer needed.
+func (e *ETCD) decryptSnapshot(snapshotDir, snapshotFile string) (string, error) {
+	logrus.Info("Decrypting etcd snapshot file: " + snapshotFile)
+
+	keys, err := snapshotDecryptionKeys(e.config)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(snapshotFile)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	dr, err := snapshot.NewDecryptReader(in, keys...)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp(snapshotDir, filepath.Base(snapshotFile)+".decrypted-*")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, dr); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// snapshotEncryptionKey returns the key used to encrypt new snapshots. The key is derived from
+// the content of the snapshot encryption key file if set, or from the server token if not.
+func snapshotEncryptionKey(config *config.Control) (*snapshot.EncryptionKey, error) {
+	if config.EtcdSnapshotKeyFile != "" {
+		return snapshot.NewEncryptionKeyFromFile(config.EtcdSnapshotKeyFile)
+	}
+	token, err := util.GetNormalizedToken(config)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.NewEncryptionKey([]byte(token))
+}
+
+// snapshotDecryptionKeys returns all keys that may be used to decrypt existing snapshots: the
+// key derived from the snapshot encryption key file if set, and the key derived from the server token.
+func snapshotDecryptionKeys(config *config.Control) ([]*snapshot.EncryptionKey, error) {
+	keys := []*snapshot.EncryptionKey{}
+	if config.EtcdSnapshotKeyFile != "" {
+		key, err := snapshot.NewEncryptionKeyFromFile(config.EtcdSnapshotKeyFile)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to read etcd snapshot encryption key file")
+		}
+		keys = append(keys, key)
+	}
+	if token, err := util.GetNormalizedToken(config); err != nil {
+		logrus.Warnf("Unable to derive etcd snapshot encryption key from server token: %v", err)
+	} else if key, err := snapshot.NewEncryptionKey([]byte(token)); err == nil {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
 // decompressSnapshot decompresses the given snapshot and provides the caller
 // with the full path to the uncompressed snapshot.
 func (e *ETCD) decompressSnapshot(snapshotDir, snapshotFile string) (string, error) {
 	logrus.Info("Decompressing etcd snapshot file: " + snapshotFile)
 
+	if keyID, err := snapshot.ReadFileEncryptionKeyID(snapshotFile); err != nil {
+		return "", err
+	} else if keyID != "" {
+		decryptedPath, err := e.decryptSnapshot(snapshotDir, snapshotFile)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(decryptedPath)
+		snapshotFile = decryptedPath
+	}
+
 	r, err := zip.OpenReader(snapshotFile)
 	if err != nil {
 		return "", err
@@ -236,6 +351,27 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 		return nil, nil
 	}
 
+	nodeName := os.Getenv("NODE_NAME")
+	now := time.Now().Round(time.Second)
+	snapshotName := fmt.Sprintf("%s-%s-%d", e.config.EtcdSnapshotName, nodeName, now.Unix())
+
+	var encryptionKey *snapshot.EncryptionKey
+	if e.config.EtcdSnapshotEncrypt {
+		encryptionKey, err = snapshotEncryptionKey(e.config)
+		if err != nil {
+			return nil, pkgerrors.WithMessage(err, "failed to get etcd snapshot encryption key")
+		}
+	}
+
+	// Streamed snapshots are uploaded directly to S3 without a local copy, so they cannot
+	// also be saved to any other remote storage backends.
+	if e.config.EtcdS3 != nil && e.config.EtcdS3Stream {
+		if e.config.EtcdRemoteDir != nil || e.config.EtcdSFTP != nil {
+			return nil, errors.New("etcd-s3-stream cannot be used with etcd-remote-dir or etcd-sftp; disable etcd-s3-stream to save snapshots to multiple remote storage backends")
+		}
+		return e.streamSnapshot(ctx, snapshotName, now, encryptionKey, extraMetadata)
+	}
+
 	snapshotDir, err := snapshotDir(e.config, true)
 	if err != nil {
 		return nil, pkgerrors.WithMessage(err, "failed to get etcd-snapshot-dir")
@@ -251,9 +387,6 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 		return nil, pkgerrors.WithMessage(err, "failed to get server token hash for etcd snapshot")
 	}
 
-	nodeName := os.Getenv("NODE_NAME")
-	now := time.Now().Round(time.Second)
-	snapshotName := fmt.Sprintf("%s-%s-%d", e.config.EtcdSnapshotName, nodeName, now.Unix())
 	snapshotPath := filepath.Join(snapshotDir, snapshotName)
 	logrus.Infof("Saving etcd snapshot to %s", snapshotPath)
 
@@ -285,6 +418,13 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 	res := &managed.SnapshotResult{}
 	// If the snapshot attempt was successful, sf will be nil as we did not set it to store the error message.
 	if sf == nil {
+		// Record the digest of the snapshot prior to compression and encryption, so that
+		// the snapshot can be verified later. Failing to compute the digest is not fatal.
+		digest, err := snapshot.Digest(snapshotPath)
+		if err != nil {
+			logrus.Warnf("Failed to compute etcd snapshot digest: %v", err)
+		}
+
 		if e.config.EtcdSnapshotCompress {
 			zipPath, err := e.compressSnapshot(snapshotDir, snapshotName, snapshotPath, now)
 
@@ -300,6 +440,17 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 			logrus.Info("Compressed snapshot: " + snapshotPath)
 		}
 
+		if encryptionKey != nil {
+			if err := encryptSnapshot(snapshotPath, encryptionKey); err != nil {
+				// ensure that the unencrypted snapshot is not left on disk if encryption fails
+				if err := os.Remove(snapshotPath); err != nil && !os.IsNotExist(err) {
+					logrus.Warnf("Failed to remove unencrypted snapshot file: %v", err)
+				}
+				return nil, pkgerrors.WithMessage(err, "failed to encrypt snapshot")
+			}
+			logrus.Info("Encrypted snapshot: " + snapshotPath)
+		}
+
 		f, err := os.Stat(snapshotPath)
 		if err != nil {
 			return nil, pkgerrors.WithMessage(err, "unable to retrieve snapshot information from local snapshot")
@@ -317,6 +468,10 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 			Compressed:     e.config.EtcdSnapshotCompress,
 			MetadataSource: extraMetadata,
 			TokenHash:      tokenHash,
+			Digest:         digest,
+		}
+		if encryptionKey != nil {
+			sf.EncryptionKeyID = encryptionKey.ID
 		}
 		res.Created = append(res.Created, sf.Name)
 
@@ -332,55 +487,56 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 		}
 
 		// Snapshot retention may prune some files before returning an error. Failing to prune is not fatal.
-		deleted, err := snapshotRetention(e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName, snapshotDir)
+		decisions, err := e.localSnapshotRetention(snapshotDir, false)
 		if err != nil {
 			logrus.Warnf("Failed to apply local snapshot retention policy: %v", err)
 		}
-		res.Deleted = append(res.Deleted, deleted...)
+		res.Deleted = append(res.Deleted, snapshot.Pruned(decisions)...)
 
-		if e.config.EtcdS3 != nil {
-			s3Start := time.Now()
-			if s3client, err := e.getS3Client(ctx); err != nil {
-				logrus.Warnf("Unable to initialize S3 client: %v", err)
+		for _, b := range e.storageBackends() {
+			storageStart := time.Now()
+			if client, err := b.client(ctx); err != nil {
+				logrus.Warnf("Unable to initialize %s client: %v", b.title, err)
 				if !errors.Is(err, s3.ErrNoConfigSecret) {
-					metrics.ObserveWithStatus(snapshotSaveS3Count, s3Start, err)
-					err = pkgerrors.WithMessage(err, "failed to initialize S3 client")
+					observeStorageSave(b.name, storageStart, err)
+					err = pkgerrors.WithMessagef(err, "failed to initialize %s client", b.title)
 					sf = &snapshot.File{
 						Name:     f.Name(),
-						NodeName: "s3",
+						NodeName: b.name,
 						CreatedAt: &metav1.Time{
 							Time: now,
 						},
 						Message:        base64.StdEncoding.EncodeToString([]byte(err.Error())),
 						Size:           0,
 						Status:         snapshot.FailedStatus,
-						S3:             &snapshot.S3Config{EtcdS3: *e.config.EtcdS3},
+						S3:             b.s3,
 						MetadataSource: extraMetadata,
 					}
 				}
 			} else {
-				logrus.Infof("Saving etcd snapshot %s to S3", snapshotName)
+				logrus.Infof("Saving etcd snapshot %s to %s", snapshotName, b.title)
 				// upload will return a snapshot.File even on error - if there was an
 				// error, it will be reflected in the status and message.
-				sf, err = s3client.Upload(ctx, snapshotPath, extraMetadata, now)
-				metrics.ObserveWithStatus(snapshotSaveS3Count, s3Start, err)
+				sf, err = client.Upload(ctx, snapshotPath, extraMetadata, now)
+				observeStorageSave(b.name, storageStart, err)
 				if err != nil {
-					logrus.Errorf("Error received during snapshot upload to S3: %s", err)
+					logrus.Errorf("Error received during snapshot upload to %s: %s", b.title, err)
 				} else {
+					sf.Digest = digest
 					res.Created = append(res.Created, sf.Name)
-					logrus.Infof("S3 upload complete for %s", snapshotName)
+					logrus.Infof("%s upload complete for %s", b.title, snapshotName)
 				}
-				// Attempt to apply retention even if the upload failed; failure may be due to bucket
+				// Attempt to apply retention even if the upload failed; failure may be due to storage
 				// being full or some other condition that retention policy would resolve.
 				// Snapshot retention may prune some files before returning an error. Failing to prune is not fatal.
-				deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName)
-				res.Deleted = append(res.Deleted, deleted...)
+				decisions, err := e.storageSnapshotRetention(ctx, b, client, false)
+				res.Deleted = append(res.Deleted, snapshot.Pruned(decisions)...)
 				if err != nil {
-					logrus.Warnf("Failed to apply s3 snapshot retention policy: %v", err)
+					logrus.Warnf("Failed to apply %s snapshot retention policy: %v", b.title, err)
 				}
 			}
-			// sf is either s3 snapshot metadata, or s3 init/upload failure record.
-			// If this fails, just log an error - the snapshot file will remain on s3
+			// sf is either remote snapshot metadata, or remote init/upload failure record.
+			// If this fails, just log an error - the snapshot file will remain in remote storage
 			// and will be recorded next time the snapshot list is reconciled.
 			if err := e.addSnapshotData(*sf); err != nil {
 				logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
@@ -391,6 +547,151 @@ func (e *ETCD) snapshot(ctx context.Context) (_ *managed.SnapshotResult, rerr er
 	return res, nil
 }
 
+// streamSnapshot saves a new snapshot directly to S3, without writing a copy of the snapshot to
+// the local snapshot directory. If compression or encryption are enabled, the snapshot is compressed
+// and encrypted inline as it is uploaded. S3 snapshot retention is applied after the upload completes.
+func (e *ETCD) streamSnapshot(ctx context.Context, snapshotName string, now time.Time, encryptionKey *snapshot.EncryptionKey, extraMetadata *v1.ConfigMap) (_ *managed.SnapshotResult, rerr error) {
+	s3Start := time.Now()
+	defer func() {
+		metrics.ObserveWithStatus(snapshotSaveS3Count, s3Start, rerr)
+	}()
+
+	fileName := snapshotName
+	if e.config.EtcdSnapshotCompress {
+		fileName += snapshot.CompressedExtension
+	}
+
+	s3client, err := e.getS3Client(ctx)
+	if err != nil {
+		logrus.Warnf("Unable to initialize S3 client: %v", err)
+		err = pkgerrors.WithMessage(err, "failed to initialize S3 client")
+		if !errors.Is(err, s3.ErrNoConfigSecret) {
+			sf := &snapshot.File{
+				Name:     fileName,
+				NodeName: "s3",
+				CreatedAt: &metav1.Time{
+					Time: now,
+				},
+				Message:        base64.StdEncoding.EncodeToString([]byte(err.Error())),
+				Status:         snapshot.FailedStatus,
+				S3:             &snapshot.S3Config{EtcdS3: *e.config.EtcdS3},
+				MetadataSource: extraMetadata,
+			}
+			if err := e.addSnapshotData(*sf); err != nil {
+				logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+			}
+		}
+		return nil, err
+	}
+
+	logrus.Infof("Streaming etcd snapshot %s to S3", fileName)
+	rd, err := e.client.Snapshot(ctx)
+	if err != nil {
+		return nil, pkgerrors.WithMessage(err, "failed to open etcd snapshot stream")
+	}
+
+	// The snapshot is copied from etcd into the pipe by a separate goroutine, while the
+	// upload reads from the other end. Any error encountered while reading from etcd is
+	// passed through the pipe, causing the upload to fail. The digest of the snapshot is
+	// calculated as it is read, prior to compression and encryption.
+	digest := sha256.New()
+	done := make(chan struct{})
+	pr, pw := io.Pipe()
+	go func() {
+		defer close(done)
+		defer rd.Close()
+		pw.CloseWithError(writeSnapshot(pw, io.TeeReader(rd, digest), snapshotName, e.config.EtcdSnapshotCompress, encryptionKey, now))
+	}()
+
+	// upload will return a snapshot.File even on error - if there was an
+	// error, it will be reflected in the status and message.
+	sf, err := s3client.UploadStream(ctx, fileName, pr, extraMetadata, now)
+	pr.CloseWithError(err)
+
+	res := &managed.SnapshotResult{}
+	if err != nil {
+		logrus.Errorf("Error received during snapshot stream to S3: %s", err)
+	} else {
+		<-done
+		sf.Digest = snapshot.FormatDigest(digest.Sum(nil))
+		res.Created = append(res.Created, sf.Name)
+		logrus.Infof("S3 upload complete for %s", fileName)
+	}
+
+	// If this fails, just log an error - the snapshot file will remain on s3
+	// and will be recorded next time the snapshot list is reconciled.
+	if err := e.addSnapshotData(*sf); err != nil {
+		logrus.Warnf("Failed to sync ETCDSnapshotFile: %v", err)
+	}
+
+	// Attempt to apply retention even if the upload failed; failure may be due to bucket
+	// being full or some other condition that retention policy would resolve.
+	b := storageBackend{name: snapshot.StorageS3, retention: e.config.EtcdS3.Retention}
+	decisions, perr := e.storageSnapshotRetention(ctx, b, s3client, false)
+	res.Deleted = append(res.Deleted, snapshot.Pruned(decisions)...)
+	if perr != nil {
+		logrus.Warnf("Failed to apply s3 snapshot retention policy: %v", perr)
+	}
+
+	return res, err
+}
+
+// writeSnapshot copies the snapshot from the etcd snapshot stream to the provided writer,
+// optionally compressing it as a zip archive containing a single file, and encrypting it with
+// the provided key. An error is returned if the stream does not end with the sha256 digest
+// appended by etcd, as this indicates that the snapshot was truncated.
+func writeSnapshot(w io.Writer, r io.Reader, snapshotName string, compress bool, key *snapshot.EncryptionKey, now time.Time) error {
+	if key != nil {
+		ew, err := snapshot.NewEncryptWriter(w, key)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshot(ew, r, snapshotName, compress, nil, now); err != nil {
+			return err
+		}
+		return ew.Close()
+	}
+
+	if !compress {
+		n, err := io.Copy(w, r)
+		if err != nil {
+			return err
+		}
+		return checkSnapshotSize(n)
+	}
+
+	zipWriter := zip.NewWriter(w)
+	header := &zip.FileHeader{
+		Name:     snapshotName,
+		Method:   zip.Deflate,
+		Modified: now,
+	}
+	header.SetMode(0600)
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(writer, r)
+	if err != nil {
+		return err
+	}
+	if err := checkSnapshotSize(n); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// checkSnapshotSize returns an error if a snapshot of the given size
+// does not have a sha256 digest appended to it.
+func checkSnapshotSize(n int64) error {
+	// etcd pads the snapshot to a multiple of 512 bytes before appending the digest.
+	if n%512 != sha256.Size {
+		return fmt.Errorf("sha256 checksum not found [bytes: %d]", n)
+	}
+	return nil
+}
+
 // listLocalSnapshots provides a list of the currently stored
 // snapshots on disk along with their relevant
 // metadata.
@@ -422,6 +723,11 @@ func (e *ETCD) listLocalSnapshots() (map[string]snapshot.File, error) {
 			metadata = base64.StdEncoding.EncodeToString(m)
 		}
 
+		keyID, err := snapshot.ReadFileEncryptionKeyID(path)
+		if err != nil {
+			logrus.Warnf("Failed to read encryption header from snapshot %s: %v", path, err)
+		}
+
 		sf := snapshot.File{
 			Name:     file.Name(),
 			Location: "file://" + filepath.Join(snapshotDir, file.Name()),
@@ -430,9 +736,10 @@ func (e *ETCD) listLocalSnapshots() (map[string]snapshot.File, error) {
 			CreatedAt: &metav1.Time{
 				Time: time.Unix(ts, 0),
 			},
-			Size:       file.Size(),
-			Status:     snapshot.SuccessfulStatus,
-			Compressed: compressed,
+			Size:            file.Size(),
+			Status:          snapshot.SuccessfulStatus,
+			Compressed:      compressed,
+			EncryptionKeyID: keyID,
 		}
 		sfKey := sf.GenerateConfigMapKey()
 		snapshots[sfKey] = sf
@@ -461,39 +768,45 @@ func (e *ETCD) getS3Client(ctx context.Context) (*s3.Client, error) {
 	return e.s3.GetClient(ctx, e.config.EtcdS3)
 }
 
-// PruneSnapshots deleted old snapshots in excess of the configured retention count.
-// Returns a list of deleted snapshots. Note that snapshots may be deleted
-// with a non-nil error return.
-func (e *ETCD) PruneSnapshots(ctx context.Context) (*managed.SnapshotResult, error) {
+// PruneSnapshots deletes old snapshots that are not retained by the configured retention policy.
+// Returns a list of deleted snapshots, along with the retention decision for each snapshot.
+// If dryRun is set, the retention decisions are returned but no snapshots are deleted.
+// Note that snapshots may be deleted with a non-nil error return.
+func (e *ETCD) PruneSnapshots(ctx context.Context, dryRun bool) (*PruneResult, error) {
 	snapshotDir, err := snapshotDir(e.config, false)
 	if err != nil {
 		return nil, pkgerrors.WithMessage(err, "failed to get etcd-snapshot-dir")
 	}
 
-	res := &managed.SnapshotResult{}
-	// Note that snapshotRetention functions may return a list of deleted files, as well as
-	// an error, if some snapshots are deleted before the error is encountered.
-	res.Deleted, err = snapshotRetention(e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName, snapshotDir)
+	res := &PruneResult{}
+	// Note that snapshotRetention functions may return a list of decisions for deleted files, as
+	// well as an error, if some snapshots are deleted before the error is encountered.
+	res.Retention, err = e.localSnapshotRetention(snapshotDir, dryRun)
 	if err != nil {
 		logrus.Errorf("Error applying snapshot retention policy: %v", err)
 	}
 
-	if e.config.EtcdS3 != nil {
-		if s3client, err := e.getS3Client(ctx); err != nil {
-			logrus.Warnf("Unable to initialize S3 client: %v", err)
+	for _, b := range e.storageBackends() {
+		if client, err := b.client(ctx); err != nil {
+			logrus.Warnf("Unable to initialize %s client: %v", b.title, err)
 		} else {
-			deleted, err := s3client.SnapshotRetention(ctx, e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotName)
+			decisions, err := e.storageSnapshotRetention(ctx, b, client, dryRun)
 			if err != nil {
-				logrus.Errorf("Error applying S3 snapshot retention policy: %v", err)
+				logrus.Errorf("Error applying %s snapshot retention policy: %v", b.title, err)
 			}
-			res.Deleted = append(res.Deleted, deleted...)
+			res.Retention = append(res.Retention, decisions...)
 		}
 	}
-	return res, e.reconcileSnapshotData(ctx, res)
+
+	if dryRun {
+		return res, nil
+	}
+	res.Deleted = snapshot.Pruned(res.Retention)
+	return res, e.reconcileSnapshotData(ctx, &res.SnapshotResult)
 }
 
 // ListSnapshots returns a list of snapshots. Local snapshots are always listed,
-// s3 snapshots are listed if s3 is enabled.
+// remote snapshots are listed if a remote storage backend is enabled.
 // Snapshots are listed locally, not listed from the apiserver, so results
 // are guaranteed to be in sync with what is on disk.
 func (e *ETCD) ListSnapshots(ctx context.Context) (*k3s.ETCDSnapshotFileList, error) {
@@ -501,14 +814,14 @@ func (e *ETCD) ListSnapshots(ctx context.Context) (*k3s.ETCDSnapshotFileList, er
 		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"},
 	}
 
-	if e.config.EtcdS3 != nil {
-		if s3client, err := e.getS3Client(ctx); err != nil {
-			logrus.Warnf("Unable to initialize S3 client: %v", err)
+	for _, b := range e.storageBackends() {
+		if client, err := b.client(ctx); err != nil {
+			logrus.Warnf("Unable to initialize %s client: %v", b.title, err)
 			if !errors.Is(err, s3.ErrNoConfigSecret) {
-				return nil, pkgerrors.WithMessage(err, "failed to initialize S3 client")
+				return nil, pkgerrors.WithMessagef(err, "failed to initialize %s client", b.title)
 			}
 		} else {
-			sfs, err := s3client.ListSnapshots(ctx)
+			sfs, err := client.ListSnapshots(ctx)
 			if err != nil {
 				return nil, err
 			}
@@ -533,7 +846,7 @@ func (e *ETCD) ListSnapshots(ctx context.Context) (*k3s.ETCDSnapshotFileList, er
 	return snapshotFiles, nil
 }
 
-// DeleteSnapshots removes the given snapshots from local storage and S3.
+// DeleteSnapshots removes the given snapshots from local and remote storage.
 // Returns a list of deleted snapshots. Note that snapshots may be deleted
 // with a non-nil error return.
 func (e *ETCD) DeleteSnapshots(ctx context.Context, snapshots []string) (*managed.SnapshotResult, error) {
@@ -542,13 +855,14 @@ func (e *ETCD) DeleteSnapshots(ctx context.Context, snapshots []string) (*manage
 		return nil, pkgerrors.WithMessage(err, "failed to get etcd-snapshot-dir")
 	}
 
-	var s3client *s3.Client
-	if e.config.EtcdS3 != nil {
-		s3client, err = e.getS3Client(ctx)
+	backends := e.storageBackends()
+	clients := make([]snapshot.Storage, len(backends))
+	for i, b := range backends {
+		clients[i], err = b.client(ctx)
 		if err != nil {
-			logrus.Warnf("Unable to initialize S3 client: %v", err)
+			logrus.Warnf("Unable to initialize %s client: %v", b.title, err)
 			if !errors.Is(err, s3.ErrNoConfigSecret) {
-				return nil, pkgerrors.WithMessage(err, "failed to initialize S3 client")
+				return nil, pkgerrors.WithMessagef(err, "failed to initialize %s client", b.title)
 			}
 		}
 	}
@@ -566,16 +880,19 @@ func (e *ETCD) DeleteSnapshots(ctx context.Context, snapshots []string) (*manage
 			logrus.Infof("Snapshot %s deleted locally", s)
 		}
 
-		if s3client != nil {
-			if err := s3client.DeleteSnapshot(ctx, s); err != nil {
+		for i, client := range clients {
+			if client == nil {
+				continue
+			}
+			if err := client.DeleteSnapshot(ctx, s); err != nil {
 				if snapshot.IsNotExist(err) {
-					logrus.Infof("Snapshot %s not found in S3", s)
+					logrus.Infof("Snapshot %s not found in %s", s, backends[i].title)
 				} else {
-					logrus.Errorf("Failed to delete S3 snapshot %s: %v", s, err)
+					logrus.Errorf("Failed to delete %s snapshot %s: %v", backends[i].title, s, err)
 				}
 			} else {
 				res.Deleted = append(res.Deleted, s)
-				logrus.Infof("Snapshot %s deleted from S3", s)
+				logrus.Infof("Snapshot %s deleted from %s", s, backends[i].title)
 			}
 		}
 	}
@@ -652,6 +969,9 @@ func generateETCDSnapshotFileConfigMapKey(esf k3s.ETCDSnapshotFile) string {
 	if esf.Spec.S3 != nil {
 		return "s3-" + name
 	}
+	if storage := esf.Labels[snapshot.LabelStorageNode]; snapshot.IsRemoteStorage(storage) {
+		return storage + "-" + name
+	}
 	return "local-" + name
 }
 
@@ -672,15 +992,15 @@ func (e *ETCD) emitEvent(esf *k3s.ETCDSnapshotFile) {
 }
 
 // ReconcileSnapshotData reconciles snapshot data in the ETCDSnapshotFile resources.
-// It will reconcile snapshot data from disk locally always, and if S3 is enabled, will attempt to
-// list S3 snapshots and reconcile snapshots from S3.
+// It will reconcile snapshot data from disk locally always, and if remote storage is enabled, will attempt to
+// list remote snapshots and reconcile snapshots from remote storage.
 func (e *ETCD) ReconcileSnapshotData(ctx context.Context) error {
 	return e.reconcileSnapshotData(ctx, nil)
 }
 
 // reconcileSnapshotData reconciles snapshot data in the ETCDSnapshotFile resources.
-// It will reconcile snapshot data from disk locally always, and if S3 is enabled, will attempt to
-// list S3 snapshots and reconcile snapshots from S3. Any snapshots listed in the Deleted field of
+// It will reconcile snapshot data from disk locally always, and if remote storage is enabled, will attempt to
+// list remote snapshots and reconcile snapshots from remote storage. Any snapshots listed in the Deleted field of
 // the provided SnapshotResult are deleted, even if they are within a retention window.
 func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotResult) (rerr error) {
 	reconcileStart := time.Now()
@@ -705,30 +1025,30 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 
 	nodeNames := []string{os.Getenv("NODE_NAME")}
 
-	// Get snapshots from S3
-	if e.config.EtcdS3 != nil {
-		s3Start := time.Now()
-		if s3client, err := e.getS3Client(ctx); err != nil {
-			logrus.Warnf("Unable to initialize S3 client: %v", err)
+	// Get snapshots from remote storage
+	for _, b := range e.storageBackends() {
+		storageStart := time.Now()
+		if client, err := b.client(ctx); err != nil {
+			logrus.Warnf("Unable to initialize %s client: %v", b.title, err)
 			if !errors.Is(err, s3.ErrNoConfigSecret) {
-				metrics.ObserveWithStatus(snapshotReconcileS3Count, s3Start, err)
-				return pkgerrors.WithMessage(err, "failed to initialize S3 client")
+				observeStorageReconcile(b.name, storageStart, err)
+				return pkgerrors.WithMessagef(err, "failed to initialize %s client", b.title)
 			}
 		} else {
-			s3Snapshots, err := s3client.ListSnapshots(ctx)
-			metrics.ObserveWithStatus(snapshotReconcileS3Count, s3Start, err)
+			remoteSnapshots, err := client.ListSnapshots(ctx)
+			observeStorageReconcile(b.name, storageStart, err)
 			if err != nil {
-				logrus.Errorf("Error retrieving S3 snapshots for reconciliation: %v", err)
+				logrus.Errorf("Error retrieving %s snapshots for reconciliation: %v", b.title, err)
 			} else {
-				for k, v := range s3Snapshots {
+				for k, v := range remoteSnapshots {
 					snapshotFiles[k] = v
 				}
-				nodeNames = append(nodeNames, "s3")
+				nodeNames = append(nodeNames, b.name)
 			}
 		}
 	}
 
-	// Try to load metadata from the legacy configmap, in case any local or s3 snapshots
+	// Try to load metadata from the legacy configmap, in case any local or remote snapshots
 	// were created by an old release that does not write the metadata alongside the snapshot file.
 	snapshotConfigMap, err := e.config.Runtime.Core.Core().V1().ConfigMap().Get(metav1.NamespaceSystem, snapshotConfigMapName, metav1.GetOptions{})
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -794,10 +1114,10 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 					// it's an error that hasn't expired yet, leave it
 					return nil
 				}
-			} else if esf.Spec.S3 != nil {
+			} else if esf.Spec.S3 != nil || snapshot.IsRemoteStorage(esf.Labels[snapshot.LabelStorageNode]) {
 				expires := esf.ObjectMeta.CreationTimestamp.Add(s3ReconcileTTL)
 				if now.Before(expires) {
-					// it's an s3 snapshot that's only just been created, leave it to prevent a race condition
+					// it's a remote snapshot that's only just been created, leave it to prevent a race condition
 					// when multiple nodes are uploading snapshots at the same time.
 					return nil
 				}
@@ -832,11 +1152,11 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 		return nil
 	}
 
-	// List all snapshots in Kubernetes not stored on S3 or a current etcd node.
+	// List all snapshots in Kubernetes not stored on remote storage or a current etcd node.
 	// These snapshots are local to a node that no longer runs etcd and cannot be restored.
 	// If the node rejoins later and has local snapshots, it will reconcile them itself.
 	labelSelector.MatchExpressions[0].Operator = metav1.LabelSelectorOpNotIn
-	labelSelector.MatchExpressions[0].Values = []string{"s3"}
+	labelSelector.MatchExpressions[0].Values = slices.Clone(snapshot.RemoteStorageNodes)
 
 	// Get a list of all etcd nodes currently in the cluster and add them to the selector
 	nodes := e.config.Runtime.Core.Core().V1().Node()
@@ -878,11 +1198,11 @@ func (e *ETCD) reconcileSnapshotData(ctx context.Context, res *managed.SnapshotR
 			"path":  "/metadata/annotations/" + strings.ReplaceAll(annotationLocalReconciled, "/", "~1"),
 		},
 	}
-	if e.config.EtcdS3 != nil {
+	for _, b := range e.storageBackends() {
 		patch = append(patch, map[string]string{
 			"op":    "add",
 			"value": now.Format(time.RFC3339),
-			"path":  "/metadata/annotations/" + strings.ReplaceAll(annotationS3Reconciled, "/", "~1"),
+			"path":  "/metadata/annotations/" + strings.ReplaceAll(annotationStorageReconciled(b.name), "/", "~1"),
 		})
 	}
 	b, err := json.Marshal(patch)
@@ -901,20 +1221,26 @@ func (e *ETCD) setSnapshotFunction(ctx context.Context) {
 		// having all the nodes take a snapshot at the exact same time can lead to excessive retry thrashing
 		// when updating the snapshot list configmap.
 		time.Sleep(time.Duration(rand.Float64() * float64(snapshotJitterMax)))
-		if _, err := e.Snapshot(ctx); err != nil {
+		res, err := e.Snapshot(ctx)
+		if err != nil {
 			logrus.Errorf("Failed to take scheduled snapshot: %v", err)
 		}
+		if e.config.EtcdSnapshotVerify && res != nil && len(res.Created) > 0 {
+			if _, err := e.VerifySnapshots(ctx, res.Created); err != nil {
+				logrus.Errorf("Failed to verify scheduled snapshot: %v", err)
+			}
+		}
 	})))
 }
 
-// snapshotRetention iterates through the snapshots and removes the oldest
-// leaving the desired number of snapshots. Returns a list of pruned snapshot names.
-func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string) ([]string, error) {
-	if retention < 1 {
+// snapshotRetention iterates through the snapshots and removes those that are not
+// retained by the retention policy. Returns the retention decision for each snapshot.
+func snapshotRetention(policy snapshot.RetentionPolicy, snapshotPrefix string, snapshotDir string, dryRun bool) ([]snapshot.RetentionDecision, error) {
+	if !policy.Enabled() {
 		return nil, nil
 	}
 
-	logrus.Infof("Applying snapshot retention=%d to local snapshots with prefix %s in %s", retention, snapshotPrefix, snapshotDir)
+	logrus.Infof("Applying snapshot %s to local snapshots with prefix %s in %s", policy, snapshotPrefix, snapshotDir)
 
 	var snapshotFiles []snapshot.File
 	if err := filepath.Walk(snapshotDir, func(path string, info os.FileInfo, err error) error {
@@ -933,30 +1259,19 @@ func snapshotRetention(retention int, snapshotPrefix string, snapshotDir string)
 	}); err != nil {
 		return nil, err
 	}
-	if len(snapshotFiles) <= retention {
-		return nil, nil
-	}
 
-	// sort newest-first so we can prune entries past the retention count
-	sort.Slice(snapshotFiles, func(i, j int) bool {
-		return snapshotFiles[j].CreatedAt.Before(snapshotFiles[i].CreatedAt)
-	})
-
-	deleted := []string{}
-	for _, df := range snapshotFiles[retention:] {
-		snapshotPath := filepath.Join(snapshotDir, df.Name)
-		metadataPath := filepath.Join(snapshotDir, "..", snapshot.MetadataDir, df.Name)
+	return snapshot.ApplyRetention(snapshotFiles, policy, dryRun, func(name string) error {
+		snapshotPath := filepath.Join(snapshotDir, name)
+		metadataPath := filepath.Join(snapshotDir, "..", snapshot.MetadataDir, name)
 		logrus.Infof("Removing local snapshot %s", snapshotPath)
 		if err := os.Remove(snapshotPath); err != nil {
-			return deleted, err
+			return err
 		}
 		if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
-			return deleted, err
+			return err
 		}
-		deleted = append(deleted, df.Name)
-	}
-
-	return deleted, nil
+		return nil
+	})
 }
 
 // saveSnapshotMetadata writes extra metadata to disk.
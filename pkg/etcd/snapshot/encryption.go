@@ -0,0 +1,309 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted snapshots are written as a short header, followed by the snapshot content split into
+// fixed-size segments, each sealed individually with AES-256-GCM. The header contains the ID of the
+// key used to encrypt the snapshot, and a random salt that is used to derive a unique content key
+// for each snapshot. Segment nonces are derived from the segment index and a flag marking the final
+// segment, so that reordered, truncated, or extended snapshots fail to decrypt.
+//
+//	magic | key ID length (1 byte) | key ID | salt (32 bytes) | segment... | final segment
+const (
+	encryptionMagic    = "ETCD-SNAPSHOT-AES-GCM-V1"
+	encryptionSaltSize = 32
+	segmentSize        = 64 * 1024
+	keySize            = 32
+	keyIDSize          = 8
+)
+
+var (
+	ErrNotEncrypted = errors.New("snapshot is not encrypted")
+	ErrNoKey        = errors.New("no matching snapshot encryption key")
+
+	keyInfo        = []byte("etcd snapshot encryption key")
+	contentKeyInfo = []byte("etcd snapshot content key")
+)
+
+// EncryptionKey is a key used to encrypt or decrypt snapshots.
+type EncryptionKey struct {
+	// ID is a non-secret identifier for the key, derived from the key itself.
+	// It is stored alongside encrypted snapshots so that the correct key can
+	// be selected when decrypting.
+	ID  string
+	key []byte
+}
+
+// NewEncryptionKey derives a snapshot encryption key from the given secret.
+func NewEncryptionKey(secret []byte) (*EncryptionKey, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("snapshot encryption secret is empty")
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, string(keyInfo), keySize)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(key)
+	return &EncryptionKey{ID: hex.EncodeToString(digest[:keyIDSize]), key: key}, nil
+}
+
+// NewEncryptionKeyFromFile derives a snapshot encryption key from the content of the
+// given file. Leading and trailing whitespace is ignored.
+func NewEncryptionKeyFromFile(path string) (*EncryptionKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptionKey(bytes.TrimSpace(b))
+}
+
+// NewEncryptWriter returns a WriteCloser that encrypts content with the given key before
+// writing it to w. Close must be called to write the final segment; it does not close w.
+func NewEncryptWriter(w io.Writer, key *EncryptionKey) (io.WriteCloser, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newContentAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(encryptionMagic)+1+len(key.ID)+encryptionSaltSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, byte(len(key.ID)))
+	header = append(header, key.ID...)
+	header = append(header, salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, segmentSize),
+	}, nil
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	segment uint64
+	closed  bool
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := 0
+	for len(p) > 0 {
+		// Only seal a full buffer once more data arrives, so that the
+		// final segment is never empty unless the content is.
+		if len(ew.buf) == segmentSize {
+			if err := ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(ew.buf[len(ew.buf):segmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (ew *encryptWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	return ew.seal(true)
+}
+
+func (ew *encryptWriter) seal(final bool) error {
+	ew.out = ew.aead.Seal(ew.out[:0], segmentNonce(ew.segment, final), ew.buf, nil)
+	ew.buf = ew.buf[:0]
+	ew.segment++
+	_, err := ew.w.Write(ew.out)
+	return err
+}
+
+// NewDecryptReader returns a Reader that decrypts content read from r. The key used to decrypt
+// the content is selected from the provided keys by the key ID stored in the header. ErrNotEncrypted
+// is returned if the content does not have an encryption header, and ErrNoKey is returned if
+// none of the provided keys match.
+func NewDecryptReader(r io.Reader, keys ...*EncryptionKey) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	keyID, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *EncryptionKey
+	for _, k := range keys {
+		if k != nil && k.ID == keyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: snapshot was encrypted with key %s", ErrNoKey, keyID)
+	}
+
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return nil, err
+	}
+	aead, err := newContentAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		r:    br,
+		aead: aead,
+		in:   make([]byte, segmentSize+aead.Overhead()),
+	}, nil
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	in      []byte
+	buf     []byte
+	segment uint64
+	done    bool
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) open() error {
+	n, err := io.ReadFull(dr.r, dr.in)
+	final := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		final = true
+	case err != nil:
+		return err
+	default:
+		// A full segment was read; it is the final segment only if there is nothing left to read.
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	dr.buf, err = dr.aead.Open(dr.in[:0], segmentNonce(dr.segment, final), dr.in[:n], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt snapshot segment %d: %w", dr.segment, err)
+	}
+	dr.segment++
+	dr.done = final
+	return nil
+}
+
+// PeekEncryptionKeyID returns the ID of the key used to encrypt the content buffered by r,
+// without consuming any data from the reader. An empty string is returned if the content
+// is not encrypted.
+func PeekEncryptionKeyID(r *bufio.Reader) (string, error) {
+	keyID, _, err := peekHeader(r)
+	if errors.Is(err, ErrNotEncrypted) {
+		return "", nil
+	}
+	return keyID, err
+}
+
+// ReadFileEncryptionKeyID returns the ID of the key used to encrypt the given
+// file. An empty string is returned if the file is not encrypted.
+func ReadFileEncryptionKeyID(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return PeekEncryptionKeyID(bufio.NewReader(f))
+}
+
+// readHeader reads the encryption magic and key ID from r, returning ErrNotEncrypted
+// if the magic does not match.
+func readHeader(r *bufio.Reader) (string, error) {
+	keyID, n, err := peekHeader(r)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.Discard(n)
+	return keyID, err
+}
+
+// peekHeader returns the key ID and length of the encryption header at the start of r,
+// without consuming it. ErrNotEncrypted is returned if the magic does not match.
+func peekHeader(r *bufio.Reader) (string, int, error) {
+	b, err := r.Peek(len(encryptionMagic) + 1)
+	if err == io.EOF || (err == nil && string(b[:len(encryptionMagic)]) != encryptionMagic) {
+		return "", 0, ErrNotEncrypted
+	} else if err != nil {
+		return "", 0, err
+	}
+
+	n := len(b) + int(b[len(encryptionMagic)])
+	b, err = r.Peek(n)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(b[len(encryptionMagic)+1:]), n, nil
+}
+
+// newContentAEAD derives a per-snapshot content key from the
+// encryption key and salt, and returns an AES-GCM AEAD for it.
+func newContentAEAD(key *EncryptionKey, salt []byte) (cipher.AEAD, error) {
+	contentKey, err := hkdf.Key(sha256.New, key.key, salt, string(contentKeyInfo), keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce returns the nonce for the segment with the given index. The last byte
+// of the nonce is set if the segment is the final segment.
+func segmentNonce(segment uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], segment)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
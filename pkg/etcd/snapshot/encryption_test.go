@@ -0,0 +1,174 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func Test_UnitEncryptionRoundTrip(t *testing.T) {
+	key, err := NewEncryptionKey([]byte("K10deadbeef::server:secret"))
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+	otherKey, err := NewEncryptionKey([]byte("some-other-secret"))
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		size    int
+		keys    []*EncryptionKey
+		wantErr error
+	}{
+		{
+			name: "Empty",
+			size: 0,
+			keys: []*EncryptionKey{key},
+		},
+		{
+			name: "Small",
+			size: 1024,
+			keys: []*EncryptionKey{key},
+		},
+		{
+			name: "Exact segment",
+			size: segmentSize,
+			keys: []*EncryptionKey{key},
+		},
+		{
+			name: "Multiple segments",
+			size: segmentSize*3 + 17,
+			keys: []*EncryptionKey{otherKey, key},
+		},
+		{
+			name:    "Wrong key",
+			size:    1024,
+			keys:    []*EncryptionKey{otherKey},
+			wantErr: ErrNoKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			rand.Read(plaintext)
+
+			encrypted := &bytes.Buffer{}
+			ew, err := NewEncryptWriter(encrypted, key)
+			if err != nil {
+				t.Fatalf("NewEncryptWriter() error = %v", err)
+			}
+			if _, err := ew.Write(plaintext); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := ew.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			keyID, err := PeekEncryptionKeyID(bufio.NewReader(bytes.NewReader(encrypted.Bytes())))
+			if err != nil {
+				t.Fatalf("PeekEncryptionKeyID() error = %v", err)
+			}
+			if keyID != key.ID {
+				t.Errorf("PeekEncryptionKeyID() = %q, want %q", keyID, key.ID)
+			}
+
+			dr, err := NewDecryptReader(encrypted, tt.keys...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDecryptReader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			decrypted, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted content does not match plaintext")
+			}
+		})
+	}
+}
+
+func Test_UnitDecryptTampered(t *testing.T) {
+	key, err := NewEncryptionKey([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	plaintext := make([]byte, segmentSize*2+100)
+	rand.Read(plaintext)
+	encrypted := &bytes.Buffer{}
+	ew, err := NewEncryptWriter(encrypted, key)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter() error = %v", err)
+	}
+	ew.Write(plaintext)
+	ew.Close()
+
+	headerSize := len(encryptionMagic) + 1 + len(key.ID) + encryptionSaltSize
+	sealedSegmentSize := segmentSize + 16
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{
+			name: "Truncated at segment boundary",
+			mutate: func(b []byte) []byte {
+				return b[:headerSize+sealedSegmentSize*2]
+			},
+		},
+		{
+			name: "Truncated mid segment",
+			mutate: func(b []byte) []byte {
+				return b[:len(b)-10]
+			},
+		},
+		{
+			name: "Modified content",
+			mutate: func(b []byte) []byte {
+				b[headerSize+10] ^= 0xff
+				return b
+			},
+		},
+		{
+			name: "Extended",
+			mutate: func(b []byte) []byte {
+				return append(b, b[headerSize:headerSize+sealedSegmentSize]...)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.mutate(bytes.Clone(encrypted.Bytes()))
+			dr, err := NewDecryptReader(bytes.NewReader(b), key)
+			if err != nil {
+				t.Fatalf("NewDecryptReader() error = %v", err)
+			}
+			if _, err := io.ReadAll(dr); err == nil {
+				t.Errorf("ReadAll() expected error for tampered snapshot")
+			}
+		})
+	}
+}
+
+func Test_UnitPeekEncryptionKeyIDUnencrypted(t *testing.T) {
+	for _, content := range []string{"", "ETCD", "not an encrypted snapshot, just some plain content"} {
+		keyID, err := PeekEncryptionKeyID(bufio.NewReader(bytes.NewReader([]byte(content))))
+		if err != nil {
+			t.Errorf("PeekEncryptionKeyID(%q) error = %v", content, err)
+		}
+		if keyID != "" {
+			t.Errorf("PeekEncryptionKeyID(%q) = %q, want empty", content, keyID)
+		}
+	}
+	if _, err := NewDecryptReader(bytes.NewReader([]byte("plain"))); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("NewDecryptReader() error = %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -0,0 +1,201 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy describes which snapshots to retain when pruning. The newest Count snapshots
+// are always retained. In addition, grandfather-father-son style tiers retain the newest snapshot
+// from each of the most recent Hourly hours, Daily days, Weekly weeks, and Monthly months that
+// have snapshots. A snapshot is retained if any part of the policy retains it.
+type RetentionPolicy struct {
+	Count   int `json:"count,omitempty"`
+	Hourly  int `json:"hourly,omitempty"`
+	Daily   int `json:"daily,omitempty"`
+	Weekly  int `json:"weekly,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+}
+
+// RetentionDecision records whether a snapshot is retained by a retention policy, and why.
+// If a snapshot that is not retained could not be deleted, Error is set to the reason.
+type RetentionDecision struct {
+	Name      string    `json:"name"`
+	Storage   string    `json:"storage,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Keep      bool      `json:"keep"`
+	Reasons   []string  `json:"reasons,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// retentionTier is a single tier of a grandfather-father-son retention policy.
+// Snapshots are grouped into periods by the period function.
+type retentionTier struct {
+	name   string
+	count  int
+	period func(t time.Time) string
+}
+
+// ParseRetentionPolicy returns a RetentionPolicy that retains the given number of newest snapshots,
+// along with the tiers from a comma-separated list of tier=count pairs, such as
+// "hourly=24,daily=7,weekly=4,monthly=12". An empty list does not enable any tiers.
+func ParseRetentionPolicy(count int, tiers string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{Count: count}
+	for _, tier := range strings.Split(tiers, ",") {
+		tier = strings.TrimSpace(tier)
+		if tier == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(tier, "=")
+		if !ok {
+			return policy, fmt.Errorf("invalid retention policy tier %q: must be in the form tier=count", tier)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("invalid retention policy tier %q: count must be a non-negative integer", tier)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "hourly":
+			policy.Hourly = n
+		case "daily":
+			policy.Daily = n
+		case "weekly":
+			policy.Weekly = n
+		case "monthly":
+			policy.Monthly = n
+		default:
+			return policy, fmt.Errorf("invalid retention policy tier %q: must be one of hourly, daily, weekly, monthly", tier)
+		}
+	}
+	return policy, nil
+}
+
+// Enabled returns true if the policy retains any snapshots. Pruning
+// is disabled if the policy does not retain any snapshots.
+func (p RetentionPolicy) Enabled() bool {
+	return p.Count > 0 || p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+func (p RetentionPolicy) String() string {
+	s := fmt.Sprintf("retention=%d", p.Count)
+	for _, tier := range p.tiers() {
+		if tier.count > 0 {
+			s += fmt.Sprintf(",%s=%d", tier.name, tier.count)
+		}
+	}
+	return s
+}
+
+func (p RetentionPolicy) tiers() []retentionTier {
+	return []retentionTier{
+		{
+			name:   "hourly",
+			count:  p.Hourly,
+			period: func(t time.Time) string { return t.UTC().Format("2006-01-02T15Z") },
+		},
+		{
+			name:   "daily",
+			count:  p.Daily,
+			period: func(t time.Time) string { return t.UTC().Format("2006-01-02") },
+		},
+		{
+			name:  "weekly",
+			count: p.Weekly,
+			period: func(t time.Time) string {
+				year, week := t.UTC().ISOWeek()
+				return fmt.Sprintf("%d-W%02d", year, week)
+			},
+		},
+		{
+			name:   "monthly",
+			count:  p.Monthly,
+			period: func(t time.Time) string { return t.UTC().Format("2006-01") },
+		},
+	}
+}
+
+// Apply decides which of the given snapshots are retained by the policy. Decisions are
+// returned sorted newest-first. Snapshots without a creation time are treated as the oldest.
+func (p RetentionPolicy) Apply(files []File) []RetentionDecision {
+	decisions := make([]RetentionDecision, 0, len(files))
+	for _, f := range files {
+		d := RetentionDecision{Name: f.Name}
+		if f.CreatedAt != nil {
+			d.CreatedAt = f.CreatedAt.Time
+		}
+		decisions = append(decisions, d)
+	}
+
+	// sort newest-first so that the first snapshot seen in each period is the newest
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[j].CreatedAt.Before(decisions[i].CreatedAt)
+	})
+
+	for i := range decisions {
+		if i >= p.Count {
+			break
+		}
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf("newest %d", p.Count))
+	}
+
+	for _, tier := range p.tiers() {
+		var periods int
+		var last string
+		for i := range decisions {
+			if periods >= tier.count {
+				break
+			}
+			period := tier.period(decisions[i].CreatedAt)
+			if period == last {
+				continue
+			}
+			last = period
+			periods++
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, tier.name+" "+period)
+		}
+	}
+
+	return decisions
+}
+
+// ApplyRetention applies the retention policy to the given snapshots, calling deleteSnapshot for each
+// snapshot that is not retained, unless dryRun is set. Decisions for all snapshots are returned newest-first.
+// If a snapshot cannot be deleted, the error is recorded on its decision and the remaining snapshots are
+// still processed; the returned error includes all failed deletions.
+func ApplyRetention(files []File, policy RetentionPolicy, dryRun bool, deleteSnapshot func(name string) error) ([]RetentionDecision, error) {
+	if !policy.Enabled() {
+		return nil, nil
+	}
+	decisions := policy.Apply(files)
+	if dryRun {
+		return decisions, nil
+	}
+	var errs []error
+	for i, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		if err := deleteSnapshot(d.Name); err != nil {
+			decisions[i].Error = err.Error()
+			errs = append(errs, fmt.Errorf("failed to delete snapshot %s: %w", d.Name, err))
+		}
+	}
+	return decisions, errors.Join(errs...)
+}
+
+// Pruned returns the names of snapshots that were not retained, excluding any that could not be deleted.
+func Pruned(decisions []RetentionDecision) []string {
+	var names []string
+	for _, d := range decisions {
+		if !d.Keep && d.Error == "" {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,202 @@
+package snapshot
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_UnitParseRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		tiers   string
+		want    RetentionPolicy
+		wantErr bool
+	}{
+		{
+			name:  "Count only",
+			count: 5,
+			want:  RetentionPolicy{Count: 5},
+		},
+		{
+			name:  "All tiers",
+			count: 1,
+			tiers: "hourly=24, daily=7,weekly=4,Monthly=12",
+			want:  RetentionPolicy{Count: 1, Hourly: 24, Daily: 7, Weekly: 4, Monthly: 12},
+		},
+		{
+			name:  "Trailing comma",
+			tiers: "daily=7,",
+			want:  RetentionPolicy{Daily: 7},
+		},
+		{
+			name:    "Unknown tier",
+			tiers:   "yearly=1",
+			wantErr: true,
+		},
+		{
+			name:    "Missing count",
+			tiers:   "daily",
+			wantErr: true,
+		},
+		{
+			name:    "Negative count",
+			tiers:   "daily=-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetentionPolicy(tt.count, tt.tiers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetentionPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseRetentionPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitRetentionPolicyApply(t *testing.T) {
+	// one snapshot every 6 hours for 60 days, starting at midnight on a Monday
+	start := time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC)
+	files := []File{}
+	for i := 0; i < 60*4; i++ {
+		ts := start.Add(time.Duration(i) * 6 * time.Hour)
+		files = append(files, File{Name: fmt.Sprintf("etcd-snapshot-node1-%d", ts.Unix()), CreatedAt: &metav1.Time{Time: ts}})
+	}
+	newest := start.Add(time.Duration(60*4-1) * 6 * time.Hour)
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []time.Time
+	}{
+		{
+			name:   "Count only",
+			policy: RetentionPolicy{Count: 3},
+			want:   []time.Time{newest, newest.Add(-6 * time.Hour), newest.Add(-12 * time.Hour)},
+		},
+		{
+			name:   "Daily",
+			policy: RetentionPolicy{Daily: 3},
+			want:   []time.Time{newest, newest.Add(-24 * time.Hour), newest.Add(-48 * time.Hour)},
+		},
+		{
+			name:   "Count and daily overlap",
+			policy: RetentionPolicy{Count: 2, Daily: 2},
+			want:   []time.Time{newest, newest.Add(-6 * time.Hour), newest.Add(-24 * time.Hour)},
+		},
+		{
+			name:   "Weekly",
+			policy: RetentionPolicy{Weekly: 2},
+			// newest is the last snapshot on Tuesday March 6th; the previous week ends on Sunday March 2nd
+			want: []time.Time{newest, time.Date(2025, time.March, 2, 18, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "Monthly",
+			policy: RetentionPolicy{Monthly: 12},
+			want: []time.Time{
+				newest,
+				time.Date(2025, time.February, 28, 18, 0, 0, 0, time.UTC),
+				time.Date(2025, time.January, 31, 18, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := tt.policy.Apply(files)
+			if len(decisions) != len(files) {
+				t.Fatalf("RetentionPolicy.Apply() returned %d decisions, want %d", len(decisions), len(files))
+			}
+			got := []time.Time{}
+			for _, d := range decisions {
+				if d.Keep {
+					got = append(got, d.CreatedAt)
+					if len(d.Reasons) == 0 {
+						t.Errorf("RetentionPolicy.Apply() retained %s without a reason", d.Name)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RetentionPolicy.Apply() retained = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitApplyRetention(t *testing.T) {
+	start := time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC)
+	files := []File{}
+	for i := 0; i < 5; i++ {
+		ts := start.Add(time.Duration(i) * time.Hour)
+		files = append(files, File{Name: fmt.Sprintf("etcd-snapshot-node1-%d", i), CreatedAt: &metav1.Time{Time: ts}})
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		fail        map[string]bool
+		wantDeleted []string
+		wantPruned  []string
+		wantErrors  []string
+		wantErr     bool
+	}{
+		{
+			name:        "All deleted",
+			wantDeleted: []string{"etcd-snapshot-node1-2", "etcd-snapshot-node1-1", "etcd-snapshot-node1-0"},
+			wantPruned:  []string{"etcd-snapshot-node1-2", "etcd-snapshot-node1-1", "etcd-snapshot-node1-0"},
+		},
+		{
+			name:       "Dry run",
+			dryRun:     true,
+			wantPruned: []string{"etcd-snapshot-node1-2", "etcd-snapshot-node1-1", "etcd-snapshot-node1-0"},
+		},
+		{
+			name:        "Delete failure does not stop pruning",
+			fail:        map[string]bool{"etcd-snapshot-node1-1": true},
+			wantDeleted: []string{"etcd-snapshot-node1-2", "etcd-snapshot-node1-1", "etcd-snapshot-node1-0"},
+			wantPruned:  []string{"etcd-snapshot-node1-2", "etcd-snapshot-node1-0"},
+			wantErrors:  []string{"etcd-snapshot-node1-1"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			decisions, err := ApplyRetention(files, RetentionPolicy{Count: 2}, tt.dryRun, func(name string) error {
+				deleted = append(deleted, name)
+				if tt.fail[name] {
+					return fmt.Errorf("permission denied")
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(decisions) != len(files) {
+				t.Fatalf("ApplyRetention() returned %d decisions, want %d", len(decisions), len(files))
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("ApplyRetention() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if pruned := Pruned(decisions); !reflect.DeepEqual(pruned, tt.wantPruned) {
+				t.Errorf("Pruned() = %v, want %v", pruned, tt.wantPruned)
+			}
+			var gotErrors []string
+			for _, d := range decisions {
+				if d.Error != "" {
+					gotErrors = append(gotErrors, d.Name)
+				}
+			}
+			if !reflect.DeepEqual(gotErrors, tt.wantErrors) {
+				t.Errorf("ApplyRetention() decisions with errors = %v, want %v", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
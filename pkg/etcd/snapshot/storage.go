@@ -0,0 +1,46 @@
+package snapshot
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Remote storage node names. Snapshots held by a remote storage backend use the
+// storage node name as their node name, instead of the name of the node that took
+// the snapshot.
+const (
+	StorageS3        = "s3"
+	StorageRemoteDir = "remote-dir"
+	StorageSFTP      = "sftp"
+)
+
+// RemoteStorageNodes lists the node names used by all remote storage backends.
+var RemoteStorageNodes = []string{StorageS3, StorageRemoteDir, StorageSFTP}
+
+// IsRemoteStorage returns true if the node name is that of a remote storage backend.
+func IsRemoteStorage(nodeName string) bool {
+	return slices.Contains(RemoteStorageNodes, nodeName)
+}
+
+// Storage is implemented by remote snapshot storage backends.
+type Storage interface {
+	// Upload copies the local snapshot file, and any metadata saved alongside it,
+	// to remote storage. A File is returned even on error; if there was an error,
+	// it will be reflected in the status and message.
+	Upload(ctx context.Context, snapshotPath string, extraMetadata *v1.ConfigMap, now time.Time) (*File, error)
+	// Download copies the named snapshot from remote storage into the local
+	// snapshot directory, returning the path of the downloaded file.
+	Download(ctx context.Context, snapshotName, snapshotDir string) (string, error)
+	// ListSnapshots lists the snapshots held in remote storage, keyed by configmap key.
+	ListSnapshots(ctx context.Context) (map[string]File, error)
+	// SnapshotRetention prunes snapshots with the given name prefix that are not retained
+	// by the retention policy. If dryRun is set, snapshots are not actually pruned.
+	// Returns the retention decision for each snapshot, as returned by ApplyRetention.
+	SnapshotRetention(ctx context.Context, policy RetentionPolicy, prefix string, dryRun bool) ([]RetentionDecision, error)
+	// DeleteSnapshot deletes the named snapshot and its metadata from remote storage.
+	// An error satisfying IsNotExist is returned if the snapshot does not exist.
+	DeleteSnapshot(ctx context.Context, snapshotName string) error
+}
@@ -38,6 +38,10 @@ var (
 
 	LabelStorageNode    = "etcd." + version.Program + ".cattle.io/snapshot-storage-node"
 	AnnotationTokenHash = "etcd." + version.Program + ".cattle.io/snapshot-token-hash"
+	AnnotationKeyID     = "etcd." + version.Program + ".cattle.io/snapshot-encryption-key-id"
+	AnnotationDigest    = "etcd." + version.Program + ".cattle.io/snapshot-digest"
+	// AnnotationVerification holds the JSON-encoded Verified condition from the last verification of the snapshot.
+	AnnotationVerification = "etcd." + version.Program + ".cattle.io/snapshot-verification"
 
 	ExtraMetadataConfigMapName = version.Program + "-etcd-snapshot-extra-metadata"
 )
@@ -68,6 +72,12 @@ type File struct {
 	Status     SnapshotStatus `json:"status,omitempty"`
 	S3         *S3Config      `json:"s3Config,omitempty"`
 	Compressed bool           `json:"compressed"`
+	// EncryptionKeyID contains the ID of the key used to encrypt the snapshot.
+	// Unencrypted snapshots do not have a key ID.
+	EncryptionKeyID string `json:"encryptionKeyID,omitempty"`
+	// Digest contains the sha256 digest of the snapshot database, recorded when the
+	// snapshot was saved. The digest is of the snapshot prior to compression and encryption.
+	Digest string `json:"digest,omitempty"`
 
 	// these fields are used for the internal representation of the snapshot
 	// to populate other fields before serialization to the legacy configmap.
@@ -80,8 +90,8 @@ type File struct {
 // as a configmap key.
 func (sf *File) GenerateConfigMapKey() string {
 	name := InvalidKeyChars.ReplaceAllString(sf.Name, "_")
-	if sf.NodeName == "s3" {
-		return "s3-" + name
+	if IsRemoteStorage(sf.NodeName) {
+		return sf.NodeName + "-" + name
 	}
 	return "local-" + name
 }
@@ -108,8 +118,8 @@ func (sf *File) GenerateName() string {
 			name += CompressedExtension
 		}
 	}
-	if sf.NodeName == "s3" {
-		return "s3-" + name + "-" + hex.EncodeToString(digest[0:])[0:6]
+	if IsRemoteStorage(sf.NodeName) {
+		return sf.NodeName + "-" + name + "-" + hex.EncodeToString(digest[0:])[0:6]
 	}
 	return "local-" + name + "-" + hex.EncodeToString(digest[0:])[0:6]
 }
@@ -159,10 +169,20 @@ func (sf *File) FromETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 		sf.TokenHash = tokenHash
 	}
 
-	if esf.Spec.S3 == nil {
+	if keyID := esf.Annotations[AnnotationKeyID]; keyID != "" {
+		sf.EncryptionKeyID = keyID
+	}
+
+	if digest := esf.Annotations[AnnotationDigest]; digest != "" {
+		sf.Digest = digest
+	}
+
+	if storage := esf.Labels[LabelStorageNode]; esf.Spec.S3 == nil && IsRemoteStorage(storage) {
+		sf.NodeName = storage
+	} else if esf.Spec.S3 == nil {
 		sf.NodeName = esf.Spec.NodeName
 	} else {
-		sf.NodeName = "s3"
+		sf.NodeName = StorageS3
 		sf.S3 = &S3Config{
 			EtcdS3: config.EtcdS3{
 				Endpoint:      esf.Spec.S3.Endpoint,
@@ -235,10 +255,20 @@ func (sf *File) ToETCDSnapshotFile(esf *k3s.ETCDSnapshotFile) {
 		esf.ObjectMeta.Annotations[AnnotationTokenHash] = sf.TokenHash
 	}
 
-	if sf.S3 == nil {
+	if sf.EncryptionKeyID != "" {
+		esf.ObjectMeta.Annotations[AnnotationKeyID] = sf.EncryptionKeyID
+	}
+
+	if sf.Digest != "" {
+		esf.ObjectMeta.Annotations[AnnotationDigest] = sf.Digest
+	}
+
+	if sf.S3 == nil && IsRemoteStorage(sf.NodeName) {
+		esf.ObjectMeta.Labels[LabelStorageNode] = sf.NodeName
+	} else if sf.S3 == nil {
 		esf.ObjectMeta.Labels[LabelStorageNode] = esf.Spec.NodeName
 	} else {
-		esf.ObjectMeta.Labels[LabelStorageNode] = "s3"
+		esf.ObjectMeta.Labels[LabelStorageNode] = StorageS3
 		esf.Spec.S3 = &k3s.ETCDSnapshotS3{
 			Endpoint:      sf.S3.Endpoint,
 			EndpointCA:    sf.S3.EndpointCA,
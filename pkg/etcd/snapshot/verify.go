@@ -0,0 +1,122 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DigestPrefix is the algorithm prefix of snapshot digests.
+	DigestPrefix = "sha256:"
+
+	// ConditionVerified is the type of the condition recording the result of snapshot verification.
+	ConditionVerified = "Verified"
+
+	ReasonVerified = "Verified"
+	// ReasonVerifiedWithoutDigest is used when the snapshot is readable and its etcd checksum is valid, but no
+	// digest was recorded when it was saved, so the snapshot could not be compared against the original.
+	ReasonVerifiedWithoutDigest = "VerifiedWithoutDigest"
+	ReasonNotFound              = "NotFound"
+	ReasonUnreadable            = "Unreadable"
+	ReasonChecksumFailed        = "ChecksumFailed"
+	ReasonDigestMismatch        = "DigestMismatch"
+	ReasonStatusCheckFailed     = "StatusCheckFailed"
+	ReasonStorageUnavailable    = "StorageUnavailable"
+)
+
+// Verification is the result of verifying a single copy of a snapshot.
+type Verification struct {
+	Name    string `json:"name"`
+	Storage string `json:"storage"`
+	// Verified is true if the snapshot was successfully verified.
+	Verified bool `json:"verified"`
+	// Reason is a machine-readable reason for the verification result.
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	// Digest is the digest of the snapshot database, if it could be read.
+	Digest string `json:"digest,omitempty"`
+	// Revision, TotalKey, and TotalSize are reported by the etcd snapshot status API.
+	Revision  int64       `json:"revision,omitempty"`
+	TotalKey  int         `json:"totalKey,omitempty"`
+	TotalSize int64       `json:"totalSize,omitempty"`
+	Time      metav1.Time `json:"time"`
+}
+
+// Condition returns the verification result as a condition, suitable for recording on the ETCDSnapshotFile.
+func (v *Verification) Condition() metav1.Condition {
+	condition := metav1.Condition{
+		Type:               ConditionVerified,
+		Status:             metav1.ConditionFalse,
+		Reason:             v.Reason,
+		Message:            v.Message,
+		LastTransitionTime: v.Time,
+	}
+	if v.Verified {
+		condition.Status = metav1.ConditionTrue
+		condition.Message = fmt.Sprintf("Snapshot verified at revision %d with %d keys", v.Revision, v.TotalKey)
+		if v.Message != "" {
+			condition.Message += "; " + v.Message
+		}
+	}
+	return condition
+}
+
+// MarshalCondition returns the JSON encoding of the verification condition.
+func (v *Verification) MarshalCondition() (string, error) {
+	b, err := json.Marshal(v.Condition())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FormatDigest returns the digest string for the given sha256 sum.
+func FormatDigest(sum []byte) string {
+	return DigestPrefix + hex.EncodeToString(sum)
+}
+
+// Digest returns the digest of the uncompressed and unencrypted snapshot database at
+// the given path. The sha256 checksum appended to the database by etcd is also
+// validated; an error is returned if it is missing or does not match.
+func Digest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// etcd pads the snapshot to a multiple of 512 bytes before appending the digest.
+	size := info.Size()
+	if size%512 != sha256.Size {
+		return "", fmt.Errorf("sha256 checksum not found [bytes: %d]", size)
+	}
+
+	digest := sha256.New()
+	checksum := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(digest, checksum), f, size-sha256.Size); err != nil {
+		return "", err
+	}
+
+	expected := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(f, expected); err != nil {
+		return "", err
+	}
+	if !bytes.Equal(checksum.Sum(nil), expected) {
+		return "", fmt.Errorf("sha256 checksum mismatch: expected %x, got %x", expected, checksum.Sum(nil))
+	}
+	digest.Write(expected)
+
+	return FormatDigest(digest.Sum(nil)), nil
+}
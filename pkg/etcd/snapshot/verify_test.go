@@ -0,0 +1,115 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// writeSnapshotDB writes a fake snapshot database of the given size, padded to a
+// multiple of 512 bytes with the sha256 checksum appended, as etcd does.
+func writeSnapshotDB(t *testing.T, size int) (string, []byte) {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	sum := sha256.Sum256(data)
+	data = append(data, sum[:]...)
+	path := filepath.Join(t.TempDir(), "etcd-snapshot-node1-1700000000")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func Test_UnitDigest(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(data []byte) []byte
+		wantErr string
+	}{
+		{
+			name: "Valid snapshot",
+		},
+		{
+			name:    "Truncated snapshot",
+			mutate:  func(data []byte) []byte { return data[:len(data)-1] },
+			wantErr: "sha256 checksum not found",
+		},
+		{
+			name: "Corrupted snapshot",
+			mutate: func(data []byte) []byte {
+				data[100] ^= 0xff
+				return data
+			},
+			wantErr: "sha256 checksum mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, data := writeSnapshotDB(t, 1024)
+			if tt.mutate != nil {
+				if err := os.WriteFile(path, tt.mutate(data), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Digest(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Digest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Digest() error = %v", err)
+			}
+			if want := FormatDigest(func() []byte { s := sha256.Sum256(data); return s[:] }()); got != want {
+				t.Errorf("Digest() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Test_UnitVerificationCondition(t *testing.T) {
+	now := metav1.NewTime(time.Now().Round(time.Second))
+	tests := []struct {
+		name         string
+		verification Verification
+		wantStatus   metav1.ConditionStatus
+	}{
+		{
+			name:         "Verified",
+			verification: Verification{Verified: true, Reason: ReasonVerified, Revision: 10, TotalKey: 5, Time: now},
+			wantStatus:   metav1.ConditionTrue,
+		},
+		{
+			name:         "Verified without digest",
+			verification: Verification{Verified: true, Reason: ReasonVerifiedWithoutDigest, Message: "digest check skipped", Revision: 10, TotalKey: 5, Time: now},
+			wantStatus:   metav1.ConditionTrue,
+		},
+		{
+			name:         "Digest mismatch",
+			verification: Verification{Reason: ReasonDigestMismatch, Message: "digest mismatch", Time: now},
+			wantStatus:   metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.verification.Condition()
+			if c.Type != ConditionVerified || c.Status != tt.wantStatus || c.Reason != tt.verification.Reason || !c.LastTransitionTime.Equal(&now) {
+				t.Errorf("Verification.Condition() = %+v", c)
+			}
+			if c.Message == "" {
+				t.Errorf("Verification.Condition() has empty message")
+			}
+			if !strings.Contains(c.Message, tt.verification.Message) {
+				t.Errorf("Verification.Condition() message %q does not include %q", c.Message, tt.verification.Message)
+			}
+		})
+	}
+}
@@ -193,8 +193,8 @@ func (e *etcdSnapshotHandler) reconcile() error {
 				},
 			},
 		}
-		if e.etcd.s3 != nil {
-			node.Annotations[annotationS3Reconciled] = "true"
+		for _, b := range e.etcd.storageBackends() {
+			node.Annotations[annotationStorageReconciled(b.name)] = "true"
 		}
 		nodeList.Items = append(nodeList.Items, node)
 	}
@@ -208,8 +208,10 @@ func (e *etcdSnapshotHandler) reconcile() error {
 		if _, ok := node.Annotations[annotationLocalReconciled]; ok {
 			syncedNodes[node.Name] = true
 		}
-		if _, ok := node.Annotations[annotationS3Reconciled]; ok {
-			syncedNodes["s3"] = true
+		for _, storage := range snapshot.RemoteStorageNodes {
+			if _, ok := node.Annotations[annotationStorageReconciled(storage)]; ok {
+				syncedNodes[storage] = true
+			}
 		}
 	}
 
@@ -259,9 +261,9 @@ func (e *etcdSnapshotHandler) reconcile() error {
 
 	// Delete any keys missing from synced storages, or associated with missing nodes
 	for key := range snapshotConfigMap.Data {
-		if strings.HasPrefix(key, "s3-") {
-			// If a node has syncd s3 and the key is missing then delete it
-			if syncedNodes["s3"] && snapshots[key] == nil {
+		if storage, ok := remoteStorageForKey(key); ok {
+			// If a node has synced the remote storage and the key is missing then delete it
+			if syncedNodes[storage] && snapshots[key] == nil {
 				delete(snapshotConfigMap.Data, key)
 			}
 		} else if s, ok := strings.CutPrefix(key, "local-"); ok {
@@ -360,3 +362,14 @@ func isTooLargeError(err error) bool {
 	// There are no helpers for unpacking field validation errors, so we just check for "Too long" in the error string.
 	return apierrors.IsRequestEntityTooLargeError(err) || (apierrors.IsInvalid(err) && strings.Contains(err.Error(), "Too long"))
 }
+
+// remoteStorageForKey returns the name of the remote storage that
+// the snapshot ConfigMap key belongs to, if any.
+func remoteStorageForKey(key string) (string, bool) {
+	for _, storage := range snapshot.RemoteStorageNodes {
+		if strings.HasPrefix(key, storage+"-") {
+			return storage, true
+		}
+	}
+	return "", false
+}
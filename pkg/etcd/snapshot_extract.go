@@ -0,0 +1,88 @@
+package etcd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/extract"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// ExtractSnapshot reads the resources matching the filter from a snapshot, and writes them to the
+// writer as YAML that can be re-applied to a cluster. The snapshot may be the name of a snapshot in
+// the snapshot directory, or the path to a snapshot file. Encrypted and compressed snapshots are
+// supported. Resources encrypted at rest are decrypted using the server's secrets encryption
+// configuration, if available. The snapshot is read offline; etcd does not need to be running.
+// The number of resources written is returned.
+func ExtractSnapshot(ctx context.Context, control *config.Control, name string, filter extract.Filter, w io.Writer) (int, error) {
+	snapshotPath, err := resolveSnapshotPath(control, name)
+	if err != nil {
+		return 0, err
+	}
+
+	// The snapshot is decrypted and decompressed into a temporary directory alongside the snapshot,
+	// as it may contain secrets that should not be written to a shared temporary directory.
+	workDir, err := os.MkdirTemp(filepath.Dir(snapshotPath), ".extract-")
+	if err != nil {
+		return 0, pkgerrors.WithMessage(err, "failed to create snapshot extraction directory")
+	}
+	defer os.RemoveAll(workDir)
+
+	e := &ETCD{config: control}
+	dbPath, err := e.extractSnapshotDB(filepath.Base(snapshotPath), snapshotPath, workDir)
+	if err != nil {
+		return 0, pkgerrors.WithMessage(err, "failed to decrypt or decompress snapshot")
+	}
+
+	r, err := extract.Open(dbPath, encryptionTransformers(ctx, control))
+	if err != nil {
+		return 0, pkgerrors.WithMessage(err, "failed to open snapshot database")
+	}
+	defer r.Close()
+
+	objects, err := r.Objects(ctx, filter)
+	if err != nil {
+		return 0, pkgerrors.WithMessage(err, "failed to read resources from snapshot")
+	}
+	return len(objects), extract.WriteYAML(w, objects)
+}
+
+// resolveSnapshotPath returns the path to the named snapshot. If the name is not the path
+// to an existing file, the snapshot is expected to be found in the snapshot directory.
+func resolveSnapshotPath(control *config.Control, name string) (string, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return name, nil
+	}
+	snapshotDir, err := snapshotDir(control, false)
+	if err != nil {
+		return "", pkgerrors.WithMessage(err, "failed to get etcd-snapshot-dir")
+	}
+	snapshotPath := filepath.Join(snapshotDir, filepath.Base(name))
+	if _, err := os.Stat(snapshotPath); err != nil {
+		return "", err
+	}
+	return snapshotPath, nil
+}
+
+// encryptionTransformers returns the transformers for the server's secrets encryption configuration.
+// If the configuration cannot be loaded, a warning is logged and encrypted resources will be skipped.
+func encryptionTransformers(ctx context.Context, control *config.Control) map[schema.GroupResource]value.Transformer {
+	configPath := filepath.Join(control.DataDir, "cred", "encryption-config.json")
+	if _, err := os.Stat(configPath); err != nil {
+		logrus.Debugf("Secrets encryption configuration not found at %s; encrypted resources will not be extracted", configPath)
+		return nil
+	}
+	encryptionConfig, err := encryptionconfig.LoadEncryptionConfig(ctx, configPath, false, "")
+	if err != nil {
+		logrus.Warnf("Failed to load secrets encryption configuration; encrypted resources will not be extracted: %v", err)
+		return nil
+	}
+	return encryptionConfig.Transformers
+}
@@ -10,6 +10,7 @@ import (
 	k3s "github.com/k3s-io/api/k3s.cattle.io/v1"
 	"github.com/k3s-io/k3s/pkg/cluster/managed"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/extract"
 	"github.com/k3s-io/k3s/pkg/util"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -22,15 +23,26 @@ const (
 	SnapshotOperationList   SnapshotOperation = "list"
 	SnapshotOperationPrune  SnapshotOperation = "prune"
 	SnapshotOperationDelete SnapshotOperation = "delete"
+	SnapshotOperationVerify SnapshotOperation = "verify"
+	SnapshotOperationDiff   SnapshotOperation = "diff"
 )
 
 type SnapshotRequest struct {
-	Operation SnapshotOperation `json:"operation"`
-	Name      []string          `json:"name,omitempty"`
-	Dir       *string           `json:"dir,omitempty"`
-	Compress  *bool             `json:"compress,omitempty"`
-	Retention *int              `json:"retention,omitempty"`
-	S3        *config.EtcdS3    `json:"s3,omitempty"`
+	Operation             SnapshotOperation     `json:"operation"`
+	Name                  []string              `json:"name,omitempty"`
+	Dir                   *string               `json:"dir,omitempty"`
+	Compress              *bool                 `json:"compress,omitempty"`
+	Encrypt               *bool                 `json:"encrypt,omitempty"`
+	KeyFile               *string               `json:"keyFile,omitempty"`
+	Retention             *int                  `json:"retention,omitempty"`
+	RetentionPolicy       *string               `json:"retentionPolicy,omitempty"`
+	RemoteRetentionPolicy *string               `json:"remoteRetentionPolicy,omitempty"`
+	DryRun                *bool                 `json:"dryRun,omitempty"`
+	S3                    *config.EtcdS3        `json:"s3,omitempty"`
+	S3Stream              *bool                 `json:"s3Stream,omitempty"`
+	RemoteDir             *config.EtcdRemoteDir `json:"remoteDir,omitempty"`
+	SFTP                  *config.EtcdSFTP      `json:"sftp,omitempty"`
+	Filter                *extract.Filter       `json:"filter,omitempty"`
 
 	ctx context.Context
 }
@@ -48,6 +60,7 @@ func (e *ETCD) snapshotHandler() http.Handler {
 		sr, err := getSnapshotRequest(req)
 		if err != nil {
 			util.SendErrorWithID(err, "etcd-snapshot", rw, req, http.StatusInternalServerError)
+			return
 		}
 		switch sr.Operation {
 		case SnapshotOperationList:
@@ -55,9 +68,13 @@ func (e *ETCD) snapshotHandler() http.Handler {
 		case SnapshotOperationSave:
 			err = e.withRequest(sr).handleSave(rw, req)
 		case SnapshotOperationPrune:
-			err = e.withRequest(sr).handlePrune(rw, req)
+			err = e.withRequest(sr).handlePrune(rw, req, sr.DryRun != nil && *sr.DryRun)
 		case SnapshotOperationDelete:
 			err = e.withRequest(sr).handleDelete(rw, req, sr.Name)
+		case SnapshotOperationVerify:
+			err = e.withRequest(sr).handleVerify(rw, req, sr.Name)
+		case SnapshotOperationDiff:
+			err = e.withRequest(sr).handleDiff(rw, req, sr.Name, sr.Filter)
 		default:
 			err = e.handleInvalid(rw, req)
 		}
@@ -101,7 +118,7 @@ func (e *ETCD) handleSave(rw http.ResponseWriter, req *http.Request) error {
 	return err
 }
 
-func (e *ETCD) handlePrune(rw http.ResponseWriter, req *http.Request) error {
+func (e *ETCD) handlePrune(rw http.ResponseWriter, req *http.Request, dryRun bool) error {
 	if e.config.EtcdS3 != nil {
 		if _, err := e.getS3Client(req.Context()); err != nil {
 			err = pkgerrors.WithMessage(err, "failed to initialize S3 client")
@@ -109,12 +126,12 @@ func (e *ETCD) handlePrune(rw http.ResponseWriter, req *http.Request) error {
 			return nil
 		}
 	}
-	sr, err := e.PruneSnapshots(req.Context())
-	if sr == nil {
+	pr, err := e.PruneSnapshots(req.Context(), dryRun)
+	if pr == nil {
 		util.SendError(err, rw, req, http.StatusInternalServerError)
 		return nil
 	}
-	sendSnapshotResponse(rw, req, sr)
+	sendPruneResponse(rw, req, pr)
 	return err
 }
 
@@ -135,6 +152,47 @@ func (e *ETCD) handleDelete(rw http.ResponseWriter, req *http.Request, snapshots
 	return err
 }
 
+func (e *ETCD) handleVerify(rw http.ResponseWriter, req *http.Request, snapshots []string) error {
+	if e.config.EtcdS3 != nil {
+		if _, err := e.getS3Client(req.Context()); err != nil {
+			err = pkgerrors.WithMessage(err, "failed to initialize S3 client")
+			util.SendError(err, rw, req, http.StatusBadRequest)
+			return nil
+		}
+	}
+	vr, err := e.VerifySnapshots(req.Context(), snapshots)
+	if vr == nil {
+		util.SendError(err, rw, req, http.StatusInternalServerError)
+		return nil
+	}
+	sendVerifyResponse(rw, req, vr)
+	return err
+}
+
+func (e *ETCD) handleDiff(rw http.ResponseWriter, req *http.Request, snapshots []string, filter *extract.Filter) error {
+	if len(snapshots) != 2 {
+		util.SendError(fmt.Errorf("exactly two snapshots must be given for comparison"), rw, req, http.StatusBadRequest)
+		return nil
+	}
+	if filter == nil {
+		filter = &extract.Filter{}
+	}
+	if e.config.EtcdS3 != nil {
+		if _, err := e.getS3Client(req.Context()); err != nil {
+			err = pkgerrors.WithMessage(err, "failed to initialize S3 client")
+			util.SendError(err, rw, req, http.StatusBadRequest)
+			return nil
+		}
+	}
+	dr, err := e.DiffSnapshots(req.Context(), snapshots[0], snapshots[1], *filter)
+	if err != nil {
+		util.SendError(err, rw, req, http.StatusInternalServerError)
+		return nil
+	}
+	sendDiffResponse(rw, req, dr)
+	return nil
+}
+
 func (e *ETCD) handleInvalid(rw http.ResponseWriter, req *http.Request) error {
 	util.SendErrorWithID(fmt.Errorf("invalid snapshot operation"), "etcd-snapshot", rw, req, http.StatusBadRequest)
 	return nil
@@ -146,14 +204,21 @@ func (e *ETCD) withRequest(sr *SnapshotRequest) *ETCD {
 	re := &ETCD{
 		client: e.client,
 		config: &config.Control{
-			CriticalControlArgs:   e.config.CriticalControlArgs,
-			Runtime:               e.config.Runtime,
-			DataDir:               e.config.DataDir,
-			Datastore:             e.config.Datastore,
-			EtcdSnapshotCompress:  e.config.EtcdSnapshotCompress,
-			EtcdSnapshotName:      e.config.EtcdSnapshotName,
-			EtcdSnapshotRetention: e.config.EtcdSnapshotRetention,
-			EtcdS3:                sr.S3,
+			CriticalControlArgs:     e.config.CriticalControlArgs,
+			Runtime:                 e.config.Runtime,
+			DataDir:                 e.config.DataDir,
+			Datastore:               e.config.Datastore,
+			EtcdSnapshotCompress:    e.config.EtcdSnapshotCompress,
+			EtcdSnapshotEncrypt:     e.config.EtcdSnapshotEncrypt,
+			EtcdSnapshotKeyFile:     e.config.EtcdSnapshotKeyFile,
+			EtcdSnapshotName:        e.config.EtcdSnapshotName,
+			EtcdSnapshotRetention:   e.config.EtcdSnapshotRetention,
+			EtcdSnapshotTiers:       e.config.EtcdSnapshotTiers,
+			EtcdRemoteSnapshotTiers: e.config.EtcdRemoteSnapshotTiers,
+			EtcdS3:                  sr.S3,
+			EtcdS3Stream:            e.config.EtcdS3Stream,
+			EtcdRemoteDir:           sr.RemoteDir,
+			EtcdSFTP:                sr.SFTP,
 		},
 		s3:         e.s3,
 		name:       e.name,
@@ -168,12 +233,27 @@ func (e *ETCD) withRequest(sr *SnapshotRequest) *ETCD {
 	if sr.Compress != nil {
 		re.config.EtcdSnapshotCompress = *sr.Compress
 	}
+	if sr.Encrypt != nil {
+		re.config.EtcdSnapshotEncrypt = *sr.Encrypt
+	}
+	if sr.KeyFile != nil {
+		re.config.EtcdSnapshotKeyFile = *sr.KeyFile
+	}
 	if sr.Dir != nil {
 		re.config.EtcdSnapshotDir = *sr.Dir
 	}
 	if sr.Retention != nil {
 		re.config.EtcdSnapshotRetention = *sr.Retention
 	}
+	if sr.RetentionPolicy != nil {
+		re.config.EtcdSnapshotTiers = *sr.RetentionPolicy
+	}
+	if sr.RemoteRetentionPolicy != nil {
+		re.config.EtcdRemoteSnapshotTiers = *sr.RemoteRetentionPolicy
+	}
+	if sr.S3Stream != nil {
+		re.config.EtcdS3Stream = *sr.S3Stream
+	}
 	return re
 }
 
@@ -204,6 +284,36 @@ func sendSnapshotResponse(rw http.ResponseWriter, req *http.Request, sr *managed
 	rw.Write(b)
 }
 
+func sendPruneResponse(rw http.ResponseWriter, req *http.Request, pr *PruneResult) {
+	b, err := json.Marshal(pr)
+	if err != nil {
+		util.SendErrorWithID(err, "etcd-snapshot", rw, req, http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
+func sendVerifyResponse(rw http.ResponseWriter, req *http.Request, vr *VerifyResult) {
+	b, err := json.Marshal(vr)
+	if err != nil {
+		util.SendErrorWithID(err, "etcd-snapshot", rw, req, http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
+func sendDiffResponse(rw http.ResponseWriter, req *http.Request, dr *extract.DiffResult) {
+	b, err := json.Marshal(dr)
+	if err != nil {
+		util.SendErrorWithID(err, "etcd-snapshot", rw, req, http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(b)
+}
+
 func sendSnapshotList(rw http.ResponseWriter, req *http.Request, sf *k3s.ETCDSnapshotFileList) {
 	b, err := json.Marshal(sf)
 	if err != nil {
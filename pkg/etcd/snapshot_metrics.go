@@ -25,6 +25,12 @@ var (
 		Buckets: metrics.ExponentialBuckets(0.008, 2, 15),
 	}, []string{"status"})
 
+	snapshotSaveRemoteCount = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    version.Program + "_etcd_snapshot_save_remote_duration_seconds",
+		Help:    "Total time in seconds taken to upload a snapshot file to remote storage, labeled by storage and success/failure status.",
+		Buckets: metrics.ExponentialBuckets(0.008, 2, 15),
+	}, []string{"storage", "status"})
+
 	snapshotReconcileCount = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    version.Program + "_etcd_snapshot_reconcile_duration_seconds",
 		Help:    "Total time in seconds taken to sync the list of etcd snapshots, labeled by success/failure status.",
@@ -42,6 +48,18 @@ var (
 		Help:    "Total time in seconds taken to list S3 snapshot files, labeled by success/failure status.",
 		Buckets: metrics.ExponentialBuckets(0.008, 2, 15),
 	}, []string{"status"})
+
+	snapshotReconcileRemoteCount = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    version.Program + "_etcd_snapshot_reconcile_remote_duration_seconds",
+		Help:    "Total time in seconds taken to list remote storage snapshot files, labeled by storage and success/failure status.",
+		Buckets: metrics.ExponentialBuckets(0.008, 2, 15),
+	}, []string{"storage", "status"})
+
+	snapshotVerifyCount = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    version.Program + "_etcd_snapshot_verify_duration_seconds",
+		Help:    "Total time in seconds taken to verify a snapshot file, labeled by storage and success/failure status.",
+		Buckets: metrics.ExponentialBuckets(0.008, 2, 15),
+	}, []string{"storage", "status"})
 )
 
 // MustRegister registers etcd snapshot metrics
@@ -50,8 +68,11 @@ func MustRegister(registerer prometheus.Registerer) {
 		snapshotSaveCount,
 		snapshotSaveLocalCount,
 		snapshotSaveS3Count,
+		snapshotSaveRemoteCount,
 		snapshotReconcileCount,
 		snapshotReconcileLocalCount,
 		snapshotReconcileS3Count,
+		snapshotReconcileRemoteCount,
+		snapshotVerifyCount,
 	)
 }
@@ -0,0 +1,196 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/cluster/managed"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/etcd/filestore"
+	"github.com/k3s-io/k3s/pkg/etcd/sftp"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/util"
+	"github.com/k3s-io/k3s/pkg/util/metrics"
+	"github.com/k3s-io/k3s/pkg/version"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// localStorage is the storage name used in retention decisions for local snapshots.
+const localStorage = "local"
+
+// PruneResult is returned by the prune operation. In addition to the names of
+// deleted snapshots, it includes the retention decision for each snapshot.
+type PruneResult struct {
+	managed.SnapshotResult
+	Retention []snapshot.RetentionDecision `json:"retention,omitempty"`
+}
+
+// storageBackend is a remote snapshot storage backend that is enabled in the current configuration.
+type storageBackend struct {
+	// name is the storage node name used for snapshots held by the backend
+	name string
+	// title is the human-readable name of the backend, used in log messages
+	title string
+	// retention is the number of snapshots to retain in the backend;
+	// if zero the etcd-snapshot-retention value is used instead.
+	retention int
+	// s3 is the S3 configuration, which is stored in the ETCDSnapshotFile
+	// for failed S3 snapshots. It is nil for other backends.
+	s3 *snapshot.S3Config
+	// client returns a client for the storage backend
+	client func(ctx context.Context) (snapshot.Storage, error)
+}
+
+// storageBackends returns the remote snapshot storage backends that are enabled in the
+// current configuration. Clients for each backend are created on demand, as some backends
+// may not be reachable at the time the list is generated.
+func (e *ETCD) storageBackends() []storageBackend {
+	backends := []storageBackend{}
+	if e.config.EtcdS3 != nil {
+		backends = append(backends, storageBackend{
+			name:      snapshot.StorageS3,
+			title:     "S3",
+			retention: e.config.EtcdS3.Retention,
+			s3:        &snapshot.S3Config{EtcdS3: *e.config.EtcdS3},
+			client: func(ctx context.Context) (snapshot.Storage, error) {
+				s3client, err := e.getS3Client(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return s3client, nil
+			},
+		})
+	}
+	if e.config.EtcdRemoteDir != nil {
+		backends = append(backends, storageBackend{
+			name:      snapshot.StorageRemoteDir,
+			title:     "remote directory",
+			retention: e.config.EtcdRemoteDir.Retention,
+			client: func(ctx context.Context) (snapshot.Storage, error) {
+				return filestore.NewRemoteDir(e.config.EtcdRemoteDir, os.Getenv("NODE_NAME"), e.storageTokenHash())
+			},
+		})
+	}
+	if e.config.EtcdSFTP != nil {
+		backends = append(backends, storageBackend{
+			name:      snapshot.StorageSFTP,
+			title:     "SFTP",
+			retention: e.config.EtcdSFTP.Retention,
+			client: func(ctx context.Context) (snapshot.Storage, error) {
+				return sftp.New(e.config.EtcdSFTP, os.Getenv("NODE_NAME"), e.storageTokenHash())
+			},
+		})
+	}
+	return backends
+}
+
+// retentionPolicy returns the retention policy for snapshots in the storage backend. The retention
+// count and policy tiers default to those used for local snapshots, if not set for remote storage.
+func (b storageBackend) retentionPolicy(control *config.Control) (snapshot.RetentionPolicy, error) {
+	retention := control.EtcdSnapshotRetention
+	if b.retention != 0 {
+		retention = b.retention
+	}
+	tiers := control.EtcdSnapshotTiers
+	if control.EtcdRemoteSnapshotTiers != "" {
+		tiers = control.EtcdRemoteSnapshotTiers
+	}
+	return snapshot.ParseRetentionPolicy(retention, tiers)
+}
+
+// localSnapshotRetention applies the retention policy to local snapshots,
+// returning the retention decision for each snapshot.
+func (e *ETCD) localSnapshotRetention(snapshotDir string, dryRun bool) ([]snapshot.RetentionDecision, error) {
+	policy, err := snapshot.ParseRetentionPolicy(e.config.EtcdSnapshotRetention, e.config.EtcdSnapshotTiers)
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := snapshotRetention(policy, e.config.EtcdSnapshotName, snapshotDir, dryRun)
+	for i := range decisions {
+		decisions[i].Storage = localStorage
+	}
+	return decisions, err
+}
+
+// storageSnapshotRetention applies the retention policy to snapshots in the storage
+// backend, returning the retention decision for each snapshot.
+func (e *ETCD) storageSnapshotRetention(ctx context.Context, b storageBackend, client snapshot.Storage, dryRun bool) ([]snapshot.RetentionDecision, error) {
+	policy, err := b.retentionPolicy(e.config)
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := client.SnapshotRetention(ctx, policy, e.config.EtcdSnapshotName, dryRun)
+	for i := range decisions {
+		decisions[i].Storage = b.name
+	}
+	return decisions, err
+}
+
+// storageTokenHash returns the server token hash to be recorded for snapshots uploaded
+// to remote storage. The token hash is not available during cluster-reset.
+func (e *ETCD) storageTokenHash() string {
+	if e.config.ClusterReset {
+		return ""
+	}
+	tokenHash, err := util.GetTokenHash(e.config)
+	if err != nil {
+		logrus.Warnf("Failed to get server token hash for remote snapshot storage: %v", err)
+	}
+	return tokenHash
+}
+
+// downloadSnapshot attempts to download the named snapshot from each remote storage backend
+// in turn, returning the path of the downloaded snapshot from the first backend that has it.
+func (e *ETCD) downloadSnapshot(ctx context.Context, snapshotName string) (string, error) {
+	dir, err := snapshotDir(e.config, true)
+	if err != nil {
+		return "", pkgerrors.WithMessage(err, "failed to get the snapshot dir")
+	}
+
+	errs := []error{}
+	for _, b := range e.storageBackends() {
+		logrus.Infof("Retrieving etcd snapshot %s from %s", snapshotName, b.title)
+		client, err := b.client(ctx)
+		if err != nil {
+			errs = append(errs, pkgerrors.WithMessagef(err, "failed to initialize %s client", b.title))
+			continue
+		}
+		path, err := client.Download(ctx, snapshotName, dir)
+		if err != nil {
+			errs = append(errs, pkgerrors.WithMessagef(err, "failed to download snapshot from %s", b.title))
+			continue
+		}
+		logrus.Infof("%s download complete for %s", b.title, path)
+		return path, nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// annotationStorageReconciled returns the name of the node annotation used to record
+// the time that snapshots in the given remote storage were last reconciled.
+func annotationStorageReconciled(storage string) string {
+	return "etcd." + version.Program + ".cattle.io/" + storage + "-snapshots-timestamp"
+}
+
+// observeStorageSave records the time taken to save a snapshot to remote storage.
+// S3 snapshot saves are recorded in a separate metric, for backwards compatibility.
+func observeStorageSave(storage string, start time.Time, err error) {
+	if storage == snapshot.StorageS3 {
+		metrics.ObserveWithStatus(snapshotSaveS3Count, start, err)
+	} else {
+		metrics.ObserveWithStatus(snapshotSaveRemoteCount, start, err, storage)
+	}
+}
+
+// observeStorageReconcile records the time taken to list snapshots in remote storage.
+// S3 snapshot listing is recorded in a separate metric, for backwards compatibility.
+func observeStorageReconcile(storage string, start time.Time, err error) {
+	if storage == snapshot.StorageS3 {
+		metrics.ObserveWithStatus(snapshotReconcileS3Count, start, err)
+	} else {
+		metrics.ObserveWithStatus(snapshotReconcileRemoteCount, start, err, storage)
+	}
+}
@@ -0,0 +1,287 @@
+package etcd
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	k3s "github.com/k3s-io/api/k3s.cattle.io/v1"
+	"github.com/k3s-io/k3s/pkg/etcd/snapshot"
+	"github.com/k3s-io/k3s/pkg/util/metrics"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	snapshotv3 "go.etcd.io/etcd/etcdutl/v3/snapshot"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+// VerifyResult is returned by the verify operation, and contains the
+// verification result for each copy of the requested snapshots.
+type VerifyResult struct {
+	Snapshots []snapshot.Verification `json:"snapshots,omitempty"`
+}
+
+// VerifySnapshots verifies each copy of the named snapshots held in local and remote storage. Each copy
+// is retrieved, decrypted and decompressed if necessary, and checked against the digest recorded when the
+// snapshot was saved, if one was recorded. The snapshot database is then opened with the etcd snapshot
+// status API to confirm that it is readable. The result is recorded as a condition on the corresponding ETCDSnapshotFile.
+// An error is returned if any copy of any snapshot could not be verified.
+func (e *ETCD) VerifySnapshots(ctx context.Context, snapshots []string) (*VerifyResult, error) {
+	snapshotDir, err := snapshotDir(e.config, true)
+	if err != nil {
+		return nil, pkgerrors.WithMessage(err, "failed to get etcd-snapshot-dir")
+	}
+
+	// Remote snapshots are downloaded into a temporary directory alongside the snapshot
+	// directory, so that they are not picked up by local snapshot reconciliation.
+	workDir, err := os.MkdirTemp(filepath.Dir(snapshotDir), ".verify-")
+	if err != nil {
+		return nil, pkgerrors.WithMessage(err, "failed to create snapshot verification directory")
+	}
+	defer os.RemoveAll(workDir)
+
+	res := &VerifyResult{}
+	errs := []error{}
+	for _, name := range slices.Compact(slices.Sorted(slices.Values(snapshots))) {
+		verifications := []snapshot.Verification{}
+
+		if _, err := os.Stat(filepath.Join(snapshotDir, name)); err == nil {
+			verifications = append(verifications, e.verifySnapshotFile(name, localStorage, filepath.Join(snapshotDir, name), workDir))
+		}
+
+		for _, b := range e.storageBackends() {
+			v := snapshot.Verification{Name: name, Storage: b.name, Time: metav1.Now()}
+			client, err := b.client(ctx)
+			if err != nil {
+				v.Reason = snapshot.ReasonStorageUnavailable
+				v.Message = fmt.Sprintf("failed to initialize %s client: %v", b.title, err)
+				verifications = append(verifications, v)
+				continue
+			}
+			downloadDir := filepath.Join(workDir, b.name)
+			if err := os.MkdirAll(downloadDir, 0700); err != nil {
+				return nil, err
+			}
+			logrus.Infof("Retrieving etcd snapshot %s from %s for verification", name, b.title)
+			snapshotPath, err := client.Download(ctx, name, downloadDir)
+			if snapshot.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				v.Reason = snapshot.ReasonStorageUnavailable
+				v.Message = fmt.Sprintf("failed to download snapshot from %s: %v", b.title, err)
+				verifications = append(verifications, v)
+				continue
+			}
+			verifications = append(verifications, e.verifySnapshotFile(name, b.name, snapshotPath, workDir))
+			os.Remove(snapshotPath)
+		}
+
+		if len(verifications) == 0 {
+			verifications = append(verifications, snapshot.Verification{
+				Name:    name,
+				Reason:  snapshot.ReasonNotFound,
+				Message: "snapshot not found in local or remote storage",
+				Time:    metav1.Now(),
+			})
+		}
+
+		for _, v := range verifications {
+			if v.Verified && v.Reason == snapshot.ReasonVerifiedWithoutDigest {
+				logrus.Warnf("Verified etcd snapshot %s in %s storage: revision=%d keys=%d; %s", v.Name, v.Storage, v.Revision, v.TotalKey, v.Message)
+			} else if v.Verified {
+				logrus.Infof("Verified etcd snapshot %s in %s storage: revision=%d keys=%d", v.Name, v.Storage, v.Revision, v.TotalKey)
+			} else {
+				logrus.Errorf("Failed to verify etcd snapshot %s in %s storage: %s", v.Name, v.Storage, v.Message)
+				errs = append(errs, fmt.Errorf("snapshot %s in %s storage: %s", v.Name, v.Storage, v.Message))
+			}
+			if v.Storage != "" {
+				if err := e.recordVerification(v); err != nil {
+					logrus.Warnf("Failed to record verification result for ETCDSnapshotFile: %v", err)
+				}
+			}
+		}
+		res.Snapshots = append(res.Snapshots, verifications...)
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// verifySnapshotFile verifies a single snapshot file against the digest recorded on
+// its ETCDSnapshotFile. Temporary files are written to the provided directory.
+func (e *ETCD) verifySnapshotFile(name, storage, snapshotPath, workDir string) (v snapshot.Verification) {
+	verifyStart := time.Now()
+	v = snapshot.Verification{Name: name, Storage: storage}
+	defer func() {
+		v.Time = metav1.Now()
+		var err error
+		if !v.Verified {
+			err = errors.New(v.Reason)
+		}
+		metrics.ObserveWithStatus(snapshotVerifyCount, verifyStart, err, storage)
+	}()
+
+	dbPath, err := e.extractSnapshotDB(name, snapshotPath, workDir)
+	if err != nil {
+		v.Reason = snapshot.ReasonUnreadable
+		v.Message = fmt.Sprintf("failed to decrypt or decompress snapshot: %v", err)
+		return v
+	}
+	if dbPath != snapshotPath {
+		defer os.Remove(dbPath)
+	}
+
+	v.Digest, err = snapshot.Digest(dbPath)
+	if err != nil {
+		v.Reason = snapshot.ReasonChecksumFailed
+		v.Message = err.Error()
+		return v
+	}
+
+	// Snapshots reconciled from storage, rather than saved by this cluster, do not have a recorded digest.
+	// These are still verified using the etcd checksum, but the result reports that the digest was not checked.
+	reason := snapshot.ReasonVerified
+	if esf, err := e.getSnapshotFile(name, storage); err != nil {
+		reason = snapshot.ReasonVerifiedWithoutDigest
+		v.Message = fmt.Sprintf("digest check skipped: unable to retrieve recorded digest: %v", err)
+	} else if expected := esf.Annotations[snapshot.AnnotationDigest]; expected == "" {
+		reason = snapshot.ReasonVerifiedWithoutDigest
+		v.Message = "digest check skipped: no digest was recorded when the snapshot was saved; only the etcd checksum was verified"
+	} else if expected != v.Digest {
+		v.Reason = snapshot.ReasonDigestMismatch
+		v.Message = fmt.Sprintf("digest %s does not match digest %s recorded when the snapshot was saved", v.Digest, expected)
+		return v
+	}
+
+	status, err := snapshotv3.NewV3(e.client.GetLogger()).Status(dbPath)
+	if err != nil {
+		v.Reason = snapshot.ReasonStatusCheckFailed
+		v.Message = err.Error()
+		return v
+	}
+
+	v.Verified = true
+	v.Reason = reason
+	v.Revision = status.Revision
+	v.TotalKey = status.TotalKey
+	v.TotalSize = status.TotalSize
+	return v
+}
+
+// extractSnapshotDB decrypts and decompresses the snapshot file into the provided directory as
+// necessary, returning the path to the snapshot database. The caller is responsible for removing
+// the returned file if it differs from the provided snapshot path.
+func (e *ETCD) extractSnapshotDB(name, snapshotPath, workDir string) (string, error) {
+	dbPath := snapshotPath
+	if keyID, err := snapshot.ReadFileEncryptionKeyID(dbPath); err != nil {
+		return "", err
+	} else if keyID != "" {
+		decryptedPath, err := e.decryptSnapshot(workDir, dbPath)
+		if err != nil {
+			return "", err
+		}
+		dbPath = decryptedPath
+	}
+
+	if !strings.HasSuffix(name, snapshot.CompressedExtension) {
+		return dbPath, nil
+	}
+	if dbPath != snapshotPath {
+		defer os.Remove(dbPath)
+	}
+
+	r, err := zip.OpenReader(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	if len(r.File) != 1 {
+		return "", fmt.Errorf("expected 1 file in snapshot archive, found %d", len(r.File))
+	}
+
+	in, err := r.File[0].Open()
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(workDir, strings.TrimSuffix(name, snapshot.CompressedExtension)+".decompressed-*")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// getSnapshotFile returns the ETCDSnapshotFile for the named snapshot in the given storage.
+func (e *ETCD) getSnapshotFile(name, storage string) (*k3s.ETCDSnapshotFile, error) {
+	if e.config.Runtime.K3s == nil {
+		return nil, errors.New("runtime is not ready")
+	}
+	storageNode := storage
+	if storage == localStorage {
+		storageNode = os.Getenv("NODE_NAME")
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{snapshot.LabelStorageNode: storageNode})
+	esfList, err := e.config.Runtime.K3s.K3s().V1().ETCDSnapshotFile().List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	for _, esf := range esfList.Items {
+		if esf.Spec.SnapshotName == name {
+			return &esf, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(k3s.Resource("etcdsnapshotfile"), name)
+}
+
+// recordVerification records the verification result as a condition
+// annotation on the ETCDSnapshotFile, and emits an event.
+func (e *ETCD) recordVerification(v snapshot.Verification) error {
+	condition, err := v.MarshalCondition()
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		esf, err := e.getSnapshotFile(v.Name, v.Storage)
+		if err != nil {
+			return err
+		}
+		if esf.Annotations == nil {
+			esf.Annotations = map[string]string{}
+		}
+		esf.Annotations[snapshot.AnnotationVerification] = condition
+		esf, err = e.config.Runtime.K3s.K3s().V1().ETCDSnapshotFile().Update(esf)
+		if err != nil {
+			return err
+		}
+		if e.config.Runtime.Event != nil {
+			if v.Verified {
+				if v.Message != "" {
+					e.config.Runtime.Event.Eventf(esf, v1.EventTypeNormal, "ETCDSnapshotVerified", "Snapshot %s verified in %s storage; %s", v.Name, v.Storage, v.Message)
+				} else {
+					e.config.Runtime.Event.Eventf(esf, v1.EventTypeNormal, "ETCDSnapshotVerified", "Snapshot %s verified in %s storage", v.Name, v.Storage)
+				}
+			} else {
+				e.config.Runtime.Event.Eventf(esf, v1.EventTypeWarning, "ETCDSnapshotVerificationFailed", "Failed to verify snapshot %s in %s storage: %s", v.Name, v.Storage, v.Message)
+			}
+		}
+		return nil
+	})
+}
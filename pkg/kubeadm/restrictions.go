@@ -0,0 +1,57 @@
+package kubeadm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+)
+
+// ValidateJoin checks that a node with the given name, role, and address is allowed to join the
+// cluster using this token. Nodes that have already joined using the token are not counted
+// against the join limit when they rejoin.
+func (bt *BootstrapToken) ValidateJoin(nodeName, role string, addr net.IP) error {
+	if nodeName == "" && (bt.NodeNamePattern != "" || bt.MaxJoins > 0) {
+		return errors.New("node name must be provided to join using this token")
+	}
+
+	if bt.NodeNamePattern != "" {
+		if ok, err := path.Match(bt.NodeNamePattern, nodeName); err != nil || !ok {
+			return fmt.Errorf("node name %q does not match token node name pattern %q", nodeName, bt.NodeNamePattern)
+		}
+	}
+
+	if len(bt.Roles) > 0 && !slices.Contains(bt.Roles, role) {
+		return fmt.Errorf("token may not be used to join %s nodes", role)
+	}
+
+	if len(bt.AllowedCIDRs) > 0 {
+		if addr == nil {
+			return errors.New("unable to determine address of joining node")
+		}
+		if !slices.ContainsFunc(bt.AllowedCIDRs, func(cidr string) bool {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			return err == nil && ipNet.Contains(addr)
+		}) {
+			return fmt.Errorf("address %s is not within token allowed CIDRs", addr)
+		}
+	}
+
+	if bt.MaxJoins > 0 && !slices.Contains(bt.JoinedNodes, nodeName) && len(bt.JoinedNodes) >= bt.MaxJoins {
+		return fmt.Errorf("token has already been used to join %d nodes", len(bt.JoinedNodes))
+	}
+
+	return nil
+}
+
+// AddJoinedNode records that a node has joined the cluster using this token.
+// It returns false if the node was already recorded, or if no node name was provided.
+func (bt *BootstrapToken) AddJoinedNode(nodeName string) bool {
+	if nodeName == "" || slices.Contains(bt.JoinedNodes, nodeName) {
+		return false
+	}
+	bt.JoinedNodes = append(bt.JoinedNodes, nodeName)
+	slices.Sort(bt.JoinedNodes)
+	return true
+}
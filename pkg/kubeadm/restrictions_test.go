@@ -0,0 +1,208 @@
+package kubeadm
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_UnitValidateJoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    BootstrapToken
+		nodeName string
+		role     string
+		addr     net.IP
+		wantErr  bool
+	}{
+		{
+			name:     "Unrestricted token",
+			token:    BootstrapToken{},
+			nodeName: "node-1",
+			role:     RoleAgent,
+		},
+		{
+			name:     "Matching node name pattern",
+			token:    BootstrapToken{NodeNamePattern: "worker-*"},
+			nodeName: "worker-1",
+			role:     RoleAgent,
+		},
+		{
+			name:     "Mismatched node name pattern",
+			token:    BootstrapToken{NodeNamePattern: "worker-*"},
+			nodeName: "server-1",
+			role:     RoleAgent,
+			wantErr:  true,
+		},
+		{
+			name:  "Unnamed node with unrestricted token",
+			token: BootstrapToken{AllowedCIDRs: []string{"10.0.0.0/8"}},
+			addr:  net.ParseIP("10.0.0.10"),
+		},
+		{
+			name:    "Unnamed node with node name pattern",
+			token:   BootstrapToken{NodeNamePattern: "worker-*"},
+			wantErr: true,
+		},
+		{
+			name:    "Unnamed node with join limit",
+			token:   BootstrapToken{MaxJoins: 2},
+			wantErr: true,
+		},
+		{
+			name:     "Allowed role",
+			token:    BootstrapToken{Roles: []string{RoleAgent}},
+			nodeName: "node-1",
+			role:     RoleAgent,
+		},
+		{
+			name:     "Disallowed role",
+			token:    BootstrapToken{Roles: []string{RoleServer}},
+			nodeName: "node-1",
+			role:     RoleAgent,
+			wantErr:  true,
+		},
+		{
+			name:     "Address within allowed CIDRs",
+			token:    BootstrapToken{AllowedCIDRs: []string{"10.0.0.0/8", "fd00::/8"}},
+			nodeName: "node-1",
+			role:     RoleAgent,
+			addr:     net.ParseIP("fd00::10"),
+		},
+		{
+			name:     "Address outside allowed CIDRs",
+			token:    BootstrapToken{AllowedCIDRs: []string{"10.0.0.0/8"}},
+			nodeName: "node-1",
+			role:     RoleAgent,
+			addr:     net.ParseIP("192.168.1.10"),
+			wantErr:  true,
+		},
+		{
+			name:     "Unknown address with allowed CIDRs",
+			token:    BootstrapToken{AllowedCIDRs: []string{"10.0.0.0/8"}},
+			nodeName: "node-1",
+			role:     RoleAgent,
+			wantErr:  true,
+		},
+		{
+			name:     "Join limit not reached",
+			token:    BootstrapToken{MaxJoins: 2, JoinedNodes: []string{"node-1"}},
+			nodeName: "node-2",
+			role:     RoleAgent,
+		},
+		{
+			name:     "Join limit reached",
+			token:    BootstrapToken{MaxJoins: 2, JoinedNodes: []string{"node-1", "node-2"}},
+			nodeName: "node-3",
+			role:     RoleAgent,
+			wantErr:  true,
+		},
+		{
+			name:     "Join limit reached by rejoining node",
+			token:    BootstrapToken{MaxJoins: 2, JoinedNodes: []string{"node-1", "node-2"}},
+			nodeName: "node-2",
+			role:     RoleAgent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.token.ValidateJoin(tt.nodeName, tt.role, tt.addr); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJoin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_UnitBootstrapTokenSecretRestrictions(t *testing.T) {
+	bts, err := NewBootstrapTokenString("abcdef.0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BootstrapToken{
+		Token:           bts,
+		MaxJoins:        3,
+		NodeNamePattern: "worker-*",
+		AllowedCIDRs:    []string{"10.0.0.0/8", "fd00::/8"},
+		Roles:           []string{RoleAgent},
+	}
+	want.AddJoinedNode("worker-2")
+	want.AddJoinedNode("worker-1")
+	if want.AddJoinedNode("worker-1") {
+		t.Errorf("AddJoinedNode() recorded the same node twice")
+	}
+	if want.AddJoinedNode("") {
+		t.Errorf("AddJoinedNode() recorded an unnamed node")
+	}
+
+	secret := BootstrapTokenToSecret(want)
+	got, err := BootstrapTokenFromSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BootstrapTokenFromSecret() = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(got.JoinedNodes, []string{"worker-1", "worker-2"}) {
+		t.Errorf("JoinedNodes = %v, want sorted node names", got.JoinedNodes)
+	}
+}
+
+func Test_UnitValidateRestrictions(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     cmds.Token
+		wantErr string
+	}{
+		{
+			name: "No restrictions",
+		},
+		{
+			name: "Valid restrictions",
+			cfg: cmds.Token{
+				MaxJoins:        3,
+				NodeNamePattern: "worker-*",
+				AllowedCIDRs:    *cli.NewStringSlice("10.0.0.0/8"),
+				Roles:           *cli.NewStringSlice(RoleAgent),
+			},
+		},
+		{
+			name:    "Negative join limit",
+			cfg:     cmds.Token{MaxJoins: -1},
+			wantErr: "invalid max-joins",
+		},
+		{
+			name:    "Invalid node name pattern",
+			cfg:     cmds.Token{NodeNamePattern: "worker-["},
+			wantErr: "invalid node-name-pattern",
+		},
+		{
+			name:    "Invalid CIDR",
+			cfg:     cmds.Token{AllowedCIDRs: *cli.NewStringSlice("10.0.0.0")},
+			wantErr: "invalid allowed-cidrs",
+		},
+		{
+			name:    "Server role",
+			cfg:     cmds.Token{Roles: *cli.NewStringSlice(RoleServer)},
+			wantErr: "bootstrap tokens cannot be used to join servers",
+		},
+		{
+			name:    "Unknown role",
+			cfg:     cmds.Token{Roles: *cli.NewStringSlice("worker")},
+			wantErr: "invalid role: worker",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRestrictions(&tt.cfg)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateRestrictions() error = %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateRestrictions() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
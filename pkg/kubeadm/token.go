@@ -2,6 +2,9 @@ package kubeadm
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"path"
 
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/version"
@@ -12,6 +15,18 @@ import (
 
 var (
 	NodeBootstrapTokenAuthGroup = "system:bootstrappers:" + version.Program + ":default-node-token"
+
+	// Secret data keys used to store join restrictions and usage alongside the bootstrap token
+	BootstrapTokenMaxJoinsKey        = version.Program + "-max-joins"
+	BootstrapTokenNodeNamePatternKey = version.Program + "-node-name-pattern"
+	BootstrapTokenAllowedCIDRsKey    = version.Program + "-allowed-cidrs"
+	BootstrapTokenRolesKey           = version.Program + "-roles"
+	BootstrapTokenJoinedNodesKey     = version.Program + "-joined-nodes"
+)
+
+const (
+	RoleServer = "server"
+	RoleAgent  = "agent"
 )
 
 // SetDefaults ensures that the default values are set on the token configuration.
@@ -36,6 +51,10 @@ func SetDefaults(clx *cli.Context, cfg *cmds.Token) error {
 		}
 	}
 
+	if err := validateRestrictions(cfg); err != nil {
+		return err
+	}
+
 	if clx.Args().Len() > 0 {
 		cfg.Token = clx.Args().Get(0)
 	}
@@ -50,3 +69,35 @@ func SetDefaults(clx *cli.Context, cfg *cmds.Token) error {
 
 	return nil
 }
+
+// validateRestrictions ensures that the join restrictions set on the token configuration are valid.
+func validateRestrictions(cfg *cmds.Token) error {
+	if cfg.MaxJoins < 0 {
+		return errors.New("invalid max-joins: must not be negative")
+	}
+
+	if _, err := path.Match(cfg.NodeNamePattern, ""); err != nil {
+		return fmt.Errorf("invalid node-name-pattern %q: %w", cfg.NodeNamePattern, err)
+	}
+
+	for _, cidr := range cfg.AllowedCIDRs.Value() {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid allowed-cidrs: %w", err)
+		}
+	}
+
+	// Bootstrap tokens are only authorized to access the agent routes on the supervisor, and cannot be used to
+	// retrieve the bootstrap data needed to join a server. Reject the server role, rather than creating a token
+	// with a role that it cannot be used for.
+	for _, role := range cfg.Roles.Value() {
+		switch role {
+		case RoleAgent:
+		case RoleServer:
+			return errors.New("invalid role: bootstrap tokens cannot be used to join servers; use the server token to join servers")
+		default:
+			return errors.New("invalid role: " + role)
+		}
+	}
+
+	return nil
+}
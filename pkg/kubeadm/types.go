@@ -33,6 +33,27 @@ type BootstrapToken struct {
 	// used for authentication
 	// +optional
 	Groups []string `json:"groups,omitempty"`
+
+	// The following fields are not part of the kubeadm bootstrap token; they are used
+	// to restrict which nodes may join the cluster using the token.
+
+	// MaxJoins limits the number of distinct nodes that may join using this token.
+	// A value of zero allows an unlimited number of nodes to join.
+	// +optional
+	MaxJoins int `json:"maxJoins,omitempty"`
+	// NodeNamePattern is a glob pattern that the names of joining nodes must match.
+	// +optional
+	NodeNamePattern string `json:"nodeNamePattern,omitempty"`
+	// AllowedCIDRs lists the address ranges from which nodes may join using this token.
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+	// Roles lists the node roles that may join using this token. Bootstrap tokens
+	// can only be used to join agents, so the only supported role is "agent".
+	// +optional
+	Roles []string `json:"roles,omitempty"`
+	// JoinedNodes lists the names of nodes that have joined using this token.
+	// +optional
+	JoinedNodes []string `json:"joinedNodes,omitempty"`
 }
 
 // BootstrapTokenString is a token of the format abcdef.abcdef0123456789 that is used
@@ -3,6 +3,7 @@ package kubeadm
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -93,6 +94,22 @@ func encodeTokenSecretData(token *BootstrapToken, now time.Time) map[string][]by
 	if len(token.Groups) > 0 {
 		data[bootstrapapi.BootstrapTokenExtraGroupsKey] = []byte(strings.Join(token.Groups, ","))
 	}
+
+	if token.MaxJoins > 0 {
+		data[BootstrapTokenMaxJoinsKey] = []byte(strconv.Itoa(token.MaxJoins))
+	}
+	if len(token.NodeNamePattern) > 0 {
+		data[BootstrapTokenNodeNamePatternKey] = []byte(token.NodeNamePattern)
+	}
+	if len(token.AllowedCIDRs) > 0 {
+		data[BootstrapTokenAllowedCIDRsKey] = []byte(strings.Join(token.AllowedCIDRs, ","))
+	}
+	if len(token.Roles) > 0 {
+		data[BootstrapTokenRolesKey] = []byte(strings.Join(token.Roles, ","))
+	}
+	if len(token.JoinedNodes) > 0 {
+		data[BootstrapTokenJoinedNodesKey] = []byte(strings.Join(token.JoinedNodes, ","))
+	}
 	return data
 }
 
@@ -164,11 +181,33 @@ func BootstrapTokenFromSecret(secret *v1.Secret) (*BootstrapToken, error) {
 		groups = g
 	}
 
+	// Get the join restrictions and joined nodes from the Secret
+	var maxJoins int
+	if secretMaxJoins := bootstrapsecretutil.GetData(secret, BootstrapTokenMaxJoinsKey); len(secretMaxJoins) > 0 {
+		maxJoins, err = strconv.Atoi(secretMaxJoins)
+		if err != nil {
+			return nil, pkgerrors.WithMessagef(err, "can't parse max joins of bootstrap token %q", secret.Name)
+		}
+	}
+
 	return &BootstrapToken{
-		Token:       bts,
-		Description: description,
-		Expires:     expires,
-		Usages:      usages,
-		Groups:      groups,
+		Token:           bts,
+		Description:     description,
+		Expires:         expires,
+		Usages:          usages,
+		Groups:          groups,
+		MaxJoins:        maxJoins,
+		NodeNamePattern: bootstrapsecretutil.GetData(secret, BootstrapTokenNodeNamePatternKey),
+		AllowedCIDRs:    splitOrNil(bootstrapsecretutil.GetData(secret, BootstrapTokenAllowedCIDRsKey)),
+		Roles:           splitOrNil(bootstrapsecretutil.GetData(secret, BootstrapTokenRolesKey)),
+		JoinedNodes:     splitOrNil(bootstrapsecretutil.GetData(secret, BootstrapTokenJoinedNodesKey)),
 	}, nil
 }
+
+// splitOrNil splits a comma-separated list, returning nil if the list is empty.
+func splitOrNil(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
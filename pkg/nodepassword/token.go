@@ -0,0 +1,147 @@
+package nodepassword
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/kubeadm"
+	"github.com/k3s-io/k3s/pkg/util"
+	pkgerrors "github.com/pkg/errors"
+	coreclient "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/util/retry"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+)
+
+// bootstrapTokenID returns the ID of the bootstrap token used to authenticate, if the user was
+// authenticated with a bootstrap token.
+func bootstrapTokenID(u user.Info) (string, bool) {
+	return strings.CutPrefix(u.GetName(), bootstrapapi.BootstrapUserPrefix)
+}
+
+// GetBootstrapTokenValidator returns a middleware function that enforces the join restrictions set on
+// bootstrap tokens. Bootstrap tokens are only authorized to access agent routes, so the restrictions are
+// checked on every request made with a token, not just on kubelet certificate requests. The requesting
+// node is identified by the node name header, which must be set. Nodes are not recorded as having joined
+// using the token until their node password has been verified, when requesting kubelet certificates.
+func GetBootstrapTokenValidator(control *config.Control) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if u, ok := request.UserFrom(req.Context()); ok {
+				if tokenID, isTokenAuth := bootstrapTokenID(u); isTokenAuth {
+					// Bootstrap token join restrictions cannot be enforced without the apiserver.
+					if control.Runtime.Core == nil {
+						util.SendError(util.ErrCoreNotReady, resp, req, http.StatusServiceUnavailable)
+						return
+					}
+					nodeName := strings.ToLower(req.Header.Get(mux.Vars(req)["program"] + "-Node-Name"))
+					if nodeName == "" {
+						util.SendError(errors.New("node name not set"), resp, req, http.StatusBadRequest)
+						return
+					}
+					secrets := control.Runtime.Core.Core().V1().Secret()
+					if errCode, err := verifyBootstrapToken(secrets, req, tokenID, nodeName); err != nil {
+						util.SendError(err, resp, req, errCode)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
+// verifyBootstrapToken checks that the node is allowed to join using the bootstrap token used to
+// authenticate the request. The token is read from the secret cache, and is not modified.
+func verifyBootstrapToken(secrets coreclient.SecretController, req *http.Request, tokenID, nodeName string) (int, error) {
+	addr := getRequestAddr(req)
+	_, bt, err := getBootstrapToken(secrets, tokenID, true)
+	if err != nil {
+		return http.StatusInternalServerError, pkgerrors.WithMessagef(err, "unable to verify bootstrap token %s", tokenID)
+	}
+	if err := bt.ValidateJoin(nodeName, kubeadm.RoleAgent, addr); err != nil {
+		return http.StatusForbidden, pkgerrors.WithMessagef(err, "bootstrap token %s may not be used by node %q from %s", tokenID, nodeName, addr)
+	}
+	return http.StatusOK, nil
+}
+
+// recordBootstrapTokenJoin records the node as having joined using the bootstrap token used to authenticate
+// the request, if any. This is called once the node password has been verified, so that only the names of
+// nodes that have requested kubelet certificates count against the token's join limit. The restrictions are
+// checked again before the token secret is updated, so that concurrent joins cannot exceed the limit.
+func recordBootstrapTokenJoin(secrets coreclient.SecretController, req *http.Request, node *nodeInfo) (int, error) {
+	tokenID, isTokenAuth := bootstrapTokenID(node.User)
+	if !isTokenAuth {
+		return http.StatusOK, nil
+	}
+
+	addr := getRequestAddr(req)
+	var joinErr error
+	cached := true
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Read from the cache first, but get the current secret from the apiserver after a conflict.
+		secret, bt, err := getBootstrapToken(secrets, tokenID, cached)
+		cached = false
+		if err != nil {
+			return err
+		}
+		if joinErr = bt.ValidateJoin(node.Name, kubeadm.RoleAgent, addr); joinErr != nil {
+			return nil
+		}
+		if !bt.AddJoinedNode(node.Name) {
+			return nil
+		}
+		secret = secret.DeepCopy()
+		secret.Data[kubeadm.BootstrapTokenJoinedNodesKey] = []byte(strings.Join(bt.JoinedNodes, ","))
+		_, err = secrets.Update(secret)
+		return err
+	})
+	if err != nil {
+		return http.StatusInternalServerError, pkgerrors.WithMessagef(err, "unable to record join for bootstrap token %s", tokenID)
+	}
+	if joinErr != nil {
+		return http.StatusForbidden, pkgerrors.WithMessagef(joinErr, "bootstrap token %s may not be used by node %q from %s", tokenID, node.Name, addr)
+	}
+	return http.StatusOK, nil
+}
+
+// getBootstrapToken returns the secret for the bootstrap token with the given ID, along with the token it holds.
+// If cached is true, the secret is read from the cache, falling back to the apiserver if it has not been cached yet.
+// Secrets read from the cache must not be modified.
+func getBootstrapToken(secrets coreclient.SecretController, tokenID string, cached bool) (*v1.Secret, *kubeadm.BootstrapToken, error) {
+	secretName := bootstraputil.BootstrapTokenSecretName(tokenID)
+	var secret *v1.Secret
+	var err error
+	if cached {
+		secret, err = secrets.Cache().Get(metav1.NamespaceSystem, secretName)
+	}
+	if !cached || apierrors.IsNotFound(err) {
+		secret, err = secrets.Get(metav1.NamespaceSystem, secretName, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	bt, err := kubeadm.BootstrapTokenFromSecret(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret, bt, nil
+}
+
+// getRequestAddr returns the source address of the request.
+func getRequestAddr(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,209 @@
+package nodepassword
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/kubeadm"
+	"github.com/k3s-io/k3s/tests/mock"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+)
+
+// newBootstrapTokenMock returns a core factory mock holding a bootstrap token with the given restrictions.
+// The returned counter is incremented each time the token secret is updated.
+func newBootstrapTokenMock(t *testing.T, bt *kubeadm.BootstrapToken) (*mock.CoreFactoryMock, *mock.SecretStore, *int) {
+	coreFactory := mock.NewCoreFactory(gomock.NewController(t))
+	v1Mock := coreFactory.CoreMock.V1Mock
+	secretStore := &mock.SecretStore{}
+	updates := 0
+	v1Mock.SecretMock.EXPECT().Cache().AnyTimes().Return(v1Mock.SecretCache)
+	v1Mock.SecretCache.EXPECT().Get(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(secretStore.Get)
+	v1Mock.SecretMock.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(namespace, name string, _ metav1.GetOptions) (*v1.Secret, error) {
+		return secretStore.Get(namespace, name)
+	})
+	v1Mock.SecretMock.EXPECT().Update(gomock.Any()).AnyTimes().DoAndReturn(func(secret *v1.Secret) (*v1.Secret, error) {
+		updates++
+		if err := secretStore.Delete(secret.Namespace, secret.Name, nil); err != nil {
+			return nil, err
+		}
+		return secretStore.Create(secret)
+	})
+
+	if _, err := secretStore.Create(kubeadm.BootstrapTokenToSecret(bt)); err != nil {
+		t.Fatal(err)
+	}
+	return coreFactory, secretStore, &updates
+}
+
+func Test_UnitBootstrapTokenJoin(t *testing.T) {
+	bts, err := kubeadm.NewBootstrapTokenString("abcdef.0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreFactory, secretStore, updates := newBootstrapTokenMock(t, &kubeadm.BootstrapToken{
+		Token:        bts,
+		MaxJoins:     1,
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		Roles:        []string{kubeadm.RoleAgent},
+	})
+	secrets := coreFactory.Core().V1().Secret()
+	tokenUser := &user.DefaultInfo{Name: bootstrapapi.BootstrapUserPrefix + bts.ID}
+
+	tests := []struct {
+		name        string
+		nodeName    string
+		remoteAddr  string
+		record      bool
+		wantCode    int
+		wantUpdates int
+	}{
+		{
+			name:       "Unjoined node",
+			nodeName:   "node-1",
+			remoteAddr: "10.0.0.1:12345",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Other unjoined node is not counted against the limit",
+			nodeName:   "node-2",
+			remoteAddr: "10.0.0.2:12345",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:        "First node joins",
+			nodeName:    "node-1",
+			remoteAddr:  "10.0.0.1:12345",
+			record:      true,
+			wantCode:    http.StatusOK,
+			wantUpdates: 1,
+		},
+		{
+			name:        "First node rejoins without updating the token",
+			nodeName:    "node-1",
+			remoteAddr:  "10.0.0.1:12345",
+			record:      true,
+			wantCode:    http.StatusOK,
+			wantUpdates: 1,
+		},
+		{
+			name:        "Second node joins over join limit",
+			nodeName:    "node-2",
+			remoteAddr:  "10.0.0.2:12345",
+			record:      true,
+			wantCode:    http.StatusForbidden,
+			wantUpdates: 1,
+		},
+		{
+			name:        "Second node over join limit",
+			nodeName:    "node-2",
+			remoteAddr:  "10.0.0.2:12345",
+			wantCode:    http.StatusForbidden,
+			wantUpdates: 1,
+		},
+		{
+			name:        "First node outside allowed CIDRs",
+			nodeName:    "node-1",
+			remoteAddr:  "192.168.1.1:12345",
+			wantCode:    http.StatusForbidden,
+			wantUpdates: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{RemoteAddr: tt.remoteAddr}
+			var code int
+			if tt.record {
+				code, err = recordBootstrapTokenJoin(secrets, req, &nodeInfo{Name: tt.nodeName, User: tokenUser})
+			} else {
+				code, err = verifyBootstrapToken(secrets, req, bts.ID, tt.nodeName)
+			}
+			if code != tt.wantCode {
+				t.Errorf("bootstrap token join = %d, %v, want %d", code, err, tt.wantCode)
+			}
+			if *updates != tt.wantUpdates {
+				t.Errorf("bootstrap token secret updated %d times, want %d", *updates, tt.wantUpdates)
+			}
+		})
+	}
+
+	secret, err := secretStore.Get(metav1.NamespaceSystem, "bootstrap-token-"+bts.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined := string(secret.Data[kubeadm.BootstrapTokenJoinedNodesKey]); joined != "node-1" {
+		t.Errorf("joined nodes = %q, want %q", joined, "node-1")
+	}
+}
+
+func Test_UnitGetBootstrapTokenValidator(t *testing.T) {
+	bts, err := kubeadm.NewBootstrapTokenString("abcdef.0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coreFactory, _, updates := newBootstrapTokenMock(t, &kubeadm.BootstrapToken{
+		Token:           bts,
+		NodeNamePattern: "worker-*",
+	})
+	control := &config.Control{Runtime: &config.ControlRuntime{Core: coreFactory}}
+
+	tests := []struct {
+		name     string
+		user     user.Info
+		nodeName string
+		wantCode int
+	}{
+		{
+			name:     "Bootstrap token with matching node name",
+			user:     &user.DefaultInfo{Name: bootstrapapi.BootstrapUserPrefix + bts.ID},
+			nodeName: "worker-1",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Bootstrap token with mismatched node name",
+			user:     &user.DefaultInfo{Name: bootstrapapi.BootstrapUserPrefix + bts.ID},
+			nodeName: "server-1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Bootstrap token without node name",
+			user:     &user.DefaultInfo{Name: bootstrapapi.BootstrapUserPrefix + bts.ID},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Node without node name",
+			user:     &user.DefaultInfo{Name: "system:node:server-1", Groups: []string{user.NodesGroup}},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Use(GetBootstrapTokenValidator(control))
+	router.Handle("/v1-{program}/config", http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1-k3s/config", nil)
+			req = req.WithContext(request.WithUser(req.Context(), tt.user))
+			if tt.nodeName != "" {
+				req.Header.Set("k3s-Node-Name", tt.nodeName)
+			}
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			if resp.Code != tt.wantCode {
+				t.Errorf("GetBootstrapTokenValidator() = %d %s, want %d", resp.Code, resp.Body.String(), tt.wantCode)
+			}
+		})
+	}
+
+	if *updates != 0 {
+		t.Errorf("bootstrap token secret updated %d times, want 0", *updates)
+	}
+}
@@ -87,11 +87,18 @@ func GetNodeAuthValidator(ctx context.Context, control *config.Control) NodeAuth
 		}
 
 		// verify that the node password secret matches, or create it if it does not
-		if err := Ensure(secretClient, node.Name, node.Password); err != nil {
-			// if the verification failed, reject the request
-			if errors.Is(err, ErrVerifyFailed) {
-				return "", http.StatusForbidden, err
-			}
+		ensureErr := Ensure(secretClient, node.Name, node.Password)
+		// if the verification failed, reject the request
+		if errors.Is(ensureErr, ErrVerifyFailed) {
+			return "", http.StatusForbidden, ensureErr
+		}
+
+		// record that the node has joined using the bootstrap token, if one was used
+		if errCode, err := recordBootstrapTokenJoin(secretClient, req, node); err != nil {
+			return "", errCode, err
+		}
+
+		if ensureErr != nil {
 			// If verification failed due to an error creating the node password secret, allow
 			// the request, but retry verification until the outage is resolved.  This behavior
 			// allows nodes to join the cluster during outages caused by validating webhooks
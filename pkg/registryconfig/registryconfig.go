@@ -0,0 +1,85 @@
+package registryconfig
+
+import (
+	"maps"
+
+	"github.com/k3s-io/k3s/pkg/version"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rancher/wharfie/pkg/registries"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	// SecretName is the name of the secret in the kube-system namespace that contains
+	// the cluster registry configuration.
+	SecretName = version.Program + "-registries"
+)
+
+// SecretKey is the key within the secret that contains the cluster registry configuration.
+const SecretKey = "registries.yaml"
+
+// Config is the cluster registry configuration distributed to all nodes by the servers.
+// The mirrors and configs use the same format as the registries.yaml file on each node.
+// Overrides are applied in order on top of the base configuration, on nodes whose labels
+// match the override's node selector.
+type Config struct {
+	Registry  `yaml:",inline"`
+	Overrides []Override `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// Override is a set of mirrors and configs that apply only to nodes matching a label selector.
+type Override struct {
+	NodeSelector string `json:"nodeSelector" yaml:"nodeSelector"`
+	Registry     `yaml:",inline"`
+}
+
+// Parse parses and validates the cluster registry configuration.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, pkgerrors.WithMessage(err, "failed to parse cluster registry configuration")
+	}
+	for i, override := range config.Overrides {
+		if _, err := labels.Parse(override.NodeSelector); err != nil {
+			return nil, pkgerrors.WithMessagef(err, "invalid node selector for override %d", i)
+		}
+	}
+	return config, nil
+}
+
+// ForNode returns the registry configuration for a node with the provided labels. The base
+// configuration is merged with each override whose node selector matches the labels.
+// An empty node selector matches all nodes.
+func (c *Config) ForNode(nodeLabels map[string]string) (*Registry, error) {
+	registry := Merge(nil, &c.Registry)
+	for i, override := range c.Overrides {
+		selector, err := labels.Parse(override.NodeSelector)
+		if err != nil {
+			return nil, pkgerrors.WithMessagef(err, "invalid node selector for override %d", i)
+		}
+		if selector.Matches(labels.Set(nodeLabels)) {
+			registry = Merge(registry, &override.Registry)
+		}
+	}
+	return registry, nil
+}
+
+// Merge returns a new registry configuration containing the mirrors, configs, and auths from both the base
+// and override configurations. Entries are replaced as a whole: if the same registry host is present in
+// both, the entry from the override is used and the entry from the base is discarded.
+func Merge(base, override *Registry) *Registry {
+	registry := &Registry{
+		Mirrors: map[string]Mirror{},
+		Configs: map[string]registries.RegistryConfig{},
+		Auths:   map[string]registries.AuthConfig{},
+	}
+	for _, r := range []*Registry{base, override} {
+		if r != nil {
+			maps.Copy(registry.Mirrors, r.Mirrors)
+			maps.Copy(registry.Configs, r.Configs)
+			maps.Copy(registry.Auths, r.Auths)
+		}
+	}
+	return registry
+}
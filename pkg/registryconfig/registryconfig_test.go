@@ -0,0 +1,214 @@
+package registryconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rancher/wharfie/pkg/registries"
+)
+
+const testConfig = `
+mirrors:
+  docker.io:
+    endpoint:
+    - https://mirror.example.com
+  registry.k8s.io:
+    endpoint:
+    - https://mirror.example.com
+configs:
+  mirror.example.com:
+    auth:
+      username: cluster
+      password: cluster
+overrides:
+- nodeSelector: topology.kubernetes.io/region=eu-west
+  mirrors:
+    docker.io:
+      endpoint:
+      - https://eu-west.example.com
+- nodeSelector: "!node-role.kubernetes.io/control-plane"
+  configs:
+    mirror.example.com:
+      auth:
+        username: agent
+        password: agent
+`
+
+func Test_UnitParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Overrides",
+			data: testConfig,
+			want: 2,
+		},
+		{
+			name:    "Invalid node selector",
+			data:    "overrides:\n- nodeSelector: 'foo in bar'\n",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid YAML",
+			data:    "mirrors: [",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(got.Overrides) != tt.want {
+				t.Errorf("Parse() returned %d overrides, want %d", len(got.Overrides), tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitForNode(t *testing.T) {
+	config, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		labels       map[string]string
+		wantDocker   string
+		wantUsername string
+	}{
+		{
+			name:         "Control-plane node without region",
+			labels:       map[string]string{"node-role.kubernetes.io/control-plane": "true"},
+			wantDocker:   "https://mirror.example.com",
+			wantUsername: "cluster",
+		},
+		{
+			name:         "Agent node without region",
+			labels:       map[string]string{},
+			wantDocker:   "https://mirror.example.com",
+			wantUsername: "agent",
+		},
+		{
+			name:         "Agent node in region",
+			labels:       map[string]string{"topology.kubernetes.io/region": "eu-west"},
+			wantDocker:   "https://eu-west.example.com",
+			wantUsername: "agent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.ForNode(tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if endpoints := got.Mirrors["docker.io"].Endpoints; !reflect.DeepEqual(endpoints, NewEndpoints(tt.wantDocker)) {
+				t.Errorf("ForNode() docker.io endpoints = %v, want %v", endpoints, tt.wantDocker)
+			}
+			if _, ok := got.Mirrors["registry.k8s.io"]; !ok {
+				t.Errorf("ForNode() did not include base mirror for registry.k8s.io")
+			}
+			if username := got.Configs["mirror.example.com"].Auth.Username; username != tt.wantUsername {
+				t.Errorf("ForNode() mirror.example.com username = %s, want %s", username, tt.wantUsername)
+			}
+		})
+	}
+}
+
+func Test_UnitMerge(t *testing.T) {
+	base := &Registry{
+		Mirrors: map[string]Mirror{
+			"docker.io":       {Endpoints: NewEndpoints("https://cluster.example.com")},
+			"registry.k8s.io": {Endpoints: NewEndpoints("https://cluster.example.com")},
+		},
+	}
+	local := &Registry{
+		Mirrors: map[string]Mirror{
+			"docker.io": {Endpoints: NewEndpoints("https://local.example.com")},
+		},
+		Configs: map[string]registries.RegistryConfig{
+			"local.example.com": {TLS: &registries.TLSConfig{InsecureSkipVerify: true}},
+		},
+	}
+
+	got := Merge(base, local)
+	want := &Registry{
+		Mirrors: map[string]Mirror{
+			"docker.io":       {Endpoints: NewEndpoints("https://local.example.com")},
+			"registry.k8s.io": {Endpoints: NewEndpoints("https://cluster.example.com")},
+		},
+		Configs: map[string]registries.RegistryConfig{
+			"local.example.com": {TLS: &registries.TLSConfig{InsecureSkipVerify: true}},
+		},
+		Auths: map[string]registries.AuthConfig{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %#v, want %#v", got, want)
+	}
+	if len(base.Mirrors) != 2 || base.Mirrors["docker.io"].Endpoints[0].URL != "https://cluster.example.com" {
+		t.Errorf("Merge() modified the base registry: %#v", base)
+	}
+}
+
+func Test_UnitEndpointUnmarshal(t *testing.T) {
+	want := Mirror{
+		Endpoints: []Endpoint{
+			{URL: "https://mirror.example.com"},
+			{
+				URL:      "https://harbor.example.com",
+				Rewrites: map[string]string{"^(.*)$": "proxy/$1"},
+				Auth:     &registries.AuthConfig{Username: "user", Password: "pass"},
+				TLS:      &registries.TLSConfig{InsecureSkipVerify: true},
+			},
+		},
+	}
+	yamlData := `
+mirrors:
+  docker.io:
+    endpoint:
+    - https://mirror.example.com
+    - url: https://harbor.example.com
+      rewrite:
+        "^(.*)$": "proxy/$1"
+      auth:
+        username: user
+        password: pass
+      tls:
+        insecure_skip_verify: true
+`
+	config, err := Parse([]byte(yamlData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Mirrors["docker.io"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() docker.io mirror = %#v, want %#v", got, want)
+	}
+
+	// The configuration is sent from the servers to the agents as JSON, and must round-trip.
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Mirrors["docker.io"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("json.Unmarshal() docker.io mirror = %#v, want %#v", got, want)
+	}
+	if err := json.Unmarshal([]byte(`{"mirrors":{"docker.io":{"endpoint":["https://mirror.example.com"]}}}`), config); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Mirrors["docker.io"].Endpoints; !reflect.DeepEqual(got, NewEndpoints("https://mirror.example.com")) {
+		t.Errorf("json.Unmarshal() docker.io endpoints = %#v", got)
+	}
+}
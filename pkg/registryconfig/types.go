@@ -0,0 +1,100 @@
+package registryconfig
+
+import (
+	"encoding/json"
+	"os"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rancher/wharfie/pkg/registries"
+	"gopkg.in/yaml.v2"
+)
+
+// Registry is the registry configuration read from registries.yaml. This is compatible with the
+// registries.yaml format defined by rancher/wharfie, but additionally allows each mirror endpoint
+// to be configured with its own rewrites, credentials, and TLS settings.
+type Registry struct {
+	// Mirrors are namespace to mirror mapping for all namespaces.
+	Mirrors map[string]Mirror `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Configs are configs for each registry. The key is the FQDN or IP of the registry.
+	Configs map[string]registries.RegistryConfig `json:"configs,omitempty" yaml:"configs,omitempty"`
+	// Auths are registry endpoint to auth config mapping.
+	// DEPRECATED: Use Configs instead.
+	Auths map[string]registries.AuthConfig `json:"auths,omitempty" yaml:"auths,omitempty"`
+}
+
+// Mirror contains the endpoints for a mirrored registry, and the rewrites that are used
+// for any endpoint that does not specify its own rewrites.
+type Mirror struct {
+	Endpoints []Endpoint        `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Rewrites  map[string]string `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+}
+
+// Endpoint is a mirror endpoint. In registries.yaml, an endpoint may be either a URL string,
+// or an object containing the URL and endpoint-specific settings. Rewrites replace the mirror's
+// rewrites for this endpoint. Auth and TLS replace the settings from the registry config for the
+// endpoint's host; auth is sent to the endpoint as a static Authorization header.
+type Endpoint struct {
+	URL      string                 `json:"url" yaml:"url"`
+	Rewrites map[string]string      `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+	Auth     *registries.AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"`
+	TLS      *registries.TLSConfig  `json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+// endpoint is used to unmarshal the object form of an endpoint without recursing into the custom unmarshallers.
+type endpoint Endpoint
+
+// UnmarshalYAML unmarshals an endpoint from either a URL string, or an object.
+func (e *Endpoint) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var url string
+	if err := unmarshal(&url); err == nil {
+		*e = Endpoint{URL: url}
+		return nil
+	}
+	ep := endpoint{}
+	if err := unmarshal(&ep); err != nil {
+		return err
+	}
+	*e = Endpoint(ep)
+	return nil
+}
+
+// UnmarshalJSON unmarshals an endpoint from either a URL string, or an object.
+func (e *Endpoint) UnmarshalJSON(b []byte) error {
+	var url string
+	if err := json.Unmarshal(b, &url); err == nil {
+		*e = Endpoint{URL: url}
+		return nil
+	}
+	ep := endpoint{}
+	if err := json.Unmarshal(b, &ep); err != nil {
+		return err
+	}
+	*e = Endpoint(ep)
+	return nil
+}
+
+// NewEndpoints returns a list of endpoints with the provided URLs, and no endpoint-specific settings.
+func NewEndpoints(urls ...string) []Endpoint {
+	endpoints := make([]Endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = Endpoint{URL: url}
+	}
+	return endpoints
+}
+
+// ReadFile reads the registry configuration from a registries.yaml file.
+// It is not an error if the file does not exist; an empty configuration is returned.
+func ReadFile(path string) (*Registry, error) {
+	registry := &Registry{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return registry, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, registry); err != nil {
+		return nil, pkgerrors.WithMessagef(err, "failed to parse %s", path)
+	}
+	return registry, nil
+}
@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/k3s-io/k3s/pkg/daemons/config"
@@ -33,8 +35,11 @@ const (
 	EncryptionReencryptRequest  string  = "reencrypt_request"
 	EncryptionReencryptActive   string  = "reencrypt_active"
 	EncryptionReencryptFinished string  = "reencrypt_finished"
+	EncryptionMigrate           string  = "migrate"
 	AESCBCProvider              string  = "aescbc"
+	AESGCMProvider              string  = "aesgcm"
 	SecretBoxProvider           string  = "secretbox"
+	KMSProvider                 string  = "kms"
 	KeySize                     int     = 32
 	SecretListPageSize          int64   = 20
 	SecretQPS                   float32 = 200
@@ -44,24 +49,94 @@ const (
 	SecretsUpdateCompleteEvent  string  = "SecretsUpdateComplete"
 )
 
-// We support 3 key/provider types: AESCBC, SecretBox, and Identity. The Identity provider is
+// We support 5 key/provider types: AESCBC, AESGCM, SecretBox, KMS v2, and Identity. The Identity provider is
 // represented just as a boolean, which is used to determine if encryption is enabled/disabled.
+// KMS providers do not have keys of their own; the key is held by the KMS plugin.
+// Resources lists the resources that are encrypted with the keys; if empty, only secrets are encrypted.
 type EncryptionKeys struct {
 	AESCBCKeys []apiserverconfigv1.Key
+	AESGCMKeys []apiserverconfigv1.Key
 	SBKeys     []apiserverconfigv1.Key
+	KMS        []apiserverconfigv1.KMSConfiguration
 	Identity   bool
+	Resources  []string
 }
 
 var EncryptionHashAnnotation = version.Program + ".io/encryption-config-hash"
 
-func GetEncryptionProviders(runtime *config.ControlRuntime) ([]apiserverconfigv1.ProviderConfiguration, error) {
+// DefaultResources are the resources that are encrypted if no other resources are configured.
+var DefaultResources = []string{"secrets"}
+
+// NormalizeResources returns the list of resources to encrypt, without duplicates.
+// Secrets are always encrypted, and are placed first.
+func NormalizeResources(resources []string) []string {
+	normalized := slices.Clone(DefaultResources)
+	for _, r := range resources {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if r != "" && !slices.Contains(normalized, r) {
+			normalized = append(normalized, r)
+		}
+	}
+	return normalized
+}
+
+// GetEncryptionResources returns the list of resources covered by the current encryption configuration.
+func GetEncryptionResources(runtime *config.ControlRuntime) ([]string, error) {
+	curEncryption, err := getEncryptionConfiguration(runtime)
+	if err != nil {
+		return nil, err
+	}
+	resources := []string{}
+	for _, rc := range curEncryption.Resources {
+		for _, r := range rc.Resources {
+			if !slices.Contains(resources, r) {
+				resources = append(resources, r)
+			}
+		}
+	}
+	return resources, nil
+}
+
+// GetActiveProvider returns the type of the first provider that encrypts data. If encryption is
+// disabled, this is the provider that is used once encryption is enabled.
+func GetActiveProvider(providers []apiserverconfigv1.ProviderConfiguration) string {
+	for _, p := range providers {
+		switch {
+		case p.AESCBC != nil:
+			return AESCBCProvider
+		case p.AESGCM != nil:
+			return AESGCMProvider
+		case p.Secretbox != nil:
+			return SecretBoxProvider
+		case p.KMS != nil:
+			return KMSProvider
+		}
+	}
+	return ""
+}
+
+func getEncryptionConfiguration(runtime *config.ControlRuntime) (*apiserverconfigv1.EncryptionConfiguration, error) {
 	curEncryptionByte, err := os.ReadFile(runtime.EncryptionConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	curEncryption := apiserverconfigv1.EncryptionConfiguration{}
-	if err = json.Unmarshal(curEncryptionByte, &curEncryption); err != nil {
+	curEncryption := &apiserverconfigv1.EncryptionConfiguration{}
+	if err = json.Unmarshal(curEncryptionByte, curEncryption); err != nil {
+		return nil, err
+	}
+	if len(curEncryption.Resources) == 0 {
+		return nil, fmt.Errorf("no resources found in secrets encryption configuration")
+	}
+	return curEncryption, nil
+}
+
+// GetEncryptionProviders returns the providers from the current encryption configuration. The configuration
+// written by WriteEncryptionConfig uses the same providers for all resources, so the providers for the first
+// set of resources are returned.
+func GetEncryptionProviders(runtime *config.ControlRuntime) ([]apiserverconfigv1.ProviderConfiguration, error) {
+	curEncryption, err := getEncryptionConfiguration(runtime)
+	if err != nil {
 		return nil, err
 	}
 	return curEncryption.Resources[0].Providers, nil
@@ -75,10 +150,6 @@ func GetEncryptionKeys(runtime *config.ControlRuntime) (*EncryptionKeys, error)
 	if err != nil {
 		return nil, err
 	}
-	if len(providers) > 3 {
-		return nil, fmt.Errorf("more than 3 providers (%d) found in secrets encryption", len(providers))
-	}
-
 	for _, p := range providers {
 		// Since identity doesn't have keys, we make up a fake key to represent it, so we can
 		// know that encryption is enabled/disabled in the request.
@@ -88,13 +159,25 @@ func GetEncryptionKeys(runtime *config.ControlRuntime) (*EncryptionKeys, error)
 		if p.AESCBC != nil {
 			currentKeys.AESCBCKeys = append(currentKeys.AESCBCKeys, p.AESCBC.Keys...)
 		}
+		if p.AESGCM != nil {
+			currentKeys.AESGCMKeys = append(currentKeys.AESGCMKeys, p.AESGCM.Keys...)
+		}
 		if p.Secretbox != nil {
 			currentKeys.SBKeys = append(currentKeys.SBKeys, p.Secretbox.Keys...)
 		}
-		if p.AESGCM != nil || p.KMS != nil {
+		if p.KMS != nil && p.KMS.APIVersion == KMSAPIVersion {
+			currentKeys.KMS = append(currentKeys.KMS, *p.KMS)
+		} else if p.KMS != nil {
 			return nil, fmt.Errorf("unsupported encryption keys found")
 		}
 	}
+	// KMS providers are in addition to the aescbc, aesgcm, secretbox, and identity providers
+	if len(providers)-len(currentKeys.KMS) > 4 {
+		return nil, fmt.Errorf("more than 4 providers (%d) found in secrets encryption", len(providers))
+	}
+	if currentKeys.Resources, err = GetEncryptionResources(runtime); err != nil {
+		return nil, err
+	}
 	return currentKeys, nil
 }
 
@@ -104,50 +187,60 @@ func WriteEncryptionConfig(runtime *config.ControlRuntime, keys *EncryptionKeys,
 
 	var providers []apiserverconfigv1.ProviderConfiguration
 	var primary apiserverconfigv1.ProviderConfiguration
-	var secondary *apiserverconfigv1.ProviderConfiguration
-	switch provider {
-	case AESCBCProvider:
-		primary = apiserverconfigv1.ProviderConfiguration{
+	var secondary []apiserverconfigv1.ProviderConfiguration
+	// Provider configurations for each type, in the order that they are placed after the primary provider.
+	// Only the KMS type may have more than one provider configuration.
+	byType := map[string][]apiserverconfigv1.ProviderConfiguration{}
+	if len(keys.AESCBCKeys) > 0 || provider == AESCBCProvider {
+		byType[AESCBCProvider] = []apiserverconfigv1.ProviderConfiguration{{
 			AESCBC: &apiserverconfigv1.AESConfiguration{
 				Keys: keys.AESCBCKeys,
 			},
-		}
-		if len(keys.SBKeys) > 0 {
-			secondary = &apiserverconfigv1.ProviderConfiguration{
-				Secretbox: &apiserverconfigv1.SecretboxConfiguration{
-					Keys: keys.SBKeys,
-				},
-			}
-		}
-	case SecretBoxProvider:
-		primary = apiserverconfigv1.ProviderConfiguration{
+		}}
+	}
+	if len(keys.AESGCMKeys) > 0 || provider == AESGCMProvider {
+		byType[AESGCMProvider] = []apiserverconfigv1.ProviderConfiguration{{
+			AESGCM: &apiserverconfigv1.AESConfiguration{
+				Keys: keys.AESGCMKeys,
+			},
+		}}
+	}
+	if len(keys.SBKeys) > 0 || provider == SecretBoxProvider {
+		byType[SecretBoxProvider] = []apiserverconfigv1.ProviderConfiguration{{
 			Secretbox: &apiserverconfigv1.SecretboxConfiguration{
 				Keys: keys.SBKeys,
 			},
+		}}
+	}
+	for _, k := range keys.KMS {
+		byType[KMSProvider] = append(byType[KMSProvider], apiserverconfigv1.ProviderConfiguration{
+			KMS: &k,
+		})
+	}
+
+	switch provider {
+	case AESCBCProvider, AESGCMProvider, SecretBoxProvider:
+	case KMSProvider:
+		if len(keys.KMS) == 0 {
+			return fmt.Errorf("no KMS provider configuration found")
 		}
-		if len(keys.AESCBCKeys) > 0 {
-			secondary = &apiserverconfigv1.ProviderConfiguration{
-				AESCBC: &apiserverconfigv1.AESConfiguration{
-					Keys: keys.AESCBCKeys,
-				},
-			}
+	default:
+		return fmt.Errorf("unsupported secrets-encryption provider %s", provider)
+	}
+	primary = byType[provider][0]
+	secondary = append(secondary, byType[provider][1:]...)
+	for _, typ := range []string{AESCBCProvider, AESGCMProvider, SecretBoxProvider, KMSProvider} {
+		if typ != provider {
+			secondary = append(secondary, byType[typ]...)
 		}
 	}
 	identity := apiserverconfigv1.ProviderConfiguration{
 		Identity: &apiserverconfigv1.IdentityConfiguration{},
 	}
 	// Placing the identity provider first disables encryption
-	if enable && secondary != nil {
-		providers = []apiserverconfigv1.ProviderConfiguration{
-			primary,
-			*secondary,
-			identity,
-		}
-	} else if enable {
-		providers = []apiserverconfigv1.ProviderConfiguration{
-			primary,
-			identity,
-		}
+	if enable {
+		providers = append([]apiserverconfigv1.ProviderConfiguration{primary}, secondary...)
+		providers = append(providers, identity)
 	} else {
 		providers = []apiserverconfigv1.ProviderConfiguration{
 			identity,
@@ -162,7 +255,7 @@ func WriteEncryptionConfig(runtime *config.ControlRuntime, keys *EncryptionKeys,
 		},
 		Resources: []apiserverconfigv1.ResourceConfiguration{
 			{
-				Resources: []string{"secrets"},
+				Resources: NormalizeResources(keys.Resources),
 				Providers: providers,
 			},
 		},
@@ -174,13 +267,14 @@ func WriteEncryptionConfig(runtime *config.ControlRuntime, keys *EncryptionKeys,
 	return util.AtomicWrite(runtime.EncryptionConfig, jsonfile, 0600)
 }
 
-func GenEncryptionConfigHash(runtime *config.ControlRuntime) (string, error) {
+// GenEncryptionConfigHash generates a sha256 hash from the current encryption configuration,
+// and the current key IDs of any KMS providers.
+func GenEncryptionConfigHash(ctx context.Context, runtime *config.ControlRuntime) (string, error) {
 	curEncryptionByte, err := os.ReadFile(runtime.EncryptionConfig)
 	if err != nil {
 		return "", err
 	}
-	encryptionConfigHash := sha256.Sum256(curEncryptionByte)
-	return hex.EncodeToString(encryptionConfigHash[:]), nil
+	return hashEncryptionConfig(ctx, curEncryptionByte)
 }
 
 // GenReencryptHash generates a sha256 hash from the existing secrets keys and
@@ -188,13 +282,14 @@ func GenEncryptionConfigHash(runtime *config.ControlRuntime) (string, error) {
 func GenReencryptHash(runtime *config.ControlRuntime, keyName string) (string, error) {
 
 	// To retain compatibility with the older encryption hash format,
-	// we contruct the hash as: aescbc + secretbox + identity + newkey
+	// we contruct the hash as: aescbc + secretbox + aesgcm + identity + newkey
 	currentKeys, err := GetEncryptionKeys(runtime)
 	if err != nil {
 		return "", err
 	}
 	keys := currentKeys.AESCBCKeys
 	keys = append(keys, currentKeys.SBKeys...)
+	keys = append(keys, currentKeys.AESGCMKeys...)
 	if currentKeys.Identity {
 		keys = append(keys, apiserverconfigv1.Key{
 			Name:   "identity",
@@ -232,8 +327,8 @@ func BootstrapEncryptionHashAnnotation(node *corev1.Node, runtime *config.Contro
 
 // WriteEncryptionHashAnnotation writes the encryption hash to the node annotation and optionally to a file.
 // The file is used to track the last stage of the reencryption process.
-func WriteEncryptionHashAnnotation(runtime *config.ControlRuntime, node *corev1.Node, skipFile bool, stage string) error {
-	encryptionConfigHash, err := GenEncryptionConfigHash(runtime)
+func WriteEncryptionHashAnnotation(ctx context.Context, runtime *config.ControlRuntime, node *corev1.Node, skipFile bool, stage string) error {
+	encryptionConfigHash, err := GenEncryptionConfigHash(ctx, runtime)
 	if err != nil {
 		return err
 	}
@@ -253,12 +348,11 @@ func WriteEncryptionHashAnnotation(runtime *config.ControlRuntime, node *corev1.
 }
 
 // WaitForEncryptionConfigReload watches the metrics API, polling the latest time the encryption config was reloaded.
-func WaitForEncryptionConfigReload(runtime *config.ControlRuntime, reloadSuccesses, reloadTime int64) error {
+func WaitForEncryptionConfigReload(ctx context.Context, runtime *config.ControlRuntime, reloadSuccesses, reloadTime int64) error {
 	var lastFailure string
 
-	ctx := context.Background()
 	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, 120*time.Second, true, func(ctx context.Context) (bool, error) {
-		newReloadTime, newReloadSuccess, err := GetEncryptionConfigMetrics(runtime, false)
+		newReloadTime, newReloadSuccess, err := GetEncryptionConfigMetrics(ctx, runtime, false)
 		if err != nil {
 			return true, err
 		}
@@ -280,7 +374,7 @@ func WaitForEncryptionConfigReload(runtime *config.ControlRuntime, reloadSuccess
 
 // GetEncryptionConfigMetrics fetches the metrics API and returns the last time the encryption config was reloaded
 // and the number of times it has been reloaded.
-func GetEncryptionConfigMetrics(runtime *config.ControlRuntime, initialMetrics bool) (int64, int64, error) {
+func GetEncryptionConfigMetrics(ctx context.Context, runtime *config.ControlRuntime, initialMetrics bool) (int64, int64, error) {
 	var unixUpdateTime int64
 	var reloadSuccessCounter int64
 	var lastFailure string
@@ -297,7 +391,6 @@ func GetEncryptionConfigMetrics(runtime *config.ControlRuntime, initialMetrics b
 
 	// This is wrapped in a poller because on startup no metrics exist. Its only after the encryption config
 	// is modified and the first reload occurs that the metrics are available.
-	ctx := context.Background()
 	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, 120*time.Second, true, func(ctx context.Context) (bool, error) {
 		data, err := restClient.Get().AbsPath("/metrics").DoRaw(ctx)
 		if err != nil {
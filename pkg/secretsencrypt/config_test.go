@@ -0,0 +1,151 @@
+package secretsencrypt
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+)
+
+// providerTypes returns the type of each provider, for comparison in tests.
+func providerTypes(providers []apiserverconfigv1.ProviderConfiguration) []string {
+	types := []string{}
+	for _, p := range providers {
+		switch {
+		case p.AESCBC != nil:
+			types = append(types, AESCBCProvider)
+		case p.AESGCM != nil:
+			types = append(types, AESGCMProvider)
+		case p.Secretbox != nil:
+			types = append(types, SecretBoxProvider)
+		case p.KMS != nil:
+			types = append(types, KMSProvider+":"+p.KMS.Name)
+		case p.Identity != nil:
+			types = append(types, "identity")
+		}
+	}
+	return types
+}
+
+func Test_UnitWriteEncryptionConfig(t *testing.T) {
+	aescbcKeys := []apiserverconfigv1.Key{{Name: "aescbckey", Secret: "c2VjcmV0"}}
+	aesgcmKeys := []apiserverconfigv1.Key{{Name: "aesgcmkey-2", Secret: "c2VjcmV0"}, {Name: "aesgcmkey-1", Secret: "c2VjcmV0"}}
+	sbKeys := []apiserverconfigv1.Key{{Name: "secretboxkey", Secret: "c2VjcmV0"}}
+	kms := []apiserverconfigv1.KMSConfiguration{NewKMSConfiguration("kms-1", "unix:///kms-1.sock"), NewKMSConfiguration("kms-2", "unix:///kms-2.sock")}
+
+	tests := []struct {
+		name     string
+		keys     *EncryptionKeys
+		provider string
+		enable   bool
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "AES-CBC only",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys},
+			provider: AESCBCProvider,
+			enable:   true,
+			want:     []string{AESCBCProvider, "identity"},
+		},
+		{
+			name:     "AES-GCM with other keys",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys, AESGCMKeys: aesgcmKeys, SBKeys: sbKeys, Resources: []string{"secrets", "configmaps", "*.example.com"}},
+			provider: AESGCMProvider,
+			enable:   true,
+			want:     []string{AESGCMProvider, AESCBCProvider, SecretBoxProvider, "identity"},
+		},
+		{
+			name:     "Secretbox with KMS",
+			keys:     &EncryptionKeys{SBKeys: sbKeys, KMS: kms[:1]},
+			provider: SecretBoxProvider,
+			enable:   true,
+			want:     []string{SecretBoxProvider, KMSProvider + ":kms-1", "identity"},
+		},
+		{
+			name:     "KMS with other keys",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys, AESGCMKeys: aesgcmKeys, KMS: kms},
+			provider: KMSProvider,
+			enable:   true,
+			want:     []string{KMSProvider + ":kms-1", KMSProvider + ":kms-2", AESCBCProvider, AESGCMProvider, "identity"},
+		},
+		{
+			name:     "AES-GCM disabled",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys, AESGCMKeys: aesgcmKeys},
+			provider: AESGCMProvider,
+			enable:   false,
+			want:     []string{"identity", AESGCMProvider},
+		},
+		{
+			name:     "KMS without configuration",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys},
+			provider: KMSProvider,
+			enable:   true,
+			wantErr:  true,
+		},
+		{
+			name:     "Unsupported provider",
+			keys:     &EncryptionKeys{AESCBCKeys: aescbcKeys},
+			provider: "aesctr",
+			enable:   true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime := &config.ControlRuntime{}
+			runtime.EncryptionConfig = filepath.Join(t.TempDir(), "encryption-config.json")
+
+			err := WriteEncryptionConfig(runtime, tt.keys, tt.provider, tt.enable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteEncryptionConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			providers, err := GetEncryptionProviders(runtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := providerTypes(providers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WriteEncryptionConfig() wrote providers %v, want %v", got, tt.want)
+			}
+
+			keys, err := GetEncryptionKeys(runtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys.AESGCMKeys, tt.keys.AESGCMKeys) {
+				t.Errorf("GetEncryptionKeys() AES-GCM keys = %v, want %v", keys.AESGCMKeys, tt.keys.AESGCMKeys)
+			}
+			if len(keys.KMS) != len(tt.keys.KMS) {
+				t.Errorf("GetEncryptionKeys() KMS = %v, want %v", keys.KMS, tt.keys.KMS)
+			}
+			if want := NormalizeResources(tt.keys.Resources); !reflect.DeepEqual(keys.Resources, want) {
+				t.Errorf("GetEncryptionKeys() resources = %v, want %v", keys.Resources, want)
+			}
+		})
+	}
+}
+
+func Test_UnitNormalizeResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		want      []string
+	}{
+		{name: "Empty", resources: nil, want: []string{"secrets"}},
+		{name: "Secrets added", resources: []string{"configmaps"}, want: []string{"secrets", "configmaps"}},
+		{name: "Duplicates removed", resources: []string{" ConfigMaps", "secrets", "configmaps", "", "widgets.example.com"}, want: []string{"secrets", "configmaps", "widgets.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeResources(tt.resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package secretsencrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k3s-io/api/pkg/generated/clientset/versioned/scheme"
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/util"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// KMSAPIVersion is the only supported KMS plugin API version.
+	KMSAPIVersion string = "v2"
+	// KMSTimeout is the timeout for calls to the KMS plugin, both by the apiserver and by the supervisor.
+	KMSTimeout time.Duration = 3 * time.Second
+	// KMSKeyIDPollInterval is the interval at which the apiserver checks the status of the KMS plugin,
+	// and picks up a new key ID after the key is rotated by the plugin.
+	KMSKeyIDPollInterval time.Duration = time.Minute
+	// KMSKeyIDWaitTimeout is how long to wait for the apiserver to pick up a new key ID. This allows for
+	// two status polls by the apiserver, in case a poll is in progress when the key is rotated.
+	KMSKeyIDWaitTimeout time.Duration = 2*KMSKeyIDPollInterval + 30*time.Second
+
+	// kmsKeyIDStatusMetric records the hash of each key ID returned to the apiserver by a KMS plugin's status call.
+	kmsKeyIDStatusMetric = "apiserver_envelope_encryption_key_id_hash_status_last_timestamp_seconds"
+)
+
+// ErrKMSUnavailable is returned when the encryption configuration cannot be hashed because a KMS plugin is
+// unreachable or unhealthy. The operation may be retried once the plugin is available.
+var ErrKMSUnavailable = errors.New("KMS plugin is unavailable; retry once the plugin is reachable and healthy")
+
+// NewKMSConfiguration returns a KMS v2 provider configuration for the plugin listening on the given endpoint.
+func NewKMSConfiguration(name, endpoint string) apiserverconfigv1.KMSConfiguration {
+	return apiserverconfigv1.KMSConfiguration{
+		APIVersion: KMSAPIVersion,
+		Name:       name,
+		Endpoint:   endpoint,
+		Timeout:    &metav1.Duration{Duration: KMSTimeout},
+	}
+}
+
+// GetKMSKeyID returns the ID of the key currently used by the KMS plugin to encrypt data.
+// An error is returned if the plugin is unreachable or unhealthy.
+func GetKMSKeyID(ctx context.Context, kms apiserverconfigv1.KMSConfiguration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, KMSTimeout)
+	defer cancel()
+
+	service, err := kmsv2.NewGRPCService(ctx, kms.Endpoint, kms.Name, KMSTimeout)
+	if err != nil {
+		return "", err
+	}
+	status, err := service.Status(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get status of KMS plugin %s: %w", kms.Name, err)
+	}
+	if status.Healthz != "ok" {
+		return "", fmt.Errorf("KMS plugin %s is not healthy: %s", kms.Name, status.Healthz)
+	}
+	if status.KeyID == "" {
+		return "", fmt.Errorf("KMS plugin %s did not return a key ID", kms.Name)
+	}
+	return status.KeyID, nil
+}
+
+// hashEncryptionConfig returns a sha256 hash of the encryption configuration. If the configuration
+// contains KMS v2 providers, the current key ID of each KMS plugin is included in the hash, so that
+// the hash changes when the key is rotated by the plugin. This allows the encryption hash annotation
+// to track whether secrets have been reencrypted with the current KMS key.
+func hashEncryptionConfig(ctx context.Context, b []byte) (string, error) {
+	encryptionConfigHash := sha256.New()
+	encryptionConfigHash.Write(b)
+
+	encConfig := apiserverconfigv1.EncryptionConfiguration{}
+	if err := json.Unmarshal(b, &encConfig); err != nil {
+		return "", err
+	}
+	for _, resource := range encConfig.Resources {
+		for _, p := range resource.Providers {
+			if p.KMS == nil || p.KMS.APIVersion != KMSAPIVersion {
+				continue
+			}
+			keyID, err := GetKMSKeyID(ctx, *p.KMS)
+			if err != nil {
+				return "", fmt.Errorf("%w: %w", ErrKMSUnavailable, err)
+			}
+			fmt.Fprintf(encryptionConfigHash, "\n%s=%s", p.KMS.Name, keyID)
+		}
+	}
+	return hex.EncodeToString(encryptionConfigHash.Sum(nil)), nil
+}
+
+// WaitForKMSKeyID waits until the apiserver has received the given key ID from the status call of the
+// named KMS plugin. The apiserver starts encrypting data with a new key ID as soon as it is received.
+func WaitForKMSKeyID(ctx context.Context, runtime *config.ControlRuntime, name, keyID string) error {
+	restConfig, err := util.GetRESTConfig(runtime.KubeConfigSupervisor)
+	if err != nil {
+		return err
+	}
+	restConfig.GroupVersion = &apiserverconfigv1.SchemeGroupVersion
+	restConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	restClient, err := rest.RESTClientFor(restConfig)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting up to %s for apiserver to pick up the current key ID of KMS provider %s", KMSKeyIDWaitTimeout, name)
+	var lastErr error
+	if err := wait.PollUntilContextTimeout(ctx, 5*time.Second, KMSKeyIDWaitTimeout, true, func(ctx context.Context) (bool, error) {
+		data, err := restClient.Get().AbsPath("/metrics").DoRaw(ctx)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		var parser expfmt.TextParser
+		mf, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		return hasKMSKeyID(mf, name, keyID), nil
+	}); err != nil {
+		if lastErr != nil {
+			err = fmt.Errorf("%w: %w", err, lastErr)
+		}
+		return fmt.Errorf("apiserver did not pick up the current key ID of KMS provider %s: %w", name, err)
+	}
+	return nil
+}
+
+// hasKMSKeyID returns true if the apiserver metrics show that the apiserver has received the given key ID
+// from the named KMS plugin. Key IDs are recorded as the hex-encoded sha256 hash of the key ID.
+func hasKMSKeyID(mf map[string]*dto.MetricFamily, name, keyID string) bool {
+	family := mf[kmsKeyIDStatusMetric]
+	if family == nil {
+		return false
+	}
+	keyIDHash := sha256.Sum256([]byte(keyID))
+	wantHash := "sha256:" + hex.EncodeToString(keyIDHash[:])
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["provider_name"] == name && labels["key_id_hash"] == wantHash {
+			return true
+		}
+	}
+	return false
+}
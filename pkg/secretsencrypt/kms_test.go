@@ -0,0 +1,181 @@
+package secretsencrypt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/prometheus/common/expfmt"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/kms/pkg/service"
+)
+
+// mockKMSService is a KMS v2 plugin that returns a configurable key ID and health status.
+type mockKMSService struct {
+	mutex   sync.Mutex
+	keyID   string
+	healthz string
+}
+
+func (m *mockKMSService) Decrypt(ctx context.Context, uid string, req *service.DecryptRequest) ([]byte, error) {
+	return req.Ciphertext, nil
+}
+
+func (m *mockKMSService) Encrypt(ctx context.Context, uid string, data []byte) (*service.EncryptResponse, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return &service.EncryptResponse{Ciphertext: data, KeyID: m.keyID}, nil
+}
+
+func (m *mockKMSService) Status(ctx context.Context) (*service.StatusResponse, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return &service.StatusResponse{Version: "v2", Healthz: m.healthz, KeyID: m.keyID}, nil
+}
+
+func (m *mockKMSService) set(keyID, healthz string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.keyID = keyID
+	m.healthz = healthz
+}
+
+// startMockKMSPlugin serves a mock KMS plugin on a unix socket, and returns its endpoint.
+func startMockKMSPlugin(t *testing.T, mock *mockKMSService) string {
+	socketPath := filepath.Join(t.TempDir(), "kms.sock")
+	plugin := service.NewGRPCService(socketPath, KMSTimeout, mock)
+	go plugin.ListenAndServe()
+	t.Cleanup(plugin.Close)
+	return "unix://" + socketPath
+}
+
+func Test_UnitGetKMSKeyID(t *testing.T) {
+	mock := &mockKMSService{}
+	kms := NewKMSConfiguration("test-kms", startMockKMSPlugin(t, mock))
+
+	tests := []struct {
+		name    string
+		keyID   string
+		healthz string
+		wantErr bool
+	}{
+		{name: "Healthy", keyID: "key-1", healthz: "ok"},
+		{name: "Unhealthy", keyID: "key-1", healthz: "unavailable", wantErr: true},
+		{name: "Missing key ID", healthz: "ok", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.set(tt.keyID, tt.healthz)
+			// the plugin is started asynchronously, so allow a few attempts to connect
+			var got string
+			var err error
+			for range 10 {
+				if got, err = GetKMSKeyID(context.Background(), kms); err == nil || tt.wantErr {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetKMSKeyID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.keyID {
+				t.Errorf("GetKMSKeyID() = %s, want %s", got, tt.keyID)
+			}
+		})
+	}
+}
+
+func Test_UnitGenEncryptionConfigHash(t *testing.T) {
+	mock := &mockKMSService{keyID: "key-1", healthz: "ok"}
+	runtime := &config.ControlRuntime{}
+	runtime.EncryptionConfig = filepath.Join(t.TempDir(), "encryption-config.json")
+	keys := &EncryptionKeys{
+		AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey", Secret: "c2VjcmV0"}},
+		KMS:        []apiserverconfigv1.KMSConfiguration{NewKMSConfiguration("test-kms", startMockKMSPlugin(t, mock))},
+	}
+	if err := WriteEncryptionConfig(runtime, keys, KMSProvider, true); err != nil {
+		t.Fatal(err)
+	}
+
+	providers, err := GetEncryptionProviders(runtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(providers) != 3 || providers[0].KMS == nil || providers[1].AESCBC == nil || providers[2].Identity == nil {
+		t.Fatalf("WriteEncryptionConfig() wrote providers %+v, want kms, aescbc, identity", providers)
+	}
+
+	var hash string
+	for range 10 {
+		if hash, err = GenEncryptionConfigHash(context.Background(), runtime); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GenEncryptionConfigHash() error = %v", err)
+	}
+	if got, _ := GenEncryptionConfigHash(context.Background(), runtime); got != hash {
+		t.Errorf("GenEncryptionConfigHash() = %s, want unchanged %s", got, hash)
+	}
+
+	mock.set("key-2", "ok")
+	if got, _ := GenEncryptionConfigHash(context.Background(), runtime); got == hash {
+		t.Errorf("GenEncryptionConfigHash() = %s, want change after KMS key rotation", got)
+	}
+}
+
+func Test_UnitGenEncryptionConfigHashUnavailable(t *testing.T) {
+	runtime := &config.ControlRuntime{}
+	runtime.EncryptionConfig = filepath.Join(t.TempDir(), "encryption-config.json")
+	keys := &EncryptionKeys{
+		KMS: []apiserverconfigv1.KMSConfiguration{NewKMSConfiguration("test-kms", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))},
+	}
+	if err := WriteEncryptionConfig(runtime, keys, KMSProvider, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GenEncryptionConfigHash(context.Background(), runtime); !errors.Is(err, ErrKMSUnavailable) {
+		t.Errorf("GenEncryptionConfigHash() error = %v, want %v", err, ErrKMSUnavailable)
+	}
+}
+
+func Test_UnitHasKMSKeyID(t *testing.T) {
+	hash := func(keyID string) string {
+		sum := sha256.Sum256([]byte(keyID))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	metrics := "# TYPE apiserver_envelope_encryption_key_id_hash_status_last_timestamp_seconds gauge\n" +
+		`apiserver_envelope_encryption_key_id_hash_status_last_timestamp_seconds{apiserver_id_hash="sha256:00",key_id_hash="` + hash("key-1") + `",provider_name="test-kms"} 1.7e+09` + "\n" +
+		`apiserver_envelope_encryption_key_id_hash_status_last_timestamp_seconds{apiserver_id_hash="sha256:00",key_id_hash="` + hash("key-2") + `",provider_name="other-kms"} 1.7e+09` + "\n"
+	var parser expfmt.TextParser
+	mf, err := parser.TextToMetricFamilies(strings.NewReader(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		kms   string
+		keyID string
+		want  bool
+	}{
+		{name: "Current key ID", kms: "test-kms", keyID: "key-1", want: true},
+		{name: "New key ID", kms: "test-kms", keyID: "key-2", want: false},
+		{name: "Key ID of other provider", kms: "other-kms", keyID: "key-1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasKMSKeyID(mf, tt.kms, tt.keyID); got != tt.want {
+				t.Errorf("hasKMSKeyID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
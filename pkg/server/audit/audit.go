@@ -0,0 +1,279 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/version"
+	"github.com/natefinch/lumberjack"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	// SyslogPrefix selects logging to syslog instead of a file. The prefix may be used alone to log
+	// to the local syslog daemon, or followed by the path to a syslog socket.
+	SyslogPrefix = "syslog"
+
+	// redacted replaces the values of request parameters that may contain secrets.
+	redacted = "<redacted>"
+
+	// maxBodySize limits the size of request bodies that will be parsed for parameters.
+	maxBodySize = 64 * 1024
+
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+var (
+	// sensitiveParameter matches the names of request parameters whose values should not be logged.
+	sensitiveParameter = regexp.MustCompile(`(?i)token|password|secret|key|content`)
+	// loggedHeaders lists request headers that are recorded as parameters.
+	loggedHeaders = []string{version.Program + "-Node-Name", version.Program + "-Node-IP"}
+)
+
+// Event is a single entry in the audit log.
+type Event struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	User       string            `json:"user,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+	SourceIP   string            `json:"sourceIP"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Code       int               `json:"code"`
+	Outcome    string            `json:"outcome"`
+	Latency    string            `json:"latency"`
+
+	skip bool
+}
+
+type eventKey struct{}
+
+// SetUser records the authenticated user on the request's audit event, if the request is being audited.
+func SetUser(req *http.Request, u user.Info) {
+	if event, ok := req.Context().Value(eventKey{}).(*Event); ok {
+		event.User = u.GetName()
+		event.Groups = u.GetGroups()
+	}
+}
+
+// Skip excludes the request from the audit log. This is used for requests that are
+// passed through to the apiserver, which has its own audit log.
+func Skip(req *http.Request) {
+	if event, ok := req.Context().Value(eventKey{}).(*Event); ok {
+		event.skip = true
+	}
+}
+
+// Logger writes audit events as JSON lines.
+type Logger struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewLogger returns a Logger that writes to the configured audit log file or syslog socket.
+// If the audit log is not enabled, a nil Logger is returned. The log is closed when the context is cancelled.
+func NewLogger(ctx context.Context, control *config.Control) (*Logger, error) {
+	path := control.SupervisorAuditLogPath
+	if path == "" {
+		return nil, nil
+	}
+
+	var w io.WriteCloser
+	if socket, ok := strings.CutPrefix(path, SyslogPrefix); ok && (socket == "" || strings.HasPrefix(socket, ":")) {
+		var err error
+		if w, err = newSyslogWriter(strings.TrimPrefix(socket, ":")); err != nil {
+			return nil, err
+		}
+	} else {
+		w = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    control.SupervisorAuditLogMaxSize,
+			MaxBackups: control.SupervisorAuditLogMaxBackup,
+			MaxAge:     control.SupervisorAuditLogMaxAge,
+			Compress:   true,
+		}
+	}
+
+	l := &Logger{w: w}
+	go func() {
+		<-ctx.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.w.Close()
+	}()
+
+	logrus.Infof("Logging supervisor API requests to %s", path)
+	return l, nil
+}
+
+// Log writes an event to the audit log.
+func (l *Logger) Log(event *Event) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("Failed to encode supervisor audit event: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		logrus.Errorf("Failed to write supervisor audit event: %v", err)
+	}
+}
+
+// Handler returns a handler that records an audit event for each request served by the next handler.
+// If the logger is nil, the next handler is returned unmodified.
+func Handler(l *Logger, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		event := &Event{
+			Timestamp:  time.Now(),
+			SourceIP:   sourceIP(req),
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Parameters: parameters(req),
+		}
+		rw := &responseWriter{ResponseWriter: resp}
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), eventKey{}, event)))
+		if event.skip {
+			return
+		}
+
+		event.Code = rw.code
+		if event.Code == 0 {
+			event.Code = http.StatusOK
+		}
+		switch {
+		case event.Code == http.StatusUnauthorized || event.Code == http.StatusForbidden:
+			event.Outcome = OutcomeDenied
+		case event.Code >= http.StatusBadRequest:
+			event.Outcome = OutcomeFailure
+		default:
+			event.Outcome = OutcomeSuccess
+		}
+		event.Latency = time.Since(event.Timestamp).String()
+		l.Log(event)
+	})
+}
+
+// sourceIP returns the address of the client that sent the request.
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// parameters collects request parameters from the query string, selected headers, and
+// top-level fields of JSON request bodies. Values of parameters that may contain secrets are
+// redacted, as are nested values within the request body.
+func parameters(req *http.Request) map[string]string {
+	params := map[string]string{}
+	for name, values := range req.URL.Query() {
+		params[name] = redact(name, strings.Join(values, ","))
+	}
+	for _, name := range loggedHeaders {
+		if value := req.Header.Get(name); value != "" {
+			params[name] = value
+		}
+	}
+
+	if req.Body != nil && req.ContentLength > 0 && req.ContentLength <= maxBodySize && isJSON(req) {
+		body, err := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		fields := map[string]json.RawMessage{}
+		if err == nil && json.Unmarshal(body, &fields) == nil {
+			for name, value := range fields {
+				var s any
+				if err := json.Unmarshal(value, &s); err != nil {
+					continue
+				}
+				switch v := s.(type) {
+				case map[string]any, []any:
+					params[name] = redacted
+				case string:
+					params[name] = redact(name, v)
+				default:
+					params[name] = redact(name, string(value))
+				}
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// isJSON returns true if the request body is, or may be, JSON.
+func isJSON(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json"
+}
+
+// redact returns the value, or a placeholder if the named parameter may contain secrets.
+func redact(name, value string) string {
+	if sensitiveParameter.MatchString(name) {
+		return redacted
+	}
+	return value
+}
+
+// responseWriter records the status code sent to the client, while passing
+// through support for hijacking and flushing the connection.
+type responseWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.code == 0 {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func Test_UnitHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logFile := filepath.Join(t.TempDir(), "audit.log")
+	l, err := NewLogger(ctx, &config.Control{SupervisorAuditLogPath: logFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Handler(l, http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/apis":
+			Skip(req)
+		case "/v1-k3s/token":
+			SetUser(req, &user.DefaultInfo{Name: "server", Groups: []string{"k3s:server"}})
+		case "/v1-k3s/encrypt/config":
+			http.Error(resp, "forbidden", http.StatusForbidden)
+		}
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		headers   map[string]string
+		wantEvent *Event
+	}{
+		{
+			name:   "Token rotation",
+			method: http.MethodPut,
+			path:   "/v1-k3s/token",
+			body:   `{"newToken":"secret-value"}`,
+			wantEvent: &Event{
+				User:       "server",
+				Groups:     []string{"k3s:server"},
+				Method:     http.MethodPut,
+				Path:       "/v1-k3s/token",
+				Parameters: map[string]string{"newToken": redacted},
+				Code:       http.StatusOK,
+				Outcome:    OutcomeSuccess,
+			},
+		},
+		{
+			name:   "Forbidden encryption config change",
+			method: http.MethodPut,
+			path:   "/v1-k3s/encrypt/config",
+			body:   `{"stage":"prepare","force":true}`,
+			wantEvent: &Event{
+				Method:     http.MethodPut,
+				Path:       "/v1-k3s/encrypt/config",
+				Parameters: map[string]string{"stage": "prepare", "force": "true"},
+				Code:       http.StatusForbidden,
+				Outcome:    OutcomeDenied,
+			},
+		},
+		{
+			name:   "CA certificate replacement",
+			method: http.MethodPut,
+			path:   "/v1-k3s/cert/cacerts",
+			body:   `{"ClientCA":{"Content":"LS0t"}}`,
+			wantEvent: &Event{
+				Method:     http.MethodPut,
+				Path:       "/v1-k3s/cert/cacerts",
+				Parameters: map[string]string{"ClientCA": redacted},
+				Code:       http.StatusOK,
+				Outcome:    OutcomeSuccess,
+			},
+		},
+		{
+			name:    "Kubelet certificate request",
+			method:  http.MethodPost,
+			path:    "/v1-k3s/client-kubelet.crt",
+			body:    "-----BEGIN CERTIFICATE REQUEST-----",
+			headers: map[string]string{"Content-Type": "application/x-pem-file", "k3s-Node-Name": "node-1", "k3s-Node-Password": "password"},
+			wantEvent: &Event{
+				Method:     http.MethodPost,
+				Path:       "/v1-k3s/client-kubelet.crt",
+				Parameters: map[string]string{"k3s-Node-Name": "node-1"},
+				Code:       http.StatusOK,
+				Outcome:    OutcomeSuccess,
+			},
+		},
+		{
+			name:   "Apiserver request",
+			method: http.MethodGet,
+			path:   "/apis",
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	events := []*Event{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		event := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			t.Fatalf("Failed to decode audit event %q: %v", scanner.Text(), err)
+		}
+		if event.SourceIP != "192.0.2.1" {
+			t.Errorf("SourceIP = %s, want 192.0.2.1", event.SourceIP)
+		}
+		event.SourceIP, event.Latency = "", ""
+		events = append(events, event)
+	}
+
+	i := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantEvent == nil {
+				return
+			}
+			if i >= len(events) {
+				t.Fatalf("Missing audit event for %s %s", tt.method, tt.path)
+			}
+			got := events[i]
+			i++
+			got.Timestamp = tt.wantEvent.Timestamp
+			if !reflect.DeepEqual(got, tt.wantEvent) {
+				t.Errorf("Handler() logged event %+v, want %+v", got, tt.wantEvent)
+			}
+		})
+	}
+	if i != len(events) {
+		t.Errorf("Handler() logged %d events, want %d", len(events), i)
+	}
+}
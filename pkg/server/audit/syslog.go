@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/k3s-io/k3s/pkg/version"
+)
+
+// newSyslogWriter returns a writer that sends messages to syslog, using the local syslog daemon
+// if no socket is specified.
+func newSyslogWriter(socket string) (io.WriteCloser, error) {
+	tag := version.Program + "-supervisor-audit"
+	if socket == "" {
+		return syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	}
+	return syslog.Dial("unixgram", socket, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+}
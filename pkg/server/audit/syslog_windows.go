@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package audit
+
+import (
+	"errors"
+	"io"
+)
+
+// newSyslogWriter returns an error, as syslog is not supported on Windows.
+func newSyslogWriter(socket string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on windows")
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/server/audit"
 	"github.com/k3s-io/k3s/pkg/util"
 	"github.com/k3s-io/k3s/pkg/version"
 	"github.com/sirupsen/logrus"
@@ -55,6 +56,10 @@ func doAuth(roles []string, serverConfig *config.Control, next http.Handler, rw
 		return
 	}
 
+	if ok {
+		audit.SetUser(req, resp.User)
+	}
+
 	if !ok || !hasRole(roles, resp.User.GetGroups()) {
 		util.SendError(errors.New("forbidden"), rw, req, http.StatusForbidden)
 		return
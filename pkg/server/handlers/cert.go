@@ -80,6 +80,10 @@ func caCertReplace(control *config.Control, buf io.ReadCloser, force bool) error
 		return pkgerrors.WithMessage(err, "failed to set default bootstrap values")
 	}
 
+	if err := sortCABundles(control, tmpControl); err != nil {
+		return pkgerrors.WithMessage(err, "failed to order new CA certificate bundles")
+	}
+
 	if err := validateBootstrap(control, tmpControl); err != nil {
 		if !force {
 			return pkgerrors.WithMessage(err, "failed to validate new CA certificates and keys")
@@ -120,7 +124,38 @@ func defaultBootstrap(oldControl, newControl *config.Control) error {
 	return merr.NewErrors(errs...)
 }
 
+// sortCABundles rewrites each new CA certificate bundle with duplicate certificates removed, and the
+// certificates ordered from the cluster CA certificate up through any intermediate CAs to the root CA.
+// This allows the bundle to contain certificates in any order, as returned by an external CA.
+func sortCABundles(oldControl, newControl *config.Control) error {
+	errs := []error{}
+	oldMeta := reflect.ValueOf(&oldControl.Runtime.ControlRuntimeBootstrap).Elem()
+	newMeta := reflect.ValueOf(&newControl.Runtime.ControlRuntimeBootstrap).Elem()
+
+	for _, field := range reflect.VisibleFields(oldMeta.Type()) {
+		if field.Tag.Get("rotate") != "true" || !strings.HasSuffix(field.Name, "CA") {
+			continue
+		}
+		// Skip bundles if old values are being reused
+		newVal := newMeta.FieldByName(field.Name)
+		if newVal.String() == oldMeta.FieldByName(field.Name).String() {
+			continue
+		}
+		certs, err := certutil.CertsFromFile(newVal.String())
+		if err != nil {
+			errs = append(errs, pkgerrors.WithMessage(err, field.Name))
+			continue
+		}
+		certs = util.SortCertChain(certs)
+		if err := certutil.WriteCert(newVal.String(), util.EncodeCertsPEM(certs[0], certs[1:])); err != nil {
+			errs = append(errs, pkgerrors.WithMessage(err, field.Name))
+		}
+	}
+	return merr.NewErrors(errs...)
+}
+
 // validateBootstrap checks the new certs and keys to ensure that the cluster would function properly were they to be used.
+// - The new CA bundles must contain a complete chain from the leaf CA certificate to a root CA.
 // - The new leaf CA certificates must be verifiable using the same root and intermediate certs as the current leaf CA certificates.
 // - The new service account signing key bundle must include the currently active signing key.
 func validateBootstrap(oldControl, newControl *config.Control) error {
@@ -181,6 +216,10 @@ func validateCA(oldCAPath, newCAPath string) error {
 		return errors.New("new CA bundle contains only a single certificate but should include root or intermediate CA certificates")
 	}
 
+	if err := util.VerifyCAChain(newCerts); err != nil {
+		return pkgerrors.WithMessage(err, "new CA bundle is not valid")
+	}
+
 	roots := x509.NewCertPool()
 	intermediates := x509.NewCertPool()
 
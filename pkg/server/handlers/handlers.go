@@ -19,17 +19,22 @@ import (
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/etcd"
 	"github.com/k3s-io/k3s/pkg/nodepassword"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
+	"github.com/k3s-io/k3s/pkg/server/audit"
 	"github.com/k3s-io/k3s/pkg/util"
 	pkgerrors "github.com/pkg/errors"
 	certutil "github.com/rancher/dynamiclistener/cert"
 	"github.com/sirupsen/logrus"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	typeddiscoveryv1 "k8s.io/client-go/kubernetes/typed/discovery/v1"
+	"k8s.io/kubernetes/pkg/auth/nodeidentifier"
 )
 
 func CACerts(config *config.Control) http.Handler {
@@ -48,8 +53,11 @@ func CACerts(config *config.Control) http.Handler {
 	})
 }
 
+// Ping returns a handler for the unauthenticated liveness endpoint. Agents poll this endpoint on every
+// server as an active load balancer health check, so requests are not recorded in the audit log.
 func Ping() http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		audit.Skip(req)
 		data := []byte("pong")
 		resp.WriteHeader(http.StatusOK)
 		resp.Header().Set("Content-Type", "text/plain")
@@ -151,6 +159,8 @@ func APIServer(control *config.Control, cfg *cmds.Server) http.Handler {
 		})
 	}
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		// requests passed through to the apiserver are recorded in the apiserver's own audit log
+		audit.Skip(req)
 		if control.Runtime != nil && control.Runtime.APIServer != nil {
 			control.Runtime.APIServer.ServeHTTP(resp, req)
 		} else {
@@ -200,6 +210,57 @@ func Readyz(control *config.Control) http.Handler {
 	})
 }
 
+// Registries returns the cluster registry configuration from the registries secret in the kube-system namespace.
+// If the secret does not exist, an empty configuration is returned.
+// Registries returns the cluster registry configuration for the requesting node, with any overrides that match
+// the node's labels merged into the base configuration. Overrides are only applied for requests made with node
+// identity auth, using the labels of the node object; other clients, including nodes that have not yet registered,
+// receive the base configuration. Overrides that do not apply to the node, and their credentials, are never sent.
+func Registries(control *config.Control) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if control.Runtime.Core == nil {
+			util.SendError(util.ErrCoreNotReady, resp, req, http.StatusServiceUnavailable)
+			return
+		}
+
+		nodeLabels := map[string]string{}
+		if u, ok := apirequest.UserFrom(req.Context()); ok {
+			if nodeName, isNodeAuth := nodeidentifier.NewDefaultNodeIdentifier().NodeIdentity(u); isNodeAuth {
+				node, err := control.Runtime.Core.Core().V1().Node().Cache().Get(nodeName)
+				if err != nil && !apierrors.IsNotFound(err) {
+					util.SendError(pkgerrors.WithMessagef(err, "failed to get node %s", nodeName), resp, req, http.StatusInternalServerError)
+					return
+				} else if err == nil {
+					nodeLabels = node.Labels
+				}
+			}
+		}
+
+		registryConfig := &registryconfig.Config{}
+		secret, err := control.Runtime.Core.Core().V1().Secret().Get(metav1.NamespaceSystem, registryconfig.SecretName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			util.SendError(pkgerrors.WithMessage(err, "failed to get cluster registry configuration"), resp, req, http.StatusInternalServerError)
+			return
+		} else if err == nil {
+			if registryConfig, err = registryconfig.Parse(secret.Data[registryconfig.SecretKey]); err != nil {
+				util.SendError(err, resp, req, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		registry, err := registryConfig.ForNode(nodeLabels)
+		if err != nil {
+			util.SendError(err, resp, req, http.StatusInternalServerError)
+			return
+		}
+
+		resp.Header().Set("content-type", "application/json")
+		if err := json.NewEncoder(resp).Encode(registry); err != nil {
+			util.SendError(pkgerrors.WithMessage(err, "failed to encode cluster registry configuration"), resp, req, http.StatusInternalServerError)
+		}
+	})
+}
+
 func Bootstrap(control *config.Control) http.Handler {
 	if control.Runtime.HTTPBootstrap != nil {
 		return control.Runtime.HTTPBootstrap
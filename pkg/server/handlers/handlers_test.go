@@ -16,11 +16,16 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/k3s-io/k3s/pkg/authenticator"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
+	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
+	"github.com/k3s-io/k3s/pkg/server/audit"
 	testutil "github.com/k3s-io/k3s/tests"
 	"github.com/k3s-io/k3s/tests/mock"
 	. "github.com/onsi/gomega"
@@ -30,7 +35,9 @@ import (
 	"go.uber.org/mock/gomock"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apiserver/pkg/authentication/user"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 )
 
 func init() {
@@ -1782,3 +1789,168 @@ func withCertificateRequest(req *http.Request) {
 func withClientAddress(req *http.Request, address string) {
 	req.RemoteAddr = net.JoinHostPort(address, "1234")
 }
+
+// Test_UnitCACerts confirms that the CA certificates are served exactly as they are stored on disk,
+// so that the CA hash in a token matches the hash of the certificates retrieved by the agent.
+func Test_UnitPing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logFile := filepath.Join(t.TempDir(), "audit.log")
+	l, err := audit.NewLogger(ctx, &config.Control{SupervisorAuditLogPath: logFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	audit.Handler(l, Ping()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "pong" {
+		t.Fatalf("Ping() = %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusOK, "pong")
+	}
+	if b, err := os.ReadFile(logFile); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	} else if len(b) != 0 {
+		t.Errorf("Ping() was recorded in the audit log: %s", b)
+	}
+}
+
+func Test_UnitCACerts(t *testing.T) {
+	key, err := certutil.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: "k3s-server-ca"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM := certutil.EncodeCertPEM(caCert)
+
+	tests := []struct {
+		name   string
+		bundle []byte
+	}{
+		{
+			name:   "Single certificate",
+			bundle: caPEM,
+		},
+		{
+			name:   "Single certificate with explanatory text",
+			bundle: append([]byte("Cluster server CA\n"), caPEM...),
+		},
+		{
+			name:   "Duplicate certificates",
+			bundle: append(slices.Clone(caPEM), caPEM...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := &config.Control{Runtime: &config.ControlRuntime{}}
+			control.Runtime.ServerCA = filepath.Join(t.TempDir(), "server-ca.crt")
+			if err := os.WriteFile(control.Runtime.ServerCA, tt.bundle, 0600); err != nil {
+				t.Fatal(err)
+			}
+			token, err := clientaccess.FormatToken("server:password", control.Runtime.ServerCA)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			CACerts(control).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cacerts", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("CACerts() status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			got, err := clientaccess.FormatTokenBytes("server:password", rec.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != token {
+				t.Errorf("CACerts() response hashes to token %s, want %s", got, token)
+			}
+		})
+	}
+}
+
+func Test_UnitRegistries(t *testing.T) {
+	registryConfig := `
+mirrors:
+  docker.io:
+    endpoint:
+    - https://mirror.example.com
+overrides:
+- nodeSelector: topology.kubernetes.io/region=eu-west
+  mirrors:
+    docker.io:
+      endpoint:
+      - https://eu-west.example.com
+  configs:
+    eu-west.example.com:
+      auth:
+        username: eu-west
+`
+	nodeStore := &mock.NodeStore{}
+	nodeStore.Create(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "k3s-agent-1", Labels: map[string]string{"topology.kubernetes.io/region": "eu-west"}}})
+	nodeStore.Create(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "k3s-agent-2"}})
+
+	ctrl := gomock.NewController(t)
+	coreFactory := mock.NewCoreFactory(ctrl)
+	coreFactory.CoreMock.V1Mock.SecretMock.EXPECT().Get(metav1.NamespaceSystem, registryconfig.SecretName, gomock.Any()).AnyTimes().Return(&v1.Secret{
+		Data: map[string][]byte{registryconfig.SecretKey: []byte(registryConfig)},
+	}, nil)
+	coreFactory.CoreMock.V1Mock.NodeMock.EXPECT().Cache().AnyTimes().Return(coreFactory.CoreMock.V1Mock.NodeCache)
+	coreFactory.CoreMock.V1Mock.NodeCache.EXPECT().Get(gomock.Any()).AnyTimes().DoAndReturn(nodeStore.Get)
+	control := &config.Control{Runtime: &config.ControlRuntime{Core: coreFactory}}
+
+	tests := []struct {
+		name       string
+		user       user.Info
+		wantDocker string
+		wantAuth   bool
+	}{
+		{
+			name:       "Node matching override",
+			user:       &user.DefaultInfo{Name: "system:node:k3s-agent-1", Groups: []string{user.NodesGroup}},
+			wantDocker: "https://eu-west.example.com",
+			wantAuth:   true,
+		},
+		{
+			name:       "Node not matching override",
+			user:       &user.DefaultInfo{Name: "system:node:k3s-agent-2", Groups: []string{user.NodesGroup}},
+			wantDocker: "https://mirror.example.com",
+		},
+		{
+			name:       "Unregistered node",
+			user:       &user.DefaultInfo{Name: "system:node:k3s-agent-3", Groups: []string{user.NodesGroup}},
+			wantDocker: "https://mirror.example.com",
+		},
+		{
+			name:       "Token auth",
+			user:       &user.DefaultInfo{Name: "node", Groups: []string{"k3s:agent"}},
+			wantDocker: "https://mirror.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1-k3s/registries", nil)
+			req = req.WithContext(apirequest.WithUser(req.Context(), tt.user))
+			rec := httptest.NewRecorder()
+			Registries(control).ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Registries() status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			got := &registryconfig.Registry{}
+			if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+				t.Fatal(err)
+			}
+			if endpoints := got.Mirrors["docker.io"].Endpoints; !reflect.DeepEqual(endpoints, registryconfig.NewEndpoints(tt.wantDocker)) {
+				t.Errorf("Registries() docker.io endpoints = %v, want %v", endpoints, tt.wantDocker)
+			}
+			if _, ok := got.Configs["eu-west.example.com"]; ok != tt.wantAuth {
+				t.Errorf("Registries() included eu-west.example.com config = %v, want %v", ok, tt.wantAuth)
+			}
+			if bytes.Contains(rec.Body.Bytes(), []byte("overrides")) {
+				t.Errorf("Registries() response includes overrides: %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -23,29 +24,44 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/pager"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/ptr"
 )
 
 type EncryptionState struct {
-	Stage        string   `json:"stage"`
-	ActiveKey    string   `json:"activekey"`
-	Enable       *bool    `json:"enable,omitempty"`
-	HashMatch    bool     `json:"hashmatch,omitempty"`
-	HashError    string   `json:"hasherror,omitempty"`
-	InactiveKeys []string `json:"inactivekeys,omitempty"`
+	Stage        string          `json:"stage"`
+	ActiveKey    string          `json:"activekey"`
+	Enable       *bool           `json:"enable,omitempty"`
+	HashMatch    bool            `json:"hashmatch,omitempty"`
+	HashError    string          `json:"hasherror,omitempty"`
+	InactiveKeys []string        `json:"inactivekeys,omitempty"`
+	Resources    []ResourceState `json:"resources,omitempty"`
+}
+
+// ResourceState is the encryption state of a resource covered by the encryption configuration.
+// Resources may be a resource name, a resource.group name, or a wildcard.
+type ResourceState struct {
+	Resource  string `json:"resource"`
+	Encrypted bool   `json:"encrypted"`
+	ActiveKey string `json:"activekey,omitempty"`
 }
 
 type EncryptionRequest struct {
-	Stage  *string `json:"stage,omitempty"`
-	Enable *bool   `json:"enable,omitempty"`
-	Force  bool    `json:"force"`
-	Skip   bool    `json:"skip"`
+	Stage    *string `json:"stage,omitempty"`
+	Enable   *bool   `json:"enable,omitempty"`
+	Provider *string `json:"provider,omitempty"`
+	Force    bool    `json:"force"`
+	Skip     bool    `json:"skip"`
 }
 
 func getEncryptionRequest(req *http.Request) (*EncryptionRequest, error) {
@@ -60,7 +76,7 @@ func getEncryptionRequest(req *http.Request) (*EncryptionRequest, error) {
 
 func EncryptionStatus(control *config.Control) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
-		status, err := encryptionStatus(control)
+		status, err := encryptionStatus(req.Context(), control)
 		if err != nil {
 			util.SendErrorWithID(err, "secret-encrypt", resp, req, http.StatusInternalServerError)
 			return
@@ -75,7 +91,7 @@ func EncryptionStatus(control *config.Control) http.Handler {
 	})
 }
 
-func encryptionStatus(control *config.Control) (EncryptionState, error) {
+func encryptionStatus(ctx context.Context, control *config.Control) (EncryptionState, error) {
 	state := EncryptionState{}
 	if control.Runtime.Core == nil {
 		return state, util.ErrCoreNotReady
@@ -87,13 +103,13 @@ func encryptionStatus(control *config.Control) (EncryptionState, error) {
 	} else if err != nil {
 		return state, err
 	}
-	if providers[len(providers)-1].Identity != nil && (providers[0].AESCBC != nil || providers[0].Secretbox != nil) {
+	if providers[len(providers)-1].Identity != nil && isEncryptingProvider(providers[0]) {
 		state.Enable = ptr.To(true)
-	} else if !control.EncryptSecrets || providers[0].Identity != nil && (providers[1].AESCBC != nil || providers[1].Secretbox != nil) {
+	} else if !control.EncryptSecrets || providers[0].Identity != nil && isEncryptingProvider(providers[1]) {
 		state.Enable = ptr.To(false)
 	}
 
-	if err := verifyEncryptionHashAnnotation(control.Runtime, control.Runtime.Core.Core(), ""); err != nil {
+	if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), ""); err != nil {
 		state.HashMatch = false
 		state.HashError = err.Error()
 	} else {
@@ -117,6 +133,17 @@ func encryptionStatus(control *config.Control) (EncryptionState, error) {
 				}
 			}
 		}
+		if p.AESGCM != nil {
+			for _, aesKey := range p.AESGCM.Keys {
+				typName := "AES-GCM " + aesKey.Name
+				if active {
+					active = false
+					state.ActiveKey = typName
+				} else {
+					state.InactiveKeys = append(state.InactiveKeys, typName)
+				}
+			}
+		}
 		if p.Secretbox != nil {
 			for _, sbKey := range p.Secretbox.Keys {
 				typName := "XSalsa20-POLY1305 " + sbKey.Name
@@ -128,50 +155,85 @@ func encryptionStatus(control *config.Control) (EncryptionState, error) {
 				}
 			}
 		}
+		if p.KMS != nil {
+			typName := "KMSv2 " + p.KMS.Name
+			if active {
+				active = false
+				if keyID, err := secretsencrypt.GetKMSKeyID(ctx, *p.KMS); err != nil {
+					// Report the plugin as unreachable, rather than failing; the status of the
+					// other keys and resources is still useful when diagnosing a broken plugin.
+					logrus.Warnf("Failed to get key ID of KMS provider %s: %v", p.KMS.Name, err)
+					typName += " (KMS plugin unreachable)"
+				} else {
+					typName += " (key ID " + keyID + ")"
+				}
+				state.ActiveKey = typName
+			} else {
+				state.InactiveKeys = append(state.InactiveKeys, typName)
+			}
+		}
 		if p.Identity != nil {
 			active = false
 		}
 	}
 
+	resources, err := secretsencrypt.GetEncryptionResources(control.Runtime)
+	if err != nil {
+		return state, err
+	}
+	encrypted := isEncryptingProvider(providers[0])
+	for _, r := range resources {
+		rs := ResourceState{Resource: r, Encrypted: encrypted}
+		if encrypted {
+			rs.ActiveKey = state.ActiveKey
+		}
+		state.Resources = append(state.Resources, rs)
+	}
+
 	return state, nil
 }
 
+// isEncryptingProvider returns true if the provider encrypts data; that is, if it is not the identity provider.
+func isEncryptingProvider(p apiserverconfigv1.ProviderConfiguration) bool {
+	return p.AESCBC != nil || p.AESGCM != nil || p.Secretbox != nil || p.KMS != nil
+}
+
 func encryptionEnable(ctx context.Context, control *config.Control, enable bool) error {
 	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
 	if err != nil {
 		return err
 	}
-	if len(providers) > 3 {
-		return fmt.Errorf("more than 3 providers (%d) found in secrets encryption", len(providers))
-	}
+	provider := getEncryptionProvider(control, providers)
 	curKeys, err := secretsencrypt.GetEncryptionKeys(control.Runtime)
 	if err != nil {
 		return err
 	}
 
-	if providers[len(providers)-1].Identity != nil && (providers[0].AESCBC != nil || providers[0].Secretbox != nil) && !enable {
+	if providers[len(providers)-1].Identity != nil && isEncryptingProvider(providers[0]) && !enable {
 		logrus.Infoln("Disabling secrets encryption")
-		if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, control.EncryptProvider, enable); err != nil {
+		if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, enable); err != nil {
 			return err
 		}
 	} else if !enable {
 		logrus.Infoln("Secrets encryption already disabled")
 		return nil
-	} else if providers[0].Identity != nil && (providers[1].AESCBC != nil || providers[1].Secretbox != nil) && enable {
+	} else if providers[0].Identity != nil && isEncryptingProvider(providers[1]) && enable {
 		foundKey := false
 		// Check the rest of the providers (generally 2nd and 3rd) for the key type we are trying to enable.
 		// If we find one, we can proceed.
 		for _, p := range providers[1:] {
-			if (control.EncryptProvider == secretsencrypt.AESCBCProvider && p.AESCBC != nil) ||
-				(control.EncryptProvider == secretsencrypt.SecretBoxProvider && p.Secretbox != nil) {
+			if (provider == secretsencrypt.AESCBCProvider && p.AESCBC != nil) ||
+				(provider == secretsencrypt.AESGCMProvider && p.AESGCM != nil) ||
+				(provider == secretsencrypt.SecretBoxProvider && p.Secretbox != nil) ||
+				(provider == secretsencrypt.KMSProvider && p.KMS != nil) {
 				foundKey = true
 			}
 		}
 		if !foundKey {
-			return fmt.Errorf("cannot enable secrets encryption with %s key type, no keys found", control.EncryptProvider)
+			return fmt.Errorf("cannot enable secrets encryption with %s key type, no keys found", provider)
 		}
 		logrus.Infoln("Enabling secrets encryption")
-		if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, control.EncryptProvider, enable); err != nil {
+		if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, enable); err != nil {
 			return err
 		}
 	} else if enable {
@@ -213,6 +275,8 @@ func EncryptionConfig(ctx context.Context, control *config.Control) http.Handler
 				err = encryptionRotateKeys(ctx, control)
 			case secretsencrypt.EncryptionReencryptActive:
 				err = encryptionReencrypt(ctx, control, encryptReq.Force, encryptReq.Skip)
+			case secretsencrypt.EncryptionMigrate:
+				err = encryptionMigrate(ctx, control, ptr.Deref(encryptReq.Provider, ""))
 			default:
 				err = fmt.Errorf("unknown stage %s requested", *encryptReq.Stage)
 			}
@@ -220,7 +284,10 @@ func EncryptionConfig(ctx context.Context, control *config.Control) http.Handler
 			err = encryptionEnable(ctx, control, *encryptReq.Enable)
 		}
 
-		if err != nil {
+		if errors.Is(err, secretsencrypt.ErrKMSUnavailable) {
+			util.SendErrorWithID(err, "secret-encrypt", resp, req, http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
 			util.SendErrorWithID(err, "secret-encrypt", resp, req, http.StatusBadRequest)
 			return
 		}
@@ -234,10 +301,15 @@ func EncryptionConfig(ctx context.Context, control *config.Control) http.Handler
 
 func encryptionPrepare(ctx context.Context, control *config.Control, force bool) error {
 	states := secretsencrypt.EncryptionStart + "-" + secretsencrypt.EncryptionReencryptFinished
-	if err := verifyEncryptionHashAnnotation(control.Runtime, control.Runtime.Core.Core(), states); err != nil && !force {
+	if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), states); err != nil && !force {
+		return err
+	}
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
 		return err
 	}
-	if control.EncryptProvider == secretsencrypt.SecretBoxProvider {
+	provider := getEncryptionProvider(control, providers)
+	if provider == secretsencrypt.SecretBoxProvider {
 		return fmt.Errorf("prepare does not support secretbox key type, use rotate-keys instead")
 	}
 
@@ -245,11 +317,12 @@ func encryptionPrepare(ctx context.Context, control *config.Control, force bool)
 	if err != nil {
 		return err
 	}
-	if err := AppendNewEncryptionKey(curKeys, control.EncryptProvider); err != nil {
+	if err := appendNewKey(ctx, curKeys, control, provider); err != nil {
 		return err
 	}
 
-	if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, control.EncryptProvider, true); err != nil {
+	// The new key is not used to encrypt secrets until it is rotated to the front.
+	if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, true); err != nil {
 		return err
 	}
 	nodeName := os.Getenv("NODE_NAME")
@@ -258,7 +331,7 @@ func encryptionPrepare(ctx context.Context, control *config.Control, force bool)
 		if err != nil {
 			return err
 		}
-		return secretsencrypt.WriteEncryptionHashAnnotation(control.Runtime, node, false, secretsencrypt.EncryptionPrepare)
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, false, secretsencrypt.EncryptionPrepare)
 	})
 	if err != nil {
 		return err
@@ -267,10 +340,15 @@ func encryptionPrepare(ctx context.Context, control *config.Control, force bool)
 }
 
 func encryptionRotate(ctx context.Context, control *config.Control, force bool) error {
-	if err := verifyEncryptionHashAnnotation(control.Runtime, control.Runtime.Core.Core(), secretsencrypt.EncryptionPrepare); err != nil && !force {
+	if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), secretsencrypt.EncryptionPrepare); err != nil && !force {
 		return err
 	}
-	if control.EncryptProvider == secretsencrypt.SecretBoxProvider {
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
+		return err
+	}
+	provider := getEncryptionProvider(control, providers)
+	if provider == secretsencrypt.SecretBoxProvider {
 		return fmt.Errorf("rotate does not support secretbox key type, use rotate-keys instead")
 	}
 
@@ -280,26 +358,22 @@ func encryptionRotate(ctx context.Context, control *config.Control, force bool)
 	}
 
 	// Right rotate selected keys
-	switch control.EncryptProvider {
-	case secretsencrypt.AESCBCProvider:
-		rotatedKeys := append(curKeys.AESCBCKeys[len(curKeys.AESCBCKeys)-1:], curKeys.AESCBCKeys[:len(curKeys.AESCBCKeys)-1]...)
-		curKeys.AESCBCKeys = rotatedKeys
-	case secretsencrypt.SecretBoxProvider:
-		rotatedKeys := append(curKeys.SBKeys[len(curKeys.SBKeys)-1:], curKeys.SBKeys[:len(curKeys.SBKeys)-1]...)
-		curKeys.SBKeys = rotatedKeys
+	if numKeys(curKeys, provider) == 0 {
+		return fmt.Errorf("no %s keys found, run prepare first", provider)
 	}
+	rotateKeys(curKeys, provider)
 
-	if err = secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, control.EncryptProvider, true); err != nil {
+	if err = secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, true); err != nil {
 		return err
 	}
-	logrus.Infof("Encryption %s keys right rotated\n", control.EncryptProvider)
+	logrus.Infof("Encryption %s keys right rotated\n", provider)
 	nodeName := os.Getenv("NODE_NAME")
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		node, err := control.Runtime.Core.Core().V1().Node().Get(nodeName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
-		return secretsencrypt.WriteEncryptionHashAnnotation(control.Runtime, node, false, secretsencrypt.EncryptionRotate)
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, false, secretsencrypt.EncryptionRotate)
 	})
 	if err != nil {
 		return err
@@ -308,10 +382,14 @@ func encryptionRotate(ctx context.Context, control *config.Control, force bool)
 }
 
 func encryptionReencrypt(ctx context.Context, control *config.Control, force bool, skip bool) error {
-	if err := verifyEncryptionHashAnnotation(control.Runtime, control.Runtime.Core.Core(), secretsencrypt.EncryptionRotate); err != nil && !force {
+	if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), secretsencrypt.EncryptionRotate); err != nil && !force {
 		return err
 	}
-	if control.EncryptProvider == secretsencrypt.SecretBoxProvider {
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
+		return err
+	}
+	if getEncryptionProvider(control, providers) == secretsencrypt.SecretBoxProvider {
 		return fmt.Errorf("reencrypt does not support secretbox key type, use rotate-keys instead")
 	}
 
@@ -323,7 +401,7 @@ func encryptionReencrypt(ctx context.Context, control *config.Control, force boo
 		if err != nil {
 			return err
 		}
-		return secretsencrypt.WriteEncryptionHashAnnotation(control.Runtime, node, true, secretsencrypt.EncryptionReencryptActive)
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, true, secretsencrypt.EncryptionReencryptActive)
 	}); err != nil {
 		return err
 	}
@@ -334,13 +412,13 @@ func encryptionReencrypt(ctx context.Context, control *config.Control, force boo
 	return nil
 }
 
-func addAndRotateKeys(control *config.Control, keyType string) error {
+func addAndRotateKeys(ctx context.Context, control *config.Control, keyType string) error {
 	curKeys, err := secretsencrypt.GetEncryptionKeys(control.Runtime)
 	if err != nil {
 		return err
 	}
 
-	if err := AppendNewEncryptionKey(curKeys, keyType); err != nil {
+	if err := appendNewKey(ctx, curKeys, control, keyType); err != nil {
 		return err
 	}
 
@@ -349,13 +427,7 @@ func addAndRotateKeys(control *config.Control, keyType string) error {
 	}
 
 	// Right rotate keyType keys
-	if keyType == secretsencrypt.AESCBCProvider {
-		rotatedKeys := append(curKeys.AESCBCKeys[len(curKeys.AESCBCKeys)-1:], curKeys.AESCBCKeys[:len(curKeys.AESCBCKeys)-1]...)
-		curKeys.AESCBCKeys = rotatedKeys
-	} else if keyType == secretsencrypt.SecretBoxProvider {
-		rotatedKeys := append(curKeys.SBKeys[len(curKeys.SBKeys)-1:], curKeys.SBKeys[:len(curKeys.SBKeys)-1]...)
-		curKeys.SBKeys = rotatedKeys
-	}
+	rotateKeys(curKeys, keyType)
 	logrus.Infof("Rotating secrets-encryption %s keys\n", keyType)
 	return secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, keyType, true)
 }
@@ -364,7 +436,19 @@ func addAndRotateKeys(control *config.Control, keyType string) error {
 // reencryption process. It is the preferred way to rotate keys, starting with v1.28
 func encryptionRotateKeys(ctx context.Context, control *config.Control) error {
 	states := secretsencrypt.EncryptionStart + "-" + secretsencrypt.EncryptionReencryptFinished
-	if err := verifyEncryptionHashAnnotation(control.Runtime, control.Runtime.Core.Core(), states); err != nil {
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
+		return err
+	}
+	provider := getEncryptionProvider(control, providers)
+	rotateKMSKey := isActiveKMSProvider(control, providers)
+	if rotateKMSKey {
+		// The hash includes the KMS key ID, so it no longer matches the annotation after the
+		// key has been rotated by the plugin. Only check that all nodes are at the same stage.
+		if _, err := verifyEncryptionStage(control.Runtime.Core.Core(), states); err != nil {
+			return err
+		}
+	} else if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), states); err != nil {
 		return err
 	}
 
@@ -372,7 +456,7 @@ func encryptionRotateKeys(ctx context.Context, control *config.Control) error {
 		return err
 	}
 
-	reloadTime, reloadSuccesses, err := secretsencrypt.GetEncryptionConfigMetrics(control.Runtime, true)
+	reloadTime, reloadSuccesses, err := secretsencrypt.GetEncryptionConfigMetrics(ctx, control.Runtime, true)
 	if err != nil {
 		return err
 	}
@@ -385,24 +469,137 @@ func encryptionRotateKeys(ctx context.Context, control *config.Control) error {
 		if err != nil {
 			return err
 		}
-		return secretsencrypt.WriteEncryptionHashAnnotation(control.Runtime, node, true, secretsencrypt.EncryptionReencryptActive)
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, true, secretsencrypt.EncryptionReencryptActive)
 	}); err != nil {
 		return err
 	}
 
-	if err := addAndRotateKeys(control, control.EncryptProvider); err != nil {
+	if rotateKMSKey {
+		// The key is held by the KMS plugin, so there is no new key to add. The apiserver
+		// periodically polls the plugin status, and starts using the new key ID on the next poll.
+		kms := secretsencrypt.NewKMSConfiguration(control.EncryptKMSName, control.EncryptKMSEndpoint)
+		keyID, err := secretsencrypt.GetKMSKeyID(ctx, kms)
+		if err != nil {
+			return fmt.Errorf("%w: %w", secretsencrypt.ErrKMSUnavailable, err)
+		}
+		if err := secretsencrypt.WaitForKMSKeyID(ctx, control.Runtime, control.EncryptKMSName, keyID); err != nil {
+			return err
+		}
+	} else {
+		if err := addAndRotateKeys(ctx, control, provider); err != nil {
+			return err
+		}
+
+		if err := secretsencrypt.WaitForEncryptionConfigReload(ctx, control.Runtime, reloadSuccesses, reloadTime); err != nil {
+			return err
+		}
+	}
+
+	return reencryptAndRemoveKey(ctx, control, false, nodeName)
+}
+
+// encryptionMigrate moves secrets encryption to a different provider. A new key for the provider is added
+// and made active, all secrets are reencrypted with it, and then the keys of all other providers are removed.
+// As with rotate-keys, the stages are tracked with the reencrypt annotations.
+func encryptionMigrate(ctx context.Context, control *config.Control, provider string) error {
+	switch provider {
+	case secretsencrypt.AESCBCProvider, secretsencrypt.AESGCMProvider, secretsencrypt.SecretBoxProvider, secretsencrypt.KMSProvider:
+	case "":
+		return errors.New("provider to migrate to is required")
+	default:
+		return fmt.Errorf("unsupported secrets-encryption provider %s", provider)
+	}
+
+	states := secretsencrypt.EncryptionStart + "-" + secretsencrypt.EncryptionReencryptFinished
+	if err := verifyEncryptionHashAnnotation(ctx, control.Runtime, control.Runtime.Core.Core(), states); err != nil {
 		return err
 	}
 
-	if err := secretsencrypt.WaitForEncryptionConfigReload(control.Runtime, reloadSuccesses, reloadTime); err != nil {
+	if err := verifyRotateKeysSupport(control.Runtime.Core.Core()); err != nil {
 		return err
 	}
 
-	return reencryptAndRemoveKey(ctx, control, false, nodeName)
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
+		return err
+	}
+	if !isEncryptingProvider(providers[0]) {
+		return errors.New("secrets encryption is disabled, enable it before migrating to a different provider")
+	}
+	activeProvider := secretsencrypt.GetActiveProvider(providers)
+	if activeProvider == provider && provider != secretsencrypt.KMSProvider {
+		return fmt.Errorf("secrets are already encrypted with the %s provider, use rotate-keys to rotate keys", provider)
+	}
+
+	curKeys, err := secretsencrypt.GetEncryptionKeys(control.Runtime)
+	if err != nil {
+		return err
+	}
+	if err := appendNewKey(ctx, curKeys, control, provider); err != nil {
+		return err
+	}
+
+	reloadTime, reloadSuccesses, err := secretsencrypt.GetEncryptionConfigMetrics(ctx, control.Runtime, true)
+	if err != nil {
+		return err
+	}
+
+	// Set the reencrypt-active annotation so other nodes know we are in the process of reencrypting.
+	// As this stage is not persisted, we do not write the annotation to file
+	nodeName := os.Getenv("NODE_NAME")
+	if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := control.Runtime.Core.Core().V1().Node().Get(nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, true, secretsencrypt.EncryptionReencryptActive)
+	}); err != nil {
+		return err
+	}
+
+	// Right rotate the new key to the front, and make its provider active
+	logrus.Infof("Migrating secrets-encryption from %s to %s provider", activeProvider, provider)
+	rotateKeys(curKeys, provider)
+	if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, true); err != nil {
+		return err
+	}
+
+	if err := secretsencrypt.WaitForEncryptionConfigReload(ctx, control.Runtime, reloadSuccesses, reloadTime); err != nil {
+		return err
+	}
+
+	if err := updateResources(ctx, control, nodeName); err != nil {
+		return err
+	}
+
+	// All secrets are now encrypted with the new key, so remove all other keys and providers
+	removeOtherKeys(curKeys, provider)
+	for numKeys(curKeys, provider) > 1 {
+		removeLastKey(curKeys, provider)
+	}
+	if err := secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, true); err != nil {
+		return err
+	}
+
+	if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := control.Runtime.Core.Core().V1().Node().Get(nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, false, secretsencrypt.EncryptionReencryptFinished)
+	}); err != nil {
+		return err
+	}
+
+	// The new provider is now the active provider in the encryption configuration, which is shared
+	// with all servers when it is saved, and is used by all servers for any further key rotation.
+	logrus.Infof("Secrets-encryption migrated to %s provider", provider)
+
+	return cluster.Save(ctx, control, true)
 }
 
 func reencryptAndRemoveKey(ctx context.Context, control *config.Control, skip bool, nodeName string) error {
-	if err := updateSecrets(ctx, control, nodeName); err != nil {
+	if err := updateResources(ctx, control, nodeName); err != nil {
 		return err
 	}
 
@@ -420,33 +617,25 @@ func reencryptAndRemoveKey(ctx context.Context, control *config.Control, skip bo
 		return err
 	}
 
-	// Remove old key. If there is only one of that key type, the cluster just
-	// migrated between key types. Check for the other key type and remove that.
+	// Remove old key. If there is only one of that key type, the cluster just migrated
+	// between key types, or a KMS plugin rotated its key. Remove keys of the other types.
+	providers, err := secretsencrypt.GetEncryptionProviders(control.Runtime)
+	if err != nil {
+		return err
+	}
+	provider := getEncryptionProvider(control, providers)
 	curKeys, err := secretsencrypt.GetEncryptionKeys(control.Runtime)
 	if err != nil {
 		return err
 	}
 
-	switch control.EncryptProvider {
-	case secretsencrypt.AESCBCProvider:
-		if len(curKeys.AESCBCKeys) == 1 && len(curKeys.SBKeys) > 0 {
-			logrus.Infoln("Removing secretbox key: ", curKeys.SBKeys[len(curKeys.SBKeys)-1])
-			curKeys.SBKeys = curKeys.SBKeys[:len(curKeys.SBKeys)-1]
-		} else {
-			logrus.Infoln("Removing aescbc key: ", curKeys.AESCBCKeys[len(curKeys.AESCBCKeys)-1])
-			curKeys.AESCBCKeys = curKeys.AESCBCKeys[:len(curKeys.AESCBCKeys)-1]
-		}
-	case secretsencrypt.SecretBoxProvider:
-		if len(curKeys.SBKeys) == 1 && len(curKeys.AESCBCKeys) > 0 {
-			logrus.Infoln("Removing aescbc key: ", curKeys.AESCBCKeys[len(curKeys.AESCBCKeys)-1])
-			curKeys.AESCBCKeys = curKeys.AESCBCKeys[:len(curKeys.AESCBCKeys)-1]
-		} else {
-			logrus.Infoln("Removing secretbox key: ", curKeys.SBKeys[len(curKeys.SBKeys)-1])
-			curKeys.SBKeys = curKeys.SBKeys[:len(curKeys.SBKeys)-1]
-		}
+	if numKeys(curKeys, provider) == 1 {
+		removeOtherKeys(curKeys, provider)
+	} else {
+		removeLastKey(curKeys, provider)
 	}
 
-	if err = secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, control.EncryptProvider, true); err != nil {
+	if err = secretsencrypt.WriteEncryptionConfig(control.Runtime, curKeys, provider, true); err != nil {
 		return err
 	}
 
@@ -455,7 +644,7 @@ func reencryptAndRemoveKey(ctx context.Context, control *config.Control, skip bo
 		if err != nil {
 			return err
 		}
-		return secretsencrypt.WriteEncryptionHashAnnotation(control.Runtime, node, false, secretsencrypt.EncryptionReencryptFinished)
+		return secretsencrypt.WriteEncryptionHashAnnotation(ctx, control.Runtime, node, false, secretsencrypt.EncryptionReencryptFinished)
 	}); err != nil {
 		return err
 	}
@@ -463,7 +652,9 @@ func reencryptAndRemoveKey(ctx context.Context, control *config.Control, skip bo
 	return cluster.Save(ctx, control, true)
 }
 
-func updateSecrets(ctx context.Context, control *config.Control, nodeName string) error {
+// updateResources rewrites all objects of each resource type covered by the encryption configuration,
+// so that they are reencrypted with the active key.
+func updateResources(ctx context.Context, control *config.Control, nodeName string) error {
 	k8s := control.Runtime.K8s
 	nodeRef := &corev1.ObjectReference{
 		Kind:      "Node",
@@ -475,38 +666,224 @@ func updateSecrets(ctx context.Context, control *config.Control, nodeName string
 	// For backwards compatibility with the old controller, we use an event recorder instead of logrus
 	recorder := util.BuildControllerEventRecorder(k8s, "secrets-reencrypt", metav1.NamespaceDefault)
 
-	secretPager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
-		return k8s.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, opts)
+	resources, err := secretsencrypt.GetEncryptionResources(control.Runtime)
+	if err != nil {
+		return err
+	}
+	gvrs, err := getEncryptedResources(k8s.Discovery(), resources)
+	if err != nil {
+		return err
+	}
+
+	restConfig, err := util.GetRESTConfig(control.Runtime.KubeConfigSupervisor)
+	if err != nil {
+		return err
+	}
+	restConfig.QPS = secretsencrypt.SecretQPS
+	restConfig.Burst = secretsencrypt.SecretBurst
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, gvr := range gvrs {
+		if err := updateResource(ctx, dynamicClient.Resource(gvr), gvr.GroupResource(), recorder, nodeRef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateResource rewrites all objects of a single resource type.
+func updateResource(ctx context.Context, client dynamic.NamespaceableResourceInterface, gr schema.GroupResource, recorder record.EventRecorder, nodeRef *corev1.ObjectReference) error {
+	resourcePager := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return client.List(ctx, opts)
 	}))
-	secretPager.PageSize = secretsencrypt.SecretListPageSize
+	resourcePager.PageSize = secretsencrypt.SecretListPageSize
 
 	i := 0
-	if err := secretPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
-		secret, ok := obj.(*corev1.Secret)
+	if err := resourcePager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		u, ok := obj.(*unstructured.Unstructured)
 		if !ok {
-			return errors.New("failed to convert object to Secret")
+			return fmt.Errorf("failed to convert object to %s", gr)
 		}
-		if _, err := k8s.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil && !apierrors.IsConflict(err) {
-			recorder.Eventf(nodeRef, corev1.EventTypeWarning, secretsencrypt.SecretsUpdateErrorEvent, "failed to update secret: %v", err)
-			return fmt.Errorf("failed to update secret: %v", err)
+		if _, err := client.Namespace(u.GetNamespace()).Update(ctx, u, metav1.UpdateOptions{}); err != nil && !apierrors.IsConflict(err) && !apierrors.IsNotFound(err) {
+			recorder.Eventf(nodeRef, corev1.EventTypeWarning, secretsencrypt.SecretsUpdateErrorEvent, "failed to update %s: %v", gr, err)
+			return fmt.Errorf("failed to update %s: %v", gr, err)
 		}
 		if i != 0 && i%50 == 0 {
-			recorder.Eventf(nodeRef, corev1.EventTypeNormal, secretsencrypt.SecretsProgressEvent, "reencrypted %d secrets", i)
+			recorder.Eventf(nodeRef, corev1.EventTypeNormal, secretsencrypt.SecretsProgressEvent, "reencrypted %d %s", i, gr)
 		}
 		i++
 		return nil
 	}); err != nil {
 		return err
 	}
-	recorder.Eventf(nodeRef, corev1.EventTypeNormal, secretsencrypt.SecretsUpdateCompleteEvent, "reencrypted %d secrets", i)
+	recorder.Eventf(nodeRef, corev1.EventTypeNormal, secretsencrypt.SecretsUpdateCompleteEvent, "reencrypted %d %s", i, gr)
 	return nil
 }
 
+// getEncryptedResources returns the preferred version of each resource type that is covered by the encryption
+// configuration. Resources that are not served by the apiserver, such as custom resources whose definitions have
+// not been created yet, are skipped.
+func getEncryptedResources(discoveryClient discovery.DiscoveryInterface, resources []string) ([]schema.GroupVersionResource, error) {
+	lists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	} else if err != nil {
+		logrus.Warnf("Failed to discover some resources for reencryption: %v", err)
+	}
+
+	gvrs := []schema.GroupVersionResource{}
+	matched := map[string]bool{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !slices.Contains(r.Verbs, "list") || !slices.Contains(r.Verbs, "update") {
+				continue
+			}
+			for _, pattern := range resources {
+				if matchesResource(pattern, r.Name, gv.Group) {
+					gvrs = append(gvrs, gv.WithResource(r.Name))
+					matched[pattern] = true
+					break
+				}
+			}
+		}
+	}
+	for _, pattern := range resources {
+		if !matched[pattern] {
+			logrus.Warnf("No resources found matching %s, skipping reencryption", pattern)
+		}
+	}
+	return gvrs, nil
+}
+
+// matchesResource returns true if the resource matches an entry in the encryption configuration. Entries may
+// be a resource name for the core group, a resource.group name, or a wildcard for all resources in a group
+// (*.group, or *. for the core group) or all resources (*.*).
+func matchesResource(pattern, resource, group string) bool {
+	switch {
+	case pattern == "*.*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return group == strings.TrimPrefix(pattern, "*.")
+	case group == "":
+		return pattern == resource || pattern == resource+"."
+	default:
+		return pattern == resource+"."+group
+	}
+}
+
+// rotateKeys right rotates the keys of the given type, so that the most recently added key is first.
+func rotateKeys(keys *secretsencrypt.EncryptionKeys, keyType string) {
+	switch keyType {
+	case secretsencrypt.AESCBCProvider:
+		keys.AESCBCKeys = append(keys.AESCBCKeys[len(keys.AESCBCKeys)-1:], keys.AESCBCKeys[:len(keys.AESCBCKeys)-1]...)
+	case secretsencrypt.AESGCMProvider:
+		keys.AESGCMKeys = append(keys.AESGCMKeys[len(keys.AESGCMKeys)-1:], keys.AESGCMKeys[:len(keys.AESGCMKeys)-1]...)
+	case secretsencrypt.SecretBoxProvider:
+		keys.SBKeys = append(keys.SBKeys[len(keys.SBKeys)-1:], keys.SBKeys[:len(keys.SBKeys)-1]...)
+	case secretsencrypt.KMSProvider:
+		keys.KMS = append(keys.KMS[len(keys.KMS)-1:], keys.KMS[:len(keys.KMS)-1]...)
+	}
+}
+
+// numKeys returns the number of keys of the given type. Each KMS provider counts as a single key.
+func numKeys(keys *secretsencrypt.EncryptionKeys, keyType string) int {
+	switch keyType {
+	case secretsencrypt.AESCBCProvider:
+		return len(keys.AESCBCKeys)
+	case secretsencrypt.AESGCMProvider:
+		return len(keys.AESGCMKeys)
+	case secretsencrypt.SecretBoxProvider:
+		return len(keys.SBKeys)
+	case secretsencrypt.KMSProvider:
+		return len(keys.KMS)
+	}
+	return 0
+}
+
+// removeLastKey removes the last, and therefore oldest, key of the given type.
+func removeLastKey(keys *secretsencrypt.EncryptionKeys, keyType string) {
+	switch keyType {
+	case secretsencrypt.AESCBCProvider:
+		logrus.Infoln("Removing aescbc key: ", keys.AESCBCKeys[len(keys.AESCBCKeys)-1])
+		keys.AESCBCKeys = keys.AESCBCKeys[:len(keys.AESCBCKeys)-1]
+	case secretsencrypt.AESGCMProvider:
+		logrus.Infoln("Removing aesgcm key: ", keys.AESGCMKeys[len(keys.AESGCMKeys)-1])
+		keys.AESGCMKeys = keys.AESGCMKeys[:len(keys.AESGCMKeys)-1]
+	case secretsencrypt.SecretBoxProvider:
+		logrus.Infoln("Removing secretbox key: ", keys.SBKeys[len(keys.SBKeys)-1])
+		keys.SBKeys = keys.SBKeys[:len(keys.SBKeys)-1]
+	case secretsencrypt.KMSProvider:
+		logrus.Infoln("Removing KMS provider: ", keys.KMS[len(keys.KMS)-1].Name)
+		keys.KMS = keys.KMS[:len(keys.KMS)-1]
+	}
+}
+
+// removeOtherKeys removes all keys that are not of the given type.
+func removeOtherKeys(keys *secretsencrypt.EncryptionKeys, keyType string) {
+	for _, typ := range []string{secretsencrypt.AESCBCProvider, secretsencrypt.AESGCMProvider, secretsencrypt.SecretBoxProvider, secretsencrypt.KMSProvider} {
+		for typ != keyType && numKeys(keys, typ) > 0 {
+			removeLastKey(keys, typ)
+		}
+	}
+}
+
+// appendNewKey appends a new key of the given type. For the KMS provider, there are no keys
+// to generate; instead a provider for the configured KMS plugin is appended.
+func appendNewKey(ctx context.Context, keys *secretsencrypt.EncryptionKeys, control *config.Control, keyType string) error {
+	if keyType != secretsencrypt.KMSProvider {
+		return AppendNewEncryptionKey(keys, keyType)
+	}
+	if control.EncryptKMSEndpoint == "" {
+		return fmt.Errorf("secrets-encryption-kms-endpoint is required when using the %s secrets-encryption provider", secretsencrypt.KMSProvider)
+	}
+	for _, kms := range keys.KMS {
+		if kms.Name == control.EncryptKMSName {
+			return fmt.Errorf("KMS provider %s is already configured, use rotate-keys to reencrypt secrets with the current key of the KMS plugin", kms.Name)
+		}
+	}
+	kms := secretsencrypt.NewKMSConfiguration(control.EncryptKMSName, control.EncryptKMSEndpoint)
+	if _, err := secretsencrypt.GetKMSKeyID(ctx, kms); err != nil {
+		return err
+	}
+	keys.KMS = append(keys.KMS, kms)
+	logrus.Infoln("Adding secrets-encryption KMS provider: ", kms.Name)
+	return nil
+}
+
+// isActiveKMSProvider returns true if the configured KMS plugin is already being used to encrypt new secrets.
+func isActiveKMSProvider(control *config.Control, providers []apiserverconfigv1.ProviderConfiguration) bool {
+	for _, p := range providers {
+		if isEncryptingProvider(p) {
+			return p.KMS != nil && p.KMS.Name == control.EncryptKMSName
+		}
+	}
+	return false
+}
+
+// getEncryptionProvider returns the type of the provider used to encrypt new secrets. This is read from the
+// encryption configuration, which is shared by all servers, so that a provider change made by migrate is used
+// by every server. The secrets-encryption-provider setting is only used when the configuration has no keys.
+func getEncryptionProvider(control *config.Control, providers []apiserverconfigv1.ProviderConfiguration) string {
+	if provider := secretsencrypt.GetActiveProvider(providers); provider != "" {
+		return provider
+	}
+	return control.EncryptProvider
+}
+
 func AppendNewEncryptionKey(keys *secretsencrypt.EncryptionKeys, keyType string) error {
 	var keyPrefix string
 	switch keyType {
 	case secretsencrypt.AESCBCProvider:
 		keyPrefix = "aescbckey-"
+	case secretsencrypt.AESGCMProvider:
+		keyPrefix = "aesgcmkey-"
 	case secretsencrypt.SecretBoxProvider:
 		keyPrefix = "secretboxkey-"
 	}
@@ -525,6 +902,8 @@ func AppendNewEncryptionKey(keys *secretsencrypt.EncryptionKeys, keyType string)
 	}
 	if keyType == secretsencrypt.AESCBCProvider {
 		keys.AESCBCKeys = append(keys.AESCBCKeys, newKey...)
+	} else if keyType == secretsencrypt.AESGCMProvider {
+		keys.AESGCMKeys = append(keys.AESGCMKeys, newKey...)
 	} else if keyType == secretsencrypt.SecretBoxProvider {
 		keys.SBKeys = append(keys.SBKeys, newKey...)
 	}
@@ -576,14 +955,37 @@ func verifyRotateKeysSupport(core core.Interface) error {
 
 // verifyEncryptionHashAnnotation checks that all nodes are on the same stage,
 // and that a request for new stage is valid
-func verifyEncryptionHashAnnotation(runtime *config.ControlRuntime, core core.Interface, prevStage string) error {
+func verifyEncryptionHashAnnotation(ctx context.Context, runtime *config.ControlRuntime, core core.Interface, prevStage string) error {
+	oldHash, err := verifyEncryptionStage(core, prevStage)
+	if err != nil || prevStage == "" {
+		return err
+	}
+
+	encryptionConfigHash, err := secretsencrypt.GenEncryptionConfigHash(ctx, runtime)
+	if err != nil {
+		return err
+	}
+	if oldHash != encryptionConfigHash {
+		err := fmt.Errorf("invalid hash: %s found on node %s", oldHash, os.Getenv("NODE_NAME"))
+		if keys, kerr := secretsencrypt.GetEncryptionKeys(runtime); kerr == nil && len(keys.KMS) > 0 {
+			err = fmt.Errorf("%w; the key ID of a KMS plugin may have changed, use rotate-keys to reencrypt secrets with the current key", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// verifyEncryptionStage checks that all nodes are on the same stage, and that the
+// local node is at one of the given stages. The hash from the local node's annotation is returned.
+func verifyEncryptionStage(core core.Interface, prevStage string) (string, error) {
 	var firstHash string
 	var firstNodeName string
 	first := true
 	labelSelector := labels.Set{util.ControlPlaneRoleLabelKey: "true"}.String()
 	nodes, err := core.V1().Node().List(metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
-		return err
+		return "", err
 	}
 	for _, node := range nodes.Items {
 		hash, ok := node.Annotations[secretsencrypt.EncryptionHashAnnotation]
@@ -592,28 +994,20 @@ func verifyEncryptionHashAnnotation(runtime *config.ControlRuntime, core core.In
 			first = false
 			firstNodeName = node.ObjectMeta.Name
 		} else if ok && hash != firstHash {
-			return fmt.Errorf("hash does not match between %s and %s", firstNodeName, node.ObjectMeta.Name)
+			return "", fmt.Errorf("hash does not match between %s and %s", firstNodeName, node.ObjectMeta.Name)
 		}
 	}
 
 	if prevStage == "" {
-		return nil
+		return "", nil
 	}
 
 	oldStage, oldHash, err := getEncryptionHashAnnotation(core)
 	if err != nil {
-		return err
-	}
-
-	encryptionConfigHash, err := secretsencrypt.GenEncryptionConfigHash(runtime)
-	if err != nil {
-		return err
+		return "", err
 	}
 	if !strings.Contains(prevStage, oldStage) {
-		return fmt.Errorf("incorrect stage: %s found on node %s", oldStage, nodes.Items[0].ObjectMeta.Name)
-	} else if oldHash != encryptionConfigHash {
-		return fmt.Errorf("invalid hash: %s found on node %s", oldHash, nodes.Items[0].ObjectMeta.Name)
+		return "", fmt.Errorf("incorrect stage: %s found on node %s", oldStage, nodes.Items[0].ObjectMeta.Name)
 	}
-
-	return nil
+	return oldHash, nil
 }
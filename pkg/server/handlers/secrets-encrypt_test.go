@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/secretsencrypt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_UnitRotateAndRemoveKeys(t *testing.T) {
+	newKeys := func() *secretsencrypt.EncryptionKeys {
+		return &secretsencrypt.EncryptionKeys{
+			AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey-1"}},
+			AESGCMKeys: []apiserverconfigv1.Key{{Name: "aesgcmkey-1"}, {Name: "aesgcmkey-2"}},
+			SBKeys:     []apiserverconfigv1.Key{{Name: "secretboxkey-1"}},
+			KMS:        []apiserverconfigv1.KMSConfiguration{{Name: "kms-1"}, {Name: "kms-2"}},
+			Identity:   true,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keyType string
+		apply   func(keys *secretsencrypt.EncryptionKeys, keyType string)
+		want    *secretsencrypt.EncryptionKeys
+	}{
+		{
+			name:    "Rotate AES-GCM keys",
+			keyType: secretsencrypt.AESGCMProvider,
+			apply:   rotateKeys,
+			want: &secretsencrypt.EncryptionKeys{
+				AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey-1"}},
+				AESGCMKeys: []apiserverconfigv1.Key{{Name: "aesgcmkey-2"}, {Name: "aesgcmkey-1"}},
+				SBKeys:     []apiserverconfigv1.Key{{Name: "secretboxkey-1"}},
+				KMS:        []apiserverconfigv1.KMSConfiguration{{Name: "kms-1"}, {Name: "kms-2"}},
+				Identity:   true,
+			},
+		},
+		{
+			name:    "Rotate KMS providers",
+			keyType: secretsencrypt.KMSProvider,
+			apply:   rotateKeys,
+			want: &secretsencrypt.EncryptionKeys{
+				AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey-1"}},
+				AESGCMKeys: []apiserverconfigv1.Key{{Name: "aesgcmkey-1"}, {Name: "aesgcmkey-2"}},
+				SBKeys:     []apiserverconfigv1.Key{{Name: "secretboxkey-1"}},
+				KMS:        []apiserverconfigv1.KMSConfiguration{{Name: "kms-2"}, {Name: "kms-1"}},
+				Identity:   true,
+			},
+		},
+		{
+			name:    "Remove last AES-GCM key",
+			keyType: secretsencrypt.AESGCMProvider,
+			apply:   removeLastKey,
+			want: &secretsencrypt.EncryptionKeys{
+				AESCBCKeys: []apiserverconfigv1.Key{{Name: "aescbckey-1"}},
+				AESGCMKeys: []apiserverconfigv1.Key{{Name: "aesgcmkey-1"}},
+				SBKeys:     []apiserverconfigv1.Key{{Name: "secretboxkey-1"}},
+				KMS:        []apiserverconfigv1.KMSConfiguration{{Name: "kms-1"}, {Name: "kms-2"}},
+				Identity:   true,
+			},
+		},
+		{
+			name:    "Remove keys other than secretbox",
+			keyType: secretsencrypt.SecretBoxProvider,
+			apply:   removeOtherKeys,
+			want: &secretsencrypt.EncryptionKeys{
+				AESCBCKeys: []apiserverconfigv1.Key{},
+				AESGCMKeys: []apiserverconfigv1.Key{},
+				SBKeys:     []apiserverconfigv1.Key{{Name: "secretboxkey-1"}},
+				KMS:        []apiserverconfigv1.KMSConfiguration{},
+				Identity:   true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newKeys()
+			tt.apply(keys, tt.keyType)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %+v, want %+v", keys, tt.want)
+			}
+		})
+	}
+}
+
+// preferredResourcesDiscovery returns a fixed list of preferred resources, which the fake discovery client does not support.
+type preferredResourcesDiscovery struct {
+	discovery.DiscoveryInterface
+	resources []*metav1.APIResourceList
+}
+
+func (d *preferredResourcesDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.resources, nil
+}
+
+func Test_UnitGetEncryptedResources(t *testing.T) {
+	client := &preferredResourcesDiscovery{DiscoveryInterface: fake.NewSimpleClientset().Discovery()}
+	client.resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "secrets", Namespaced: true, Verbs: metav1.Verbs{"list", "update"}},
+				{Name: "configmaps", Namespaced: true, Verbs: metav1.Verbs{"list", "update"}},
+				{Name: "pods/status", Namespaced: true, Verbs: metav1.Verbs{"get", "update"}},
+			},
+		},
+		{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "widgets", Namespaced: true, Verbs: metav1.Verbs{"list", "update"}},
+				{Name: "gadgets", Namespaced: false, Verbs: metav1.Verbs{"list", "update"}},
+				{Name: "reviews", Namespaced: false, Verbs: metav1.Verbs{"create"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		resources []string
+		want      []string
+	}{
+		{
+			name:      "Secrets",
+			resources: []string{"secrets"},
+			want:      []string{"/v1, Resource=secrets"},
+		},
+		{
+			name:      "Custom resource",
+			resources: []string{"secrets", "widgets.example.com", "missing.example.com"},
+			want:      []string{"/v1, Resource=secrets", "example.com/v1, Resource=widgets"},
+		},
+		{
+			name:      "Group wildcards",
+			resources: []string{"*.", "*.example.com"},
+			want:      []string{"/v1, Resource=secrets", "/v1, Resource=configmaps", "example.com/v1, Resource=widgets", "example.com/v1, Resource=gadgets"},
+		},
+		{
+			name:      "All resources",
+			resources: []string{"*.*"},
+			want:      []string{"/v1, Resource=secrets", "/v1, Resource=configmaps", "example.com/v1, Resource=widgets", "example.com/v1, Resource=gadgets"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gvrs, err := getEncryptedResources(client, tt.resources)
+			if err != nil {
+				t.Fatalf("getEncryptedResources() error = %v", err)
+			}
+			got := []string{}
+			for _, gvr := range gvrs {
+				got = append(got, gvr.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getEncryptedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnitGetEncryptionProvider(t *testing.T) {
+	aescbc := apiserverconfigv1.ProviderConfiguration{AESCBC: &apiserverconfigv1.AESConfiguration{}}
+	aesgcm := apiserverconfigv1.ProviderConfiguration{AESGCM: &apiserverconfigv1.AESConfiguration{}}
+	identity := apiserverconfigv1.ProviderConfiguration{Identity: &apiserverconfigv1.IdentityConfiguration{}}
+
+	tests := []struct {
+		name       string
+		configured string
+		providers  []apiserverconfigv1.ProviderConfiguration
+		want       string
+	}{
+		{
+			name:       "Configured provider is active",
+			configured: secretsencrypt.AESCBCProvider,
+			providers:  []apiserverconfigv1.ProviderConfiguration{aescbc, identity},
+			want:       secretsencrypt.AESCBCProvider,
+		},
+		{
+			name:       "Migrated by another server",
+			configured: secretsencrypt.AESCBCProvider,
+			providers:  []apiserverconfigv1.ProviderConfiguration{aesgcm, aescbc, identity},
+			want:       secretsencrypt.AESGCMProvider,
+		},
+		{
+			name:       "Encryption disabled",
+			configured: secretsencrypt.AESCBCProvider,
+			providers:  []apiserverconfigv1.ProviderConfiguration{identity, aesgcm},
+			want:       secretsencrypt.AESGCMProvider,
+		},
+		{
+			name:       "No keys",
+			configured: secretsencrypt.SecretBoxProvider,
+			providers:  []apiserverconfigv1.ProviderConfiguration{identity},
+			want:       secretsencrypt.SecretBoxProvider,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			control := &config.Control{CriticalControlArgs: config.CriticalControlArgs{EncryptProvider: tt.configured}}
+			if got := getEncryptionProvider(control, tt.providers); got != tt.want {
+				t.Errorf("getEncryptionProvider() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
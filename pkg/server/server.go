@@ -12,10 +12,12 @@ import (
 
 	helmchart "github.com/k3s-io/helm-controller/pkg/controllers/chart"
 	helmcommon "github.com/k3s-io/helm-controller/pkg/controllers/common"
+	"github.com/k3s-io/k3s/pkg/certmonitor"
 	"github.com/k3s-io/k3s/pkg/cli/cmds"
 	"github.com/k3s-io/k3s/pkg/clientaccess"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
 	"github.com/k3s-io/k3s/pkg/daemons/control"
+	"github.com/k3s-io/k3s/pkg/daemons/control/deps"
 	"github.com/k3s-io/k3s/pkg/daemons/executor"
 	"github.com/k3s-io/k3s/pkg/datadir"
 	"github.com/k3s-io/k3s/pkg/deploy"
@@ -23,6 +25,7 @@ import (
 	"github.com/k3s-io/k3s/pkg/nodepassword"
 	"github.com/k3s-io/k3s/pkg/rootlessports"
 	"github.com/k3s-io/k3s/pkg/secretsencrypt"
+	"github.com/k3s-io/k3s/pkg/server/audit"
 	"github.com/k3s-io/k3s/pkg/server/handlers"
 	"github.com/k3s-io/k3s/pkg/static"
 	"github.com/k3s-io/k3s/pkg/util"
@@ -60,7 +63,12 @@ func PrepareServer(ctx context.Context, config *Config, cfg *cmds.Server) error
 		return err
 	}
 
-	config.ControlConfig.Runtime.Handler = handlers.NewHandler(ctx, &config.ControlConfig, cfg)
+	auditLog, err := audit.NewLogger(ctx, &config.ControlConfig)
+	if err != nil {
+		return pkgerrors.WithMessage(err, "failed to open supervisor audit log")
+	}
+
+	config.ControlConfig.Runtime.Handler = audit.Handler(auditLog, handlers.NewHandler(ctx, &config.ControlConfig, cfg))
 
 	return nil
 }
@@ -128,12 +136,19 @@ func runControllers(ctx context.Context, config *Config) error {
 	controlConfig.Runtime.K3s = sc.K3s
 	controlConfig.Runtime.Event = sc.Event
 	controlConfig.Runtime.Core = sc.Core
+	// register the secret cache before controllers are started, so that bootstrap token join
+	// restrictions can be checked without querying the apiserver on every request.
+	sc.Core.Core().V1().Secret().Cache()
 	controlConfig.Runtime.Discovery = sc.Discovery
 
 	for name, cb := range controlConfig.Runtime.ClusterControllerStarts {
 		go runOrDie(ctx, name, cb)
 	}
 
+	certmonitor.StartRenewer(ctx, sc.Event, controlConfig.ServerNodeName, func() ([]string, error) {
+		return deps.RenewCerts(controlConfig)
+	})
+
 	for _, controller := range config.Controllers {
 		if err := controller(ctx, sc); err != nil {
 			return pkgerrors.WithMessagef(err, "failed to start %s controller", util.GetFunctionName(controller))
@@ -312,11 +327,19 @@ func stageFiles(ctx context.Context, sc *Context, controlConfig *config.Control)
 
 	apply := apply.New(k8s, apply.NewClientFactory(restConfig)).WithDynamicLookup()
 	k3s := sc.K3s.WithAgent(restConfig.UserAgent)
+	templateData := deploy.NewTemplateData(
+		controlConfig.ClusterIPRanges,
+		controlConfig.ServiceIPRanges,
+		controlConfig.ClusterDNSs,
+		controlConfig.ClusterDomain,
+		controlConfig.SystemDefaultRegistry)
 
 	return deploy.WatchFiles(ctx,
 		k8s,
 		apply,
 		k3s.V1().Addon(),
+		sc.Core.Core().V1().Node(),
+		templateData,
 		controlConfig.Disables,
 		dataDir)
 }
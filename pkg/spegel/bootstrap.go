@@ -132,7 +132,7 @@ func (c *agentBootstrapper) Get(ctx context.Context) ([]peer.AddrInfo, error) {
 		return nil, errors.New("client not ready")
 	}
 
-	addr, err := c.info.Get("/v1-"+version.Program+"/p2p", clientaccess.WithHeader("Accept", "application/json"))
+	addr, err := c.info.Get("/v1-"+version.Program+"/p2p", clientaccess.WithHeader("Accept", "application/json"), clientaccess.WithHeader(version.Program+"-Node-Name", os.Getenv("NODE_NAME")))
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/containerd/containerd/v2/core/remotes/docker"
 	"github.com/k3s-io/k3s/pkg/daemons/config"
+	"github.com/k3s-io/k3s/pkg/registryconfig"
 	"github.com/rancher/wharfie/pkg/registries"
 )
 
@@ -13,7 +14,7 @@ import (
 func (c *Config) InjectMirror(nodeConfig *config.Node) error {
 	mirrorAddr := net.JoinHostPort(c.InternalAddress, c.RegistryPort)
 	mirrorURL := "https://" + mirrorAddr + "/v2"
-	registry := nodeConfig.AgentConfig.Registry
+	registry := nodeConfig.AgentConfig.Registry.Load()
 
 	if registry.Configs == nil {
 		registry.Configs = map[string]registries.RegistryConfig{}
@@ -27,17 +28,17 @@ func (c *Config) InjectMirror(nodeConfig *config.Node) error {
 	}
 
 	if registry.Mirrors == nil {
-		registry.Mirrors = map[string]registries.Mirror{}
+		registry.Mirrors = map[string]registryconfig.Mirror{}
 	}
 	for host, mirror := range registry.Mirrors {
 		// Don't handle local registry entries
 		if !docker.IsLocalhost(host) {
-			mirror.Endpoints = append([]string{mirrorURL}, mirror.Endpoints...)
+			mirror.Endpoints = append(registryconfig.NewEndpoints(mirrorURL), mirror.Endpoints...)
 			registry.Mirrors[host] = mirror
 		}
 	}
-	registry.Mirrors[mirrorAddr] = registries.Mirror{
-		Endpoints: []string{mirrorURL},
+	registry.Mirrors[mirrorAddr] = registryconfig.Mirror{
+		Endpoints: registryconfig.NewEndpoints(mirrorURL),
 	}
 
 	return nil
@@ -114,7 +114,7 @@ func (c *Config) Start(ctx context.Context, nodeConfig *config.Node) error {
 	// configured endpoint, just having a key for the registry will do.
 	urls := []url.URL{}
 	registries := []string{}
-	for host := range nodeConfig.AgentConfig.Registry.Mirrors {
+	for host := range nodeConfig.AgentConfig.Registry.Load().Mirrors {
 		if host == localAddr {
 			continue
 		}
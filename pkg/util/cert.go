@@ -1,7 +1,11 @@
 package util
 
 import (
+	"bytes"
 	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	certutil "github.com/rancher/dynamiclistener/cert"
@@ -64,3 +68,89 @@ func GetCertStatus(cert *x509.Certificate, now time.Time, warn time.Time) string
 	}
 	return CertStatusOK
 }
+
+// SortCertChain returns a copy of the certificate bundle with duplicate certificates removed, and the
+// certificates ordered from the leaf-most certificate up through its issuers to the root. The leaf-most
+// certificate is the first certificate in the bundle that did not issue any other certificate in the bundle,
+// preferring certificates that are signed by another CA over self-signed certificates.
+// Any certificates that are not part of that chain are appended in their original order.
+func SortCertChain(certs []*x509.Certificate) []*x509.Certificate {
+	unique := []*x509.Certificate{}
+	for _, cert := range certs {
+		if !slices.ContainsFunc(unique, cert.Equal) {
+			unique = append(unique, cert)
+		}
+	}
+	if len(unique) == 0 {
+		return unique
+	}
+
+	// find the leaf-most certificate, preferring certificates that are not self-signed, and defaulting
+	// to the first cert if all certs issued another cert in the bundle
+	leaves := slices.DeleteFunc(slices.Clone(unique), func(cert *x509.Certificate) bool {
+		return slices.ContainsFunc(unique, func(c *x509.Certificate) bool { return c != cert && isIssuedBy(c, cert) })
+	})
+	leaf := unique[0]
+	if i := slices.IndexFunc(leaves, func(cert *x509.Certificate) bool { return !isSelfSigned(cert) }); i != -1 {
+		leaf = leaves[i]
+	} else if len(leaves) > 0 {
+		leaf = leaves[0]
+	}
+
+	sorted := []*x509.Certificate{leaf}
+	for current := leaf; !isSelfSigned(current); {
+		i := slices.IndexFunc(unique, func(c *x509.Certificate) bool { return !slices.Contains(sorted, c) && isIssuedBy(current, c) })
+		if i == -1 {
+			break
+		}
+		current = unique[i]
+		sorted = append(sorted, current)
+	}
+
+	for _, cert := range unique {
+		if !slices.Contains(sorted, cert) {
+			sorted = append(sorted, cert)
+		}
+	}
+	return sorted
+}
+
+// VerifyCAChain confirms that the first certificate in the bundle is a CA certificate, and that the
+// bundle contains a complete chain of valid CA certificates from that certificate to a self-signed root.
+func VerifyCAChain(certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("CA bundle does not contain any certificates")
+	}
+	if !certs[0].IsCA || certs[0].KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("first certificate in CA bundle is not a CA certificate")
+	}
+
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		if isSelfSigned(cert) {
+			roots.AddCert(cert)
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	if isSelfSigned(certs[0]) {
+		roots.AddCert(certs[0])
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	if err != nil {
+		return fmt.Errorf("CA bundle does not contain a complete chain to a root CA: %w", err)
+	}
+	return nil
+}
+
+// isSelfSigned returns true if the certificate is self-signed.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return isIssuedBy(cert, cert)
+}
+
+// isIssuedBy returns true if the certificate was signed by the issuer.
+func isIssuedBy(cert, issuer *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuer.RawSubject) && cert.CheckSignatureFrom(issuer) == nil
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestCert returns a certificate signed by the parent certificate, or a self-signed certificate if the parent is nil.
+func newTestCert(t *testing.T, commonName string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func Test_UnitCertChain(t *testing.T) {
+	root, rootKey := newTestCert(t, "root-ca", true, nil, nil)
+	intermediate, intermediateKey := newTestCert(t, "intermediate-ca", true, root, rootKey)
+	clusterCA, clusterCAKey := newTestCert(t, "k3s-client-ca", true, intermediate, intermediateKey)
+	leaf, _ := newTestCert(t, "system:admin", false, clusterCA, clusterCAKey)
+	otherRoot, _ := newTestCert(t, "other-root-ca", true, nil, nil)
+
+	tests := []struct {
+		name      string
+		certs     []*x509.Certificate
+		wantOrder []*x509.Certificate
+		wantErr   bool
+	}{
+		{
+			name:      "Ordered chain",
+			certs:     []*x509.Certificate{clusterCA, intermediate, root},
+			wantOrder: []*x509.Certificate{clusterCA, intermediate, root},
+		},
+		{
+			name:      "Unordered chain with duplicates",
+			certs:     []*x509.Certificate{root, intermediate, clusterCA, root},
+			wantOrder: []*x509.Certificate{clusterCA, intermediate, root},
+		},
+		{
+			name:      "Chain with unrelated root",
+			certs:     []*x509.Certificate{otherRoot, intermediate, clusterCA, root},
+			wantOrder: []*x509.Certificate{clusterCA, intermediate, root, otherRoot},
+		},
+		{
+			name:      "Self-signed CA",
+			certs:     []*x509.Certificate{root},
+			wantOrder: []*x509.Certificate{root},
+		},
+		{
+			name:      "Missing root",
+			certs:     []*x509.Certificate{clusterCA, intermediate},
+			wantOrder: []*x509.Certificate{clusterCA, intermediate},
+			wantErr:   true,
+		},
+		{
+			name:      "Missing intermediate",
+			certs:     []*x509.Certificate{clusterCA, root},
+			wantOrder: []*x509.Certificate{clusterCA, root},
+			wantErr:   true,
+		},
+		{
+			name:      "Leaf certificate",
+			certs:     []*x509.Certificate{leaf, clusterCA, intermediate, root},
+			wantOrder: []*x509.Certificate{leaf, clusterCA, intermediate, root},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SortCertChain(tt.certs)
+			if len(got) != len(tt.wantOrder) {
+				t.Fatalf("SortCertChain() returned %d certs, want %d", len(got), len(tt.wantOrder))
+			}
+			for i := range got {
+				if !got[i].Equal(tt.wantOrder[i]) {
+					t.Errorf("SortCertChain()[%d] = %s, want %s", i, got[i].Subject.CommonName, tt.wantOrder[i].Subject.CommonName)
+				}
+			}
+			if err := VerifyCAChain(got); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyCAChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
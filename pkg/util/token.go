@@ -56,6 +56,16 @@ func NormalizeToken(token string) (string, error) {
 }
 
 func GetTokenHash(config *config.Control) (string, error) {
+	normalizedToken, err := GetNormalizedToken(config)
+	if err != nil {
+		return "", err
+	}
+	return ShortHash(normalizedToken, 12), nil
+}
+
+// GetNormalizedToken returns the normalized server token, either from the
+// control config, or from the token file if not set in the config.
+func GetNormalizedToken(config *config.Control) (string, error) {
 	token := config.Token
 	if token == "" {
 		tokenFromFile, err := ReadTokenFromFile(config.Runtime.ServerToken, config.Runtime.ServerCA, config.DataDir)
@@ -64,11 +74,7 @@ func GetTokenHash(config *config.Control) (string, error) {
 		}
 		token = tokenFromFile
 	}
-	normalizedToken, err := NormalizeToken(token)
-	if err != nil {
-		return "", err
-	}
-	return ShortHash(normalizedToken, 12), nil
+	return NormalizeToken(token)
 }
 
 func ShortHash(s string, i int) string {